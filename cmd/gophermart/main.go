@@ -1,9 +1,16 @@
 package main
 
 import (
+	"os"
+
 	"github.com/dnsoftware/gophermart2/internal/gophermart/app"
 )
 
 func main() {
+	// gophermart config print [флаги] - действующие параметры без запуска сервиса
+	if len(os.Args) > 2 && os.Args[1] == "config" && os.Args[2] == "print" {
+		os.Exit(app.PrintConfig(os.Args[3:]))
+	}
+
 	app.Run()
 }
@@ -9,6 +9,7 @@ import (
 const (
 	RunAddress     string = "localhost:8081" // адрес:порт сервера по умолчанию
 	AccrualAddress string = "localhost:8080" // адрес:порт сервера по умолчанию
+	MetricsAddress string = "localhost:9091" // адрес:порт отдачи метрик по умолчанию, доступен только изнутри
 )
 
 // интервалы
@@ -17,10 +18,37 @@ const (
 	HTTPAttemtPeriods string        = "1s,2s,5s"
 )
 
+// конфигурация
+const (
+	ConfigFileEnv       string = "CONFIG_FILE" // переменная окружения с путем к файлу конфигурации
+	SecretFileEnvSuffix string = "_FILE"       // <ENV>_FILE - путь к файлу со значением секрета
+)
+
+// функции, которые можно отключить в конфигурации без перезапуска
+const (
+	FeatureBatchUpload  string = "batch_upload"  // пакетная загрузка заказов
+	FeatureEventsStream string = "events_stream" // поток событий Server-Sent Events
+	FeatureWebSocket    string = "websocket"     // события через WebSocket
+	FeaturePartnerAPI   string = "partner_api"   // API партнерских систем
+)
+
 // логгер
 const (
-	LogFile  string = "log.log"
-	LogLevel        = zapcore.InfoLevel
+	LogFile    string = "log.log"
+	LogLevel          = zapcore.InfoLevel
+	LogOutputs string = "stdout," + LogFile // выводы журнала по умолчанию через запятую
+
+	LogFormatAuto    string = "auto" // JSON в файлы, текст в консоль
+	LogFormatJSON    string = "json"
+	LogFormatConsole string = "console"
+	LogOutputStdout  string = "stdout"
+	LogOutputStderr  string = "stderr"
+
+	LogMaxSize          = 100 // размер файла журнала в мегабайтах, после которого он ротируется
+	LogMaxAge           = 30  // срок хранения старых файлов журнала в днях
+	LogMaxBackups       = 10  // кол-во хранимых старых файлов журнала
+	LogSampleInitial    = 100 // из записей о запросах за секунду пишутся первые LogSampleInitial
+	LogSampleThereafter = 100 // и далее каждая LogSampleThereafter-я
 )
 
 const (
@@ -35,26 +63,145 @@ const (
 
 // routes
 const (
-	UserRegisterRoute    string = "/api/user/register"
-	UserLoginRoute       string = "/api/user/login"
-	UserOrderUploadRoute string = "/api/user/orders"
-	UserOrdersListRoute  string = "/api/user/orders"
-	UserBalanceRoute     string = "/api/user/balance"
-	UserWithdrawalsRoute string = "/api/user/withdrawals"
-	UserWithdrawRoute    string = "/api/user/balance/withdraw"
+	UserRegisterRoute     string = "/api/user/register"
+	UserLoginRoute        string = "/api/user/login"
+	UserOrderUploadRoute  string = "/api/user/orders"
+	UserOrdersListRoute   string = "/api/user/orders"
+	UserOrderRoute        string = "/api/user/orders/{number}"
+	UserOrdersBatchRoute  string = "/api/user/orders/batch"
+	UserOrderDisputeRoute string = "/api/user/orders/{number}/dispute"
+	UserDisputesRoute     string = "/api/user/disputes"
+	UserBalanceRoute      string = "/api/user/balance"
+	UserWithdrawalsRoute  string = "/api/user/withdrawals"
+	UserWithdrawRoute     string = "/api/user/balance/withdraw"
+	UserLedgerRoute       string = "/api/user/balance/history"
+	UserEventsRoute       string = "/api/user/events"
+	UserWebSocketRoute    string = "/api/user/ws"
+
+	UserPasswordRoute             string = "/api/user/password"
+	UserPasswordResetRoute        string = "/api/user/password/reset"
+	UserPasswordResetConfirmRoute string = "/api/user/password/reset/confirm"
+	UserProfileRoute              string = "/api/user/me"
+	UserAccountRoute              string = "/api/user"
+	UserExportRoute               string = "/api/user/export"
+	UserDeletionCancelRoute       string = "/api/user/delete/cancel"
+
+	AdminRoute string = "/api/admin"
+
+	// внутри AdminRoute
+	AdminUsersRoute        string = "/users"
+	AdminUserRoleRoute     string = "/users/{id}/role"
+	AdminUserOrdersRoute   string = "/users/{id}/orders"
+	AdminUserLedgerRoute   string = "/users/{id}/ledger"
+	AdminUserBlockRoute    string = "/users/{id}/block"
+	AdminUserUnblockRoute  string = "/users/{id}/unblock"
+	AdminOrderRecheckRoute string = "/orders/{number}/recheck"
+	AdminOrderStatusRoute  string = "/orders/{number}/status"
+	AdminAuditRoute        string = "/audit"
+	AdminAdjustmentsRoute  string = "/adjustments"
+	AdminAdjustmentApprove string = "/adjustments/{id}/approve"
+	AdminAdjustmentReject  string = "/adjustments/{id}/reject"
+	AdminAPIKeysRoute      string = "/apikeys"
+	AdminAPIKeyRoute       string = "/apikeys/{id}"
+	AdminAPIKeyRotateRoute string = "/apikeys/{id}/rotate"
+	AdminAPIKeySigningKey  string = "/apikeys/{id}/signing-key"
+	AdminDisputesRoute     string = "/disputes"
+	AdminDisputeApprove    string = "/disputes/{id}/approve"
+	AdminDisputeReject     string = "/disputes/{id}/reject"
+	AdminLogLevelRoute     string = "/log-level"
+	AdminConfigRoute       string = "/config"
+	AdminConfigReloadRoute string = "/config/reload"
+
+	PartnerRoute string = "/api/partner"
+
+	// внутри PartnerRoute
+	PartnerOrdersRoute     string = "/orders"
+	PartnerWebhooksRoute   string = "/webhooks"
+	PartnerWebhookRoute    string = "/webhooks/{id}"
+	PartnerWebhookDelivery string = "/webhooks/{id}/deliveries"
+
+	MetricsRoute     string = "/metrics"
+	HealthRoute      string = "/healthz"
+	ReadyRoute       string = "/readyz"
+	DebugStatusRoute string = "/debug/status" // только для администраторов
 )
 
 // разное
 const (
 	ApplicationJSON string = "application/json"
+	TextEventStream string = "text/event-stream"
+
+	TokenExp           = time.Hour * 24
+	SecretKey          = "golangforever"
+	MinSecretKeyLength = 8 // минимальная длина ключа подписи токенов в конфигурации
+
+	MinLoginLength     = 3
+	MinPasswordLength  = 8
+	DeletedLoginPrefix = "deleted-" // логины обезличенных аккаунтов, при регистрации недоступен
 
-	TokenExp  = time.Hour * 24
-	SecretKey = "golangforever"
+	AdminSearchLimit = 50  // максимальное кол-во пользователей в результатах поиска
+	AdminAuditLimit  = 100 // максимальное кол-во записей журнала аудита в ответе
 
-	MinLoginLength    = 3
-	MinPasswordLength = 8
+	OrdersPageDefaultLimit = 20  // размер страницы списка заказов, если он не указан
+	OrdersPageMaxLimit     = 100 // максимальный размер страницы списка заказов
+	OrdersBatchMaxSize     = 100 // максимальное кол-во номеров в пакетной загрузке
+
+	MaxDisputeCommentLength = 1000 // максимальная длина комментария к спору о заказе
+
+	MaxDisplayNameLength = 128
+	MaxEmailLength       = 255
+
+	AccountDeletionGracePeriod = time.Hour * 24 * 30 // срок, в течение которого удаление аккаунта можно отменить
+	AccountDeletionCheckPeriod = time.Hour           // период проверки аккаунтов на обезличивание
 
 	HeaderAuthorization = "Authorization"
+	HeaderAPIKey        = "X-API-Key"
+	HeaderRequestID     = "X-Request-ID"
+	HeaderTotalCount    = "X-Total-Count"
+	HeaderNextCursor    = "X-Next-Cursor"
+	HeaderLastEventID   = "Last-Event-ID"
+	HeaderWebhookEvent  = "X-Webhook-Event"
+	HeaderWebhookID     = "X-Webhook-ID" // ID события, по нему получатель отбрасывает повторы
+
+	APIKeyPrefix           = "gm"  // префикс ключей API, по нему ключ легко опознать
+	APIKeyIDLength         = 4     // длина публичного идентификатора ключа в байтах
+	APIKeySecretLength     = 32    // длина секретной части ключа в байтах
+	APIKeyDefaultRateLimit = 60    // запросов в минуту по умолчанию
+	APIKeyMaxRateLimit     = 10000 // максимально допустимый лимит запросов в минуту
+	MinSigningKeyLength    = 16    // минимальная длина ключа подписи запросов
+
+	PasswordResetTokenExp    = time.Hour // время жизни токена сброса пароля
+	PasswordResetTokenLength = 32        // длина токена сброса пароля в байтах
+
+	EventsSubscriberBuffer = 64               // емкость очереди событий одного подписчика
+	EventsReplayLimit      = 500              // кол-во событий из журнала, читаемых за один запрос
+	EventsHeartbeatPeriod  = 15 * time.Second // период отправки пустых сообщений, чтобы соединение не закрывали прокси
+
+	EventBusPollPeriod  = time.Second         // период проверки очереди доменных событий
+	EventBusBatchSize   = 100                 // кол-во событий, передаваемых получателю за один проход
+	EventBusRetention   = time.Hour * 24 * 30 // срок хранения доменных событий
+	EventBusPurgePeriod = time.Hour           // период очистки очереди доменных событий
+	EventLogFile        = "events.log"        // файл журнала доменных событий по умолчанию
+	NatsSubjectPrefix   = "gophermart"        // префикс темы брокера, полная тема - <префикс>.<тип события>
+	NatsTimeout         = 5 * time.Second     // время ожидания ответа брокера
+
+	WebhookTimeout          = 10 * time.Second // время ожидания ответа получателя
+	WebhookDeliveryPeriod   = 5 * time.Second  // период разбора очереди доставки
+	WebhookBatchSize        = 50               // кол-во событий и доставок, обрабатываемых за один проход
+	WebhookClaimLease       = 10 * time.Minute // на это время взятая доставка скрыта от повторного взятия
+	WebhookMaxAttempts      = 10               // после стольких неудачных попыток доставка прекращается
+	WebhookRetryBase        = 30 * time.Second // задержка перед первой повторной попыткой, далее удваивается
+	WebhookRetryMax         = 6 * time.Hour    // максимальная задержка между попытками
+	WebhookMaxSubscriptions = 10               // максимальное кол-во подписок одного клиента
+	WebhookDeliveryLogLimit = 100              // кол-во записей журнала доставки в ответе
+	MaxWebhookURLLength     = 2048
+
+	WSWriteWait      = 10 * time.Second // время на отправку одного сообщения клиенту
+	WSPongWait       = 60 * time.Second // время ожидания pong, после которого соединение считается потерянным
+	WSPingPeriod     = 54 * time.Second // период отправки ping, должен быть меньше WSPongWait
+	WSAuthTimeout    = 10 * time.Second // время на передачу токена первым сообщением
+	WSMaxMessageSize = 4096             // максимальный размер сообщения от клиента
+	WSCommandBuffer  = 16               // емкость очереди команд клиента
 
 	AccrualServiceQueryLimit = 100                    // максимально кол-во запросов к Accrual сервису в минуту
 	AccrualCheckPeriod       = 5                      // период проверки
@@ -62,6 +209,55 @@ const (
 
 	AccrualTickerPeriod   = 3  // период тикера в секундах
 	OrdersChannelCapacity = 10 // емкость канала для обмена данными по ордерам
+	OrderSaveWorkers      = 1  // кол-во обработчиков проверенных заказов по умолчанию
+	MaxOrderSaveWorkers   = 32 // максимальное кол-во обработчиков проверенных заказов
+
+	TracingServiceName = "gophermart" // имя сервиса в трассировке
+
+	CORSAllowMethods  = "GET, POST, PUT, PATCH, DELETE"
+	CORSAllowHeaders  = "Authorization, Content-Type, Content-Encoding, Accept-Encoding, X-Request-ID, Last-Event-ID"
+	CORSExposeHeaders = "Authorization, X-Request-ID, X-Total-Count, X-Next-Cursor"
+	CORSMaxAge        = "600" // время в секундах, на которое браузер запоминает ответ на предварительный запрос
+
+	MaxRequestIDLength = 128      // более длинный X-Request-ID клиента заменяется своим
+	LogBodyLimit       = 4096     // тело запроса в журнале обрезается до этого размера
+	LogRedacted        = "******" // значение, которым в журнале заменяются секреты
+
+	SchemaVersion       = 1               // версия структуры БД, увеличивается при каждом изменении createDatabaseTables
+	HealthCheckTimeout  = 2 * time.Second // время на проверку готовности
+	ShutdownDrainPeriod = 3 * time.Second // после сигнала завершения сервер еще отвечает, но уже не готов, чтобы балансировщик успел его исключить
+	ShutdownTimeout     = 5 * time.Second // время на завершение обработки текущих запросов
+
+	AccrualBreakerThreshold = 5                // после стольких ошибок подряд запросы к Accrual приостанавливаются
+	AccrualBreakerCooldown  = 30 * time.Second // пауза перед пробным запросом
+	AccrualBreakerMaxOpen   = 5 * time.Minute  // если Accrual недоступен дольше, сервис считается неготовым
+
+	MetricsNamespace = "gophermart" // префикс имен метрик
+	MetricsNoRoute   = "unmatched"  // метка маршрута для запросов, не попавших ни в один маршрут
+	QueueUnchecked   = "unchecked"  // очередь заказов на проверку в Accrual
+	QueueChecked     = "checked"    // очередь проверенных заказов на сохранение
+)
+
+// состояния ограничителя запросов к Accrual
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// фоновые службы
+const (
+	WorkerAccrualChecker  = "accrual_checker"
+	WorkerAccountDeletion = "account_deletion"
+	WorkerWebhookDelivery = "webhook_delivery"
+	WorkerEventDispatcher = "event_dispatcher"
+)
+
+// роли пользователей
+const (
+	RoleUser    = "user"
+	RoleSupport = "support"
+	RoleAdmin   = "admin"
 )
 
 // статусы заказов
@@ -70,6 +266,109 @@ const (
 	OrderProcessing = "PROCESSING"
 	OrderInvalid    = "INVALID"
 	OrderProcessed  = "PROCESSED"
+
+	OrderCancelled = "CANCELLED" // заказ удален пользователем, встречается только в событиях
+)
+
+// источники смены статуса заказа в истории статусов
+const (
+	StatusSourceUser    = "user"    // загрузка заказа пользователем
+	StatusSourceAccrual = "accrual" // ответ системы расчета начислений
+	StatusSourceAdmin   = "admin"   // ручная смена статуса администратором
+)
+
+// результаты загрузки номера в пакете
+const (
+	BatchResultAccepted     = "accepted"       // новый номер принят в обработку
+	BatchResultAlreadyYours = "already_yours"  // номер уже загружен этим пользователем
+	BatchResultOwnedByOther = "owned_by_other" // номер загружен другим пользователем
+	BatchResultInvalid      = "invalid_format" // неверный формат номера
+)
+
+// сортировка списка заказов
+const (
+	OrdersSortNewest = "newest" // сначала новые (по умолчанию)
+	OrdersSortOldest = "oldest" // сначала старые
+)
+
+// права ключей API
+const (
+	ScopeOrdersWrite = "orders:write" // загрузка заказов от имени пользователей
+	ScopeWebhooks    = "webhooks"     // подписка на события через webhook
+)
+
+// доменные события
+const (
+	EventUserRegistered     = "user.registered"
+	EventOrderUploaded      = "order.uploaded"
+	EventOrderStatusChanged = "order.status_changed"
+	EventOrderCancelled     = "order.cancelled"
+	EventPointsAccrued      = "points.accrued"
+	EventPointsWithdrawn    = "points.withdrawn"
+	EventPointsAdjusted     = "points.adjusted" // подтверждена ручная корректировка баланса
+)
+
+// события, на которые можно подписать webhook
+const (
+	WebhookOrderProcessed  = "order.processed"  // начислены баллы за заказ
+	WebhookOrderInvalid    = "order.invalid"    // заказ не принят системой расчета
+	WebhookPointsWithdrawn = "points.withdrawn" // пользователь списал баллы
+)
+
+// статусы доставки webhook
+const (
+	DeliveryPending   = "PENDING"
+	DeliveryDelivered = "DELIVERED"
+	DeliveryFailed    = "FAILED" // попытки исчерпаны
+)
+
+// операции по балансу
+const (
+	OperationAccrual    = "accrual"
+	OperationWithdrawal = "withdrawal"
+	OperationAdjustment = "adjustment"
+)
+
+// статусы ручных корректировок баланса
+const (
+	AdjustmentPending  = "PENDING"
+	AdjustmentApproved = "APPROVED"
+	AdjustmentRejected = "REJECTED"
+)
+
+// типы событий пользователя
+const (
+	EventTypeOrder   = "order"   // смена статуса заказа
+	EventTypeBalance = "balance" // изменение баланса
+)
+
+// команды клиента WebSocket
+const (
+	WSActionAuth        = "auth"
+	WSActionSubscribe   = "subscribe"
+	WSActionUnsubscribe = "unsubscribe"
+)
+
+// типы сообщений сервера WebSocket
+const (
+	WSMessageEvent        = "event"
+	WSMessageSubscribed   = "subscribed"
+	WSMessageUnsubscribed = "unsubscribed"
+	WSMessageError        = "error"
+)
+
+// статусы споров о принадлежности заказа
+const (
+	DisputePending  = "PENDING"
+	DisputeApproved = "APPROVED" // заказ передан заявителю
+	DisputeRejected = "REJECTED"
+)
+
+// коды причин ручных корректировок баланса
+const (
+	ReasonGoodwill        = "goodwill"         // компенсация клиенту
+	ReasonAccrualReversal = "accrual_reversal" // отмена ошибочного начисления
+	ReasonCorrection      = "correction"       // прочие исправления
 )
 
 // статусы расчетов
@@ -84,6 +383,8 @@ type key int
 
 const (
 	UserIDKey key = iota
+	UserRoleKey
+	APIClientKey
 )
 
 const (
@@ -92,4 +393,8 @@ const (
 	StatusUnauthorized        = "пользователь не авторизован"
 	StatusBadRequestFormat    = "неверный формат запроса"
 	StatusBadNumberFormat     = "неверный формат номера заказа"
+	StatusForbidden           = "недостаточно прав"
+	StatusNotFound            = "не найдено"
+	StatusUserBlocked         = "пользователь заблокирован"
+	StatusFeatureDisabled     = "функция отключена"
 )
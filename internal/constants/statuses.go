@@ -9,6 +9,7 @@ const (
 	LoginOk
 	LoginBadFormat
 	LoginBadPair
+	LoginBlocked
 	LoginInternalError
 
 	OrderOk
@@ -44,6 +45,97 @@ const (
 	AccrualNumberExceeded
 	AccrualInternalError
 
+	PasswordChangeOk
+	PasswordChangeBadFormat
+	PasswordChangeBadPair
+	PasswordChangeInternalError
+
+	PasswordResetAccepted
+	PasswordResetOk
+	PasswordResetBadFormat
+	PasswordResetBadToken
+	PasswordResetInternalError
+
+	ProfileOk
+	ProfileBadFormat
+	ProfileNotFound
+	ProfileInternalError
+
+	ExportOk
+	ExportInternalError
+
+	DeletionScheduled
+	DeletionCancelled
+	DeletionNotScheduled
+	DeletionInternalError
+
+	RoleSetOk
+	RoleSetBadFormat
+	RoleSetNotFound
+	RoleSetInternalError
+
+	AdminOk
+	AdminBadFormat
+	AdminNotFound
+	AdminTransitionRejected
+	AdminInternalError
+
+	AdjustmentCreated
+	AdjustmentOk
+	AdjustmentBadFormat
+	AdjustmentNotFound
+	AdjustmentSelfApproval
+	AdjustmentNotPending
+	AdjustmentNotEnoughFunds
+	AdjustmentInternalError
+
+	APIKeyCreated
+	APIKeyOk
+	APIKeyBadFormat
+	APIKeyNotFound
+	APIKeyInvalid
+	APIKeyForbidden
+	APIKeyRateLimited
+	APIKeyInternalError
+
+	PartnerUserNotFound
+
+	OrdersListBadFormat
+
+	OrderDetailOk
+	OrderDetailBadFormat
+	OrderDetailNotFound
+	OrderDetailInternalError
+
+	OrdersBatchOk
+	OrdersBatchBadFormat
+	OrdersBatchTooLarge
+	OrdersBatchInternalError
+
+	OrderCancelOk
+	OrderCancelBadFormat
+	OrderCancelNotFound
+	OrderCancelNotAllowed
+	OrderCancelInternalError
+
+	DisputeCreated
+	DisputeOk
+	DisputeBadFormat
+	DisputeNotFound
+	DisputeNoConflict
+	DisputeAlreadyOpen
+	DisputeNotPending
+	DisputeOwnerChanged
+	DisputeOrderProcessed
+	DisputeInternalError
+
+	WebhookCreated
+	WebhookOk
+	WebhookBadFormat
+	WebhookNotFound
+	WebhookLimitExceeded
+	WebhookInternalError
+
 	Unknown
 )
 
@@ -65,6 +157,8 @@ func StatusData(code int) (int, string) {
 		return 400, StatusBadRequestFormat
 	case LoginBadPair:
 		return 401, "неверная пара логин/пароль"
+	case LoginBlocked:
+		return 403, StatusUserBlocked
 	case LoginInternalError:
 		return 500, StatusInternalServerError
 
@@ -128,6 +222,172 @@ func StatusData(code int) (int, string) {
 	case AccrualInternalError:
 		return 500, StatusInternalServerError
 
+	case PasswordChangeOk:
+		return 200, "пароль успешно изменен"
+	case PasswordChangeBadFormat:
+		return 400, StatusBadRequestFormat
+	case PasswordChangeBadPair:
+		return 401, "неверный текущий пароль"
+	case PasswordChangeInternalError:
+		return 500, StatusInternalServerError
+
+	case PasswordResetAccepted:
+		return 202, "запрос на сброс пароля принят"
+	case PasswordResetOk:
+		return 200, "пароль успешно сброшен"
+	case PasswordResetBadFormat:
+		return 400, StatusBadRequestFormat
+	case PasswordResetBadToken:
+		return 422, "токен сброса пароля недействителен или просрочен"
+	case PasswordResetInternalError:
+		return 500, StatusInternalServerError
+
+	case ProfileOk:
+		return 200, StatusSuccessfulRequest
+	case ProfileBadFormat:
+		return 400, StatusBadRequestFormat
+	case ProfileNotFound:
+		return 404, "пользователь не найден"
+	case ProfileInternalError:
+		return 500, StatusInternalServerError
+
+	case ExportOk:
+		return 200, StatusSuccessfulRequest
+	case ExportInternalError:
+		return 500, StatusInternalServerError
+
+	case DeletionScheduled:
+		return 202, "удаление аккаунта запланировано"
+	case DeletionCancelled:
+		return 200, "удаление аккаунта отменено"
+	case DeletionNotScheduled:
+		return 409, "удаление аккаунта не запланировано"
+	case DeletionInternalError:
+		return 500, StatusInternalServerError
+
+	case RoleSetOk:
+		return 200, "роль пользователя изменена"
+	case RoleSetBadFormat:
+		return 400, StatusBadRequestFormat
+	case RoleSetNotFound:
+		return 404, "пользователь не найден"
+	case RoleSetInternalError:
+		return 500, StatusInternalServerError
+
+	case AdminOk:
+		return 200, StatusSuccessfulRequest
+	case AdminBadFormat:
+		return 400, StatusBadRequestFormat
+	case AdminNotFound:
+		return 404, StatusNotFound
+	case AdminTransitionRejected:
+		return 409, "текущий статус заказа не допускает такой смены"
+	case AdminInternalError:
+		return 500, StatusInternalServerError
+
+	case AdjustmentCreated:
+		return 201, "корректировка создана и ожидает подтверждения"
+	case AdjustmentOk:
+		return 200, StatusSuccessfulRequest
+	case AdjustmentBadFormat:
+		return 400, StatusBadRequestFormat
+	case AdjustmentNotFound:
+		return 404, "корректировка не найдена"
+	case AdjustmentSelfApproval:
+		return 403, "корректировку должен подтвердить другой администратор"
+	case AdjustmentNotPending:
+		return 409, "корректировка уже обработана"
+	case AdjustmentNotEnoughFunds:
+		return 409, "после корректировки баланс станет отрицательным"
+	case AdjustmentInternalError:
+		return 500, StatusInternalServerError
+
+	case APIKeyCreated:
+		return 201, "ключ API создан"
+	case APIKeyOk:
+		return 200, StatusSuccessfulRequest
+	case APIKeyBadFormat:
+		return 400, StatusBadRequestFormat
+	case APIKeyNotFound:
+		return 404, "ключ API не найден"
+	case APIKeyInvalid:
+		return 401, "неверный или отозванный ключ API"
+	case APIKeyForbidden:
+		return 403, StatusForbidden
+	case APIKeyRateLimited:
+		return 429, "превышено количество запросов для ключа API"
+	case APIKeyInternalError:
+		return 500, StatusInternalServerError
+
+	case PartnerUserNotFound:
+		return 404, "пользователь не найден"
+
+	case OrdersListBadFormat:
+		return 400, StatusBadRequestFormat
+
+	case OrderDetailOk:
+		return 200, StatusSuccessfulRequest
+	case OrderDetailBadFormat:
+		return 400, StatusBadNumberFormat
+	case OrderDetailNotFound:
+		return 404, "заказ не найден"
+	case OrderDetailInternalError:
+		return 500, StatusInternalServerError
+
+	case OrdersBatchOk:
+		return 207, "результат загрузки по каждому номеру"
+	case OrdersBatchBadFormat:
+		return 400, StatusBadRequestFormat
+	case OrdersBatchTooLarge:
+		return 413, "слишком много номеров заказов в одном запросе"
+	case OrdersBatchInternalError:
+		return 500, StatusInternalServerError
+
+	case OrderCancelOk:
+		return 200, "заказ удален"
+	case OrderCancelBadFormat:
+		return 400, StatusBadNumberFormat
+	case OrderCancelNotFound:
+		return 404, "заказ не найден"
+	case OrderCancelNotAllowed:
+		return 409, "заказ уже принят в обработку, удаление невозможно"
+	case OrderCancelInternalError:
+		return 500, StatusInternalServerError
+
+	case DisputeCreated:
+		return 201, "спор создан и будет рассмотрен поддержкой"
+	case DisputeOk:
+		return 200, StatusSuccessfulRequest
+	case DisputeBadFormat:
+		return 400, StatusBadRequestFormat
+	case DisputeNotFound:
+		return 404, "спор не найден"
+	case DisputeNoConflict:
+		return 422, "заказ не загружен другим пользователем, оспаривать нечего"
+	case DisputeAlreadyOpen:
+		return 409, "спор по этому заказу уже открыт"
+	case DisputeNotPending:
+		return 409, "спор уже рассмотрен"
+	case DisputeOwnerChanged:
+		return 409, "владелец заказа изменился после открытия спора"
+	case DisputeOrderProcessed:
+		return 409, "баллы за заказ уже начислены владельцу, требуется корректировка баланса"
+	case DisputeInternalError:
+		return 500, StatusInternalServerError
+
+	case WebhookCreated:
+		return 201, "подписка создана"
+	case WebhookOk:
+		return 200, StatusSuccessfulRequest
+	case WebhookBadFormat:
+		return 400, StatusBadRequestFormat
+	case WebhookNotFound:
+		return 404, "подписка не найдена"
+	case WebhookLimitExceeded:
+		return 409, "превышено количество подписок"
+	case WebhookInternalError:
+		return 500, StatusInternalServerError
+
 	case Unknown:
 		return 1000, "unknown 1000"
 
@@ -3,11 +3,15 @@ package app
 import (
 	"context"
 	"fmt"
+	"github.com/dnsoftware/gophermart2/internal/constants"
 	"github.com/dnsoftware/gophermart2/internal/gophermart/config"
 	"github.com/dnsoftware/gophermart2/internal/gophermart/domain"
 	"github.com/dnsoftware/gophermart2/internal/gophermart/handlers"
 	"github.com/dnsoftware/gophermart2/internal/logger"
+	"github.com/dnsoftware/gophermart2/internal/metrics"
+	"github.com/dnsoftware/gophermart2/internal/notifier"
 	"github.com/dnsoftware/gophermart2/internal/storage"
+	"github.com/dnsoftware/gophermart2/internal/tracing"
 	"net/http"
 	"os"
 	"os/signal"
@@ -18,13 +22,50 @@ import (
 
 func Run() {
 	var wg sync.WaitGroup
-	gLogger := logger.Log()
-	defer gLogger.Sync()
 
 	cfg := config.NewServerConfig()
 
+	// журнал настраивается до первой записи
+	err := logger.Init(logger.Options{
+		Level:            cfg.Log.Level,
+		Format:           cfg.Log.Format,
+		Outputs:          cfg.Log.Outputs,
+		MaxSize:          cfg.Log.MaxSize,
+		MaxAge:           cfg.Log.MaxAge,
+		MaxBackups:       cfg.Log.MaxBackups,
+		Compress:         cfg.Log.Compress,
+		RotateInterval:   cfg.Log.RotateInterval,
+		SampleInitial:    cfg.Log.SampleInitial,
+		SampleThereafter: cfg.Log.SampleThereafter,
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer logger.Log().Close()
+
+	domain.SetAuthSettings(domain.AuthSettings{
+		SecretKey:        cfg.Auth.SecretKey,
+		PasswordSalt:     cfg.Auth.PasswordSalt,
+		TokenTTL:         cfg.Auth.TokenTTL,
+		PasswordResetTTL: cfg.Auth.PasswordResetTTL,
+	})
+
+	// трассировка
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.OTLPEndpoint, cfg.TraceFile)
+	if err != nil {
+		panic(err)
+	}
+	defer func() {
+		ctxTracing, cancelTracing := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelTracing()
+
+		if err := shutdownTracing(ctxTracing); err != nil {
+			logger.Log().Error("Ошибка при завершении трассировки: " + err.Error())
+		}
+	}()
+
 	// репозитории
-	martStorage, err := storage.NewMartStorage(cfg.DatabaseURI)
+	martStorage, err := storage.NewMartStorage(cfg.DatabaseURI, cfg.Timeouts.DBConnect)
 	if err != nil {
 		panic(err)
 	}
@@ -33,14 +74,34 @@ func Run() {
 	orderRepo := storage.NewOrderRepo(martStorage)
 	balanceRepo := storage.NewBalanceRepo(martStorage)
 	accrualRepo := storage.NewAccrualRepo(cfg.AccrualAddress)
+	adminRepo := storage.NewAdminRepo(martStorage)
+	apiKeyRepo := storage.NewAPIKeyRepo(martStorage)
+	webhookRepo := storage.NewWebhookRepo(martStorage, cfg.Timeouts.Webhook)
+	outboxRepo := storage.NewOutboxRepo(martStorage)
 
 	// канал с ордерами на проверку
-	chanUnchecked := domain.NewOrdersUnchecked()
+	chanUnchecked := domain.NewOrdersUnchecked(cfg.Accrual.QueueCapacity)
 	// канал с проверенными ордерами
-	chanChecked := domain.NewOrdersChecked()
+	chanChecked := domain.NewOrdersChecked(cfg.Accrual.QueueCapacity)
+
+	// метрики, которые снимаются в момент запроса
+	metrics.RegisterDB(martStorage.DB())
+	metrics.RegisterQueue(constants.QueueUnchecked, chanUnchecked.Len)
+	metrics.RegisterQueue(constants.QueueChecked, chanChecked.Len)
+	metrics.RegisterLedger(balanceRepo.LedgerTotals)
 
 	// основные объекты
-	user := domain.NewUserModel(userRepo)
+	user := domain.NewUserModel(userRepo, notifier.NewLogNotifier())
+
+	// первоначальный администратор
+	if cfg.AdminLogin != "" {
+		ctxAdmin, cancelAdmin := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+		err = user.PromoteAdmin(ctxAdmin, cfg.AdminLogin)
+		cancelAdmin()
+		if err != nil {
+			logger.Log().Error(err.Error())
+		}
+	}
 
 	// берет из chanBalance и сохраняет в базу
 	balance := domain.NewBalanceModel(balanceRepo)
@@ -48,23 +109,87 @@ func Run() {
 	// готовит ордера на проверку chanUnchecked<-, берет данные проверенных ордеров и сохраняет статус в базу ордеров <-chanChecked
 	// а также в базу балансов
 	order := domain.NewOrderModel(orderRepo, chanUnchecked, chanChecked, balance)
+	metrics.RegisterRejectedTransitions(order.RejectedTransitions)
+
+	// выгрузка данных и удаление аккаунтов
+	account := domain.NewAccountModel(userRepo, user, order, balance)
+
+	// служебные операции поддержки и администраторов
+	admin := domain.NewAdminModel(adminRepo, balanceRepo, apiKeyRepo, user, order, balance)
+
+	// операции партнерских систем по ключам API
+	partner := domain.NewPartnerModel(apiKeyRepo, user, order)
+
+	// подписки партнеров на события и доставка им событий
+	webhooks := domain.NewWebhooksModel(webhookRepo)
+
+	// рассылка событий пользователей подключенным клиентам (SSE, WebSocket)
+	events := domain.NewEventsModel(outboxRepo)
+
+	// рассылка доменных событий: клиенты пользователей, журнал, вебхуки и, если задан, брокер
+	eventLog, err := os.OpenFile(cfg.EventLogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		panic(err)
+	}
+	defer eventLog.Close()
+
+	sinks := []domain.EventSink{events, domain.NewLogSink(eventLog), webhooks}
+	if cfg.NatsAddress != "" {
+		nats := storage.NewNatsPublisher(cfg.NatsAddress)
+		defer nats.Close()
+		sinks = append(sinks, domain.NewBrokerSink(nats, constants.NatsSubjectPrefix))
+	}
+	bus := domain.NewEventBus(outboxRepo, sinks...)
 
 	// отсылает ордера на проверку <-chanUnchecked, ставит в очередь на сохранение chanChecked<-
-	accrual := domain.NewAccrualModel(accrualRepo, chanUnchecked, chanChecked)
+	accrual := domain.NewAccrualModel(accrualRepo, chanUnchecked, chanChecked, cfg.Accrual.QueryLimit)
+
+	// живость, готовность и подробное состояние сервиса
+	health := domain.NewHealthModel(martStorage, accrual, chanUnchecked, chanChecked)
 
 	ctxSignal, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 
 	order.ProcessUnchecked(ctxSignal)
-	order.ProcessChecked(ctxSignal)
+	order.ProcessChecked(ctxSignal, cfg.Accrual.SaveWorkers)
+
+	// перечитывание конфигурации без перезапуска: по SIGHUP и по запросу администратора
+	reload := newReloader(os.Args[1:], cfg, accrual, order)
+	reload.watch(ctxSignal)
 
 	// работа с accrual сервисом - проверка начислений по заказам
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
+		defer health.Worker(constants.WorkerAccrualChecker)()
 		accrual.StartAccrualChecker(ctxSignal)
 	}()
 
-	srv := handlers.NewServer(cfg.RunAddress, user, order, balance)
+	// обезличивание аккаунтов с истекшим льготным периодом
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer health.Worker(constants.WorkerAccountDeletion)()
+		account.StartDeletionWorker(ctxSignal)
+	}()
+
+	// доставка событий партнерам
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer health.Worker(constants.WorkerWebhookDelivery)()
+		webhooks.StartDeliveryWorker(ctxSignal)
+	}()
+
+	// рассылка доменных событий получателям
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer health.Worker(constants.WorkerEventDispatcher)()
+		bus.StartDispatcher(ctxSignal)
+	}()
+
+	srv := handlers.NewServer(cfg.RunAddress, cfg.Timeouts.Request, user, order, balance, account, admin, partner, events, webhooks, health, reload)
+	srv.RegisterOnShutdown(events.Close)
 
 	// запуск HTTP сервера
 	wg.Add(1)
@@ -77,12 +202,31 @@ func Run() {
 		}
 	}()
 
+	// метрики на отдельном адресе, закрытом от внешних клиентов
+	var metricsSrv *http.Server
+	if cfg.MetricsAddress != "" {
+		metricsSrv = handlers.NewMetricsServer(cfg.MetricsAddress)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if err := metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				panic(err)
+			}
+		}()
+	}
+
 	// ждет сигнала завершения программы, потом завершает работу HTTP сервера и работу с Accrual
 	wg.Add(1)
 	go func() {
 		<-ctxSignal.Done()
 
-		ctxTimeout, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		// сервер еще отвечает, но уже не готов: балансировщик перестает направлять на него запросы
+		health.Shutdown()
+		time.Sleep(cfg.Timeouts.ShutdownDrain)
+
+		ctxTimeout, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Shutdown)
 
 		defer func() {
 			wg.Done()
@@ -90,6 +234,12 @@ func Run() {
 			cancel()
 		}()
 
+		if metricsSrv != nil {
+			if err := metricsSrv.Shutdown(ctxTimeout); err != nil {
+				logger.Log().Error("Ошибка при завершении сервера метрик: " + err.Error())
+			}
+		}
+
 		if err := srv.Shutdown(ctxTimeout); err != nil {
 			logger.Log().Error("Ошибка при завершении HTTP сервера: " + err.Error())
 			return
@@ -103,3 +253,25 @@ func Run() {
 	fmt.Println("\nПрограмма завершена!")
 
 }
+
+// PrintConfig вывод действующих параметров со скрытыми секретами, возвращает код завершения программы
+// если параметры не прошли проверку, они все равно выводятся, а ошибки пишутся в stderr
+func PrintConfig(args []string) int {
+	cfg, err := config.Load(args)
+	if cfg == nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	if errPrint := config.Print(os.Stdout, cfg); errPrint != nil {
+		fmt.Fprintln(os.Stderr, errPrint)
+		return 1
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
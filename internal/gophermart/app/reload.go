@@ -0,0 +1,101 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/dnsoftware/gophermart2/internal/gophermart/config"
+	"github.com/dnsoftware/gophermart2/internal/gophermart/domain"
+	"github.com/dnsoftware/gophermart2/internal/logger"
+	"go.uber.org/zap"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+// reloader перечитывание конфигурации по SIGHUP или запросу администратора
+// параметры сначала полностью проверяются, при ошибке работающий сервис не меняется
+type reloader struct {
+	mu      sync.Mutex     // перечитывания выполняются по очереди
+	args    []string       // аргументы командной строки: флаги по-прежнему применяются поверх файла
+	started *config.Config // параметры, с которыми сервис запущен
+	runtime atomic.Pointer[config.Runtime]
+
+	accrual *domain.Accrual
+	order   *domain.Order
+}
+
+func newReloader(args []string, cfg *config.Config, accrual *domain.Accrual, order *domain.Order) *reloader {
+	r := &reloader{
+		args:    args,
+		started: cfg,
+		accrual: accrual,
+		order:   order,
+	}
+	r.runtime.Store(cfg.Runtime())
+
+	return r
+}
+
+// Runtime действующие параметры, которые меняются без перезапуска
+func (r *reloader) Runtime() *config.Runtime {
+	return r.runtime.Load()
+}
+
+// Reload перечитывание конфигурации и применение параметров, которые меняются без перезапуска
+func (r *reloader) Reload() (*config.ReloadReport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := config.Load(r.args)
+	if err != nil {
+		return nil, fmt.Errorf("Reload: %w", err)
+	}
+
+	rt := next.Runtime()
+
+	// все значения уже проверены, дальше ошибок быть не может
+	if err = logger.Log().SetLevel(rt.LogLevel); err != nil {
+		return nil, fmt.Errorf("Reload: %w", err)
+	}
+	r.accrual.SetQueryLimit(rt.AccrualQueryLimit)
+	r.order.SetSaveWorkers(rt.OrderSaveWorkers)
+	r.runtime.Store(rt)
+
+	report := &config.ReloadReport{
+		Applied:         rt,
+		RestartRequired: config.RestartRequired(r.started, next),
+	}
+
+	return report, nil
+}
+
+// перечитывание конфигурации по сигналу SIGHUP до завершения ctx
+// обработчик сигнала устанавливается сразу, чтобы ранний SIGHUP не завершил процесс
+func (r *reloader) watch(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(hup)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				report, err := r.Reload()
+				if err != nil {
+					logger.Log().Error("Конфигурация не применена: " + err.Error())
+					continue
+				}
+
+				logger.Log().Warn("Конфигурация перечитана",
+					zap.Any("applied", report.Applied),
+					zap.Strings("restart_required", report.RestartRequired),
+				)
+			}
+		}
+	}()
+}
@@ -1,50 +1,291 @@
 package config
 
 import (
+	"bytes"
+	"errors"
 	"flag"
+	"fmt"
 	"github.com/caarlos0/env/v6"
 	"github.com/dnsoftware/gophermart2/internal/constants"
+	"gopkg.in/yaml.v3"
+	"io"
 	"log"
+	"os"
+	"reflect"
+	"strings"
+	"time"
 )
 
+// Config параметры сервиса
+// порядок применения: значения по умолчанию, файл конфигурации, флаги, переменные окружения
+// поля с тегом secret не выводятся в открытом виде и могут читаться из файла, путь к которому задан в <ENV>_FILE
 type Config struct {
-	RunAddress     string `env:"RUN_ADDRESS"`
-	DatabaseURI    string `env:"DATABASE_URI"`
-	AccrualAddress string `env:"ACCRUAL_SYSTEM_ADDRESS"`
+	RunAddress     string `yaml:"run_address" env:"RUN_ADDRESS"`
+	MetricsAddress string `yaml:"metrics_address" env:"METRICS_ADDRESS"` // отдельный адрес для /metrics, не публикуется наружу; пусто - метрики не отдаются
+	DatabaseURI    string `yaml:"database_uri" env:"DATABASE_URI" secret:"true"`
+	AccrualAddress string `yaml:"accrual_address" env:"ACCRUAL_SYSTEM_ADDRESS"`
+	AdminLogin     string `yaml:"admin_login" env:"ADMIN_LOGIN"`                   // логин пользователя, которому при старте назначается роль администратора
+	EventLogFile   string `yaml:"event_log_file" env:"EVENT_LOG_FILE"`             // журнал доменных событий
+	NatsAddress    string `yaml:"nats_address" env:"NATS_ADDRESS"`                 // адрес брокера NATS, если не задан - события в брокер не публикуются
+	OTLPEndpoint   string `yaml:"otlp_endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"` // приемник трассировки OTLP/HTTP, например http://localhost:4318
+	TraceFile      string `yaml:"trace_file" env:"TRACE_FILE"`                     // файл для трассировки, если приемник OTLP не задан
+
+	Log      LogConfig       `yaml:"log"`
+	Auth     AuthConfig      `yaml:"auth"`
+	Accrual  AccrualConfig   `yaml:"accrual"`
+	Timeouts TimeoutsConfig  `yaml:"timeouts"`
+	Features map[string]bool `yaml:"features" env:"FEATURES"` // включение функций, например websocket:false
+	CORS     CORSConfig      `yaml:"cors"`
 }
 
-type confFlags struct {
-	runAddress     string
-	databaseURI    string
-	accrualAddress string
+// LogConfig журнал
+type LogConfig struct {
+	Level            string        `yaml:"level" env:"LOG_LEVEL"`                         // debug, info, warn, error
+	Format           string        `yaml:"format" env:"LOG_FORMAT"`                       // json, console или auto - JSON в файлы, текст в консоль
+	Outputs          []string      `yaml:"outputs" env:"LOG_OUTPUTS" envSeparator:","`    // stdout, stderr или пути к файлам
+	MaxSize          int           `yaml:"max_size" env:"LOG_MAX_SIZE"`                   // размер файла журнала в мегабайтах, после которого он ротируется
+	MaxAge           int           `yaml:"max_age" env:"LOG_MAX_AGE"`                     // срок хранения старых файлов журнала в днях, 0 - не удалять по сроку
+	MaxBackups       int           `yaml:"max_backups" env:"LOG_MAX_BACKUPS"`             // кол-во хранимых старых файлов журнала, 0 - все
+	Compress         bool          `yaml:"compress" env:"LOG_COMPRESS"`                   // сжимать старые файлы журнала
+	RotateInterval   time.Duration `yaml:"rotate_interval" env:"LOG_ROTATE_INTERVAL"`     // ротация журнала по времени, 0 - только по размеру
+	SampleInitial    int           `yaml:"sample_initial" env:"LOG_SAMPLE_INITIAL"`       // из записей о запросах за секунду пишутся первые N
+	SampleThereafter int           `yaml:"sample_thereafter" env:"LOG_SAMPLE_THEREAFTER"` // и далее каждая N-я, 0 - пишутся все
 }
 
-func NewServerConfig() *Config {
-	cfg := &Config{}
-	cFlags := confFlags{}
+// AuthConfig авторизация
+type AuthConfig struct {
+	SecretKey        string        `yaml:"secret_key" env:"SECRET_KEY" secret:"true"`       // ключ подписи токенов
+	PasswordSalt     string        `yaml:"password_salt" env:"PASSWORD_SALT" secret:"true"` // соль хэшей паролей, после смены старые пароли не подходят
+	TokenTTL         time.Duration `yaml:"token_ttl" env:"TOKEN_TTL"`                       // время жизни токена авторизации
+	PasswordResetTTL time.Duration `yaml:"password_reset_ttl" env:"PASSWORD_RESET_TTL"`     // время жизни токена сброса пароля
+}
+
+// AccrualConfig работа с Accrual
+type AccrualConfig struct {
+	QueryLimit    int `yaml:"query_limit" env:"ACCRUAL_QUERY_LIMIT"`      // запросов к Accrual за период тикера
+	QueueCapacity int `yaml:"queue_capacity" env:"ORDERS_QUEUE_CAPACITY"` // емкость очередей заказов на проверку и на сохранение
+	SaveWorkers   int `yaml:"save_workers" env:"ORDERS_SAVE_WORKERS"`     // кол-во обработчиков проверенных заказов
+}
+
+// CORSConfig запросы из браузера с других адресов
+type CORSConfig struct {
+	Origins []string `yaml:"origins" env:"CORS_ORIGINS" envSeparator:","` // разрешенные источники, например https://mart.example.com, или *; пусто - CORS выключен
+}
 
-	err := env.Parse(cfg)
+// TimeoutsConfig интервалы ожидания
+type TimeoutsConfig struct {
+	Request       time.Duration `yaml:"request" env:"REQUEST_TIMEOUT"`              // время на обработку запроса к API
+	DBConnect     time.Duration `yaml:"db_connect" env:"DB_CONNECT_TIMEOUT"`        // время на подключение к БД и подготовку таблиц при старте
+	Webhook       time.Duration `yaml:"webhook" env:"WEBHOOK_TIMEOUT"`              // время ожидания ответа получателя вебхука
+	ShutdownDrain time.Duration `yaml:"shutdown_drain" env:"SHUTDOWN_DRAIN_PERIOD"` // после сигнала завершения сервер еще отвечает, но уже не готов
+	Shutdown      time.Duration `yaml:"shutdown" env:"SHUTDOWN_TIMEOUT"`            // время на завершение обработки текущих запросов
+}
+
+// Default значения по умолчанию
+func Default() *Config {
+	return &Config{
+		RunAddress:     constants.RunAddress,
+		AccrualAddress: constants.AccrualAddress,
+		MetricsAddress: constants.MetricsAddress,
+		EventLogFile:   constants.EventLogFile,
+		Log: LogConfig{
+			Level:            constants.LogLevel.String(),
+			Format:           constants.LogFormatAuto,
+			Outputs:          strings.Split(constants.LogOutputs, ","),
+			MaxSize:          constants.LogMaxSize,
+			MaxAge:           constants.LogMaxAge,
+			MaxBackups:       constants.LogMaxBackups,
+			SampleInitial:    constants.LogSampleInitial,
+			SampleThereafter: constants.LogSampleThereafter,
+		},
+		Auth: AuthConfig{
+			SecretKey:        constants.SecretKey,
+			PasswordSalt:     constants.PasswordSalt,
+			TokenTTL:         constants.TokenExp,
+			PasswordResetTTL: constants.PasswordResetTokenExp,
+		},
+		Accrual: AccrualConfig{
+			QueryLimit:    constants.AccrualServiceQueryLimit,
+			QueueCapacity: constants.OrdersChannelCapacity,
+			SaveWorkers:   constants.OrderSaveWorkers,
+		},
+		Timeouts: TimeoutsConfig{
+			Request:       constants.DBContextTimeout,
+			DBConnect:     constants.DBContextTimeout,
+			Webhook:       constants.WebhookTimeout,
+			ShutdownDrain: constants.ShutdownDrainPeriod,
+			Shutdown:      constants.ShutdownTimeout,
+		},
+		Features: map[string]bool{
+			constants.FeatureBatchUpload:  true,
+			constants.FeatureEventsStream: true,
+			constants.FeatureWebSocket:    true,
+			constants.FeaturePartnerAPI:   true,
+		},
+	}
+}
+
+// NewServerConfig параметры сервиса из аргументов командной строки, файла конфигурации и окружения
+// при ошибке в параметрах сервис не запускается
+func NewServerConfig() *Config {
+	cfg, err := Load(os.Args[1:])
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	flag.StringVar(&cFlags.runAddress, "a", constants.RunAddress, "server endpoint")
-	flag.StringVar(&cFlags.databaseURI, "d", "", "data source name")
-	flag.StringVar(&cFlags.accrualAddress, "r", constants.AccrualAddress, "accrual endpoint")
-	flag.Parse()
+	return cfg
+}
+
+// Load загрузка и проверка параметров
+// при ошибке проверки возвращаются и загруженные параметры, чтобы их можно было показать
+func Load(args []string) (*Config, error) {
+	cfg := Default()
+
+	var configFile string
+	fs := flag.NewFlagSet("gophermart", flag.ContinueOnError)
+	fs.StringVar(&configFile, "config", "", "YAML or JSON config file")
+	bindFlags(fs, cfg)
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// флаги применяются поверх файла, поэтому запоминаем явно заданные и повторяем их после чтения файла
+	set := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = f.Value.String()
+	})
+
+	if path, ok := os.LookupEnv(constants.ConfigFileEnv); ok && path != "" {
+		configFile = path
+	}
+
+	if configFile != "" {
+		*cfg = *Default()
+		if err := loadFile(configFile, cfg); err != nil {
+			return nil, err
+		}
 
-	// если какого-то параметра нет в переменных окружения - берем значение флага, а если и флага нет - берем по умолчанию
-	if cfg.RunAddress == "" {
-		cfg.RunAddress = cFlags.runAddress
+		for name, value := range set {
+			if name != "config" {
+				fs.Set(name, value)
+			}
+		}
 	}
 
-	if cfg.DatabaseURI == "" {
-		cfg.DatabaseURI = cFlags.databaseURI
+	if err := env.Parse(cfg); err != nil {
+		return nil, fmt.Errorf("переменные окружения: %w", err)
 	}
 
-	if cfg.AccrualAddress == "" {
-		cfg.AccrualAddress = cFlags.accrualAddress
+	if err := loadSecretFiles(reflect.ValueOf(cfg).Elem()); err != nil {
+		return nil, err
 	}
 
-	return cfg
+	return cfg, cfg.Validate()
+}
+
+func bindFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.StringVar(&cfg.RunAddress, "a", cfg.RunAddress, "server endpoint")
+	fs.StringVar(&cfg.MetricsAddress, "metrics", cfg.MetricsAddress, "metrics endpoint, empty disables metrics")
+	fs.StringVar(&cfg.DatabaseURI, "d", cfg.DatabaseURI, "data source name")
+	fs.StringVar(&cfg.AccrualAddress, "r", cfg.AccrualAddress, "accrual endpoint")
+	fs.StringVar(&cfg.AdminLogin, "admin", cfg.AdminLogin, "administrator login")
+	fs.StringVar(&cfg.EventLogFile, "events-log", cfg.EventLogFile, "domain events log file")
+	fs.StringVar(&cfg.NatsAddress, "nats", cfg.NatsAddress, "NATS endpoint")
+	fs.StringVar(&cfg.OTLPEndpoint, "otlp", cfg.OTLPEndpoint, "OTLP/HTTP traces endpoint")
+	fs.StringVar(&cfg.TraceFile, "trace-file", cfg.TraceFile, "traces file")
+
+	fs.StringVar(&cfg.Log.Level, "log-level", cfg.Log.Level, "log level: debug, info, warn, error")
+	fs.StringVar(&cfg.Log.Format, "log-format", cfg.Log.Format, "log format: json, console or auto")
+	fs.Var((*listFlag)(&cfg.Log.Outputs), "log-outputs", "comma separated log outputs: stdout, stderr or file paths")
+	fs.IntVar(&cfg.Log.MaxSize, "log-max-size", cfg.Log.MaxSize, "log file size in megabytes before rotation")
+	fs.IntVar(&cfg.Log.MaxAge, "log-max-age", cfg.Log.MaxAge, "days to keep rotated log files")
+	fs.IntVar(&cfg.Log.MaxBackups, "log-max-backups", cfg.Log.MaxBackups, "number of rotated log files to keep")
+	fs.BoolVar(&cfg.Log.Compress, "log-compress", cfg.Log.Compress, "compress rotated log files")
+	fs.DurationVar(&cfg.Log.RotateInterval, "log-rotate-interval", cfg.Log.RotateInterval, "time based log rotation interval")
+	fs.IntVar(&cfg.Log.SampleInitial, "log-sample-initial", cfg.Log.SampleInitial, "request log entries per second written in full")
+	fs.IntVar(&cfg.Log.SampleThereafter, "log-sample-thereafter", cfg.Log.SampleThereafter, "after that every Nth request log entry is written, 0 disables sampling")
+
+	fs.DurationVar(&cfg.Auth.TokenTTL, "token-ttl", cfg.Auth.TokenTTL, "authorization token lifetime")
+	fs.IntVar(&cfg.Accrual.QueryLimit, "accrual-query-limit", cfg.Accrual.QueryLimit, "accrual requests per ticker period")
+	fs.IntVar(&cfg.Accrual.QueueCapacity, "orders-queue-capacity", cfg.Accrual.QueueCapacity, "orders queues capacity")
+	fs.IntVar(&cfg.Accrual.SaveWorkers, "orders-save-workers", cfg.Accrual.SaveWorkers, "checked orders save workers")
+	fs.Var((*listFlag)(&cfg.CORS.Origins), "cors-origins", "comma separated allowed CORS origins")
+	fs.DurationVar(&cfg.Timeouts.Request, "request-timeout", cfg.Timeouts.Request, "API request timeout")
+}
+
+// чтение файла конфигурации; JSON читается как YAML, неизвестные поля - ошибка
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("файл конфигурации: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	err = decoder.Decode(cfg)
+	if err != nil && !errors.Is(err, io.EOF) { // пустой файл - все по умолчанию
+		return fmt.Errorf("файл конфигурации %v: %w", path, err)
+	}
+
+	return nil
+}
+
+// чтение секретов из файлов, пути к которым заданы в переменных <ENV>_FILE
+func loadSecretFiles(v reflect.Value) error {
+	var errs []error
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+
+		if field.Type.Kind() == reflect.Struct {
+			if err := loadSecretFiles(v.Field(i)); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		if field.Tag.Get("secret") != "true" {
+			continue
+		}
+
+		name := field.Tag.Get("env")
+		path, ok := os.LookupEnv(name + constants.SecretFileEnvSuffix)
+		if !ok || path == "" {
+			continue
+		}
+
+		if value, ok := os.LookupEnv(name); ok && value != "" {
+			errs = append(errs, fmt.Errorf("заданы одновременно %v и %v%v", name, name, constants.SecretFileEnvSuffix))
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%v%v: %w", name, constants.SecretFileEnvSuffix, err))
+			continue
+		}
+
+		v.Field(i).SetString(strings.TrimRight(string(data), "\r\n"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// флаг со списком значений через запятую
+type listFlag []string
+
+func (l *listFlag) String() string {
+	if l == nil {
+		return ""
+	}
+
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = strings.Split(value, ",")
+
+	return nil
 }
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	"gopkg.in/yaml.v3"
+	"io"
+	"net/url"
+	"reflect"
+)
+
+// Print вывод действующих параметров в формате файла конфигурации, секреты скрываются
+func Print(w io.Writer, cfg *Config) error {
+	masked := *cfg
+	maskSecrets(reflect.ValueOf(&masked).Elem())
+
+	data, err := yaml.Marshal(&masked)
+	if err != nil {
+		return fmt.Errorf("Print: %w", err)
+	}
+
+	_, err = w.Write(data)
+
+	return err
+}
+
+func maskSecrets(v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+
+		if field.Type.Kind() == reflect.Struct {
+			maskSecrets(v.Field(i))
+			continue
+		}
+
+		if field.Tag.Get("secret") == "true" && v.Field(i).String() != "" {
+			v.Field(i).SetString(maskSecret(v.Field(i).String()))
+		}
+	}
+}
+
+// в адресе подключения скрывается только пароль, остальное полезно видеть
+func maskSecret(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.User == nil {
+		return constants.LogRedacted
+	}
+
+	return u.Redacted()
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Runtime параметры, которые применяются к работающему сервису без перезапуска
+type Runtime struct {
+	LogLevel          string          `json:"log_level"`
+	AccrualQueryLimit int             `json:"accrual_query_limit"`
+	OrderSaveWorkers  int             `json:"order_save_workers"`
+	Features          map[string]bool `json:"features"`
+	CORSOrigins       []string        `json:"cors_origins"`
+}
+
+// ReloadReport результат перечитывания конфигурации
+type ReloadReport struct {
+	Applied         *Runtime `json:"applied"`
+	RestartRequired []string `json:"restart_required"` // измененные параметры, которые вступят в силу только после перезапуска
+}
+
+// ключи параметров Runtime в файле конфигурации
+var runtimeKeys = map[string]bool{
+	"log.level":            true,
+	"accrual.query_limit":  true,
+	"accrual.save_workers": true,
+	"features":             true,
+	"cors.origins":         true,
+}
+
+// Runtime параметры, которые меняются без перезапуска; копия, не связанная с Config
+func (c *Config) Runtime() *Runtime {
+	features := make(map[string]bool, len(c.Features))
+	for name, enabled := range c.Features {
+		features[name] = enabled
+	}
+
+	return &Runtime{
+		LogLevel:          c.Log.Level,
+		AccrualQueryLimit: c.Accrual.QueryLimit,
+		OrderSaveWorkers:  c.Accrual.SaveWorkers,
+		Features:          features,
+		CORSOrigins:       append([]string(nil), c.CORS.Origins...),
+	}
+}
+
+// Feature включена ли функция; не упомянутые в конфигурации функции включены
+func (r *Runtime) Feature(name string) bool {
+	enabled, ok := r.Features[name]
+
+	return !ok || enabled
+}
+
+// AllowOrigin разрешены ли запросы из браузера с адреса origin
+func (r *Runtime) AllowOrigin(origin string) bool {
+	for _, allowed := range r.CORSOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RestartRequired ключи параметров, которые отличаются в next, но без перезапуска не применяются
+func RestartRequired(current *Config, next *Config) []string {
+	var keys []string
+	diffKeys(reflect.ValueOf(current).Elem(), reflect.ValueOf(next).Elem(), "", &keys)
+
+	return keys
+}
+
+func diffKeys(a reflect.Value, b reflect.Value, prefix string, keys *[]string) {
+	for i := 0; i < a.NumField(); i++ {
+		key := prefix + a.Type().Field(i).Tag.Get("yaml")
+		if runtimeKeys[key] {
+			continue
+		}
+
+		if a.Field(i).Kind() == reflect.Struct {
+			diffKeys(a.Field(i), b.Field(i), key+".", keys)
+			continue
+		}
+
+		if !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			*keys = append(*keys, key)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	"go.uber.org/zap/zapcore"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Validate проверка параметров, возвращает все найденные ошибки сразу
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, key string, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%v: %v", key, fmt.Sprintf(format, args...)))
+		}
+	}
+
+	_, _, err := net.SplitHostPort(c.RunAddress)
+	check(err == nil, "run_address", "ожидается адрес:порт, получено %q", c.RunAddress)
+	if c.MetricsAddress != "" {
+		_, _, err = net.SplitHostPort(c.MetricsAddress)
+		check(err == nil, "metrics_address", "ожидается адрес:порт, получено %q", c.MetricsAddress)
+		check(c.MetricsAddress != c.RunAddress, "metrics_address", "должен отличаться от run_address")
+	}
+	check(c.DatabaseURI != "", "database_uri", "не задан (флаг -d, DATABASE_URI или DATABASE_URI_FILE)")
+	check(c.AccrualAddress != "", "accrual_address", "не задан")
+
+	_, err = zapcore.ParseLevel(c.Log.Level)
+	check(err == nil, "log.level", "неизвестный уровень %q, ожидается debug, info, warn или error", c.Log.Level)
+	switch c.Log.Format {
+	case constants.LogFormatAuto, constants.LogFormatJSON, constants.LogFormatConsole:
+	default:
+		check(false, "log.format", "неизвестный формат %q, ожидается auto, json или console", c.Log.Format)
+	}
+	outputs := 0
+	for _, output := range c.Log.Outputs {
+		if strings.TrimSpace(output) != "" {
+			outputs++
+		}
+	}
+	check(outputs > 0, "log.outputs", "не задано ни одного вывода")
+	check(c.Log.MaxSize > 0, "log.max_size", "должен быть больше 0, получено %v", c.Log.MaxSize)
+	check(c.Log.MaxAge >= 0, "log.max_age", "не может быть отрицательным")
+	check(c.Log.MaxBackups >= 0, "log.max_backups", "не может быть отрицательным")
+	check(c.Log.RotateInterval >= 0, "log.rotate_interval", "не может быть отрицательным")
+	check(c.Log.SampleInitial >= 0, "log.sample_initial", "не может быть отрицательным")
+	check(c.Log.SampleThereafter >= 0, "log.sample_thereafter", "не может быть отрицательным")
+
+	check(len(c.Auth.SecretKey) >= constants.MinSecretKeyLength, "auth.secret_key",
+		"должен быть не короче %v символов", constants.MinSecretKeyLength)
+	check(c.Auth.PasswordSalt != "", "auth.password_salt", "не задана")
+	checkPositive(check, "auth.token_ttl", c.Auth.TokenTTL)
+	checkPositive(check, "auth.password_reset_ttl", c.Auth.PasswordResetTTL)
+
+	check(c.Accrual.QueryLimit > 0, "accrual.query_limit", "должен быть больше 0, получено %v", c.Accrual.QueryLimit)
+	check(c.Accrual.QueueCapacity > 0, "accrual.queue_capacity", "должна быть больше 0, получено %v", c.Accrual.QueueCapacity)
+	check(c.Accrual.SaveWorkers > 0 && c.Accrual.SaveWorkers <= constants.MaxOrderSaveWorkers, "accrual.save_workers",
+		"должно быть от 1 до %v, получено %v", constants.MaxOrderSaveWorkers, c.Accrual.SaveWorkers)
+
+	checkPositive(check, "timeouts.request", c.Timeouts.Request)
+	checkPositive(check, "timeouts.db_connect", c.Timeouts.DBConnect)
+	checkPositive(check, "timeouts.webhook", c.Timeouts.Webhook)
+	checkPositive(check, "timeouts.shutdown", c.Timeouts.Shutdown)
+	check(c.Timeouts.ShutdownDrain >= 0, "timeouts.shutdown_drain", "не может быть отрицательным")
+
+	names := make([]string, 0, len(c.Features))
+	for name := range c.Features {
+		names = append(names, name)
+	}
+	sort.Strings(names) // ошибки выводятся в одном и том же порядке
+	for _, name := range names {
+		check(knownFeature(name), "features."+name, "неизвестная функция, ожидается %v, %v, %v или %v",
+			constants.FeatureBatchUpload, constants.FeatureEventsStream, constants.FeatureWebSocket, constants.FeaturePartnerAPI)
+	}
+
+	for _, origin := range c.CORS.Origins {
+		check(originValidate(origin), "cors.origins", "ожидается * или схема://хост[:порт] без пути, получено %q", origin)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("неверные параметры:\n%w", errors.Join(errs...))
+	}
+
+	return nil
+}
+
+func knownFeature(name string) bool {
+	switch name {
+	case constants.FeatureBatchUpload, constants.FeatureEventsStream, constants.FeatureWebSocket, constants.FeaturePartnerAPI:
+		return true
+	}
+
+	return false
+}
+
+// источник CORS сравнивается с заголовком Origin как строка, поэтому путь и завершающий слэш недопустимы
+func originValidate(origin string) bool {
+	if origin == "*" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && u.Path == "" &&
+		u.RawQuery == "" && u.Fragment == "" && u.User == nil
+}
+
+func checkPositive(check func(bool, string, string, ...any), key string, d time.Duration) {
+	check(d > 0, key, "должен быть больше 0, получено %v", d)
+}
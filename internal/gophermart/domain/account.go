@@ -0,0 +1,137 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	"github.com/dnsoftware/gophermart2/internal/logger"
+	"time"
+)
+
+type AccountStorage interface {
+	RequestDeletion(ctx context.Context, userID int64) (time.Time, error)
+	CancelDeletion(ctx context.Context, userID int64) (bool, error)
+	AnonymizeExpired(ctx context.Context, requestedBefore time.Time) (int64, error)
+}
+
+// Account выгрузка персональных данных и удаление аккаунта
+type Account struct {
+	storage  AccountStorage
+	profiles *User    // источник профиля для выгрузки
+	orders   *Order   // источник заказов для выгрузки
+	ledger   *Balance // источник движений по балансу для выгрузки
+}
+
+// AccountExport машиночитаемая выгрузка всех данных пользователя
+type AccountExport struct {
+	ExportedAt  string         `json:"exported_at"`
+	Profile     *UserProfile   `json:"profile"`
+	Orders      []OrderItem    `json:"orders"`
+	Ledger      []LedgerItem   `json:"ledger"`
+	Withdrawals []WithdrawItem `json:"withdrawals"`
+}
+
+func NewAccountModel(storage AccountStorage, profiles *User, orders *Order, ledger *Balance) *Account {
+	account := &Account{
+		storage:  storage,
+		profiles: profiles,
+		orders:   orders,
+		ledger:   ledger,
+	}
+
+	return account
+}
+
+// Export выгрузка профиля, заказов, движений по балансу и списаний пользователя
+func (a *Account) Export(ctx context.Context, userID int64) (*AccountExport, int, error) {
+	profile, _, err := a.profiles.Profile(ctx, userID)
+	if err != nil {
+		return nil, constants.ExportInternalError, fmt.Errorf("выгрузка профиля: %w", err)
+	}
+
+	orders, _, err := a.orders.OrdersList(ctx, userID)
+	if err != nil {
+		return nil, constants.ExportInternalError, fmt.Errorf("выгрузка заказов: %w", err)
+	}
+
+	ledger, err := a.ledger.UserLedger(ctx, userID)
+	if err != nil {
+		return nil, constants.ExportInternalError, fmt.Errorf("выгрузка движений по балансу: %w", err)
+	}
+
+	withdrawals, err := a.ledger.UserWithrawalsList(ctx, userID)
+	if err != nil {
+		return nil, constants.ExportInternalError, fmt.Errorf("выгрузка списаний: %w", err)
+	}
+	if withdrawals == nil {
+		withdrawals = []WithdrawItem{}
+	}
+
+	return &AccountExport{
+		ExportedAt:  time.Now().Format(time.RFC3339),
+		Profile:     profile,
+		Orders:      orders,
+		Ledger:      ledger,
+		Withdrawals: withdrawals,
+	}, constants.ExportOk, nil
+}
+
+// RequestDeletion планирует обезличивание аккаунта по истечении льготного периода
+// возвращает момент, после которого отмена невозможна
+func (a *Account) RequestDeletion(ctx context.Context, userID int64) (time.Time, int, error) {
+	requestedAt, err := a.storage.RequestDeletion(ctx, userID)
+	if err != nil {
+		logger.Log().WithContext(ctx).Error("RequestDeletion: " + err.Error())
+		return time.Time{}, constants.DeletionInternalError, err
+	}
+
+	logger.Log().WithContext(ctx).Info(fmt.Sprintf("Запрошено удаление аккаунта %v", userID))
+
+	return requestedAt.Add(constants.AccountDeletionGracePeriod), constants.DeletionScheduled, nil
+}
+
+// CancelDeletion отмена запланированного удаления в течение льготного периода
+func (a *Account) CancelDeletion(ctx context.Context, userID int64) (int, error) {
+	cancelled, err := a.storage.CancelDeletion(ctx, userID)
+	if err != nil {
+		logger.Log().WithContext(ctx).Error("CancelDeletion: " + err.Error())
+		return constants.DeletionInternalError, err
+	}
+
+	if !cancelled {
+		return constants.DeletionNotScheduled, fmt.Errorf("удаление аккаунта %v не запланировано", userID)
+	}
+
+	logger.Log().WithContext(ctx).Info(fmt.Sprintf("Отменено удаление аккаунта %v", userID))
+
+	return constants.DeletionCancelled, nil
+}
+
+// StartDeletionWorker служба обезличивания аккаунтов с истекшим льготным периодом
+func (a *Account) StartDeletionWorker(ctx context.Context) {
+	ticker := time.NewTicker(constants.AccountDeletionCheckPeriod)
+	defer ticker.Stop()
+
+	for {
+		a.anonymizeExpired(ctx)
+
+		select {
+		case <-ctx.Done():
+			logger.Log().WithContext(ctx).Info("DeletionWorker DONE!!!")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *Account) anonymizeExpired(ctx context.Context) {
+	count, err := a.storage.AnonymizeExpired(ctx, time.Now().Add(-constants.AccountDeletionGracePeriod))
+	if err != nil {
+		logger.Log().WithContext(ctx).Error("AnonymizeExpired: " + err.Error())
+		return
+	}
+
+	if count > 0 {
+		logger.Log().WithContext(ctx).Info(fmt.Sprintf("Обезличено аккаунтов: %v", count))
+	}
+}
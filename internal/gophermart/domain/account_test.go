@@ -0,0 +1,112 @@
+package domain
+
+import (
+	"context"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	mock_domain "github.com/dnsoftware/gophermart2/internal/gophermart/domain/mocks"
+	"github.com/dnsoftware/gophermart2/internal/storage"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestAccount_Export(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	tm, _ := time.Parse(time.RFC3339, "2024-03-19T15:24:39-07:00")
+
+	mockUser := mock_domain.NewMockUserStorage(ctrl)
+	mockUser.EXPECT().FindByID(ctx, int64(1)).Return(storage.UserRow{ID: 1, Login: "user", CreatedAt: tm}, nil)
+	mockUser.EXPECT().ActivityCounts(ctx, int64(1)).Return(int64(1), int64(1), nil)
+
+	mockOrder := mock_domain.NewMockOrderStorage(ctrl)
+	mockOrder.EXPECT().List(ctx, int64(1)).Return([]storage.OrderRow{{
+		ID:         1,
+		UserID:     1,
+		Num:        "3840576627",
+		Status:     constants.OrderProcessed,
+		Accrual:    729.98,
+		UploadedAt: tm,
+	}}, constants.OrdersListOk, nil)
+
+	mockBalance := mock_domain.NewMockBalanceStorage(ctrl)
+	mockBalance.EXPECT().GetUserLedger(ctx, int64(1)).Return([]storage.BalanceRow{
+		{ID: 1, UserID: 1, OrderNumber: "3840576627", Amount: 729.98, Operation: constants.OperationAccrual, ProcessedAt: tm},
+		{ID: 2, UserID: 1, OrderNumber: "2377225624", Amount: -100, Operation: constants.OperationWithdrawal, ProcessedAt: tm},
+		{ID: 3, UserID: 1, Amount: 50, Operation: constants.OperationAdjustment, ReasonCode: constants.ReasonGoodwill, ProcessedAt: tm},
+	}, nil)
+	mockBalance.EXPECT().GetUserWithdrawList(ctx, int64(1)).Return([]storage.WithdrawRow{{
+		Order:       "2377225624",
+		Sum:         -100,
+		ProcessedAt: tm,
+	}}, nil)
+
+	account := NewAccountModel(
+		mock_domain.NewMockAccountStorage(ctrl),
+		NewUserModel(mockUser, nil),
+		&Order{storage: mockOrder},
+		NewBalanceModel(mockBalance),
+	)
+
+	export, status, err := account.Export(ctx, 1)
+
+	require.NoError(t, err)
+	require.Equal(t, constants.ExportOk, status)
+	assert.Equal(t, "user", export.Profile.Login)
+	assert.Equal(t, []OrderItem{{
+		Number:     "3840576627",
+		Status:     constants.OrderProcessed,
+		Accrual:    729.98,
+		UploadedAt: "2024-03-19T15:24:39-07:00",
+	}}, export.Orders)
+	assert.Equal(t, []LedgerItem{
+		{Type: constants.OperationAccrual, Order: "3840576627", Amount: 729.98, ProcessedAt: "2024-03-19T15:24:39-07:00"},
+		{Type: constants.OperationWithdrawal, Order: "2377225624", Amount: -100, ProcessedAt: "2024-03-19T15:24:39-07:00"},
+		{Type: constants.OperationAdjustment, Amount: 50, ReasonCode: constants.ReasonGoodwill, ProcessedAt: "2024-03-19T15:24:39-07:00"},
+	}, export.Ledger)
+	assert.Equal(t, []WithdrawItem{{
+		Order:       "2377225624",
+		Sum:         100,
+		ProcessedAt: "2024-03-19T15:24:39-07:00",
+	}}, export.Withdrawals)
+}
+
+func TestAccount_Deletion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockAccount := mock_domain.NewMockAccountStorage(ctrl)
+	account := NewAccountModel(mockAccount, nil, nil, nil)
+
+	requestedAt := time.Now()
+
+	// запрос удаления: аккаунт будет обезличен по истечении льготного периода
+	mockAccount.EXPECT().RequestDeletion(ctx, int64(1)).Return(requestedAt, nil)
+
+	deletionAt, status, err := account.RequestDeletion(ctx, 1)
+
+	require.NoError(t, err)
+	require.Equal(t, constants.DeletionScheduled, status)
+	require.Equal(t, requestedAt.Add(constants.AccountDeletionGracePeriod), deletionAt)
+
+	// отмена в течение льготного периода
+	mockAccount.EXPECT().CancelDeletion(ctx, int64(1)).Return(true, nil)
+
+	status, err = account.CancelDeletion(ctx, 1)
+
+	require.NoError(t, err)
+	require.Equal(t, constants.DeletionCancelled, status)
+
+	// отмена, когда удаление не запланировано
+	mockAccount.EXPECT().CancelDeletion(ctx, int64(1)).Return(false, nil)
+
+	status, err = account.CancelDeletion(ctx, 1)
+
+	require.Error(t, err)
+	require.Equal(t, constants.DeletionNotScheduled, status)
+}
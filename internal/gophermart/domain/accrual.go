@@ -5,25 +5,27 @@ import (
 	"fmt"
 	"github.com/dnsoftware/gophermart2/internal/constants"
 	"github.com/dnsoftware/gophermart2/internal/logger"
+	"github.com/dnsoftware/gophermart2/internal/metrics"
 	"github.com/dnsoftware/gophermart2/internal/storage"
 	"net/http"
 	"regexp"
 	"strconv"
+	"sync/atomic"
 	"time"
 )
 
 type AccrualStorage interface {
-	GetOrder(orderNum int64) (*storage.AccrualRow, int, error)
+	GetOrder(ctx context.Context, orderNum string) (*storage.AccrualRow, int, error)
 }
 
 // непроверенные ордера берем и отсылаем на проверку
 type Unchecked interface {
-	Pop(ctx context.Context) int64
+	Pop(ctx context.Context) string
 }
 
 // проверенные ордера ставим в очередь на сохранение
 type Checked interface {
-	Push(order int64, status string, accrual float32)
+	Push(order string, status string, accrualStatus string, accrual float32)
 }
 
 type AccrualItem struct {
@@ -31,84 +33,124 @@ type AccrualItem struct {
 
 type Accrual struct {
 	storage                  AccrualStorage
-	accrualServiceQueryLimit int           // максимально кол-во запросов к Accrual сервису в минуту
+	accrualServiceQueryLimit atomic.Int64  // максимально кол-во запросов к Accrual сервису за период тикера, меняется без остановки службы
 	counter                  int           // счетчик запросов за текущий интервал
 	checkPeriod              time.Duration // период проверки
 	ordersToCheck            Unchecked     // отсюда забираем ордера на проверку и шлем в Accrual
 	ordersToSave             Checked       // сюда заносим проверенные ордера, полученные из Accrual
+	breaker                  *accrualBreaker
 }
 
-func NewAccrualModel(storage AccrualStorage, ordersToCheck Unchecked, ordersToSave Checked) *Accrual {
+// queryLimit - максимальное кол-во запросов к Accrual за период тикера
+func NewAccrualModel(storage AccrualStorage, ordersToCheck Unchecked, ordersToSave Checked, queryLimit int) *Accrual {
 	balance := &Accrual{
-		storage:                  storage,
-		accrualServiceQueryLimit: constants.AccrualServiceQueryLimit,
-		counter:                  0,
-		checkPeriod:              time.Duration(constants.AccrualCheckPeriod) * time.Second,
-		ordersToCheck:            ordersToCheck,
-		ordersToSave:             ordersToSave,
+		storage:       storage,
+		counter:       0,
+		checkPeriod:   time.Duration(constants.AccrualCheckPeriod) * time.Second,
+		ordersToCheck: ordersToCheck,
+		ordersToSave:  ordersToSave,
+		breaker:       newAccrualBreaker(),
 	}
+	balance.accrualServiceQueryLimit.Store(int64(queryLimit))
 
 	return balance
 }
 
+// Breaker состояние ограничителя запросов к Accrual
+func (b *Accrual) Breaker() AccrualBreakerState {
+	return b.breaker.state()
+}
+
+// SetQueryLimit изменение лимита запросов к Accrual без остановки службы, действует с текущего периода
+// лимит, полученный от Accrual в ответе 429, заменяет заданный до следующего изменения
+func (b *Accrual) SetQueryLimit(limit int) {
+	b.accrualServiceQueryLimit.Store(int64(limit))
+}
+
+// QueryLimit текущий лимит запросов к Accrual
+func (b *Accrual) QueryLimit() int {
+	return int(b.accrualServiceQueryLimit.Load())
+}
+
 // StartAccrualChecker Служба проверки начислений
 func (b *Accrual) StartAccrualChecker(ctx context.Context) {
 	ticker := time.NewTicker(time.Duration(constants.AccrualTickerPeriod) * time.Second)
+	metrics.SetAccrualBudget(b.QueryLimit(), b.QueryLimit()-b.counter)
 
 	for {
 		select {
 		case <-ctx.Done():
-			logger.Log().Info("AccrualChecker DONE!!!")
+			logger.Log().WithContext(ctx).Info("AccrualChecker DONE!!!")
 			return
 		case <-ticker.C:
 			b.counter = 0
+			metrics.SetAccrualBudget(b.QueryLimit(), b.QueryLimit())
 		default:
-			if b.counter >= b.accrualServiceQueryLimit {
+			if b.counter >= b.QueryLimit() {
+				continue
+			}
+
+			// Accrual недоступен - ждем пробного запроса, не забирая заказы из очереди
+			if wait := b.breaker.wait(); wait > 0 {
+				select {
+				case <-ctx.Done():
+				case <-time.After(wait):
+				}
 				continue
 			}
 
 			// основная работа
 			orderNumber := b.ordersToCheck.Pop(ctx)
-			order, status, err := b.storage.GetOrder(orderNumber)
+			order, status, err := b.storage.GetOrder(ctx, orderNumber)
+			if status >= http.StatusInternalServerError {
+				message := http.StatusText(status)
+				if err != nil {
+					message = err.Error()
+				}
+				b.breaker.failure(message)
+			} else {
+				b.breaker.success()
+			}
 
 			switch status {
 			case http.StatusOK:
 
 				switch order.Status {
 				case constants.AccrualRegistered, constants.AccrualProcessing: // еще не готовы
-					b.ordersToSave.Push(orderNumber, constants.OrderProcessing, 0)
+					b.ordersToSave.Push(orderNumber, constants.OrderProcessing, order.Status, 0)
 				case constants.AccrualProcessed:
-					b.ordersToSave.Push(orderNumber, constants.OrderProcessed, order.Accrual)
+					b.ordersToSave.Push(orderNumber, constants.OrderProcessed, order.Status, order.Accrual)
 				case constants.AccrualInvalid:
-					b.ordersToSave.Push(orderNumber, constants.OrderInvalid, order.Accrual)
+					b.ordersToSave.Push(orderNumber, constants.OrderInvalid, order.Status, order.Accrual)
 				}
 
 			case http.StatusNoContent:
-				logger.Log().Info(fmt.Sprintf("Accrual GetOrder no content: %v", orderNumber))
+				logger.Log().WithContext(ctx).Info(fmt.Sprintf("Accrual GetOrder no content: %v", orderNumber))
 
 			case http.StatusTooManyRequests:
 				re := regexp.MustCompile(`^No more than (\d+) requests per minute allowed, Retry-After: (\d+)$`)
 				matches := re.FindStringSubmatch(err.Error())
 				if len(matches) < 3 {
-					logger.Log().Error("Error regexp match accrual too many requests")
+					logger.Log().WithContext(ctx).Error("Error regexp match accrual too many requests")
 					break
 				}
 
 				newQueryLimit, _ := strconv.Atoi(matches[1])
-				b.accrualServiceQueryLimit = newQueryLimit
+				b.SetQueryLimit(newQueryLimit)
 
 				newCheckPeriod, _ := strconv.Atoi(matches[2])
 				b.checkPeriod = time.Duration(newCheckPeriod) * time.Second
 				ticker.Reset(b.checkPeriod)
-				b.counter = b.accrualServiceQueryLimit // в этом временном отрезке запросов уже не будет
+				b.counter = newQueryLimit // в этом временном отрезке запросов уже не будет
 
-				logger.Log().Info(fmt.Sprintf("too many requests: %v", orderNumber))
+				logger.Log().WithContext(ctx).Info(fmt.Sprintf("too many requests: %v", orderNumber))
 
 			case http.StatusInternalServerError:
-				logger.Log().Error("Accrual GetOrder error: " + err.Error())
+				logger.Log().WithContext(ctx).Error("Accrual GetOrder error: " + err.Error())
 			}
 
 			b.counter++
+			metrics.SetAccrualBudget(b.QueryLimit(), b.QueryLimit()-b.counter)
 		}
 	}
 
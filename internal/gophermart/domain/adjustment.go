@@ -0,0 +1,142 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	"github.com/dnsoftware/gophermart2/internal/storage"
+	"strconv"
+	"time"
+)
+
+type AdjustmentStorage interface {
+	CreateAdjustment(ctx context.Context, adj storage.AdjustmentRow) (int64, error)
+	AdjustmentList(ctx context.Context, status string) ([]storage.AdjustmentRow, error)
+	ApproveAdjustment(ctx context.Context, id int64, approverID int64) (int, error)
+	RejectAdjustment(ctx context.Context, id int64, approverID int64) (int, error)
+}
+
+// AdjustmentRequest заявка на ручную корректировку баланса
+// положительная сумма - начисление, отрицательная - списание
+type AdjustmentRequest struct {
+	UserID     int64   `json:"user_id"`
+	Order      string  `json:"order,omitempty"`
+	Amount     float32 `json:"amount"`
+	ReasonCode string  `json:"reason_code"`
+	Comment    string  `json:"comment"`
+}
+
+// AdjustmentItem корректировка баланса
+type AdjustmentItem struct {
+	ID         int64   `json:"id"`
+	UserID     int64   `json:"user_id"`
+	Order      string  `json:"order,omitempty"`
+	Amount     float32 `json:"amount"`
+	ReasonCode string  `json:"reason_code"`
+	Comment    string  `json:"comment,omitempty"`
+	Status     string  `json:"status"`
+	CreatedBy  int64   `json:"created_by"`
+	CreatedAt  string  `json:"created_at"`
+	DecidedBy  int64   `json:"decided_by,omitempty"`
+	DecidedAt  string  `json:"decided_at,omitempty"`
+}
+
+// CreateAdjustment создание корректировки сотрудником поддержки
+// на баланс она попадет только после подтверждения другим администратором
+func (a *Admin) CreateAdjustment(ctx context.Context, adminID int64, req AdjustmentRequest) (int64, int, error) {
+	if req.UserID <= 0 {
+		return 0, constants.AdjustmentBadFormat, fmt.Errorf("не указан пользователь")
+	}
+	if req.Amount == 0 {
+		return 0, constants.AdjustmentBadFormat, fmt.Errorf("сумма корректировки не может быть нулевой")
+	}
+
+	switch req.ReasonCode {
+	case constants.ReasonGoodwill, constants.ReasonAccrualReversal, constants.ReasonCorrection:
+	default:
+		return 0, constants.AdjustmentBadFormat, fmt.Errorf("неизвестный код причины %v", req.ReasonCode)
+	}
+
+	if req.Order != "" && !IsLuhnValid(req.Order) {
+		return 0, constants.AdjustmentBadFormat, fmt.Errorf("неверный номер заказа")
+	}
+
+	id, err := a.adjustments.CreateAdjustment(ctx, storage.AdjustmentRow{
+		UserID:      req.UserID,
+		OrderNumber: req.Order,
+		Amount:      req.Amount,
+		ReasonCode:  req.ReasonCode,
+		Comment:     req.Comment,
+		CreatedBy:   adminID,
+	})
+	if err != nil {
+		return 0, constants.AdjustmentInternalError, err
+	}
+
+	a.audit(ctx, adminID, AuditAdjustCreate, adjustmentTarget(id), map[string]string{
+		"user_id":     strconv.FormatInt(req.UserID, 10),
+		"amount":      strconv.FormatFloat(float64(req.Amount), 'f', 2, 32),
+		"reason_code": req.ReasonCode,
+	})
+
+	return id, constants.AdjustmentCreated, nil
+}
+
+// Adjustments список корректировок в заданном статусе (пустой - все)
+func (a *Admin) Adjustments(ctx context.Context, status string) ([]AdjustmentItem, int, error) {
+	rows, err := a.adjustments.AdjustmentList(ctx, status)
+	if err != nil {
+		return nil, constants.AdjustmentInternalError, err
+	}
+
+	items := make([]AdjustmentItem, 0, len(rows))
+	for _, row := range rows {
+		item := AdjustmentItem{
+			ID:         row.ID,
+			UserID:     row.UserID,
+			Amount:     row.Amount,
+			ReasonCode: row.ReasonCode,
+			Comment:    row.Comment,
+			Status:     row.Status,
+			Order:      row.OrderNumber,
+			CreatedBy:  row.CreatedBy,
+			CreatedAt:  row.CreatedAt.Format(time.RFC3339),
+			DecidedBy:  row.DecidedBy,
+		}
+		if !row.DecidedAt.IsZero() {
+			item.DecidedAt = row.DecidedAt.Format(time.RFC3339)
+		}
+
+		items = append(items, item)
+	}
+
+	return items, constants.AdjustmentOk, nil
+}
+
+// ApproveAdjustment подтверждение корректировки и занесение ее на баланс
+func (a *Admin) ApproveAdjustment(ctx context.Context, adminID int64, id int64) (int, error) {
+	status, err := a.adjustments.ApproveAdjustment(ctx, id, adminID)
+	if err != nil {
+		return status, err
+	}
+
+	a.audit(ctx, adminID, AuditAdjustApprove, adjustmentTarget(id), nil)
+
+	return status, nil
+}
+
+// RejectAdjustment отклонение корректировки
+func (a *Admin) RejectAdjustment(ctx context.Context, adminID int64, id int64) (int, error) {
+	status, err := a.adjustments.RejectAdjustment(ctx, id, adminID)
+	if err != nil {
+		return status, err
+	}
+
+	a.audit(ctx, adminID, AuditAdjustReject, adjustmentTarget(id), nil)
+
+	return status, nil
+}
+
+func adjustmentTarget(id int64) string {
+	return "adjustment:" + strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,95 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	mock_domain "github.com/dnsoftware/gophermart2/internal/gophermart/domain/mocks"
+	"github.com/dnsoftware/gophermart2/internal/storage"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestAdmin_CreateAdjustment(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockAdmin := mock_domain.NewMockAdminStorage(ctrl)
+	mockAdjustment := mock_domain.NewMockAdjustmentStorage(ctrl)
+	admin := NewAdminModel(mockAdmin, mockAdjustment, nil, nil, nil, nil)
+
+	// positive: корректировка создана от имени сотрудника поддержки
+	mockAdjustment.EXPECT().CreateAdjustment(ctx, storage.AdjustmentRow{
+		UserID:      2,
+		OrderNumber: "3840576627",
+		Amount:      -100,
+		ReasonCode:  constants.ReasonAccrualReversal,
+		Comment:     "двойное начисление",
+		CreatedBy:   10,
+	}).Return(int64(7), nil)
+	mockAdmin.EXPECT().AddAudit(ctx, int64(10), AuditAdjustCreate, "adjustment:7", gomock.Any()).Return(nil)
+
+	id, status, err := admin.CreateAdjustment(ctx, 10, AdjustmentRequest{
+		UserID:     2,
+		Order:      "3840576627",
+		Amount:     -100,
+		ReasonCode: constants.ReasonAccrualReversal,
+		Comment:    "двойное начисление",
+	})
+	require.NoError(t, err)
+	require.Equal(t, constants.AdjustmentCreated, status)
+	require.Equal(t, int64(7), id)
+
+	// negative
+	tests := []struct {
+		name string
+		req  AdjustmentRequest
+	}{
+		{
+			name: "Нулевая сумма",
+			req:  AdjustmentRequest{UserID: 2, ReasonCode: constants.ReasonGoodwill},
+		},
+		{
+			name: "Неизвестный код причины",
+			req:  AdjustmentRequest{UserID: 2, Amount: 10, ReasonCode: "gift"},
+		},
+		{
+			name: "Неверный номер заказа",
+			req:  AdjustmentRequest{UserID: 2, Amount: 10, ReasonCode: constants.ReasonGoodwill, Order: "1234"},
+		},
+	}
+
+	for _, test := range tests {
+		_, status, err = admin.CreateAdjustment(ctx, 10, test.req)
+		require.Error(t, err, test.name)
+		require.Equal(t, constants.AdjustmentBadFormat, status, test.name)
+	}
+}
+
+func TestAdmin_ApproveAdjustment(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockAdmin := mock_domain.NewMockAdminStorage(ctrl)
+	mockAdjustment := mock_domain.NewMockAdjustmentStorage(ctrl)
+	admin := NewAdminModel(mockAdmin, mockAdjustment, nil, nil, nil, nil)
+
+	// positive
+	mockAdjustment.EXPECT().ApproveAdjustment(ctx, int64(7), int64(11)).Return(constants.AdjustmentOk, nil)
+	mockAdmin.EXPECT().AddAudit(ctx, int64(11), AuditAdjustApprove, "adjustment:7", "{}").Return(nil)
+
+	status, err := admin.ApproveAdjustment(ctx, 11, 7)
+	require.NoError(t, err)
+	require.Equal(t, constants.AdjustmentOk, status)
+
+	// автор пытается подтвердить свою же корректировку, в аудит не пишем
+	mockAdjustment.EXPECT().ApproveAdjustment(ctx, int64(8), int64(10)).
+		Return(constants.AdjustmentSelfApproval, errors.New("корректировка 8 создана этим же пользователем"))
+
+	status, err = admin.ApproveAdjustment(ctx, 10, 8)
+	require.Error(t, err)
+	require.Equal(t, constants.AdjustmentSelfApproval, status)
+}
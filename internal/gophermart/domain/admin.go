@@ -0,0 +1,308 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	"github.com/dnsoftware/gophermart2/internal/logger"
+	"github.com/dnsoftware/gophermart2/internal/storage"
+	"strconv"
+	"time"
+)
+
+type AdminStorage interface {
+	SearchUsers(ctx context.Context, login string, limit int) ([]storage.UserRow, error)
+	SetBlocked(ctx context.Context, userID int64, blocked bool, reason string) (bool, error)
+	AddAudit(ctx context.Context, adminID int64, action string, target string, details string) error
+	AuditList(ctx context.Context, limit int) ([]storage.AuditRow, error)
+}
+
+// действия администратора для журнала аудита
+const (
+	AuditSearchUsers    = "search_users"
+	AuditViewOrders     = "view_orders"
+	AuditViewLedger     = "view_ledger"
+	AuditRecheckOrder   = "recheck_order"
+	AuditSetOrderStatus = "set_order_status"
+	AuditBlockUser      = "block_user"
+	AuditUnblockUser    = "unblock_user"
+	AuditSetRole        = "set_role"
+	AuditAdjustCreate   = "adjustment_create"
+	AuditAdjustApprove  = "adjustment_approve"
+	AuditAdjustReject   = "adjustment_reject"
+	AuditAPIKeyCreate   = "apikey_create"
+	AuditAPIKeyRotate   = "apikey_rotate"
+	AuditAPIKeyRevoke   = "apikey_revoke"
+	AuditAPIKeySigning  = "apikey_signing_key"
+	AuditDisputeApprove = "dispute_approve"
+	AuditDisputeReject  = "dispute_reject"
+)
+
+// Admin служебные операции поддержки и администраторов
+// каждое действие записывается в журнал аудита
+type Admin struct {
+	storage     AdminStorage
+	adjustments AdjustmentStorage
+	apiKeys     APIKeyStorage
+	users       *User
+	orders      *Order
+	balance     *Balance
+}
+
+// AdminUserItem пользователь в результатах поиска
+type AdminUserItem struct {
+	ID          int64  `json:"id"`
+	Login       string `json:"login"`
+	Role        string `json:"role"`
+	Blocked     bool   `json:"blocked"`
+	CreatedAt   string `json:"created_at"`
+	LastLoginAt string `json:"last_login_at,omitempty"`
+}
+
+// AuditItem запись журнала аудита
+type AuditItem struct {
+	AdminID   int64           `json:"admin_id"`
+	Action    string          `json:"action"`
+	Target    string          `json:"target"`
+	Details   json.RawMessage `json:"details"`
+	CreatedAt string          `json:"created_at"`
+}
+
+func NewAdminModel(storage AdminStorage, adjustments AdjustmentStorage, apiKeys APIKeyStorage, users *User, orders *Order, balance *Balance) *Admin {
+	admin := &Admin{
+		storage:     storage,
+		adjustments: adjustments,
+		apiKeys:     apiKeys,
+		users:       users,
+		orders:      orders,
+		balance:     balance,
+	}
+
+	return admin
+}
+
+// SearchUsers поиск пользователей по части логина
+func (a *Admin) SearchUsers(ctx context.Context, adminID int64, login string) ([]AdminUserItem, int, error) {
+	rows, err := a.storage.SearchUsers(ctx, login, constants.AdminSearchLimit)
+	if err != nil {
+		return nil, constants.AdminInternalError, err
+	}
+
+	a.audit(ctx, adminID, AuditSearchUsers, "users", map[string]string{"login": login})
+
+	items := make([]AdminUserItem, 0, len(rows))
+	for _, row := range rows {
+		item := AdminUserItem{
+			ID:        row.ID,
+			Login:     row.Login,
+			Role:      row.Role,
+			Blocked:   !row.BlockedAt.IsZero(),
+			CreatedAt: row.CreatedAt.Format(time.RFC3339),
+		}
+		if !row.LastLoginAt.IsZero() {
+			item.LastLoginAt = row.LastLoginAt.Format(time.RFC3339)
+		}
+
+		items = append(items, item)
+	}
+
+	return items, constants.AdminOk, nil
+}
+
+// UserOrders заказы пользователя
+func (a *Admin) UserOrders(ctx context.Context, adminID int64, userID int64) ([]OrderItem, int, error) {
+	list, _, err := a.orders.OrdersList(ctx, userID)
+	if err != nil {
+		return nil, constants.AdminInternalError, err
+	}
+
+	a.audit(ctx, adminID, AuditViewOrders, userTarget(userID), nil)
+
+	return list, constants.AdminOk, nil
+}
+
+// UserLedger движения по балансу пользователя
+func (a *Admin) UserLedger(ctx context.Context, adminID int64, userID int64) ([]LedgerItem, int, error) {
+	list, err := a.balance.UserLedger(ctx, userID)
+	if err != nil {
+		return nil, constants.AdminInternalError, err
+	}
+
+	a.audit(ctx, adminID, AuditViewLedger, userTarget(userID), nil)
+
+	return list, constants.AdminOk, nil
+}
+
+// RecheckOrder повторная проверка заказа в Accrual
+// заказ переводится в статус NEW и будет взят в обработку ProcessUnchecked;
+// пересматриваются только заказы, обработка которых не завершена: по PROCESSED начисление уже внесено на баланс
+func (a *Admin) RecheckOrder(ctx context.Context, adminID int64, orderNumber string) (int, error) {
+	ctx = a.auditContext(ctx, adminID, AuditRecheckOrder, orderTarget(orderNumber), nil)
+
+	return a.changeOrderStatus(ctx, orderNumber, func() error {
+		return a.orders.SetStatus(ctx, orderNumber, constants.OrderNew, "", 0, constants.StatusSourceAdmin)
+	})
+}
+
+// SetOrderStatus ручная установка статуса заказа с указанием причины
+// смена идет по правилам OrderStateMachine; для PROCESSED обязательно начисление, оно вносится на баланс
+func (a *Admin) SetOrderStatus(ctx context.Context, adminID int64, orderNumber string, orderStatus string, accrual *float32, reason string) (int, error) {
+	if !orderStatusValidate(orderStatus) {
+		return constants.AdminBadFormat, fmt.Errorf("неизвестный статус заказа %v", orderStatus)
+	}
+	if reason == "" {
+		return constants.AdminBadFormat, fmt.Errorf("не указана причина смены статуса")
+	}
+
+	var amount float32
+	if orderStatus == constants.OrderProcessed {
+		if accrual == nil || *accrual < 0 {
+			return constants.AdminBadFormat, fmt.Errorf("для статуса %v нужно неотрицательное начисление", orderStatus)
+		}
+		amount = *accrual
+	} else if accrual != nil {
+		return constants.AdminBadFormat, fmt.Errorf("начисление указывается только для статуса %v", constants.OrderProcessed)
+	}
+
+	details := map[string]string{"status": orderStatus, "reason": reason}
+	if accrual != nil {
+		details["accrual"] = strconv.FormatFloat(float64(amount), 'f', -1, 32)
+	}
+	ctx = a.auditContext(ctx, adminID, AuditSetOrderStatus, orderTarget(orderNumber), details)
+
+	return a.changeOrderStatus(ctx, orderNumber, func() error {
+		return a.orders.SetStatus(ctx, orderNumber, orderStatus, "", amount, constants.StatusSourceAdmin)
+	})
+}
+
+// смена статуса заказа администратором, change меняет статус с проверкой текущего
+func (a *Admin) changeOrderStatus(ctx context.Context, orderNumber string, change func() error) (int, error) {
+	row, err := a.orders.storage.GetOrderByNumber(ctx, orderNumber)
+	if errors.Is(err, sql.ErrNoRows) {
+		return constants.AdminNotFound, fmt.Errorf("заказ %v не найден", orderNumber)
+	}
+	if err != nil {
+		return constants.AdminInternalError, err
+	}
+
+	err = change()
+	if errors.Is(err, storage.ErrTransitionRejected) {
+		return constants.AdminTransitionRejected, fmt.Errorf("заказ %v в статусе %v, смена статуса запрещена", orderNumber, row.Status)
+	}
+	if err != nil {
+		return constants.AdminInternalError, err
+	}
+
+	return constants.AdminOk, nil
+}
+
+// BlockUser блокировка пользователя: вход и работа с API становятся невозможны
+func (a *Admin) BlockUser(ctx context.Context, adminID int64, userID int64, reason string) (int, error) {
+	if reason == "" {
+		return constants.AdminBadFormat, fmt.Errorf("не указана причина блокировки")
+	}
+
+	return a.setBlocked(ctx, adminID, userID, true, reason)
+}
+
+// UnblockUser снятие блокировки пользователя
+func (a *Admin) UnblockUser(ctx context.Context, adminID int64, userID int64) (int, error) {
+	return a.setBlocked(ctx, adminID, userID, false, "")
+}
+
+// SetRole смена роли пользователя
+func (a *Admin) SetRole(ctx context.Context, adminID int64, userID int64, role string) (int, error) {
+	status, err := a.users.SetRole(ctx, userID, role)
+	if err != nil {
+		return status, err
+	}
+
+	a.audit(ctx, adminID, AuditSetRole, userTarget(userID), map[string]string{"role": role})
+
+	return status, nil
+}
+
+// AuditList последние записи журнала аудита
+func (a *Admin) AuditList(ctx context.Context) ([]AuditItem, int, error) {
+	rows, err := a.storage.AuditList(ctx, constants.AdminAuditLimit)
+	if err != nil {
+		return nil, constants.AdminInternalError, err
+	}
+
+	items := make([]AuditItem, 0, len(rows))
+	for _, row := range rows {
+		item := AuditItem{
+			AdminID:   row.AdminID,
+			Action:    row.Action,
+			Target:    row.Target,
+			Details:   json.RawMessage(row.Details),
+			CreatedAt: row.CreatedAt.Format(time.RFC3339),
+		}
+		if row.Details == "" {
+			item.Details = json.RawMessage("{}")
+		}
+
+		items = append(items, item)
+	}
+
+	return items, constants.AdminOk, nil
+}
+
+func (a *Admin) setBlocked(ctx context.Context, adminID int64, userID int64, blocked bool, reason string) (int, error) {
+	found, err := a.storage.SetBlocked(ctx, userID, blocked, reason)
+	if err != nil {
+		return constants.AdminInternalError, err
+	}
+	if !found {
+		return constants.AdminNotFound, fmt.Errorf("пользователь %v не найден", userID)
+	}
+
+	action := AuditUnblockUser
+	var details map[string]string
+	if blocked {
+		action = AuditBlockUser
+		details = map[string]string{"reason": reason}
+	}
+	a.audit(ctx, adminID, action, userTarget(userID), details)
+
+	return constants.AdminOk, nil
+}
+
+// запись в журнал аудита; ошибка записи не отменяет уже выполненное действие
+func (a *Admin) audit(ctx context.Context, adminID int64, action string, target string, details map[string]string) {
+	err := a.storage.AddAudit(ctx, adminID, action, target, auditDetails(details))
+	if err != nil {
+		logger.Log().WithContext(ctx).Error(fmt.Sprintf("Ошибка записи аудита %v %v администратора %v: %v", action, target, adminID, err))
+	}
+}
+
+// контекст с записью аудита для действий, которые сохраняются в одной транзакции с аудитом:
+// если запись не сохранилась, действие не выполняется
+func (a *Admin) auditContext(ctx context.Context, adminID int64, action string, target string, details map[string]string) context.Context {
+	return storage.WithAudit(ctx, storage.AuditRow{
+		AdminID: adminID,
+		Action:  action,
+		Target:  target,
+		Details: auditDetails(details),
+	})
+}
+
+func auditDetails(details map[string]string) string {
+	if details == nil {
+		return "{}"
+	}
+	data, _ := json.Marshal(details)
+
+	return string(data)
+}
+
+func userTarget(userID int64) string {
+	return "user:" + strconv.FormatInt(userID, 10)
+}
+
+func orderTarget(orderNumber string) string {
+	return "order:" + orderNumber
+}
@@ -0,0 +1,163 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	mock_domain "github.com/dnsoftware/gophermart2/internal/gophermart/domain/mocks"
+	"github.com/dnsoftware/gophermart2/internal/storage"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// контекст с записью аудита, которая сохраняется в транзакции действия
+type auditMatcher struct {
+	want storage.AuditRow
+}
+
+func withAudit(want storage.AuditRow) gomock.Matcher {
+	return auditMatcher{want: want}
+}
+
+func (m auditMatcher) Matches(x any) bool {
+	ctx, ok := x.(context.Context)
+	if !ok {
+		return false
+	}
+	row, ok := storage.AuditFromContext(ctx)
+
+	return ok && row == m.want
+}
+
+func (m auditMatcher) String() string {
+	return fmt.Sprintf("context with audit %+v", m.want)
+}
+
+func TestAdmin_SetOrderStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockAdmin := mock_domain.NewMockAdminStorage(ctrl)
+	mockOrder := mock_domain.NewMockOrderStorage(ctrl)
+	mockBalance := mock_domain.NewMockBalanceAdd(ctrl)
+	admin := NewAdminModel(mockAdmin, nil, nil, nil, NewOrderModel(mockOrder, nil, nil, mockBalance), nil)
+
+	// positive: статус изменен по правилам OrderStateMachine, аудит с причиной пишется в той же транзакции
+	invalidAudit := withAudit(storage.AuditRow{AdminID: 10, Action: AuditSetOrderStatus, Target: "order:3840576627",
+		Details: `{"reason":"подделка чека","status":"INVALID"}`})
+	mockOrder.EXPECT().GetOrderByNumber(invalidAudit, "3840576627").Return(storage.OrderRow{Num: "3840576627", Status: constants.OrderProcessing}, nil)
+	mockOrder.EXPECT().UpdateStatus(invalidAudit, "3840576627", []string{constants.OrderNew, constants.OrderProcessing},
+		constants.OrderInvalid, "", constants.StatusSourceAdmin).Return(nil)
+
+	status, err := admin.SetOrderStatus(ctx, 10, "3840576627", constants.OrderInvalid, nil, "подделка чека")
+	require.NoError(t, err)
+	require.Equal(t, constants.AdminOk, status)
+
+	// PROCESSED: начисление вносится на баланс вместе со статусом
+	accrual := float32(150)
+	processedAudit := withAudit(storage.AuditRow{AdminID: 10, Action: AuditSetOrderStatus, Target: "order:3840576627",
+		Details: `{"accrual":"150","reason":"ответ Accrual утерян","status":"PROCESSED"}`})
+	mockOrder.EXPECT().GetOrderByNumber(processedAudit, "3840576627").Return(storage.OrderRow{Num: "3840576627", Status: constants.OrderNew}, nil)
+	mockBalance.EXPECT().AddTransaction(processedAudit, "3840576627", float32(150), []string{constants.OrderNew, constants.OrderProcessing},
+		constants.StatusSourceAdmin).Return(nil)
+
+	status, err = admin.SetOrderStatus(ctx, 10, "3840576627", constants.OrderProcessed, &accrual, "ответ Accrual утерян")
+	require.NoError(t, err)
+	require.Equal(t, constants.AdminOk, status)
+
+	// конечный статус не меняется, транзакция с аудитом откатывается
+	mockOrder.EXPECT().GetOrderByNumber(invalidAudit, "3840576627").Return(storage.OrderRow{Num: "3840576627", Status: constants.OrderProcessed}, nil)
+	mockOrder.EXPECT().UpdateStatus(invalidAudit, "3840576627", []string{constants.OrderNew, constants.OrderProcessing},
+		constants.OrderInvalid, "", constants.StatusSourceAdmin).Return(storage.ErrTransitionRejected)
+
+	status, err = admin.SetOrderStatus(ctx, 10, "3840576627", constants.OrderInvalid, nil, "подделка чека")
+	require.Error(t, err)
+	require.Equal(t, constants.AdminTransitionRejected, status)
+
+	// PROCESSED без начисления и начисление для другого статуса
+	status, err = admin.SetOrderStatus(ctx, 10, "3840576627", constants.OrderProcessed, nil, "причина")
+	require.Error(t, err)
+	require.Equal(t, constants.AdminBadFormat, status)
+	status, err = admin.SetOrderStatus(ctx, 10, "3840576627", constants.OrderInvalid, &accrual, "причина")
+	require.Error(t, err)
+	require.Equal(t, constants.AdminBadFormat, status)
+
+	// без причины
+	status, err = admin.SetOrderStatus(ctx, 10, "3840576627", constants.OrderInvalid, nil, "")
+	require.Error(t, err)
+	require.Equal(t, constants.AdminBadFormat, status)
+
+	// неизвестный статус
+	status, err = admin.SetOrderStatus(ctx, 10, "3840576627", "DONE", nil, "причина")
+	require.Error(t, err)
+	require.Equal(t, constants.AdminBadFormat, status)
+}
+
+func TestAdmin_RecheckOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockAdmin := mock_domain.NewMockAdminStorage(ctrl)
+	mockOrder := mock_domain.NewMockOrderStorage(ctrl)
+	admin := NewAdminModel(mockAdmin, nil, nil, nil, NewOrderModel(mockOrder, nil, nil, nil), nil)
+
+	// положительный: незавершенный заказ возвращается в NEW по правилам OrderStateMachine, аудит в той же транзакции
+	recheckAudit := func(num string) gomock.Matcher {
+		return withAudit(storage.AuditRow{AdminID: 10, Action: AuditRecheckOrder, Target: "order:" + num, Details: "{}"})
+	}
+	mockOrder.EXPECT().GetOrderByNumber(recheckAudit("3840576627"), "3840576627").Return(storage.OrderRow{Num: "3840576627", Status: constants.OrderProcessing}, nil)
+	mockOrder.EXPECT().UpdateStatus(recheckAudit("3840576627"), "3840576627", []string{constants.OrderNew, constants.OrderProcessing},
+		constants.OrderNew, "", constants.StatusSourceAdmin).Return(nil)
+
+	status, err := admin.RecheckOrder(ctx, 10, "3840576627")
+	require.NoError(t, err)
+	require.Equal(t, constants.AdminOk, status)
+
+	// обработанный заказ не пересматривается
+	mockOrder.EXPECT().GetOrderByNumber(recheckAudit("2377225624"), "2377225624").Return(storage.OrderRow{Num: "2377225624", Status: constants.OrderProcessed}, nil)
+	mockOrder.EXPECT().UpdateStatus(recheckAudit("2377225624"), "2377225624", []string{constants.OrderNew, constants.OrderProcessing},
+		constants.OrderNew, "", constants.StatusSourceAdmin).Return(storage.ErrTransitionRejected)
+
+	status, err = admin.RecheckOrder(ctx, 10, "2377225624")
+	require.Error(t, err)
+	require.Equal(t, constants.AdminTransitionRejected, status)
+
+	// заказ не найден, в аудит не пишем
+	mockOrder.EXPECT().GetOrderByNumber(gomock.Any(), "12345678903").Return(storage.OrderRow{}, sql.ErrNoRows)
+
+	status, err = admin.RecheckOrder(ctx, 10, "12345678903")
+	require.Error(t, err)
+	require.Equal(t, constants.AdminNotFound, status)
+}
+
+func TestAdmin_BlockUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockAdmin := mock_domain.NewMockAdminStorage(ctrl)
+	admin := NewAdminModel(mockAdmin, nil, nil, nil, nil, nil)
+
+	mockAdmin.EXPECT().SetBlocked(ctx, int64(2), true, "мошенничество").Return(true, nil)
+	mockAdmin.EXPECT().AddAudit(ctx, int64(10), AuditBlockUser, "user:2", `{"reason":"мошенничество"}`).Return(nil)
+
+	status, err := admin.BlockUser(ctx, 10, 2, "мошенничество")
+	require.NoError(t, err)
+	require.Equal(t, constants.AdminOk, status)
+
+	mockAdmin.EXPECT().SetBlocked(ctx, int64(2), false, "").Return(true, nil)
+	mockAdmin.EXPECT().AddAudit(ctx, int64(10), AuditUnblockUser, "user:2", `{}`).Return(nil)
+
+	status, err = admin.UnblockUser(ctx, 10, 2)
+	require.NoError(t, err)
+	require.Equal(t, constants.AdminOk, status)
+
+	// блокировка без причины
+	status, err = admin.BlockUser(ctx, 10, 2, "")
+	require.Error(t, err)
+	require.Equal(t, constants.AdminBadFormat, status)
+}
@@ -0,0 +1,282 @@
+package domain
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	"github.com/dnsoftware/gophermart2/internal/storage"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+type APIKeyStorage interface {
+	CreateAPIKey(ctx context.Context, key storage.APIKeyRow) (int64, error)
+	FindAPIKey(ctx context.Context, keyID string) (storage.APIKeyRow, error)
+	APIKeyList(ctx context.Context) ([]storage.APIKeyRow, error)
+	RotateAPIKey(ctx context.Context, id int64, keyID string, keyHash string) (bool, error)
+	RevokeAPIKey(ctx context.Context, id int64) (bool, error)
+	SetSigningKey(ctx context.Context, id int64, signingKey string) (bool, error)
+}
+
+// APIKeyRequest заявка на выпуск ключа API для партнера
+type APIKeyRequest struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	RateLimit int      `json:"rate_limit"` // запросов в минуту, 0 - значение по умолчанию
+}
+
+// APIKeyItem ключ API без секретной части
+type APIKeyItem struct {
+	ID        int64    `json:"id"`
+	Name      string   `json:"name"`
+	KeyID     string   `json:"key_id"`
+	Scopes    []string `json:"scopes"`
+	RateLimit int      `json:"rate_limit"`
+	CreatedBy int64    `json:"created_by"`
+	CreatedAt string   `json:"created_at"`
+	RotatedAt string   `json:"rotated_at,omitempty"`
+	RevokedAt string   `json:"revoked_at,omitempty"`
+	Signed    bool     `json:"signed"` // запросы и ответы подписываются HMAC
+}
+
+// APIKeySecret выпущенный ключ, полное значение показывается только один раз
+type APIKeySecret struct {
+	ID  int64  `json:"id"`
+	Key string `json:"key"`
+}
+
+// APIClient партнер, аутентифицированный по ключу API
+type APIClient struct {
+	ID         int64
+	Name       string
+	Scopes     []string
+	SigningKey string // ключ подписи HMAC, пустой - подпись не используется
+}
+
+// HasScope проверка наличия права у клиента
+func (c *APIClient) HasScope(scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CreateAPIKey выпуск нового ключа API
+func (a *Admin) CreateAPIKey(ctx context.Context, adminID int64, req APIKeyRequest) (APIKeySecret, int, error) {
+	length := utf8.RuneCountInString(req.Name)
+	if length == 0 || length > constants.MaxDisplayNameLength {
+		return APIKeySecret{}, constants.APIKeyBadFormat, fmt.Errorf("неверное название ключа")
+	}
+	if len(req.Scopes) == 0 {
+		return APIKeySecret{}, constants.APIKeyBadFormat, fmt.Errorf("не указаны права ключа")
+	}
+	for _, scope := range req.Scopes {
+		if !scopeValidate(scope) {
+			return APIKeySecret{}, constants.APIKeyBadFormat, fmt.Errorf("неизвестное право %v", scope)
+		}
+	}
+	if req.RateLimit < 0 || req.RateLimit > constants.APIKeyMaxRateLimit {
+		return APIKeySecret{}, constants.APIKeyBadFormat, fmt.Errorf("неверный лимит запросов %v", req.RateLimit)
+	}
+	if req.RateLimit == 0 {
+		req.RateLimit = constants.APIKeyDefaultRateLimit
+	}
+
+	keyID, key, err := newAPIKey()
+	if err != nil {
+		return APIKeySecret{}, constants.APIKeyInternalError, err
+	}
+
+	id, err := a.apiKeys.CreateAPIKey(ctx, storage.APIKeyRow{
+		Name:      req.Name,
+		KeyID:     keyID,
+		KeyHash:   TokenHash(key),
+		Scopes:    req.Scopes,
+		RateLimit: req.RateLimit,
+		CreatedBy: adminID,
+	})
+	if err != nil {
+		return APIKeySecret{}, constants.APIKeyInternalError, err
+	}
+
+	a.audit(ctx, adminID, AuditAPIKeyCreate, apiKeyTarget(id), map[string]string{
+		"name":   req.Name,
+		"scopes": strings.Join(req.Scopes, ","),
+	})
+
+	return APIKeySecret{ID: id, Key: key}, constants.APIKeyCreated, nil
+}
+
+// APIKeys список ключей API
+func (a *Admin) APIKeys(ctx context.Context) ([]APIKeyItem, int, error) {
+	rows, err := a.apiKeys.APIKeyList(ctx)
+	if err != nil {
+		return nil, constants.APIKeyInternalError, err
+	}
+
+	items := make([]APIKeyItem, 0, len(rows))
+	for _, row := range rows {
+		item := APIKeyItem{
+			ID:        row.ID,
+			Name:      row.Name,
+			KeyID:     row.KeyID,
+			Scopes:    row.Scopes,
+			RateLimit: row.RateLimit,
+			CreatedBy: row.CreatedBy,
+			CreatedAt: row.CreatedAt.Format(time.RFC3339),
+			Signed:    row.SigningKey != "",
+		}
+		if !row.RotatedAt.IsZero() {
+			item.RotatedAt = row.RotatedAt.Format(time.RFC3339)
+		}
+		if !row.RevokedAt.IsZero() {
+			item.RevokedAt = row.RevokedAt.Format(time.RFC3339)
+		}
+
+		items = append(items, item)
+	}
+
+	return items, constants.APIKeyOk, nil
+}
+
+// RotateAPIKey перевыпуск ключа с сохранением названия, прав и лимитов
+func (a *Admin) RotateAPIKey(ctx context.Context, adminID int64, id int64) (APIKeySecret, int, error) {
+	keyID, key, err := newAPIKey()
+	if err != nil {
+		return APIKeySecret{}, constants.APIKeyInternalError, err
+	}
+
+	found, err := a.apiKeys.RotateAPIKey(ctx, id, keyID, TokenHash(key))
+	if err != nil {
+		return APIKeySecret{}, constants.APIKeyInternalError, err
+	}
+	if !found {
+		return APIKeySecret{}, constants.APIKeyNotFound, fmt.Errorf("действующий ключ %v не найден", id)
+	}
+
+	a.audit(ctx, adminID, AuditAPIKeyRotate, apiKeyTarget(id), nil)
+
+	return APIKeySecret{ID: id, Key: key}, constants.APIKeyOk, nil
+}
+
+// RevokeAPIKey отзыв ключа
+func (a *Admin) RevokeAPIKey(ctx context.Context, adminID int64, id int64) (int, error) {
+	found, err := a.apiKeys.RevokeAPIKey(ctx, id)
+	if err != nil {
+		return constants.APIKeyInternalError, err
+	}
+	if !found {
+		return constants.APIKeyNotFound, fmt.Errorf("действующий ключ %v не найден", id)
+	}
+
+	a.audit(ctx, adminID, AuditAPIKeyRevoke, apiKeyTarget(id), nil)
+
+	return constants.APIKeyOk, nil
+}
+
+// SetSigningKey установка ключа подписи HMAC для клиента, пустой ключ отключает подпись
+func (a *Admin) SetSigningKey(ctx context.Context, adminID int64, id int64, signingKey string) (int, error) {
+	if signingKey != "" && len(signingKey) < constants.MinSigningKeyLength {
+		return constants.APIKeyBadFormat, fmt.Errorf("ключ подписи короче %v символов", constants.MinSigningKeyLength)
+	}
+
+	found, err := a.apiKeys.SetSigningKey(ctx, id, signingKey)
+	if err != nil {
+		return constants.APIKeyInternalError, err
+	}
+	if !found {
+		return constants.APIKeyNotFound, fmt.Errorf("действующий ключ %v не найден", id)
+	}
+
+	a.audit(ctx, adminID, AuditAPIKeySigning, apiKeyTarget(id), map[string]string{"enabled": strconv.FormatBool(signingKey != "")})
+
+	return constants.APIKeyOk, nil
+}
+
+// rateLimiter ограничение количества запросов по ключу в минуту (фиксированное окно)
+type rateLimiter struct {
+	sync.Mutex
+	windows map[int64]*limitWindow
+}
+
+type limitWindow struct {
+	start time.Time
+	count int
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		windows: make(map[int64]*limitWindow),
+	}
+}
+
+// Allow учет запроса, false - лимит на текущую минуту исчерпан
+func (l *rateLimiter) Allow(id int64, limit int, now time.Time) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	w, ok := l.windows[id]
+	if !ok || now.Sub(w.start) >= time.Minute {
+		w = &limitWindow{start: now}
+		l.windows[id] = w
+	}
+
+	if w.count >= limit {
+		return false
+	}
+	w.count++
+
+	return true
+}
+
+// генерация ключа вида gm_<key_id>_<secret>
+// key_id хранится открыто для поиска, весь ключ - только в виде хэша
+func newAPIKey() (string, string, error) {
+	id := make([]byte, constants.APIKeyIDLength)
+	_, err := rand.Read(id)
+	if err != nil {
+		return "", "", err
+	}
+
+	secret := make([]byte, constants.APIKeySecretLength)
+	_, err = rand.Read(secret)
+	if err != nil {
+		return "", "", err
+	}
+
+	keyID := hex.EncodeToString(id)
+
+	return keyID, constants.APIKeyPrefix + "_" + keyID + "_" + hex.EncodeToString(secret), nil
+}
+
+// разбор ключа, возвращает публичный идентификатор
+func parseAPIKey(key string) (string, bool) {
+	parts := strings.Split(key, "_")
+	if len(parts) != 3 || parts[0] != constants.APIKeyPrefix || parts[1] == "" || parts[2] == "" {
+		return "", false
+	}
+
+	return parts[1], true
+}
+
+// Право должно быть одним из известных
+func scopeValidate(scope string) bool {
+	switch scope {
+	case constants.ScopeOrdersWrite, constants.ScopeWebhooks:
+		return true
+	}
+
+	return false
+}
+
+func apiKeyTarget(id int64) string {
+	return "apikey:" + strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,156 @@
+package domain
+
+import (
+	"context"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	mock_domain "github.com/dnsoftware/gophermart2/internal/gophermart/domain/mocks"
+	"github.com/dnsoftware/gophermart2/internal/storage"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdmin_CreateAPIKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockAdmin := mock_domain.NewMockAdminStorage(ctrl)
+	mockKeys := mock_domain.NewMockAPIKeyStorage(ctrl)
+	admin := NewAdminModel(mockAdmin, nil, mockKeys, nil, nil, nil)
+
+	// positive: в базу попадает только хэш ключа, лимит по умолчанию
+	var saved storage.APIKeyRow
+	mockKeys.EXPECT().CreateAPIKey(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, key storage.APIKeyRow) (int64, error) {
+		saved = key
+		return 5, nil
+	})
+	mockAdmin.EXPECT().AddAudit(ctx, int64(10), AuditAPIKeyCreate, "apikey:5", gomock.Any()).Return(nil)
+
+	secret, status, err := admin.CreateAPIKey(ctx, 10, APIKeyRequest{Name: "shop", Scopes: []string{constants.ScopeOrdersWrite}})
+	require.NoError(t, err)
+	require.Equal(t, constants.APIKeyCreated, status)
+	require.Equal(t, int64(5), secret.ID)
+	require.True(t, strings.HasPrefix(secret.Key, constants.APIKeyPrefix+"_"+saved.KeyID+"_"))
+	require.Equal(t, TokenHash(secret.Key), saved.KeyHash)
+	require.Equal(t, constants.APIKeyDefaultRateLimit, saved.RateLimit)
+	require.Equal(t, int64(10), saved.CreatedBy)
+
+	// negative
+	tests := []struct {
+		name string
+		req  APIKeyRequest
+	}{
+		{
+			name: "Пустое название",
+			req:  APIKeyRequest{Scopes: []string{constants.ScopeOrdersWrite}},
+		},
+		{
+			name: "Нет прав",
+			req:  APIKeyRequest{Name: "shop"},
+		},
+		{
+			name: "Неизвестное право",
+			req:  APIKeyRequest{Name: "shop", Scopes: []string{"users:delete"}},
+		},
+		{
+			name: "Отрицательный лимит",
+			req:  APIKeyRequest{Name: "shop", Scopes: []string{constants.ScopeOrdersWrite}, RateLimit: -1},
+		},
+	}
+
+	for _, test := range tests {
+		_, status, err = admin.CreateAPIKey(ctx, 10, test.req)
+		require.Error(t, err, test.name)
+		require.Equal(t, constants.APIKeyBadFormat, status, test.name)
+	}
+}
+
+func TestAdmin_RotateRevokeAPIKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockAdmin := mock_domain.NewMockAdminStorage(ctrl)
+	mockKeys := mock_domain.NewMockAPIKeyStorage(ctrl)
+	admin := NewAdminModel(mockAdmin, nil, mockKeys, nil, nil, nil)
+
+	// перевыпуск действующего ключа
+	mockKeys.EXPECT().RotateAPIKey(ctx, int64(5), gomock.Any(), gomock.Any()).Return(true, nil)
+	mockAdmin.EXPECT().AddAudit(ctx, int64(10), AuditAPIKeyRotate, "apikey:5", gomock.Any()).Return(nil)
+
+	secret, status, err := admin.RotateAPIKey(ctx, 10, 5)
+	require.NoError(t, err)
+	require.Equal(t, constants.APIKeyOk, status)
+	require.NotEmpty(t, secret.Key)
+
+	// ключ уже отозван
+	mockKeys.EXPECT().RotateAPIKey(ctx, int64(6), gomock.Any(), gomock.Any()).Return(false, nil)
+	_, status, err = admin.RotateAPIKey(ctx, 10, 6)
+	require.Error(t, err)
+	require.Equal(t, constants.APIKeyNotFound, status)
+
+	// отзыв
+	mockKeys.EXPECT().RevokeAPIKey(ctx, int64(5)).Return(true, nil)
+	mockAdmin.EXPECT().AddAudit(ctx, int64(10), AuditAPIKeyRevoke, "apikey:5", gomock.Any()).Return(nil)
+
+	status, err = admin.RevokeAPIKey(ctx, 10, 5)
+	require.NoError(t, err)
+	require.Equal(t, constants.APIKeyOk, status)
+
+	mockKeys.EXPECT().RevokeAPIKey(ctx, int64(6)).Return(false, nil)
+	status, err = admin.RevokeAPIKey(ctx, 10, 6)
+	require.Error(t, err)
+	require.Equal(t, constants.APIKeyNotFound, status)
+}
+
+func TestRateLimiter_Allow(t *testing.T) {
+	limiter := newRateLimiter()
+	now := time.Now()
+
+	require.True(t, limiter.Allow(1, 2, now))
+	require.True(t, limiter.Allow(1, 2, now.Add(time.Second)))
+	require.False(t, limiter.Allow(1, 2, now.Add(2*time.Second)))
+
+	// у другого ключа свой лимит
+	require.True(t, limiter.Allow(2, 2, now))
+
+	// в следующей минуте лимит восстанавливается
+	require.True(t, limiter.Allow(1, 2, now.Add(time.Minute)))
+}
+
+func TestAdmin_SetSigningKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockAdmin := mock_domain.NewMockAdminStorage(ctrl)
+	mockKeys := mock_domain.NewMockAPIKeyStorage(ctrl)
+	admin := NewAdminModel(mockAdmin, nil, mockKeys, nil, nil, nil)
+
+	// positive: установка и отключение подписи
+	mockKeys.EXPECT().SetSigningKey(ctx, int64(5), "0123456789abcdef").Return(true, nil)
+	mockKeys.EXPECT().SetSigningKey(ctx, int64(5), "").Return(true, nil)
+	mockAdmin.EXPECT().AddAudit(ctx, int64(10), AuditAPIKeySigning, "apikey:5", gomock.Any()).Return(nil).Times(2)
+
+	status, err := admin.SetSigningKey(ctx, 10, 5, "0123456789abcdef")
+	require.NoError(t, err)
+	require.Equal(t, constants.APIKeyOk, status)
+
+	status, err = admin.SetSigningKey(ctx, 10, 5, "")
+	require.NoError(t, err)
+	require.Equal(t, constants.APIKeyOk, status)
+
+	// короткий ключ
+	status, err = admin.SetSigningKey(ctx, 10, 5, "short")
+	require.Error(t, err)
+	require.Equal(t, constants.APIKeyBadFormat, status)
+
+	// ключ отозван
+	mockKeys.EXPECT().SetSigningKey(ctx, int64(6), "0123456789abcdef").Return(false, nil)
+	status, err = admin.SetSigningKey(ctx, 10, 6, "0123456789abcdef")
+	require.Error(t, err)
+	require.Equal(t, constants.APIKeyNotFound, status)
+}
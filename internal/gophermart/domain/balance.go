@@ -2,20 +2,21 @@ package domain
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/dnsoftware/gophermart2/internal/constants"
 	"github.com/dnsoftware/gophermart2/internal/storage"
 	"math"
-	"strconv"
 	"time"
 )
 
 type BalanceStorage interface {
-	SaveTransaction(ctx context.Context, orderNumber int64, amount float32) error
+	SaveTransaction(ctx context.Context, orderNumber string, amount float32, from []string, source string) error
 	GetUserBalance(ctx context.Context, userID int64) (float32, error)
 	GetUserWithdrawn(ctx context.Context, userID int64) (float32, error)
 	GetUserWithdrawList(ctx context.Context, userID int64) ([]storage.WithdrawRow, error)
-	WithdrawTransaction(ctx context.Context, userID int64, orderNumber int64, amount float32) error
+	WithdrawTransaction(ctx context.Context, userID int64, orderNumber string, amount float32) error
+	GetUserLedger(ctx context.Context, userID int64) ([]storage.BalanceRow, error)
 }
 
 type Balance struct {
@@ -33,6 +34,16 @@ type WithdrawItem struct {
 	ProcessedAt string  `json:"processed_at"`
 }
 
+// LedgerItem запись движения по балансу
+// Type - одна из операций constants.Operation*
+type LedgerItem struct {
+	Type        string  `json:"type"`
+	Order       string  `json:"order,omitempty"`
+	Amount      float32 `json:"amount"`
+	ReasonCode  string  `json:"reason_code,omitempty"`
+	ProcessedAt string  `json:"processed_at"`
+}
+
 func NewBalanceModel(storage BalanceStorage) *Balance {
 	balance := &Balance{
 		storage: storage,
@@ -42,9 +53,10 @@ func NewBalanceModel(storage BalanceStorage) *Balance {
 }
 
 // комплексное обновление данных в базе
-func (b *Balance) AddTransaction(ctx context.Context, orderNumber int64, amount float32) error {
+// from - статусы заказа, из которых разрешен переход в PROCESSED, source - кто меняет статус
+func (b *Balance) AddTransaction(ctx context.Context, orderNumber string, amount float32, from []string, source string) error {
 
-	err := b.storage.SaveTransaction(ctx, orderNumber, amount)
+	err := b.storage.SaveTransaction(ctx, orderNumber, amount, from, source)
 	if err != nil {
 		return fmt.Errorf("ошибка при сохранении начислений в базу %w", err)
 	}
@@ -78,7 +90,7 @@ func (b *Balance) UserWithrawalsList(ctx context.Context, userID int64) ([]Withd
 	var items []WithdrawItem
 	for _, val := range withdrawals {
 		item := WithdrawItem{
-			Order:       strconv.FormatInt(val.Order, 10),
+			Order:       val.Order,
 			Sum:         float32(math.Abs(float64(val.Sum))),
 			ProcessedAt: val.ProcessedAt.Format(time.RFC3339),
 		}
@@ -89,7 +101,31 @@ func (b *Balance) UserWithrawalsList(ctx context.Context, userID int64) ([]Withd
 	return items, nil
 }
 
-func (b *Balance) Withraw(ctx context.Context, userID int64, number int64, amount float32) (int, error) {
+// UserLedger все движения по балансу пользователя
+func (b *Balance) UserLedger(ctx context.Context, userID int64) ([]LedgerItem, error) {
+	rows, err := b.storage.GetUserLedger(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении движений по балансу пользователя %w", err)
+	}
+
+	items := make([]LedgerItem, 0, len(rows))
+	for _, val := range rows {
+		// у ручной корректировки заказа может не быть, тогда номер пустой
+		item := LedgerItem{
+			Type:        val.Operation,
+			Order:       val.OrderNumber,
+			Amount:      val.Amount,
+			ReasonCode:  val.ReasonCode,
+			ProcessedAt: val.ProcessedAt.Format(time.RFC3339),
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+func (b *Balance) Withraw(ctx context.Context, userID int64, number string, amount float32) (int, error) {
 	// проверка на отрицательное списание
 	if amount <= 0 {
 		return constants.WithdrawNotEnoughFunds, fmt.Errorf("симма списания не может быть нулевой или отрицательной")
@@ -109,8 +145,11 @@ func (b *Balance) Withraw(ctx context.Context, userID int64, number int64, amoun
 		return constants.WithdrawNotEnoughFunds, fmt.Errorf(fmt.Sprintf("ошибка получения баланса, запрошено %v, в наличии %v: ", amount, balance.Current))
 	}
 
-	// обработка списания
+	// обработка списания, баланс еще раз проверяется в транзакции: он мог измениться после проверки выше
 	err = b.storage.WithdrawTransaction(ctx, userID, number, amount)
+	if errors.Is(err, storage.ErrNotEnoughFunds) {
+		return constants.WithdrawNotEnoughFunds, err
+	}
 	if err != nil {
 		return constants.WithdrawInternalError, fmt.Errorf("ошибка обработки списания: " + err.Error())
 	}
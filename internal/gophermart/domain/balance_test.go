@@ -114,7 +114,7 @@ func TestBalance_UserWithrawalsList(t *testing.T) {
 	mockBalance := mock_domain.NewMockBalanceStorage(ctrl)
 	tm, _ := time.Parse(time.RFC3339, "2024-03-19T15:24:39-07:00")
 	mockBalance.EXPECT().GetUserWithdrawList(ctx, int64(1)).Return([]storage.WithdrawRow{{
-		Order:       3840576627,
+		Order:       "3840576627",
 		Sum:         111,
 		ProcessedAt: tm,
 	}}, nil)
@@ -163,7 +163,7 @@ func TestBalance_Withraw(t *testing.T) {
 	type args struct {
 		ctx    context.Context
 		userID int64
-		number int64
+		number string
 		amount float32
 	}
 
@@ -187,12 +187,12 @@ func TestBalance_Withraw(t *testing.T) {
 			prepare: func() {
 				mockBalance.EXPECT().GetUserBalance(ctx, int64(1)).Return(float32(1000), nil)
 				mockBalance.EXPECT().GetUserWithdrawn(ctx, int64(1)).Return(float32(50), nil)
-				mockBalance.EXPECT().WithdrawTransaction(ctx, int64(1), int64(3840576627), float32(729.98)).Return(nil)
+				mockBalance.EXPECT().WithdrawTransaction(ctx, int64(1), "3840576627", float32(729.98)).Return(nil)
 			},
 			args: args{
 				ctx:    ctx,
 				userID: int64(1),
-				number: 3840576627,
+				number: "3840576627",
 				amount: 729.98,
 			},
 			want:    constants.WithdrawalsOk,
@@ -204,13 +204,31 @@ func TestBalance_Withraw(t *testing.T) {
 			prepare: func() {
 				mockBalance.EXPECT().GetUserBalance(ctx, int64(1)).Return(float32(700), nil)
 				mockBalance.EXPECT().GetUserWithdrawn(ctx, int64(1)).Return(float32(50), nil)
-				mockBalance.EXPECT().WithdrawTransaction(ctx, int64(1), int64(3840576627), float32(729.98)).Return(nil).AnyTimes()
+				mockBalance.EXPECT().WithdrawTransaction(ctx, int64(1), "3840576627", float32(729.98)).Return(nil).AnyTimes()
 			},
 
 			args: args{
 				ctx:    ctx,
 				userID: int64(1),
-				number: 3840576627,
+				number: "3840576627",
+				amount: 729.98,
+			},
+			want:    constants.WithdrawNotEnoughFunds,
+			wantErr: assert.Error,
+		},
+		{
+			name:   "Balance changed concurrently",
+			fields: fields{storage: mockBalance},
+			prepare: func() {
+				mockBalance.EXPECT().GetUserBalance(ctx, int64(1)).Return(float32(1000), nil)
+				mockBalance.EXPECT().GetUserWithdrawn(ctx, int64(1)).Return(float32(50), nil)
+				mockBalance.EXPECT().WithdrawTransaction(ctx, int64(1), "2377225624", float32(729.98)).Return(fmt.Errorf("WithdrawTransaction: %w", storage.ErrNotEnoughFunds))
+			},
+
+			args: args{
+				ctx:    ctx,
+				userID: int64(1),
+				number: "2377225624",
 				amount: 729.98,
 			},
 			want:    constants.WithdrawNotEnoughFunds,
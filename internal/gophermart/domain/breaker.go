@@ -0,0 +1,103 @@
+package domain
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dnsoftware/gophermart2/internal/constants"
+)
+
+// AccrualBreakerState состояние ограничителя запросов к Accrual
+type AccrualBreakerState struct {
+	State       string    `json:"state"`
+	Failures    int       `json:"failures"` // ошибок подряд
+	OpenedAt    time.Time `json:"opened_at"`
+	RetryAt     time.Time `json:"retry_at"`
+	LastSuccess time.Time `json:"last_success"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// accrualBreaker после AccrualBreakerThreshold ошибок подряд приостанавливает запросы к Accrual
+// на AccrualBreakerCooldown, затем пропускает один пробный запрос: успех закрывает ограничитель,
+// ошибка снова приостанавливает запросы; время открытия при этом не сбрасывается
+type accrualBreaker struct {
+	mu          sync.Mutex
+	failures    int
+	openedAt    time.Time // нулевое, если ограничитель закрыт
+	retryAt     time.Time
+	lastSuccess time.Time
+	lastError   string
+	now         func() time.Time
+}
+
+func newAccrualBreaker() *accrualBreaker {
+	return &accrualBreaker{
+		now: time.Now,
+	}
+}
+
+// сколько еще ждать до следующего запроса, 0 - запрос можно отправлять
+func (b *accrualBreaker) wait() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.openedAt.IsZero() {
+		return 0
+	}
+
+	wait := b.retryAt.Sub(b.now())
+	if wait < 0 {
+		return 0
+	}
+
+	return wait
+}
+
+func (b *accrualBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.openedAt = time.Time{}
+	b.retryAt = time.Time{}
+	b.lastSuccess = b.now()
+}
+
+func (b *accrualBreaker) failure(message string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.lastError = message
+
+	if b.failures >= constants.AccrualBreakerThreshold {
+		now := b.now()
+		if b.openedAt.IsZero() {
+			b.openedAt = now
+		}
+		b.retryAt = now.Add(constants.AccrualBreakerCooldown)
+	}
+}
+
+func (b *accrualBreaker) state() AccrualBreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := AccrualBreakerState{
+		State:       constants.BreakerClosed,
+		Failures:    b.failures,
+		OpenedAt:    b.openedAt,
+		RetryAt:     b.retryAt,
+		LastSuccess: b.lastSuccess,
+		LastError:   b.lastError,
+	}
+
+	if !b.openedAt.IsZero() {
+		state.State = constants.BreakerOpen
+		if !b.now().Before(b.retryAt) {
+			state.State = constants.BreakerHalfOpen
+		}
+	}
+
+	return state
+}
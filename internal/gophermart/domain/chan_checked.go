@@ -2,47 +2,53 @@ package domain
 
 import (
 	"context"
-	"github.com/dnsoftware/gophermart2/internal/constants"
 )
 
 /* Очередь проверенных ордеров на занесение в базу */
 
 type orderData struct {
-	order   int64
-	status  string
-	accrual float32
+	order         string
+	status        string
+	accrualStatus string // статус заказа в Accrual как есть
+	accrual       float32
 }
 
 type OrdersChecked struct {
 	ordersCh chan orderData
 }
 
-func NewOrdersChecked() *OrdersChecked {
+func NewOrdersChecked(capacity int) *OrdersChecked {
 	return &OrdersChecked{
-		ordersCh: make(chan orderData, constants.OrdersChannelCapacity),
+		ordersCh: make(chan orderData, capacity),
 	}
 }
 
 // ставим в очередь для дальнейшего сохранения в базу
-func (c *OrdersChecked) Push(order int64, status string, accrual float32) {
+func (c *OrdersChecked) Push(order string, status string, accrualStatus string, accrual float32) {
 
 	o := orderData{
-		order:   order,
-		status:  status,
-		accrual: accrual,
+		order:         order,
+		status:        status,
+		accrualStatus: accrualStatus,
+		accrual:       accrual,
 	}
 
 	c.ordersCh <- o
 }
 
 // забираем из очереди для сохранения в базу
-func (c *OrdersChecked) Pop(ctx context.Context) (int64, string, float32) {
+func (c *OrdersChecked) Pop(ctx context.Context) (string, string, string, float32) {
 
 	select {
 	case <-ctx.Done():
 	case o := <-c.ordersCh:
-		return o.order, o.status, o.accrual
+		return o.order, o.status, o.accrualStatus, o.accrual
 	}
 
-	return 0, "", 0
+	return "", "", "", 0
+}
+
+// кол-во ордеров в очереди
+func (c *OrdersChecked) Len() int {
+	return len(c.ordersCh)
 }
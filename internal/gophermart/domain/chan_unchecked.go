@@ -2,33 +2,37 @@ package domain
 
 import (
 	"context"
-	"github.com/dnsoftware/gophermart2/internal/constants"
 )
 
 /* Очередь ордеров на проверку */
 
 type OrdersUnchecked struct {
-	ordersCh chan int64
+	ordersCh chan string
 }
 
-func NewOrdersUnchecked() *OrdersUnchecked {
+func NewOrdersUnchecked(capacity int) *OrdersUnchecked {
 	return &OrdersUnchecked{
-		ordersCh: make(chan int64, constants.OrdersChannelCapacity),
+		ordersCh: make(chan string, capacity),
 	}
 }
 
 // ставим в очередь для дальнейшей отправки на проверку
-func (u *OrdersUnchecked) Push(number int64) {
+func (u *OrdersUnchecked) Push(number string) {
 	u.ordersCh <- number
 }
 
 // забираем из очереди для отпарвки на проверку в Accrual
-func (u *OrdersUnchecked) Pop(ctx context.Context) int64 {
+func (u *OrdersUnchecked) Pop(ctx context.Context) string {
 	select {
 	case <-ctx.Done():
 	case o := <-u.ordersCh:
 		return o
 	}
 
-	return 0
+	return ""
+}
+
+// кол-во ордеров в очереди
+func (u *OrdersUnchecked) Len() int {
+	return len(u.ordersCh)
 }
@@ -0,0 +1,155 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	"github.com/dnsoftware/gophermart2/internal/logger"
+	"github.com/dnsoftware/gophermart2/internal/storage"
+	"strconv"
+	"time"
+)
+
+// DisputeItem спор о принадлежности заказа
+type DisputeItem struct {
+	ID         int64  `json:"id"`
+	Order      string `json:"order"`
+	UserID     int64  `json:"user_id"`
+	OwnerID    int64  `json:"owner_id,omitempty"` // пользователю владелец чужого заказа не показывается
+	Comment    string `json:"comment,omitempty"`
+	Status     string `json:"status"`
+	Resolution string `json:"resolution,omitempty"`
+	CreatedAt  string `json:"created_at"`
+	DecidedBy  int64  `json:"decided_by,omitempty"`
+	DecidedAt  string `json:"decided_at,omitempty"`
+}
+
+// OpenDispute оспаривание заказа, загруженного другим пользователем (ответ 409 при загрузке)
+// спор рассматривает поддержка через API администратора
+func (o *Order) OpenDispute(ctx context.Context, userID int64, number string, comment string) (int64, int, error) {
+	if !IsLuhnValid(number) {
+		return 0, constants.DisputeBadFormat, fmt.Errorf("неверный номер заказа")
+	}
+	if len([]rune(comment)) > constants.MaxDisputeCommentLength {
+		return 0, constants.DisputeBadFormat, fmt.Errorf("слишком длинный комментарий")
+	}
+
+	row, err := o.storage.GetOrderByNumber(ctx, number)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, constants.DisputeNoConflict, fmt.Errorf("заказ %v не загружен, его можно загрузить", number)
+	}
+	if err != nil {
+		return 0, constants.DisputeInternalError, err
+	}
+	if row.UserID == userID {
+		return 0, constants.DisputeNoConflict, fmt.Errorf("заказ %v уже принадлежит пользователю", number)
+	}
+
+	id, status, err := o.storage.CreateDispute(ctx, storage.DisputeRow{
+		OrderNumber: number,
+		UserID:      userID,
+		OwnerID:     row.UserID,
+		Comment:     comment,
+	})
+	if err != nil {
+		return 0, status, err
+	}
+
+	logger.Log().WithContext(ctx).Info(fmt.Sprintf("Пользователь %v оспорил заказ %v, спор %v", userID, number, id))
+
+	return id, status, nil
+}
+
+// Disputes споры, открытые пользователем
+func (o *Order) Disputes(ctx context.Context, userID int64) ([]DisputeItem, int, error) {
+	rows, err := o.storage.DisputeList(ctx, userID, "")
+	if err != nil {
+		return nil, constants.DisputeInternalError, err
+	}
+
+	items := make([]DisputeItem, 0, len(rows))
+	for _, row := range rows {
+		item := disputeItem(row)
+		item.OwnerID = 0
+		item.DecidedBy = 0
+
+		items = append(items, item)
+	}
+
+	return items, constants.DisputeOk, nil
+}
+
+// Disputes споры о принадлежности заказов в заданном статусе (пустой - все)
+func (a *Admin) Disputes(ctx context.Context, status string) ([]DisputeItem, int, error) {
+	rows, err := a.orders.storage.DisputeList(ctx, 0, status)
+	if err != nil {
+		return nil, constants.DisputeInternalError, err
+	}
+
+	items := make([]DisputeItem, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, disputeItem(row))
+	}
+
+	return items, constants.DisputeOk, nil
+}
+
+// ApproveDispute удовлетворение спора: заказ передается заявителю
+func (a *Admin) ApproveDispute(ctx context.Context, adminID int64, id int64, resolution string) (int, error) {
+	return a.resolveDispute(ctx, adminID, id, true, resolution)
+}
+
+// RejectDispute отклонение спора, причина обязательна
+func (a *Admin) RejectDispute(ctx context.Context, adminID int64, id int64, resolution string) (int, error) {
+	if resolution == "" {
+		return constants.DisputeBadFormat, fmt.Errorf("не указана причина отклонения")
+	}
+
+	return a.resolveDispute(ctx, adminID, id, false, resolution)
+}
+
+func (a *Admin) resolveDispute(ctx context.Context, adminID int64, id int64, approve bool, resolution string) (int, error) {
+	d, status, err := a.orders.storage.ResolveDispute(ctx, id, adminID, approve, resolution)
+	if err != nil {
+		return status, err
+	}
+
+	action := AuditDisputeReject
+	details := map[string]string{"order": d.OrderNumber, "user_id": strconv.FormatInt(d.UserID, 10)}
+	if approve {
+		action = AuditDisputeApprove
+		details["from_user_id"] = strconv.FormatInt(d.OwnerID, 10)
+	}
+	if resolution != "" {
+		details["resolution"] = resolution
+	}
+
+	a.audit(ctx, adminID, action, disputeTarget(id), details)
+
+	return status, nil
+}
+
+func disputeItem(row storage.DisputeRow) DisputeItem {
+	item := DisputeItem{
+		ID:         row.ID,
+		Order:      row.OrderNumber,
+		UserID:     row.UserID,
+		OwnerID:    row.OwnerID,
+		Comment:    row.Comment,
+		Status:     row.Status,
+		Resolution: row.Resolution,
+		CreatedAt:  row.CreatedAt.Format(time.RFC3339),
+		DecidedBy:  row.DecidedBy,
+	}
+	if !row.DecidedAt.IsZero() {
+		item.DecidedAt = row.DecidedAt.Format(time.RFC3339)
+	}
+
+	return item
+}
+
+func disputeTarget(id int64) string {
+	return "dispute:" + strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,163 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	mock_domain "github.com/dnsoftware/gophermart2/internal/gophermart/domain/mocks"
+	"github.com/dnsoftware/gophermart2/internal/storage"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestOrder_CancelOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockOrder := mock_domain.NewMockOrderStorage(ctrl)
+	order := NewOrderModel(mockOrder, nil, nil, nil)
+
+	// positive: новый заказ удаляется
+	mockOrder.EXPECT().GetOrderByNumber(ctx, "3840576627").Return(storage.OrderRow{UserID: 1, Num: "3840576627", Status: constants.OrderNew}, nil)
+	mockOrder.EXPECT().Delete(ctx, int64(1), "3840576627", []string{constants.OrderNew}).Return(true, nil)
+
+	status, err := order.CancelOrder(ctx, 1, "3840576627")
+	require.NoError(t, err)
+	require.Equal(t, constants.OrderCancelOk, status)
+
+	// заказ уже в обработке
+	mockOrder.EXPECT().GetOrderByNumber(ctx, "12345678903").Return(storage.OrderRow{UserID: 1, Num: "12345678903", Status: constants.OrderProcessing}, nil)
+
+	status, err = order.CancelOrder(ctx, 1, "12345678903")
+	require.Error(t, err)
+	require.Equal(t, constants.OrderCancelNotAllowed, status)
+
+	// статус сменился между проверкой и удалением
+	mockOrder.EXPECT().GetOrderByNumber(ctx, "79927398713").Return(storage.OrderRow{UserID: 1, Num: "79927398713", Status: constants.OrderNew}, nil)
+	mockOrder.EXPECT().Delete(ctx, int64(1), "79927398713", []string{constants.OrderNew}).Return(false, nil)
+
+	status, err = order.CancelOrder(ctx, 1, "79927398713")
+	require.Error(t, err)
+	require.Equal(t, constants.OrderCancelNotAllowed, status)
+
+	// чужой заказ для пользователя не существует
+	mockOrder.EXPECT().GetOrderByNumber(ctx, "3840576627").Return(storage.OrderRow{UserID: 2, Num: "3840576627", Status: constants.OrderNew}, nil)
+
+	status, err = order.CancelOrder(ctx, 1, "3840576627")
+	require.Error(t, err)
+	require.Equal(t, constants.OrderCancelNotFound, status)
+}
+
+func TestOrder_OpenDispute(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockOrder := mock_domain.NewMockOrderStorage(ctrl)
+	order := NewOrderModel(mockOrder, nil, nil, nil)
+
+	// positive: заказ загружен другим пользователем
+	mockOrder.EXPECT().GetOrderByNumber(ctx, "3840576627").Return(storage.OrderRow{UserID: 2, Num: "3840576627"}, nil)
+	mockOrder.EXPECT().CreateDispute(ctx, storage.DisputeRow{
+		OrderNumber: "3840576627",
+		UserID:      1,
+		OwnerID:     2,
+		Comment:     "чек на мое имя",
+	}).Return(int64(5), constants.DisputeCreated, nil)
+
+	id, status, err := order.OpenDispute(ctx, 1, "3840576627", "чек на мое имя")
+	require.NoError(t, err)
+	require.Equal(t, constants.DisputeCreated, status)
+	require.Equal(t, int64(5), id)
+
+	// свой заказ оспаривать нечего
+	mockOrder.EXPECT().GetOrderByNumber(ctx, "3840576627").Return(storage.OrderRow{UserID: 1, Num: "3840576627"}, nil)
+
+	_, status, err = order.OpenDispute(ctx, 1, "3840576627", "")
+	require.Error(t, err)
+	require.Equal(t, constants.DisputeNoConflict, status)
+
+	// незагруженный заказ оспаривать нечего
+	mockOrder.EXPECT().GetOrderByNumber(ctx, "79927398713").Return(storage.OrderRow{}, fmt.Errorf("GetOrder Scan: %w", sql.ErrNoRows))
+
+	_, status, err = order.OpenDispute(ctx, 1, "79927398713", "")
+	require.Error(t, err)
+	require.Equal(t, constants.DisputeNoConflict, status)
+
+	// неверный номер
+	_, status, err = order.OpenDispute(ctx, 1, "1234", "")
+	require.Error(t, err)
+	require.Equal(t, constants.DisputeBadFormat, status)
+}
+
+func TestOrder_Disputes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockOrder := mock_domain.NewMockOrderStorage(ctrl)
+	order := NewOrderModel(mockOrder, nil, nil, nil)
+
+	created := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	mockOrder.EXPECT().DisputeList(ctx, int64(1), "").Return([]storage.DisputeRow{{
+		ID: 5, OrderNumber: "3840576627", UserID: 1, OwnerID: 2, Status: constants.DisputeRejected,
+		Resolution: "чек выписан на другое лицо", CreatedAt: created, DecidedBy: 10, DecidedAt: created.Add(time.Hour),
+	}}, nil)
+
+	list, status, err := order.Disputes(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, constants.DisputeOk, status)
+	require.Len(t, list, 1)
+
+	// владелец заказа и сотрудник поддержки пользователю не показываются
+	require.Equal(t, int64(0), list[0].OwnerID)
+	require.Equal(t, int64(0), list[0].DecidedBy)
+	require.Equal(t, "2024-01-10T13:00:00Z", list[0].DecidedAt)
+}
+
+func TestAdmin_ResolveDispute(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockAdmin := mock_domain.NewMockAdminStorage(ctrl)
+	mockOrder := mock_domain.NewMockOrderStorage(ctrl)
+	admin := NewAdminModel(mockAdmin, nil, nil, nil, NewOrderModel(mockOrder, nil, nil, nil), nil)
+
+	// positive: заказ передан заявителю
+	mockOrder.EXPECT().ResolveDispute(ctx, int64(5), int64(10), true, "").
+		Return(storage.DisputeRow{ID: 5, OrderNumber: "3840576627", UserID: 1, OwnerID: 2, Status: constants.DisputeApproved}, constants.DisputeOk, nil)
+	mockAdmin.EXPECT().AddAudit(ctx, int64(10), AuditDisputeApprove, "dispute:5",
+		`{"from_user_id":"2","order":"3840576627","user_id":"1"}`).Return(nil)
+
+	status, err := admin.ApproveDispute(ctx, 10, 5, "")
+	require.NoError(t, err)
+	require.Equal(t, constants.DisputeOk, status)
+
+	// баллы уже начислены владельцу - аудит не пишется
+	mockOrder.EXPECT().ResolveDispute(ctx, int64(6), int64(10), true, "").
+		Return(storage.DisputeRow{ID: 6}, constants.DisputeOrderProcessed, fmt.Errorf("баллы за заказ уже начислены"))
+
+	status, err = admin.ApproveDispute(ctx, 10, 6, "")
+	require.Error(t, err)
+	require.Equal(t, constants.DisputeOrderProcessed, status)
+
+	// отклонение без причины
+	status, err = admin.RejectDispute(ctx, 10, 5, "")
+	require.Error(t, err)
+	require.Equal(t, constants.DisputeBadFormat, status)
+
+	// отклонение
+	mockOrder.EXPECT().ResolveDispute(ctx, int64(7), int64(10), false, "чек на другое лицо").
+		Return(storage.DisputeRow{ID: 7, OrderNumber: "3840576627", UserID: 1, OwnerID: 2, Status: constants.DisputeRejected}, constants.DisputeOk, nil)
+	mockAdmin.EXPECT().AddAudit(ctx, int64(10), AuditDisputeReject, "dispute:7",
+		`{"order":"3840576627","resolution":"чек на другое лицо","user_id":"1"}`).Return(nil)
+
+	status, err = admin.RejectDispute(ctx, 10, 7, "чек на другое лицо")
+	require.NoError(t, err)
+	require.Equal(t, constants.DisputeOk, status)
+}
@@ -0,0 +1,216 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	"github.com/dnsoftware/gophermart2/internal/logger"
+	"github.com/dnsoftware/gophermart2/internal/storage"
+	"time"
+)
+
+type OutboxStorage interface {
+	PendingEvents(ctx context.Context, sink string, limit int) ([]storage.OutboxRow, error)
+	MarkHandled(ctx context.Context, sink string, eventID int64) error
+	PurgeOutbox(ctx context.Context, before time.Time, sinks []string) (int64, error)
+}
+
+// BrokerPublisher публикация сообщений в брокер (NATS)
+type BrokerPublisher interface {
+	Publish(ctx context.Context, subject string, data []byte) error
+}
+
+// DomainEvent доменное событие из очереди
+type DomainEvent struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      DomainEventData `json:"data"`
+}
+
+// DomainEventData данные события, конкретный тип определяется DomainEvent.Type
+type DomainEventData interface {
+	EventType() string
+}
+
+// UserRegistered зарегистрирован пользователь
+type UserRegistered struct {
+	UserID int64  `json:"user_id"`
+	Login  string `json:"login"`
+}
+
+// OrderUploaded загружен новый заказ
+type OrderUploaded struct {
+	UserID int64  `json:"user_id"`
+	Login  string `json:"login"`
+	Order  string `json:"order"`
+}
+
+// OrderStatusChanged изменился статус заказа
+type OrderStatusChanged struct {
+	UserID  int64   `json:"user_id"`
+	Login   string  `json:"login"`
+	Order   string  `json:"order"`
+	Status  string  `json:"status"`
+	Accrual float32 `json:"accrual,omitempty"` // для PROCESSED
+}
+
+// OrderCancelled пользователь удалил заказ, еще не принятый в обработку
+type OrderCancelled struct {
+	UserID int64  `json:"user_id"`
+	Login  string `json:"login"`
+	Order  string `json:"order"`
+}
+
+// PointsAccrued начислены баллы за заказ
+type PointsAccrued struct {
+	UserID int64   `json:"user_id"`
+	Login  string  `json:"login"`
+	Order  string  `json:"order"`
+	Amount float32 `json:"amount"`
+}
+
+// PointsWithdrawn пользователь списал баллы
+type PointsWithdrawn struct {
+	UserID int64   `json:"user_id"`
+	Login  string  `json:"login"`
+	Order  string  `json:"order"`
+	Amount float32 `json:"amount"`
+}
+
+// PointsAdjusted подтверждена ручная корректировка баланса
+// Amount положительный для начислений и отрицательный для списаний
+type PointsAdjusted struct {
+	UserID int64   `json:"user_id"`
+	Login  string  `json:"login"`
+	Order  string  `json:"order,omitempty"`
+	Amount float32 `json:"amount"`
+}
+
+func (UserRegistered) EventType() string     { return constants.EventUserRegistered }
+func (OrderUploaded) EventType() string      { return constants.EventOrderUploaded }
+func (OrderStatusChanged) EventType() string { return constants.EventOrderStatusChanged }
+func (OrderCancelled) EventType() string     { return constants.EventOrderCancelled }
+func (PointsAccrued) EventType() string      { return constants.EventPointsAccrued }
+func (PointsWithdrawn) EventType() string    { return constants.EventPointsWithdrawn }
+func (PointsAdjusted) EventType() string     { return constants.EventPointsAdjusted }
+
+// EventBus рассылка доменных событий из очереди (transactional outbox) получателям
+// события пишутся в очередь в одной транзакции с изменением; каждый получатель обрабатывает их
+// по порядку и независимо от других, событие считается обработанным только после успешного Handle
+type EventBus struct {
+	storage OutboxStorage
+	sinks   []EventSink
+}
+
+func NewEventBus(storage OutboxStorage, sinks ...EventSink) *EventBus {
+	bus := &EventBus{
+		storage: storage,
+		sinks:   sinks,
+	}
+
+	return bus
+}
+
+// StartDispatcher служба рассылки событий получателям и очистки очереди
+func (b *EventBus) StartDispatcher(ctx context.Context) {
+	ticker := time.NewTicker(constants.EventBusPollPeriod)
+	defer ticker.Stop()
+
+	purgeTicker := time.NewTicker(constants.EventBusPurgePeriod)
+	defer purgeTicker.Stop()
+
+	for {
+		for _, sink := range b.sinks {
+			b.dispatch(ctx, sink)
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Log().WithContext(ctx).Info("EventBusDispatcher DONE!!!")
+			return
+		case <-purgeTicker.C:
+			b.purge(ctx)
+		case <-ticker.C:
+		}
+	}
+}
+
+// передача получателю необработанных им событий
+// при ошибке рассылка получателю останавливается до следующего прохода, чтобы не нарушить порядок
+func (b *EventBus) dispatch(ctx context.Context, sink EventSink) {
+	for ctx.Err() == nil {
+		rows, err := b.storage.PendingEvents(ctx, sink.Name(), constants.EventBusBatchSize)
+		if err != nil {
+			logger.Log().WithContext(ctx).Error("PendingEvents: " + err.Error())
+			return
+		}
+
+		for _, row := range rows {
+			event, err := domainEvent(row)
+			if err != nil { // неизвестное событие повторная обработка не исправит
+				logger.Log().WithContext(ctx).Error(err.Error())
+			} else if err = sink.Handle(ctx, event); err != nil {
+				logger.Log().WithContext(ctx).Error(fmt.Sprintf("Получатель %v не обработал событие %v: %v", sink.Name(), row.ID, err.Error()))
+				return
+			}
+
+			if err = b.storage.MarkHandled(ctx, sink.Name(), row.ID); err != nil {
+				logger.Log().WithContext(ctx).Error("MarkHandled: " + err.Error())
+				return
+			}
+		}
+
+		if len(rows) < constants.EventBusBatchSize {
+			return
+		}
+	}
+}
+
+// удаление устаревших событий, которые обработаны всеми получателями
+func (b *EventBus) purge(ctx context.Context) {
+	sinks := make([]string, 0, len(b.sinks))
+	for _, sink := range b.sinks {
+		sinks = append(sinks, sink.Name())
+	}
+
+	count, err := b.storage.PurgeOutbox(ctx, time.Now().Add(-constants.EventBusRetention), sinks)
+	if err != nil {
+		logger.Log().WithContext(ctx).Error("PurgeOutbox: " + err.Error())
+		return
+	}
+
+	if count > 0 {
+		logger.Log().WithContext(ctx).Info(fmt.Sprintf("Удалено устаревших доменных событий: %v", count))
+	}
+}
+
+func domainEvent(row storage.OutboxRow) (DomainEvent, error) {
+	var data DomainEventData
+
+	switch row.Type {
+	case constants.EventUserRegistered:
+		data = UserRegistered{UserID: row.UserID, Login: row.Login}
+	case constants.EventOrderUploaded:
+		data = OrderUploaded{UserID: row.UserID, Login: row.Login, Order: row.OrderNumber}
+	case constants.EventOrderStatusChanged:
+		data = OrderStatusChanged{UserID: row.UserID, Login: row.Login, Order: row.OrderNumber, Status: row.Status, Accrual: row.Amount}
+	case constants.EventOrderCancelled:
+		data = OrderCancelled{UserID: row.UserID, Login: row.Login, Order: row.OrderNumber}
+	case constants.EventPointsAccrued:
+		data = PointsAccrued{UserID: row.UserID, Login: row.Login, Order: row.OrderNumber, Amount: row.Amount}
+	case constants.EventPointsWithdrawn:
+		data = PointsWithdrawn{UserID: row.UserID, Login: row.Login, Order: row.OrderNumber, Amount: row.Amount}
+	case constants.EventPointsAdjusted:
+		data = PointsAdjusted{UserID: row.UserID, Login: row.Login, Order: row.OrderNumber, Amount: row.Amount}
+	default:
+		return DomainEvent{}, fmt.Errorf("неизвестный тип доменного события %v (%v)", row.Type, row.ID)
+	}
+
+	return DomainEvent{
+		ID:        row.ID,
+		Type:      row.Type,
+		CreatedAt: row.CreatedAt,
+		Data:      data,
+	}, nil
+}
@@ -0,0 +1,166 @@
+package domain
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	mock_domain "github.com/dnsoftware/gophermart2/internal/gophermart/domain/mocks"
+	"github.com/dnsoftware/gophermart2/internal/storage"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+// получатель, запоминающий события; на событии failID возвращает ошибку
+type testSink struct {
+	events []DomainEvent
+	failID int64
+}
+
+func (s *testSink) Name() string {
+	return "test"
+}
+
+func (s *testSink) Handle(ctx context.Context, event DomainEvent) error {
+	if event.ID == s.failID {
+		return fmt.Errorf("sink error")
+	}
+	s.events = append(s.events, event)
+
+	return nil
+}
+
+func TestEventBus_dispatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockOutbox := mock_domain.NewMockOutboxStorage(ctrl)
+	sink := &testSink{}
+	bus := NewEventBus(mockOutbox, sink)
+
+	rows := []storage.OutboxRow{
+		{ID: 1, Type: constants.EventUserRegistered, UserID: 2, Login: "user"},
+		{ID: 2, Type: "order.deleted", UserID: 2, Login: "user"},
+		{ID: 3, Type: constants.EventOrderUploaded, UserID: 2, Login: "user", OrderNumber: "12345678903"},
+	}
+
+	// события передаются по порядку, неизвестное отмечается обработанным без передачи
+	gomock.InOrder(
+		mockOutbox.EXPECT().PendingEvents(ctx, "test", constants.EventBusBatchSize).Return(rows, nil),
+		mockOutbox.EXPECT().MarkHandled(ctx, "test", int64(1)).Return(nil),
+		mockOutbox.EXPECT().MarkHandled(ctx, "test", int64(2)).Return(nil),
+		mockOutbox.EXPECT().MarkHandled(ctx, "test", int64(3)).Return(nil),
+	)
+
+	bus.dispatch(ctx, sink)
+	require.Len(t, sink.events, 2)
+	require.Equal(t, UserRegistered{UserID: 2, Login: "user"}, sink.events[0].Data)
+	require.Equal(t, OrderUploaded{UserID: 2, Login: "user", Order: "12345678903"}, sink.events[1].Data)
+
+	// ошибка получателя - рассылка останавливается, следующие события не передаются
+	sink = &testSink{failID: 1}
+	mockOutbox.EXPECT().PendingEvents(ctx, "test", constants.EventBusBatchSize).Return(rows, nil)
+
+	bus.dispatch(ctx, sink)
+	require.Len(t, sink.events, 0)
+}
+
+func TestEventBus_purge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockOutbox := mock_domain.NewMockOutboxStorage(ctrl)
+	bus := NewEventBus(mockOutbox, &testSink{}, NewLogSink(&bytes.Buffer{}))
+
+	// удаляются только события, обработанные всеми получателями
+	mockOutbox.EXPECT().PurgeOutbox(ctx, gomock.Any(), []string{"test", "log"}).Return(int64(3), nil)
+
+	bus.purge(ctx)
+}
+
+func Test_domainEvent(t *testing.T) {
+	created := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	event, err := domainEvent(storage.OutboxRow{ID: 5, Type: constants.EventOrderStatusChanged, UserID: 2, Login: "user",
+		OrderNumber: "12345678903", Status: constants.OrderProcessed, Amount: 500, CreatedAt: created})
+	require.NoError(t, err)
+	require.Equal(t, DomainEvent{
+		ID:        5,
+		Type:      constants.EventOrderStatusChanged,
+		CreatedAt: created,
+		Data:      OrderStatusChanged{UserID: 2, Login: "user", Order: "12345678903", Status: constants.OrderProcessed, Accrual: 500},
+	}, event)
+
+	event, err = domainEvent(storage.OutboxRow{ID: 6, Type: constants.EventPointsWithdrawn, UserID: 2, OrderNumber: "2377225624", Amount: 100})
+	require.NoError(t, err)
+	require.Equal(t, PointsWithdrawn{UserID: 2, Order: "2377225624", Amount: 100}, event.Data)
+
+	_, err = domainEvent(storage.OutboxRow{ID: 7, Type: "order.deleted"})
+	require.Error(t, err)
+}
+
+func TestEventSinks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	event := DomainEvent{
+		ID:        8,
+		Type:      constants.EventPointsAccrued,
+		CreatedAt: time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC),
+		Data:      PointsAccrued{UserID: 2, Login: "user", Order: "12345678903", Amount: 500},
+	}
+	expected := `{"id":8,"type":"points.accrued","created_at":"2024-01-10T12:00:00Z","data":{"user_id":2,"login":"user","order":"12345678903","amount":500}}`
+
+	// журнал
+	var buf bytes.Buffer
+	logSink := NewLogSink(&buf)
+	require.NoError(t, logSink.Handle(ctx, event))
+	require.NoError(t, logSink.Handle(ctx, event))
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+	require.JSONEq(t, expected, lines[0])
+
+	// брокер
+	mockPublisher := mock_domain.NewMockBrokerPublisher(ctrl)
+	brokerSink := NewBrokerSink(mockPublisher, constants.NatsSubjectPrefix)
+	mockPublisher.EXPECT().Publish(ctx, "gophermart.points.accrued", gomock.Any()).DoAndReturn(
+		func(ctx context.Context, subject string, data []byte) error {
+			require.True(t, json.Valid(data))
+			require.JSONEq(t, expected, string(data))
+			return nil
+		})
+	require.NoError(t, brokerSink.Handle(ctx, event))
+
+	mockPublisher.EXPECT().Publish(ctx, "gophermart.points.accrued", gomock.Any()).Return(fmt.Errorf("no connection"))
+	require.Error(t, brokerSink.Handle(ctx, event))
+}
+
+func TestWebhooks_Handle(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockWebhook := mock_domain.NewMockWebhookStorage(ctrl)
+	webhooks := NewWebhooksModel(mockWebhook)
+
+	mockWebhook.EXPECT().EnqueueDeliveries(ctx, int64(1), constants.WebhookOrderProcessed).Return(nil)
+	require.NoError(t, webhooks.Handle(ctx, DomainEvent{ID: 1, Data: PointsAccrued{Order: "12345678903", Amount: 500}}))
+
+	mockWebhook.EXPECT().EnqueueDeliveries(ctx, int64(2), constants.WebhookOrderInvalid).Return(nil)
+	require.NoError(t, webhooks.Handle(ctx, DomainEvent{ID: 2, Data: OrderStatusChanged{Order: "12345678903", Status: constants.OrderInvalid}}))
+
+	mockWebhook.EXPECT().EnqueueDeliveries(ctx, int64(3), constants.WebhookPointsWithdrawn).Return(fmt.Errorf("db error"))
+	require.Error(t, webhooks.Handle(ctx, DomainEvent{ID: 3, Data: PointsWithdrawn{Order: "2377225624", Amount: 100}}))
+
+	// остальные события партнерам не передаются
+	require.NoError(t, webhooks.Handle(ctx, DomainEvent{ID: 4, Data: OrderStatusChanged{Order: "12345678903", Status: constants.OrderProcessing}}))
+	require.NoError(t, webhooks.Handle(ctx, DomainEvent{ID: 5, Data: OrderUploaded{Order: "12345678903"}}))
+	require.NoError(t, webhooks.Handle(ctx, DomainEvent{ID: 6, Data: UserRegistered{Login: "user"}}))
+}
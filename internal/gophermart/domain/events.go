@@ -0,0 +1,226 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	"github.com/dnsoftware/gophermart2/internal/logger"
+	"github.com/dnsoftware/gophermart2/internal/storage"
+	"sync"
+	"time"
+)
+
+type EventStorage interface {
+	UserEvents(ctx context.Context, userID int64, afterID int64, eventTypes []string, limit int) ([]storage.OutboxRow, error)
+	LastUserEventID(ctx context.Context, userID int64, eventTypes []string) (int64, error)
+}
+
+// доменные события, о которых сообщается клиентам пользователя
+var userEventSources = []string{
+	constants.EventOrderStatusChanged,
+	constants.EventOrderCancelled,
+	constants.EventPointsAccrued,
+	constants.EventPointsWithdrawn,
+	constants.EventPointsAdjusted,
+}
+
+// UserEvent событие пользователя для доставки клиенту
+// ID совпадает с ID доменного события, по нему клиент продолжает поток (Last-Event-ID)
+type UserEvent struct {
+	ID        int64
+	Type      string // constants.EventType*
+	Data      string // JSON
+	CreatedAt time.Time
+}
+
+// OrderEventData смена статуса заказа
+type OrderEventData struct {
+	Number  string  `json:"number"`
+	Status  string  `json:"status"`
+	Accrual float32 `json:"accrual,omitempty"`
+}
+
+// BalanceEventData изменение баланса
+// Amount положительный для начислений и отрицательный для списаний
+type BalanceEventData struct {
+	Operation string  `json:"operation"`
+	Order     string  `json:"order,omitempty"`
+	Amount    float32 `json:"amount"`
+}
+
+// Events рассылка событий пользователей подключенным клиентам
+// получает доменные события от EventBus, т.е. только после фиксации изменения в базе;
+// пропущенные события отключившийся клиент дочитывает из очереди доменных событий
+type Events struct {
+	storage EventStorage
+
+	mu     sync.Mutex
+	subs   map[int64]map[chan UserEvent]struct{} // подписчики по ID пользователя
+	closed bool                                  // сервер останавливается, новые подписки сразу закрываются
+}
+
+func NewEventsModel(storage EventStorage) *Events {
+	events := &Events{
+		storage: storage,
+		subs:    make(map[int64]map[chan UserEvent]struct{}),
+	}
+
+	return events
+}
+
+func (e *Events) Name() string {
+	return "events"
+}
+
+// Handle рассылка события подписчикам пользователя
+// подписчик, не успевающий забирать события, отключается: он переподключится и дочитает пропущенное
+func (e *Events) Handle(ctx context.Context, event DomainEvent) error {
+	userID, userEvent, ok, err := toUserEvent(event)
+	if err != nil || !ok {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for ch := range e.subs[userID] {
+		select {
+		case ch <- userEvent:
+		default:
+			logger.Log().WithContext(ctx).Info(fmt.Sprintf("Подписчик пользователя %v не успевает забирать события, отключен", userID))
+			e.remove(userID, ch)
+		}
+	}
+
+	return nil
+}
+
+// Subscribe подписка на события пользователя
+// канал закрывается при отписке или при переполнении
+func (e *Events) Subscribe(userID int64) (<-chan UserEvent, func()) {
+	ch := make(chan UserEvent, constants.EventsSubscriberBuffer)
+
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		close(ch)
+		return ch, func() {}
+	}
+	if e.subs[userID] == nil {
+		e.subs[userID] = make(map[chan UserEvent]struct{})
+	}
+	e.subs[userID][ch] = struct{}{}
+	e.mu.Unlock()
+
+	unsubscribe := func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+
+		e.remove(userID, ch)
+	}
+
+	return ch, unsubscribe
+}
+
+// Close отключение всех подписчиков при остановке сервера
+// иначе открытые потоки событий не дадут HTTP серверу завершиться
+func (e *Events) Close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.closed = true
+	for userID, subs := range e.subs {
+		for ch := range subs {
+			e.remove(userID, ch)
+		}
+	}
+}
+
+// Replay события пользователя после afterID, не более constants.EventsReplayLimit
+func (e *Events) Replay(ctx context.Context, userID int64, afterID int64) ([]UserEvent, error) {
+	rows, err := e.storage.UserEvents(ctx, userID, afterID, userEventSources, constants.EventsReplayLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]UserEvent, 0, len(rows))
+	for _, row := range rows {
+		event, err := domainEvent(row)
+		if err != nil {
+			return nil, err
+		}
+
+		_, userEvent, ok, err := toUserEvent(event)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			events = append(events, userEvent)
+		}
+	}
+
+	return events, nil
+}
+
+// LastID ID последнего события пользователя; с него начинает поток клиент, которому не нужны прошлые события
+func (e *Events) LastID(ctx context.Context, userID int64) (int64, error) {
+	return e.storage.LastUserEventID(ctx, userID, userEventSources)
+}
+
+// удаление подписчика, вызывается под e.mu
+func (e *Events) remove(userID int64, ch chan UserEvent) {
+	if _, ok := e.subs[userID][ch]; !ok {
+		return
+	}
+
+	delete(e.subs[userID], ch)
+	if len(e.subs[userID]) == 0 {
+		delete(e.subs, userID)
+	}
+	close(ch)
+}
+
+// событие пользователя по доменному событию, ok = false - о таком событии клиентам не сообщается
+func toUserEvent(event DomainEvent) (int64, UserEvent, bool, error) {
+	var userID int64
+	var eventType string
+	var data any
+
+	switch d := event.Data.(type) {
+	case OrderStatusChanged:
+		e := OrderEventData{Number: d.Order, Status: d.Status}
+		if d.Status == constants.OrderProcessed {
+			e.Accrual = d.Accrual
+		}
+		userID, eventType, data = d.UserID, constants.EventTypeOrder, e
+	case OrderCancelled:
+		userID, eventType = d.UserID, constants.EventTypeOrder
+		data = OrderEventData{Number: d.Order, Status: constants.OrderCancelled}
+	case PointsAccrued:
+		userID, eventType = d.UserID, constants.EventTypeBalance
+		data = BalanceEventData{Operation: constants.OperationAccrual, Order: d.Order, Amount: d.Amount}
+	case PointsWithdrawn:
+		userID, eventType = d.UserID, constants.EventTypeBalance
+		data = BalanceEventData{Operation: constants.OperationWithdrawal, Order: d.Order, Amount: -d.Amount}
+	case PointsAdjusted:
+		userID, eventType = d.UserID, constants.EventTypeBalance
+		data = BalanceEventData{Operation: constants.OperationAdjustment, Order: d.Order, Amount: d.Amount}
+	default:
+		return 0, UserEvent{}, false, nil
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return 0, UserEvent{}, false, fmt.Errorf("toUserEvent | Marshal: %w", err)
+	}
+
+	userEvent := UserEvent{
+		ID:        event.ID,
+		Type:      eventType,
+		Data:      string(payload),
+		CreatedAt: event.CreatedAt,
+	}
+
+	return userID, userEvent, true, nil
+}
@@ -0,0 +1,127 @@
+package domain
+
+import (
+	"context"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	mock_domain "github.com/dnsoftware/gophermart2/internal/gophermart/domain/mocks"
+	"github.com/dnsoftware/gophermart2/internal/storage"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestEvents_Handle(t *testing.T) {
+	ctx := context.Background()
+	events := NewEventsModel(nil)
+
+	own, unsubscribe := events.Subscribe(1)
+	defer unsubscribe()
+	other, unsubscribeOther := events.Subscribe(2)
+	defer unsubscribeOther()
+
+	err := events.Handle(ctx, DomainEvent{
+		ID:   7,
+		Type: constants.EventOrderStatusChanged,
+		Data: OrderStatusChanged{UserID: 1, Order: "3840576627", Status: constants.OrderProcessing},
+	})
+	require.NoError(t, err)
+
+	// о загрузке заказа клиентам не сообщается
+	err = events.Handle(ctx, DomainEvent{ID: 8, Type: constants.EventOrderUploaded, Data: OrderUploaded{UserID: 1, Order: "3840576627"}})
+	require.NoError(t, err)
+
+	// событие получает только подписчик владельца, ID события совпадает с ID доменного события
+	event := <-own
+	require.Equal(t, int64(7), event.ID)
+	require.Equal(t, constants.EventTypeOrder, event.Type)
+	require.JSONEq(t, `{"number":"3840576627","status":"PROCESSING"}`, event.Data)
+	require.Len(t, own, 0)
+	require.Len(t, other, 0)
+}
+
+func TestEvents_SlowSubscriber(t *testing.T) {
+	ctx := context.Background()
+	events := NewEventsModel(nil)
+
+	ch, unsubscribe := events.Subscribe(1)
+
+	// очередь подписчика переполнена - он отключается, а не задерживает рассылку
+	for i := 0; i <= constants.EventsSubscriberBuffer; i++ {
+		err := events.Handle(ctx, DomainEvent{
+			ID:   int64(i + 1),
+			Type: constants.EventPointsAccrued,
+			Data: PointsAccrued{UserID: 1, Order: "3840576627", Amount: 10},
+		})
+		require.NoError(t, err)
+	}
+
+	for i := 0; i < constants.EventsSubscriberBuffer; i++ {
+		<-ch
+	}
+	_, ok := <-ch
+	require.False(t, ok, "канал отключенного подписчика должен быть закрыт")
+
+	// повторная отписка безопасна
+	unsubscribe()
+}
+
+func TestEvents_Replay(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockEvents := mock_domain.NewMockEventStorage(ctrl)
+	events := NewEventsModel(mockEvents)
+
+	mockEvents.EXPECT().UserEvents(ctx, int64(1), int64(5), userEventSources, constants.EventsReplayLimit).Return([]storage.OutboxRow{
+		{ID: 6, Type: constants.EventOrderStatusChanged, UserID: 1, OrderNumber: "3840576627", Status: constants.OrderProcessed, Amount: 500},
+		{ID: 9, Type: constants.EventPointsWithdrawn, UserID: 1, OrderNumber: "2377225624", Amount: 100},
+		{ID: 12, Type: constants.EventOrderCancelled, UserID: 1, OrderNumber: "12345678903"},
+		{ID: 14, Type: constants.EventPointsAdjusted, UserID: 1, Amount: -20},
+	}, nil)
+
+	list, err := events.Replay(ctx, 1, 5)
+	require.NoError(t, err)
+	require.Len(t, list, 4)
+	require.Equal(t, constants.EventTypeOrder, list[0].Type)
+	require.JSONEq(t, `{"number":"3840576627","status":"PROCESSED","accrual":500}`, list[0].Data)
+	require.Equal(t, int64(9), list[1].ID)
+	require.Equal(t, constants.EventTypeBalance, list[1].Type)
+	require.JSONEq(t, `{"operation":"withdrawal","order":"2377225624","amount":-100}`, list[1].Data)
+	// удаленный пользователем заказ пропадает из списка у клиентов
+	require.Equal(t, constants.EventTypeOrder, list[2].Type)
+	require.JSONEq(t, `{"number":"12345678903","status":"CANCELLED"}`, list[2].Data)
+	require.Equal(t, constants.EventTypeBalance, list[3].Type)
+	require.JSONEq(t, `{"operation":"adjustment","amount":-20}`, list[3].Data)
+}
+
+func TestEvents_LastID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockEvents := mock_domain.NewMockEventStorage(ctrl)
+	events := NewEventsModel(mockEvents)
+
+	mockEvents.EXPECT().LastUserEventID(ctx, int64(1), userEventSources).Return(int64(42), nil)
+
+	id, err := events.LastID(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, int64(42), id)
+}
+
+func TestEvents_Close(t *testing.T) {
+	events := NewEventsModel(nil)
+
+	ch, unsubscribe := events.Subscribe(1)
+	defer unsubscribe()
+
+	events.Close()
+	_, ok := <-ch
+	require.False(t, ok)
+
+	// после остановки новые подписки сразу закрыты
+	ch, _ = events.Subscribe(1)
+	_, ok = <-ch
+	require.False(t, ok)
+}
@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// EventSink получатель доменных событий
+// событие может быть передано повторно (например, после сбоя), поэтому Handle должен быть идемпотентным
+type EventSink interface {
+	Name() string // по имени учитываются обработанные события, менять его нельзя
+	Handle(ctx context.Context, event DomainEvent) error
+}
+
+// LogSink запись доменных событий в журнал, по одному JSON на строку
+type LogSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewLogSink(w io.Writer) *LogSink {
+	return &LogSink{
+		w: w,
+	}
+}
+
+func (s *LogSink) Name() string {
+	return "log"
+}
+
+func (s *LogSink) Handle(ctx context.Context, event DomainEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("LogSink | Marshal: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.w.Write(append(line, '\n'))
+
+	return err
+}
+
+// BrokerSink публикация доменных событий в брокер, тема <prefix>.<тип события>
+type BrokerSink struct {
+	publisher BrokerPublisher
+	prefix    string
+}
+
+func NewBrokerSink(publisher BrokerPublisher, prefix string) *BrokerSink {
+	return &BrokerSink{
+		publisher: publisher,
+		prefix:    prefix,
+	}
+}
+
+func (s *BrokerSink) Name() string {
+	return "broker"
+}
+
+func (s *BrokerSink) Handle(ctx context.Context, event DomainEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("BrokerSink | Marshal: %w", err)
+	}
+
+	return s.publisher.Publish(ctx, s.prefix+"."+event.Type, data)
+}
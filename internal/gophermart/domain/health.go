@@ -0,0 +1,216 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dnsoftware/gophermart2/internal/constants"
+)
+
+type HealthStorage interface {
+	Ping(ctx context.Context) error
+	SchemaVersion(ctx context.Context) (int, error)
+	Stats() sql.DBStats
+}
+
+// ReadyReport результат проверки готовности: пустая строка - проверка пройдена, иначе причина
+type ReadyReport struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]string `json:"checks"`
+}
+
+// WorkerStatus состояние фоновой службы
+type WorkerStatus struct {
+	Name      string    `json:"name"`
+	Running   bool      `json:"running"`
+	StartedAt time.Time `json:"started_at"`
+	StoppedAt time.Time `json:"stopped_at"`
+}
+
+// DebugStatus подробное состояние сервиса для эксплуатации
+type DebugStatus struct {
+	Ready         ReadyReport         `json:"readiness"`
+	StartedAt     time.Time           `json:"started_at"`
+	Uptime        string              `json:"uptime"`
+	ShuttingDown  bool                `json:"shutting_down"`
+	GoVersion     string              `json:"go_version"`
+	Goroutines    int                 `json:"goroutines"`
+	SchemaVersion int                 `json:"schema_version"`
+	SchemaWanted  int                 `json:"schema_version_required"`
+	DB            DBPoolStatus        `json:"db_pool"`
+	Accrual       AccrualBreakerState `json:"accrual_breaker"`
+	Queues        map[string]int      `json:"queues"`
+	Workers       []WorkerStatus      `json:"workers"`
+}
+
+// DBPoolStatus статистика пула соединений с БД
+type DBPoolStatus struct {
+	MaxOpen      int    `json:"max_open"`
+	Open         int    `json:"open"`
+	InUse        int    `json:"in_use"`
+	Idle         int    `json:"idle"`
+	WaitCount    int64  `json:"wait_count"`
+	WaitDuration string `json:"wait_duration"`
+}
+
+// Health проверки живости и готовности сервиса
+type Health struct {
+	storage   HealthStorage
+	accrual   *Accrual
+	unchecked *OrdersUnchecked
+	checked   *OrdersChecked
+	startedAt time.Time
+
+	mu      sync.Mutex
+	workers map[string]*WorkerStatus
+
+	shuttingDown atomic.Bool
+}
+
+func NewHealthModel(storage HealthStorage, accrual *Accrual, unchecked *OrdersUnchecked, checked *OrdersChecked) *Health {
+	return &Health{
+		storage:   storage,
+		accrual:   accrual,
+		unchecked: unchecked,
+		checked:   checked,
+		startedAt: time.Now(),
+		workers:   make(map[string]*WorkerStatus),
+	}
+}
+
+// Worker регистрация запущенной фоновой службы, возвращает функцию, которую служба вызывает при остановке
+// вызывается до запуска горутины, чтобы служба считалась работающей с самого начала
+func (h *Health) Worker(name string) func() {
+	h.mu.Lock()
+	h.workers[name] = &WorkerStatus{Name: name, Running: true, StartedAt: time.Now()}
+	h.mu.Unlock()
+
+	return func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		h.workers[name].Running = false
+		h.workers[name].StoppedAt = time.Now()
+	}
+}
+
+// Shutdown начало завершения работы: с этого момента сервис не готов принимать запросы
+func (h *Health) Shutdown() {
+	h.shuttingDown.Store(true)
+}
+
+// Ready проверка готовности: БД доступна, структура БД актуальна, Accrual не недоступен слишком долго,
+// фоновые службы работают и сервис не завершает работу
+func (h *Health) Ready(ctx context.Context) ReadyReport {
+	report, _ := h.ready(ctx)
+
+	return report
+}
+
+// Status подробное состояние сервиса
+func (h *Health) Status(ctx context.Context) DebugStatus {
+	report, version := h.ready(ctx)
+	stats := h.storage.Stats()
+
+	status := DebugStatus{
+		Ready:         report,
+		StartedAt:     h.startedAt,
+		Uptime:        time.Since(h.startedAt).Round(time.Second).String(),
+		ShuttingDown:  h.shuttingDown.Load(),
+		GoVersion:     runtime.Version(),
+		Goroutines:    runtime.NumGoroutine(),
+		SchemaVersion: version,
+		SchemaWanted:  constants.SchemaVersion,
+		DB: DBPoolStatus{
+			MaxOpen:      stats.MaxOpenConnections,
+			Open:         stats.OpenConnections,
+			InUse:        stats.InUse,
+			Idle:         stats.Idle,
+			WaitCount:    stats.WaitCount,
+			WaitDuration: stats.WaitDuration.String(),
+		},
+		Accrual: h.accrual.Breaker(),
+		Queues: map[string]int{
+			constants.QueueUnchecked: h.unchecked.Len(),
+			constants.QueueChecked:   h.checked.Len(),
+		},
+		Workers: h.workerList(),
+	}
+
+	return status
+}
+
+// проверки готовности, дополнительно возвращает версию структуры БД
+func (h *Health) ready(ctx context.Context) (ReadyReport, int) {
+	ctx, cancel := context.WithTimeout(ctx, constants.HealthCheckTimeout)
+	defer cancel()
+
+	checks := map[string]string{
+		"database":   "",
+		"migrations": "",
+		"accrual":    "",
+		"workers":    "",
+		"shutdown":   "",
+	}
+
+	version := 0
+	if err := h.storage.Ping(ctx); err != nil {
+		checks["database"] = err.Error()
+		checks["migrations"] = "БД недоступна"
+	} else {
+		v, err := h.storage.SchemaVersion(ctx)
+		version = v
+		switch {
+		case err != nil:
+			checks["migrations"] = err.Error()
+		case v < constants.SchemaVersion:
+			checks["migrations"] = fmt.Sprintf("версия структуры БД %v, требуется %v", v, constants.SchemaVersion)
+		}
+	}
+
+	breaker := h.accrual.Breaker()
+	if breaker.State != constants.BreakerClosed && time.Since(breaker.OpenedAt) > constants.AccrualBreakerMaxOpen {
+		checks["accrual"] = fmt.Sprintf("Accrual недоступен с %v: %v", breaker.OpenedAt.Format(time.RFC3339), breaker.LastError)
+	}
+
+	for _, w := range h.workerList() {
+		if !w.Running {
+			checks["workers"] = fmt.Sprintf("служба %v остановлена", w.Name)
+			break
+		}
+	}
+
+	if h.shuttingDown.Load() {
+		checks["shutdown"] = "сервис завершает работу"
+	}
+
+	ready := true
+	for name, result := range checks {
+		if result == "" {
+			checks[name] = "ok"
+		} else {
+			ready = false
+		}
+	}
+
+	return ReadyReport{Ready: ready, Checks: checks}, version
+}
+
+func (h *Health) workerList() []WorkerStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	list := make([]WorkerStatus, 0, len(h.workers))
+	for _, w := range h.workers {
+		list = append(list, *w)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	return list
+}
@@ -0,0 +1,119 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	mock_domain "github.com/dnsoftware/gophermart2/internal/gophermart/domain/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccrualBreaker(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	b := newAccrualBreaker()
+	b.now = func() time.Time { return now }
+
+	// отдельные ошибки запросы не останавливают
+	for i := 1; i < constants.AccrualBreakerThreshold; i++ {
+		b.failure("connection refused")
+	}
+	require.Equal(t, time.Duration(0), b.wait())
+	require.Equal(t, constants.BreakerClosed, b.state().State)
+
+	// ошибки подряд - пауза
+	b.failure("connection refused")
+	require.Equal(t, constants.AccrualBreakerCooldown, b.wait())
+	require.Equal(t, constants.BreakerOpen, b.state().State)
+	require.Equal(t, now, b.state().OpenedAt)
+
+	// после паузы - пробный запрос; ошибка продлевает паузу, время открытия сохраняется
+	opened := now
+	now = now.Add(constants.AccrualBreakerCooldown)
+	require.Equal(t, time.Duration(0), b.wait())
+	require.Equal(t, constants.BreakerHalfOpen, b.state().State)
+
+	b.failure("service unavailable")
+	require.Equal(t, constants.AccrualBreakerCooldown, b.wait())
+	require.Equal(t, opened, b.state().OpenedAt)
+	require.Equal(t, "service unavailable", b.state().LastError)
+
+	// успешный запрос закрывает
+	now = now.Add(constants.AccrualBreakerCooldown)
+	b.success()
+	state := b.state()
+	require.Equal(t, constants.BreakerClosed, state.State)
+	require.Equal(t, 0, state.Failures)
+	require.True(t, state.OpenedAt.IsZero())
+	require.Equal(t, now, state.LastSuccess)
+}
+
+func TestHealth_Ready(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockHealth := mock_domain.NewMockHealthStorage(ctrl)
+	accrual := NewAccrualModel(nil, nil, nil, constants.AccrualServiceQueryLimit)
+	health := NewHealthModel(mockHealth, accrual, NewOrdersUnchecked(constants.OrdersChannelCapacity), NewOrdersChecked(constants.OrdersChannelCapacity))
+	stopped := health.Worker(constants.WorkerAccrualChecker)
+
+	// все проверки пройдены
+	mockHealth.EXPECT().Ping(gomock.Any()).Return(nil)
+	mockHealth.EXPECT().SchemaVersion(gomock.Any()).Return(constants.SchemaVersion, nil)
+
+	report := health.Ready(ctx)
+	require.True(t, report.Ready)
+	require.Equal(t, "ok", report.Checks["database"])
+
+	// БД недоступна
+	mockHealth.EXPECT().Ping(gomock.Any()).Return(fmt.Errorf("connection refused"))
+
+	report = health.Ready(ctx)
+	require.False(t, report.Ready)
+	require.Equal(t, "connection refused", report.Checks["database"])
+
+	// структура БД не обновлена
+	mockHealth.EXPECT().Ping(gomock.Any()).Return(nil)
+	mockHealth.EXPECT().SchemaVersion(gomock.Any()).Return(0, nil)
+
+	report = health.Ready(ctx)
+	require.False(t, report.Ready)
+	require.NotEqual(t, "ok", report.Checks["migrations"])
+	require.Equal(t, "ok", report.Checks["database"])
+
+	// Accrual недоступен дольше допустимого
+	accrual.breaker.now = func() time.Time { return time.Now().Add(-constants.AccrualBreakerMaxOpen - time.Minute) }
+	for i := 0; i < constants.AccrualBreakerThreshold; i++ {
+		accrual.breaker.failure("connection refused")
+	}
+	accrual.breaker.now = time.Now
+	mockHealth.EXPECT().Ping(gomock.Any()).Return(nil)
+	mockHealth.EXPECT().SchemaVersion(gomock.Any()).Return(constants.SchemaVersion, nil)
+
+	report = health.Ready(ctx)
+	require.False(t, report.Ready)
+	require.NotEqual(t, "ok", report.Checks["accrual"])
+	accrual.breaker.success()
+
+	// служба остановилась, сервис завершает работу
+	stopped()
+	health.Shutdown()
+	mockHealth.EXPECT().Ping(gomock.Any()).Return(nil)
+	mockHealth.EXPECT().SchemaVersion(gomock.Any()).Return(constants.SchemaVersion, nil)
+	mockHealth.EXPECT().Stats().Return(sql.DBStats{OpenConnections: 2, InUse: 1, Idle: 1})
+
+	status := health.Status(ctx)
+	require.False(t, status.Ready.Ready)
+	require.NotEqual(t, "ok", status.Ready.Checks["workers"])
+	require.NotEqual(t, "ok", status.Ready.Checks["shutdown"])
+	require.True(t, status.ShuttingDown)
+	require.Equal(t, 2, status.DB.Open)
+	require.Len(t, status.Workers, 1)
+	require.False(t, status.Workers[0].Running)
+	require.Equal(t, constants.BreakerClosed, status.Accrual.State)
+}
@@ -1,34 +1,60 @@
 package domain
 
 import (
+	"fmt"
 	"github.com/dnsoftware/gophermart2/internal/constants"
 	"github.com/golang-jwt/jwt/v4"
 	"time"
 )
 
+// AuthSettings параметры подписи токенов и хэширования паролей
+type AuthSettings struct {
+	SecretKey        string
+	PasswordSalt     string
+	TokenTTL         time.Duration
+	PasswordResetTTL time.Duration
+}
+
+// по умолчанию - значения из constants, при старте заменяются параметрами из конфигурации
+var authSettings = AuthSettings{
+	SecretKey:        constants.SecretKey,
+	PasswordSalt:     constants.PasswordSalt,
+	TokenTTL:         constants.TokenExp,
+	PasswordResetTTL: constants.PasswordResetTokenExp,
+}
+
+// SetAuthSettings замена параметров авторизации, вызывается при старте до обработки запросов
+func SetAuthSettings(settings AuthSettings) {
+	authSettings = settings
+}
+
 // Claims — структура утверждений, которая включает стандартные утверждения
-// и одно пользовательское — UserID
+// и пользовательские — UserID и Role
 type Claims struct {
 	jwt.RegisteredClaims
 	UserID int64
+	Role   string
 }
 
 // BuildJWTString создаёт токен и возвращает его в виде строки.
-// передаем ID пользователя
-func BuildJWTString(userID int64) (string, error) {
+// передаем ID и роль пользователя
+func BuildJWTString(userID int64, role string) (string, error) {
 	// создаём новый токен с алгоритмом подписи HS256 и утверждениями — Claims
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			// когда создан токен
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(constants.TokenExp)),
+			IssuedAt: jwt.NewNumericDate(time.Now()),
+			// когда истекает токен
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(authSettings.TokenTTL)),
 		},
 
-		// собственное утверждение
+		// собственные утверждения
 		UserID: userID,
+		Role:   role,
 	})
 
 	// создаём строку токена
-	tokenString, err := token.SignedString([]byte(constants.SecretKey))
+	tokenString, err := token.SignedString([]byte(authSettings.SecretKey))
 	if err != nil {
 		return "", err
 	}
@@ -39,18 +65,28 @@ func BuildJWTString(userID int64) (string, error) {
 
 // Получение UserID из токена
 func GetUserID(tokenString string) int64 {
+	claims, err := GetClaims(tokenString)
+	if err != nil {
+		return -1
+	}
+
+	return claims.UserID
+}
+
+// GetClaims разбор и проверка подписи токена
+func GetClaims(tokenString string) (*Claims, error) {
 	claims := &Claims{}
 	token, err := jwt.ParseWithClaims(tokenString, claims,
 		func(t *jwt.Token) (interface{}, error) {
-			return []byte(constants.SecretKey), nil
+			return []byte(authSettings.SecretKey), nil
 		})
 	if err != nil {
-		return -1
+		return nil, err
 	}
 
 	if !token.Valid {
-		return -1
+		return nil, fmt.Errorf("невалидный токен")
 	}
 
-	return claims.UserID
+	return claims, nil
 }
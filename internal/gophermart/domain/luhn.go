@@ -1,37 +1,48 @@
 package domain
 
 // CalculateLuhn return the check number
-func CalculateLuhn(number int64) int64 {
-	checkNumber := checksum(number)
+// number - строка из цифр без контрольной цифры; -1, если в строке есть не цифры
+func CalculateLuhn(number string) int {
+	checkNumber := checksum(number, true)
+	if checkNumber < 0 {
+		return -1
+	}
 
 	if checkNumber == 0 {
 		return 0
 	}
-	return int64(10) - checkNumber
+	return 10 - checkNumber
 }
 
-// Valid проверка что число - валидное по алгоритму Луна
-func IsLuhnValid(number int64) bool {
-	ten := int64(10)
+// Valid проверка что строка - непустое число произвольной длины, валидное по алгоритму Луна
+func IsLuhnValid(number string) bool {
+	if number == "" {
+		return false
+	}
 
-	return (number%ten+checksum(number/ten))%ten == int64(0)
+	return checksum(number, false) == 0
 }
 
-func checksum(number int64) int64 {
-	var luhn int64
-	ten := int64(10)
-	for i := int64(0); number > int64(0); i++ {
-		cur := number % ten
+// сумма Луна по модулю 10 (-1, если в строке есть не цифры)
+// doubleLast - удваивать последнюю цифру (число без контрольной цифры)
+func checksum(number string, doubleLast bool) int {
+	var luhn int
+
+	for i := 0; i < len(number); i++ {
+		c := number[len(number)-1-i]
+		if c < '0' || c > '9' {
+			return -1
+		}
+		cur := int(c - '0')
 
-		if i%int64(2) == int64(0) { // even
-			cur = cur * int64(2)
-			if cur > int64(9) {
-				cur = cur%ten + cur/ten
+		if (i%2 == 0) == doubleLast {
+			cur = cur * 2
+			if cur > 9 {
+				cur = cur%10 + cur/10
 			}
 		}
 
 		luhn += cur
-		number = number / 10
 	}
 	return luhn % 10
 }
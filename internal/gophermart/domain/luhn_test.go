@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestIsLuhnValid(t *testing.T) {
+	tests := []struct {
+		number string
+		want   bool
+	}{
+		{"3840576627", true},
+		{"79927398713", true},
+		{"79927398710", false},
+		{"12345678903", true},
+		{"1234", false},
+		{"", false},
+		{"12a4", false},
+		// номера длиннее int64
+		{"123456789012345678906", true},
+		{"1234567890123456789012345678909", true},
+		{"1234567890123456789012345678901", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.number, func(t *testing.T) {
+			require.Equal(t, tt.want, IsLuhnValid(tt.number))
+		})
+	}
+}
+
+func TestCalculateLuhn(t *testing.T) {
+	require.Equal(t, 3, CalculateLuhn("7992739871"))
+	require.Equal(t, 6, CalculateLuhn("12345678901234567890"))
+	require.Equal(t, -1, CalculateLuhn("12a4"))
+}
@@ -0,0 +1,81 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/gophermart/domain/account.go
+
+// Package mock_domain is a generated GoMock package.
+package mock_domain
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockAccountStorage is a mock of AccountStorage interface.
+type MockAccountStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockAccountStorageMockRecorder
+}
+
+// MockAccountStorageMockRecorder is the mock recorder for MockAccountStorage.
+type MockAccountStorageMockRecorder struct {
+	mock *MockAccountStorage
+}
+
+// NewMockAccountStorage creates a new mock instance.
+func NewMockAccountStorage(ctrl *gomock.Controller) *MockAccountStorage {
+	mock := &MockAccountStorage{ctrl: ctrl}
+	mock.recorder = &MockAccountStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAccountStorage) EXPECT() *MockAccountStorageMockRecorder {
+	return m.recorder
+}
+
+// AnonymizeExpired mocks base method.
+func (m *MockAccountStorage) AnonymizeExpired(ctx context.Context, requestedBefore time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AnonymizeExpired", ctx, requestedBefore)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AnonymizeExpired indicates an expected call of AnonymizeExpired.
+func (mr *MockAccountStorageMockRecorder) AnonymizeExpired(ctx, requestedBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AnonymizeExpired", reflect.TypeOf((*MockAccountStorage)(nil).AnonymizeExpired), ctx, requestedBefore)
+}
+
+// CancelDeletion mocks base method.
+func (m *MockAccountStorage) CancelDeletion(ctx context.Context, userID int64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelDeletion", ctx, userID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CancelDeletion indicates an expected call of CancelDeletion.
+func (mr *MockAccountStorageMockRecorder) CancelDeletion(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelDeletion", reflect.TypeOf((*MockAccountStorage)(nil).CancelDeletion), ctx, userID)
+}
+
+// RequestDeletion mocks base method.
+func (m *MockAccountStorage) RequestDeletion(ctx context.Context, userID int64) (time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequestDeletion", ctx, userID)
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RequestDeletion indicates an expected call of RequestDeletion.
+func (mr *MockAccountStorageMockRecorder) RequestDeletion(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestDeletion", reflect.TypeOf((*MockAccountStorage)(nil).RequestDeletion), ctx, userID)
+}
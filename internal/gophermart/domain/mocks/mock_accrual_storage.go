@@ -36,9 +36,9 @@ func (m *MockAccrualStorage) EXPECT() *MockAccrualStorageMockRecorder {
 }
 
 // GetOrder mocks base method.
-func (m *MockAccrualStorage) GetOrder(orderNum int64) (*storage.AccrualRow, int, error) {
+func (m *MockAccrualStorage) GetOrder(ctx context.Context, orderNum string) (*storage.AccrualRow, int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetOrder", orderNum)
+	ret := m.ctrl.Call(m, "GetOrder", ctx, orderNum)
 	ret0, _ := ret[0].(*storage.AccrualRow)
 	ret1, _ := ret[1].(int)
 	ret2, _ := ret[2].(error)
@@ -46,9 +46,9 @@ func (m *MockAccrualStorage) GetOrder(orderNum int64) (*storage.AccrualRow, int,
 }
 
 // GetOrder indicates an expected call of GetOrder.
-func (mr *MockAccrualStorageMockRecorder) GetOrder(orderNum interface{}) *gomock.Call {
+func (mr *MockAccrualStorageMockRecorder) GetOrder(ctx, orderNum interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrder", reflect.TypeOf((*MockAccrualStorage)(nil).GetOrder), orderNum)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrder", reflect.TypeOf((*MockAccrualStorage)(nil).GetOrder), ctx, orderNum)
 }
 
 // MockUnchecked is a mock of Unchecked interface.
@@ -75,10 +75,10 @@ func (m *MockUnchecked) EXPECT() *MockUncheckedMockRecorder {
 }
 
 // Pop mocks base method.
-func (m *MockUnchecked) Pop(ctx context.Context) int64 {
+func (m *MockUnchecked) Pop(ctx context.Context) string {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Pop", ctx)
-	ret0, _ := ret[0].(int64)
+	ret0, _ := ret[0].(string)
 	return ret0
 }
 
@@ -112,13 +112,13 @@ func (m *MockChecked) EXPECT() *MockCheckedMockRecorder {
 }
 
 // Push mocks base method.
-func (m *MockChecked) Push(order int64, status string, accrual float32) {
+func (m *MockChecked) Push(order, status, accrualStatus string, accrual float32) {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "Push", order, status, accrual)
+	m.ctrl.Call(m, "Push", order, status, accrualStatus, accrual)
 }
 
 // Push indicates an expected call of Push.
-func (mr *MockCheckedMockRecorder) Push(order, status, accrual interface{}) *gomock.Call {
+func (mr *MockCheckedMockRecorder) Push(order, status, accrualStatus, accrual interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Push", reflect.TypeOf((*MockChecked)(nil).Push), order, status, accrual)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Push", reflect.TypeOf((*MockChecked)(nil).Push), order, status, accrualStatus, accrual)
 }
@@ -0,0 +1,96 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/gophermart/domain/adjustment.go
+
+// Package mock_domain is a generated GoMock package.
+package mock_domain
+
+import (
+	context "context"
+	reflect "reflect"
+
+	storage "github.com/dnsoftware/gophermart2/internal/storage"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockAdjustmentStorage is a mock of AdjustmentStorage interface.
+type MockAdjustmentStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockAdjustmentStorageMockRecorder
+}
+
+// MockAdjustmentStorageMockRecorder is the mock recorder for MockAdjustmentStorage.
+type MockAdjustmentStorageMockRecorder struct {
+	mock *MockAdjustmentStorage
+}
+
+// NewMockAdjustmentStorage creates a new mock instance.
+func NewMockAdjustmentStorage(ctrl *gomock.Controller) *MockAdjustmentStorage {
+	mock := &MockAdjustmentStorage{ctrl: ctrl}
+	mock.recorder = &MockAdjustmentStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAdjustmentStorage) EXPECT() *MockAdjustmentStorageMockRecorder {
+	return m.recorder
+}
+
+// AdjustmentList mocks base method.
+func (m *MockAdjustmentStorage) AdjustmentList(ctx context.Context, status string) ([]storage.AdjustmentRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdjustmentList", ctx, status)
+	ret0, _ := ret[0].([]storage.AdjustmentRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdjustmentList indicates an expected call of AdjustmentList.
+func (mr *MockAdjustmentStorageMockRecorder) AdjustmentList(ctx, status interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdjustmentList", reflect.TypeOf((*MockAdjustmentStorage)(nil).AdjustmentList), ctx, status)
+}
+
+// ApproveAdjustment mocks base method.
+func (m *MockAdjustmentStorage) ApproveAdjustment(ctx context.Context, id, approverID int64) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ApproveAdjustment", ctx, id, approverID)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ApproveAdjustment indicates an expected call of ApproveAdjustment.
+func (mr *MockAdjustmentStorageMockRecorder) ApproveAdjustment(ctx, id, approverID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApproveAdjustment", reflect.TypeOf((*MockAdjustmentStorage)(nil).ApproveAdjustment), ctx, id, approverID)
+}
+
+// CreateAdjustment mocks base method.
+func (m *MockAdjustmentStorage) CreateAdjustment(ctx context.Context, adj storage.AdjustmentRow) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAdjustment", ctx, adj)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAdjustment indicates an expected call of CreateAdjustment.
+func (mr *MockAdjustmentStorageMockRecorder) CreateAdjustment(ctx, adj interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAdjustment", reflect.TypeOf((*MockAdjustmentStorage)(nil).CreateAdjustment), ctx, adj)
+}
+
+// RejectAdjustment mocks base method.
+func (m *MockAdjustmentStorage) RejectAdjustment(ctx context.Context, id, approverID int64) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RejectAdjustment", ctx, id, approverID)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RejectAdjustment indicates an expected call of RejectAdjustment.
+func (mr *MockAdjustmentStorageMockRecorder) RejectAdjustment(ctx, id, approverID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RejectAdjustment", reflect.TypeOf((*MockAdjustmentStorage)(nil).RejectAdjustment), ctx, id, approverID)
+}
@@ -0,0 +1,95 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/gophermart/domain/admin.go
+
+// Package mock_domain is a generated GoMock package.
+package mock_domain
+
+import (
+	context "context"
+	reflect "reflect"
+
+	storage "github.com/dnsoftware/gophermart2/internal/storage"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockAdminStorage is a mock of AdminStorage interface.
+type MockAdminStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockAdminStorageMockRecorder
+}
+
+// MockAdminStorageMockRecorder is the mock recorder for MockAdminStorage.
+type MockAdminStorageMockRecorder struct {
+	mock *MockAdminStorage
+}
+
+// NewMockAdminStorage creates a new mock instance.
+func NewMockAdminStorage(ctrl *gomock.Controller) *MockAdminStorage {
+	mock := &MockAdminStorage{ctrl: ctrl}
+	mock.recorder = &MockAdminStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAdminStorage) EXPECT() *MockAdminStorageMockRecorder {
+	return m.recorder
+}
+
+// AddAudit mocks base method.
+func (m *MockAdminStorage) AddAudit(ctx context.Context, adminID int64, action, target, details string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddAudit", ctx, adminID, action, target, details)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddAudit indicates an expected call of AddAudit.
+func (mr *MockAdminStorageMockRecorder) AddAudit(ctx, adminID, action, target, details interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAudit", reflect.TypeOf((*MockAdminStorage)(nil).AddAudit), ctx, adminID, action, target, details)
+}
+
+// AuditList mocks base method.
+func (m *MockAdminStorage) AuditList(ctx context.Context, limit int) ([]storage.AuditRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuditList", ctx, limit)
+	ret0, _ := ret[0].([]storage.AuditRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AuditList indicates an expected call of AuditList.
+func (mr *MockAdminStorageMockRecorder) AuditList(ctx, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuditList", reflect.TypeOf((*MockAdminStorage)(nil).AuditList), ctx, limit)
+}
+
+// SearchUsers mocks base method.
+func (m *MockAdminStorage) SearchUsers(ctx context.Context, login string, limit int) ([]storage.UserRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchUsers", ctx, login, limit)
+	ret0, _ := ret[0].([]storage.UserRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchUsers indicates an expected call of SearchUsers.
+func (mr *MockAdminStorageMockRecorder) SearchUsers(ctx, login, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchUsers", reflect.TypeOf((*MockAdminStorage)(nil).SearchUsers), ctx, login, limit)
+}
+
+// SetBlocked mocks base method.
+func (m *MockAdminStorage) SetBlocked(ctx context.Context, userID int64, blocked bool, reason string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetBlocked", ctx, userID, blocked, reason)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetBlocked indicates an expected call of SetBlocked.
+func (mr *MockAdminStorageMockRecorder) SetBlocked(ctx, userID, blocked, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetBlocked", reflect.TypeOf((*MockAdminStorage)(nil).SetBlocked), ctx, userID, blocked, reason)
+}
@@ -0,0 +1,126 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/gophermart/domain/apikey.go
+
+// Package mock_domain is a generated GoMock package.
+package mock_domain
+
+import (
+	context "context"
+	reflect "reflect"
+
+	storage "github.com/dnsoftware/gophermart2/internal/storage"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockAPIKeyStorage is a mock of APIKeyStorage interface.
+type MockAPIKeyStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockAPIKeyStorageMockRecorder
+}
+
+// MockAPIKeyStorageMockRecorder is the mock recorder for MockAPIKeyStorage.
+type MockAPIKeyStorageMockRecorder struct {
+	mock *MockAPIKeyStorage
+}
+
+// NewMockAPIKeyStorage creates a new mock instance.
+func NewMockAPIKeyStorage(ctrl *gomock.Controller) *MockAPIKeyStorage {
+	mock := &MockAPIKeyStorage{ctrl: ctrl}
+	mock.recorder = &MockAPIKeyStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAPIKeyStorage) EXPECT() *MockAPIKeyStorageMockRecorder {
+	return m.recorder
+}
+
+// APIKeyList mocks base method.
+func (m *MockAPIKeyStorage) APIKeyList(ctx context.Context) ([]storage.APIKeyRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "APIKeyList", ctx)
+	ret0, _ := ret[0].([]storage.APIKeyRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// APIKeyList indicates an expected call of APIKeyList.
+func (mr *MockAPIKeyStorageMockRecorder) APIKeyList(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "APIKeyList", reflect.TypeOf((*MockAPIKeyStorage)(nil).APIKeyList), ctx)
+}
+
+// CreateAPIKey mocks base method.
+func (m *MockAPIKeyStorage) CreateAPIKey(ctx context.Context, key storage.APIKeyRow) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAPIKey", ctx, key)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAPIKey indicates an expected call of CreateAPIKey.
+func (mr *MockAPIKeyStorageMockRecorder) CreateAPIKey(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAPIKey", reflect.TypeOf((*MockAPIKeyStorage)(nil).CreateAPIKey), ctx, key)
+}
+
+// FindAPIKey mocks base method.
+func (m *MockAPIKeyStorage) FindAPIKey(ctx context.Context, keyID string) (storage.APIKeyRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindAPIKey", ctx, keyID)
+	ret0, _ := ret[0].(storage.APIKeyRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindAPIKey indicates an expected call of FindAPIKey.
+func (mr *MockAPIKeyStorageMockRecorder) FindAPIKey(ctx, keyID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAPIKey", reflect.TypeOf((*MockAPIKeyStorage)(nil).FindAPIKey), ctx, keyID)
+}
+
+// RevokeAPIKey mocks base method.
+func (m *MockAPIKeyStorage) RevokeAPIKey(ctx context.Context, id int64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKey", ctx, id)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevokeAPIKey indicates an expected call of RevokeAPIKey.
+func (mr *MockAPIKeyStorageMockRecorder) RevokeAPIKey(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockAPIKeyStorage)(nil).RevokeAPIKey), ctx, id)
+}
+
+// RotateAPIKey mocks base method.
+func (m *MockAPIKeyStorage) RotateAPIKey(ctx context.Context, id int64, keyID, keyHash string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RotateAPIKey", ctx, id, keyID, keyHash)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RotateAPIKey indicates an expected call of RotateAPIKey.
+func (mr *MockAPIKeyStorageMockRecorder) RotateAPIKey(ctx, id, keyID, keyHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RotateAPIKey", reflect.TypeOf((*MockAPIKeyStorage)(nil).RotateAPIKey), ctx, id, keyID, keyHash)
+}
+
+// SetSigningKey mocks base method.
+func (m *MockAPIKeyStorage) SetSigningKey(ctx context.Context, id int64, signingKey string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetSigningKey", ctx, id, signingKey)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetSigningKey indicates an expected call of SetSigningKey.
+func (mr *MockAPIKeyStorageMockRecorder) SetSigningKey(ctx, id, signingKey interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSigningKey", reflect.TypeOf((*MockAPIKeyStorage)(nil).SetSigningKey), ctx, id, signingKey)
+}
@@ -50,6 +50,21 @@ func (mr *MockBalanceStorageMockRecorder) GetUserBalance(ctx, userID interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserBalance", reflect.TypeOf((*MockBalanceStorage)(nil).GetUserBalance), ctx, userID)
 }
 
+// GetUserLedger mocks base method.
+func (m *MockBalanceStorage) GetUserLedger(ctx context.Context, userID int64) ([]storage.BalanceRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserLedger", ctx, userID)
+	ret0, _ := ret[0].([]storage.BalanceRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserLedger indicates an expected call of GetUserLedger.
+func (mr *MockBalanceStorageMockRecorder) GetUserLedger(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserLedger", reflect.TypeOf((*MockBalanceStorage)(nil).GetUserLedger), ctx, userID)
+}
+
 // GetUserWithdrawList mocks base method.
 func (m *MockBalanceStorage) GetUserWithdrawList(ctx context.Context, userID int64) ([]storage.WithdrawRow, error) {
 	m.ctrl.T.Helper()
@@ -81,21 +96,21 @@ func (mr *MockBalanceStorageMockRecorder) GetUserWithdrawn(ctx, userID interface
 }
 
 // SaveTransaction mocks base method.
-func (m *MockBalanceStorage) SaveTransaction(ctx context.Context, orderNumber int64, amount float32) error {
+func (m *MockBalanceStorage) SaveTransaction(ctx context.Context, orderNumber string, amount float32, from []string, source string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SaveTransaction", ctx, orderNumber, amount)
+	ret := m.ctrl.Call(m, "SaveTransaction", ctx, orderNumber, amount, from, source)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // SaveTransaction indicates an expected call of SaveTransaction.
-func (mr *MockBalanceStorageMockRecorder) SaveTransaction(ctx, orderNumber, amount interface{}) *gomock.Call {
+func (mr *MockBalanceStorageMockRecorder) SaveTransaction(ctx, orderNumber, amount, from, source interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveTransaction", reflect.TypeOf((*MockBalanceStorage)(nil).SaveTransaction), ctx, orderNumber, amount)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveTransaction", reflect.TypeOf((*MockBalanceStorage)(nil).SaveTransaction), ctx, orderNumber, amount, from, source)
 }
 
 // WithdrawTransaction mocks base method.
-func (m *MockBalanceStorage) WithdrawTransaction(ctx context.Context, userID, orderNumber int64, amount float32) error {
+func (m *MockBalanceStorage) WithdrawTransaction(ctx context.Context, userID int64, orderNumber string, amount float32) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "WithdrawTransaction", ctx, userID, orderNumber, amount)
 	ret0, _ := ret[0].(error)
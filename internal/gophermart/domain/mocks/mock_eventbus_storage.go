@@ -0,0 +1,155 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/gophermart/domain/eventbus.go
+
+// Package mock_domain is a generated GoMock package.
+package mock_domain
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	storage "github.com/dnsoftware/gophermart2/internal/storage"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockOutboxStorage is a mock of OutboxStorage interface.
+type MockOutboxStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockOutboxStorageMockRecorder
+}
+
+// MockOutboxStorageMockRecorder is the mock recorder for MockOutboxStorage.
+type MockOutboxStorageMockRecorder struct {
+	mock *MockOutboxStorage
+}
+
+// NewMockOutboxStorage creates a new mock instance.
+func NewMockOutboxStorage(ctrl *gomock.Controller) *MockOutboxStorage {
+	mock := &MockOutboxStorage{ctrl: ctrl}
+	mock.recorder = &MockOutboxStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockOutboxStorage) EXPECT() *MockOutboxStorageMockRecorder {
+	return m.recorder
+}
+
+// MarkHandled mocks base method.
+func (m *MockOutboxStorage) MarkHandled(ctx context.Context, sink string, eventID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkHandled", ctx, sink, eventID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkHandled indicates an expected call of MarkHandled.
+func (mr *MockOutboxStorageMockRecorder) MarkHandled(ctx, sink, eventID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkHandled", reflect.TypeOf((*MockOutboxStorage)(nil).MarkHandled), ctx, sink, eventID)
+}
+
+// PendingEvents mocks base method.
+func (m *MockOutboxStorage) PendingEvents(ctx context.Context, sink string, limit int) ([]storage.OutboxRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PendingEvents", ctx, sink, limit)
+	ret0, _ := ret[0].([]storage.OutboxRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PendingEvents indicates an expected call of PendingEvents.
+func (mr *MockOutboxStorageMockRecorder) PendingEvents(ctx, sink, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PendingEvents", reflect.TypeOf((*MockOutboxStorage)(nil).PendingEvents), ctx, sink, limit)
+}
+
+// PurgeOutbox mocks base method.
+func (m *MockOutboxStorage) PurgeOutbox(ctx context.Context, before time.Time, sinks []string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeOutbox", ctx, before, sinks)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeOutbox indicates an expected call of PurgeOutbox.
+func (mr *MockOutboxStorageMockRecorder) PurgeOutbox(ctx, before, sinks interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeOutbox", reflect.TypeOf((*MockOutboxStorage)(nil).PurgeOutbox), ctx, before, sinks)
+}
+
+// MockBrokerPublisher is a mock of BrokerPublisher interface.
+type MockBrokerPublisher struct {
+	ctrl     *gomock.Controller
+	recorder *MockBrokerPublisherMockRecorder
+}
+
+// MockBrokerPublisherMockRecorder is the mock recorder for MockBrokerPublisher.
+type MockBrokerPublisherMockRecorder struct {
+	mock *MockBrokerPublisher
+}
+
+// NewMockBrokerPublisher creates a new mock instance.
+func NewMockBrokerPublisher(ctrl *gomock.Controller) *MockBrokerPublisher {
+	mock := &MockBrokerPublisher{ctrl: ctrl}
+	mock.recorder = &MockBrokerPublisherMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockBrokerPublisher) EXPECT() *MockBrokerPublisherMockRecorder {
+	return m.recorder
+}
+
+// Publish mocks base method.
+func (m *MockBrokerPublisher) Publish(ctx context.Context, subject string, data []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Publish", ctx, subject, data)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Publish indicates an expected call of Publish.
+func (mr *MockBrokerPublisherMockRecorder) Publish(ctx, subject, data interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Publish", reflect.TypeOf((*MockBrokerPublisher)(nil).Publish), ctx, subject, data)
+}
+
+// MockDomainEventData is a mock of DomainEventData interface.
+type MockDomainEventData struct {
+	ctrl     *gomock.Controller
+	recorder *MockDomainEventDataMockRecorder
+}
+
+// MockDomainEventDataMockRecorder is the mock recorder for MockDomainEventData.
+type MockDomainEventDataMockRecorder struct {
+	mock *MockDomainEventData
+}
+
+// NewMockDomainEventData creates a new mock instance.
+func NewMockDomainEventData(ctrl *gomock.Controller) *MockDomainEventData {
+	mock := &MockDomainEventData{ctrl: ctrl}
+	mock.recorder = &MockDomainEventDataMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDomainEventData) EXPECT() *MockDomainEventDataMockRecorder {
+	return m.recorder
+}
+
+// EventType mocks base method.
+func (m *MockDomainEventData) EventType() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EventType")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// EventType indicates an expected call of EventType.
+func (mr *MockDomainEventDataMockRecorder) EventType() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EventType", reflect.TypeOf((*MockDomainEventData)(nil).EventType))
+}
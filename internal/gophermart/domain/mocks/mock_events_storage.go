@@ -0,0 +1,66 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/gophermart/domain/events.go
+
+// Package mock_domain is a generated GoMock package.
+package mock_domain
+
+import (
+	context "context"
+	reflect "reflect"
+
+	storage "github.com/dnsoftware/gophermart2/internal/storage"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockEventStorage is a mock of EventStorage interface.
+type MockEventStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockEventStorageMockRecorder
+}
+
+// MockEventStorageMockRecorder is the mock recorder for MockEventStorage.
+type MockEventStorageMockRecorder struct {
+	mock *MockEventStorage
+}
+
+// NewMockEventStorage creates a new mock instance.
+func NewMockEventStorage(ctrl *gomock.Controller) *MockEventStorage {
+	mock := &MockEventStorage{ctrl: ctrl}
+	mock.recorder = &MockEventStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockEventStorage) EXPECT() *MockEventStorageMockRecorder {
+	return m.recorder
+}
+
+// LastUserEventID mocks base method.
+func (m *MockEventStorage) LastUserEventID(ctx context.Context, userID int64, eventTypes []string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LastUserEventID", ctx, userID, eventTypes)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LastUserEventID indicates an expected call of LastUserEventID.
+func (mr *MockEventStorageMockRecorder) LastUserEventID(ctx, userID, eventTypes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LastUserEventID", reflect.TypeOf((*MockEventStorage)(nil).LastUserEventID), ctx, userID, eventTypes)
+}
+
+// UserEvents mocks base method.
+func (m *MockEventStorage) UserEvents(ctx context.Context, userID, afterID int64, eventTypes []string, limit int) ([]storage.OutboxRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UserEvents", ctx, userID, afterID, eventTypes, limit)
+	ret0, _ := ret[0].([]storage.OutboxRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UserEvents indicates an expected call of UserEvents.
+func (mr *MockEventStorageMockRecorder) UserEvents(ctx, userID, afterID, eventTypes, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserEvents", reflect.TypeOf((*MockEventStorage)(nil).UserEvents), ctx, userID, afterID, eventTypes, limit)
+}
@@ -0,0 +1,79 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/gophermart/domain/health.go
+
+// Package mock_domain is a generated GoMock package.
+package mock_domain
+
+import (
+	context "context"
+	sql "database/sql"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockHealthStorage is a mock of HealthStorage interface.
+type MockHealthStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockHealthStorageMockRecorder
+}
+
+// MockHealthStorageMockRecorder is the mock recorder for MockHealthStorage.
+type MockHealthStorageMockRecorder struct {
+	mock *MockHealthStorage
+}
+
+// NewMockHealthStorage creates a new mock instance.
+func NewMockHealthStorage(ctrl *gomock.Controller) *MockHealthStorage {
+	mock := &MockHealthStorage{ctrl: ctrl}
+	mock.recorder = &MockHealthStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockHealthStorage) EXPECT() *MockHealthStorageMockRecorder {
+	return m.recorder
+}
+
+// Ping mocks base method.
+func (m *MockHealthStorage) Ping(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockHealthStorageMockRecorder) Ping(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockHealthStorage)(nil).Ping), ctx)
+}
+
+// SchemaVersion mocks base method.
+func (m *MockHealthStorage) SchemaVersion(ctx context.Context) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SchemaVersion", ctx)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SchemaVersion indicates an expected call of SchemaVersion.
+func (mr *MockHealthStorageMockRecorder) SchemaVersion(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SchemaVersion", reflect.TypeOf((*MockHealthStorage)(nil).SchemaVersion), ctx)
+}
+
+// Stats mocks base method.
+func (m *MockHealthStorage) Stats() sql.DBStats {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stats")
+	ret0, _ := ret[0].(sql.DBStats)
+	return ret0
+}
+
+// Stats indicates an expected call of Stats.
+func (mr *MockHealthStorageMockRecorder) Stats() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockHealthStorage)(nil).Stats))
+}
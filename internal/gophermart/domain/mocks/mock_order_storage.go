@@ -36,22 +36,83 @@ func (m *MockOrderStorage) EXPECT() *MockOrderStorageMockRecorder {
 }
 
 // Create mocks base method.
-func (m *MockOrderStorage) Create(ctx context.Context, userID, number int64) (int, error) {
+func (m *MockOrderStorage) Create(ctx context.Context, userID int64, number string, apiKeyID int64) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Create", ctx, userID, number)
+	ret := m.ctrl.Call(m, "Create", ctx, userID, number, apiKeyID)
 	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Create indicates an expected call of Create.
-func (mr *MockOrderStorageMockRecorder) Create(ctx, userID, number interface{}) *gomock.Call {
+func (mr *MockOrderStorageMockRecorder) Create(ctx, userID, number, apiKeyID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockOrderStorage)(nil).Create), ctx, userID, number)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockOrderStorage)(nil).Create), ctx, userID, number, apiKeyID)
+}
+
+// CreateBatch mocks base method.
+func (m *MockOrderStorage) CreateBatch(ctx context.Context, userID int64, numbers []string) ([]int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateBatch", ctx, userID, numbers)
+	ret0, _ := ret[0].([]int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateBatch indicates an expected call of CreateBatch.
+func (mr *MockOrderStorageMockRecorder) CreateBatch(ctx, userID, numbers interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBatch", reflect.TypeOf((*MockOrderStorage)(nil).CreateBatch), ctx, userID, numbers)
+}
+
+// CreateDispute mocks base method.
+func (m *MockOrderStorage) CreateDispute(ctx context.Context, d storage.DisputeRow) (int64, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateDispute", ctx, d)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// CreateDispute indicates an expected call of CreateDispute.
+func (mr *MockOrderStorageMockRecorder) CreateDispute(ctx, d interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDispute", reflect.TypeOf((*MockOrderStorage)(nil).CreateDispute), ctx, d)
+}
+
+// Delete mocks base method.
+func (m *MockOrderStorage) Delete(ctx context.Context, userID int64, orderNumber string, from []string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, userID, orderNumber, from)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockOrderStorageMockRecorder) Delete(ctx, userID, orderNumber, from interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockOrderStorage)(nil).Delete), ctx, userID, orderNumber, from)
+}
+
+// DisputeList mocks base method.
+func (m *MockOrderStorage) DisputeList(ctx context.Context, userID int64, status string) ([]storage.DisputeRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DisputeList", ctx, userID, status)
+	ret0, _ := ret[0].([]storage.DisputeRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DisputeList indicates an expected call of DisputeList.
+func (mr *MockOrderStorageMockRecorder) DisputeList(ctx, userID, status interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisputeList", reflect.TypeOf((*MockOrderStorage)(nil).DisputeList), ctx, userID, status)
 }
 
 // GetOrderByNumber mocks base method.
-func (m *MockOrderStorage) GetOrderByNumber(ctx context.Context, orderNumber int64) (storage.OrderRow, error) {
+func (m *MockOrderStorage) GetOrderByNumber(ctx context.Context, orderNumber string) (storage.OrderRow, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetOrderByNumber", ctx, orderNumber)
 	ret0, _ := ret[0].(storage.OrderRow)
@@ -96,18 +157,65 @@ func (mr *MockOrderStorageMockRecorder) List(ctx, userID interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockOrderStorage)(nil).List), ctx, userID)
 }
 
+// ListPage mocks base method.
+func (m *MockOrderStorage) ListPage(ctx context.Context, userID int64, filter storage.OrderFilter) ([]storage.OrderRow, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPage", ctx, userID, filter)
+	ret0, _ := ret[0].([]storage.OrderRow)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ListPage indicates an expected call of ListPage.
+func (mr *MockOrderStorageMockRecorder) ListPage(ctx, userID, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPage", reflect.TypeOf((*MockOrderStorage)(nil).ListPage), ctx, userID, filter)
+}
+
+// ResolveDispute mocks base method.
+func (m *MockOrderStorage) ResolveDispute(ctx context.Context, id, adminID int64, approve bool, resolution string) (storage.DisputeRow, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResolveDispute", ctx, id, adminID, approve, resolution)
+	ret0, _ := ret[0].(storage.DisputeRow)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ResolveDispute indicates an expected call of ResolveDispute.
+func (mr *MockOrderStorageMockRecorder) ResolveDispute(ctx, id, adminID, approve, resolution interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResolveDispute", reflect.TypeOf((*MockOrderStorage)(nil).ResolveDispute), ctx, id, adminID, approve, resolution)
+}
+
+// StatusHistory mocks base method.
+func (m *MockOrderStorage) StatusHistory(ctx context.Context, orderNumber string) ([]storage.OrderStatusRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StatusHistory", ctx, orderNumber)
+	ret0, _ := ret[0].([]storage.OrderStatusRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// StatusHistory indicates an expected call of StatusHistory.
+func (mr *MockOrderStorageMockRecorder) StatusHistory(ctx, orderNumber interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StatusHistory", reflect.TypeOf((*MockOrderStorage)(nil).StatusHistory), ctx, orderNumber)
+}
+
 // UpdateStatus mocks base method.
-func (m *MockOrderStorage) UpdateStatus(ctx context.Context, orderNumber int64, orderStatus string) error {
+func (m *MockOrderStorage) UpdateStatus(ctx context.Context, orderNumber string, from []string, orderStatus, accrualStatus, source string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateStatus", ctx, orderNumber, orderStatus)
+	ret := m.ctrl.Call(m, "UpdateStatus", ctx, orderNumber, from, orderStatus, accrualStatus, source)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdateStatus indicates an expected call of UpdateStatus.
-func (mr *MockOrderStorageMockRecorder) UpdateStatus(ctx, orderNumber, orderStatus interface{}) *gomock.Call {
+func (mr *MockOrderStorageMockRecorder) UpdateStatus(ctx, orderNumber, from, orderStatus, accrualStatus, source interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateStatus", reflect.TypeOf((*MockOrderStorage)(nil).UpdateStatus), ctx, orderNumber, orderStatus)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateStatus", reflect.TypeOf((*MockOrderStorage)(nil).UpdateStatus), ctx, orderNumber, from, orderStatus, accrualStatus, source)
 }
 
 // MockUncheckedOrders is a mock of UncheckedOrders interface.
@@ -134,7 +242,7 @@ func (m *MockUncheckedOrders) EXPECT() *MockUncheckedOrdersMockRecorder {
 }
 
 // Push mocks base method.
-func (m *MockUncheckedOrders) Push(number int64) {
+func (m *MockUncheckedOrders) Push(number string) {
 	m.ctrl.T.Helper()
 	m.ctrl.Call(m, "Push", number)
 }
@@ -169,13 +277,14 @@ func (m *MockCheckedOrders) EXPECT() *MockCheckedOrdersMockRecorder {
 }
 
 // Pop mocks base method.
-func (m *MockCheckedOrders) Pop(ctx context.Context) (int64, string, float32) {
+func (m *MockCheckedOrders) Pop(ctx context.Context) (string, string, string, float32) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Pop", ctx)
-	ret0, _ := ret[0].(int64)
+	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(string)
-	ret2, _ := ret[2].(float32)
-	return ret0, ret1, ret2
+	ret2, _ := ret[2].(string)
+	ret3, _ := ret[3].(float32)
+	return ret0, ret1, ret2, ret3
 }
 
 // Pop indicates an expected call of Pop.
@@ -208,15 +317,15 @@ func (m *MockBalanceAdd) EXPECT() *MockBalanceAddMockRecorder {
 }
 
 // AddTransaction mocks base method.
-func (m *MockBalanceAdd) AddTransaction(ctx context.Context, orderNumber int64, amount float32) error {
+func (m *MockBalanceAdd) AddTransaction(ctx context.Context, orderNumber string, amount float32, from []string, source string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddTransaction", ctx, orderNumber, amount)
+	ret := m.ctrl.Call(m, "AddTransaction", ctx, orderNumber, amount, from, source)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // AddTransaction indicates an expected call of AddTransaction.
-func (mr *MockBalanceAddMockRecorder) AddTransaction(ctx, orderNumber, amount interface{}) *gomock.Call {
+func (mr *MockBalanceAddMockRecorder) AddTransaction(ctx, orderNumber, amount, from, source interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddTransaction", reflect.TypeOf((*MockBalanceAdd)(nil).AddTransaction), ctx, orderNumber, amount)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddTransaction", reflect.TypeOf((*MockBalanceAdd)(nil).AddTransaction), ctx, orderNumber, amount, from, source)
 }
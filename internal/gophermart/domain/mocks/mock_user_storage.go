@@ -7,6 +7,7 @@ package mock_domain
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	storage "github.com/dnsoftware/gophermart2/internal/storage"
 	gomock "github.com/golang/mock/gomock"
@@ -35,6 +36,22 @@ func (m *MockUserStorage) EXPECT() *MockUserStorageMockRecorder {
 	return m.recorder
 }
 
+// ActivityCounts mocks base method.
+func (m *MockUserStorage) ActivityCounts(ctx context.Context, id int64) (int64, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ActivityCounts", ctx, id)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ActivityCounts indicates an expected call of ActivityCounts.
+func (mr *MockUserStorageMockRecorder) ActivityCounts(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ActivityCounts", reflect.TypeOf((*MockUserStorage)(nil).ActivityCounts), ctx, id)
+}
+
 // Create mocks base method.
 func (m *MockUserStorage) Create(ctx context.Context, login, password string) (int64, int, error) {
 	m.ctrl.T.Helper()
@@ -51,6 +68,21 @@ func (mr *MockUserStorageMockRecorder) Create(ctx, login, password interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockUserStorage)(nil).Create), ctx, login, password)
 }
 
+// CreatePasswordReset mocks base method.
+func (m *MockUserStorage) CreatePasswordReset(ctx context.Context, login, tokenHash string, expiresAt time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePasswordReset", ctx, login, tokenHash, expiresAt)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreatePasswordReset indicates an expected call of CreatePasswordReset.
+func (mr *MockUserStorageMockRecorder) CreatePasswordReset(ctx, login, tokenHash, expiresAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePasswordReset", reflect.TypeOf((*MockUserStorage)(nil).CreatePasswordReset), ctx, login, tokenHash, expiresAt)
+}
+
 // FindByID mocks base method.
 func (m *MockUserStorage) FindByID(ctx context.Context, id int64) (storage.UserRow, error) {
 	m.ctrl.T.Helper()
@@ -81,3 +113,175 @@ func (mr *MockUserStorageMockRecorder) FindByLoginPassword(ctx, login, password
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByLoginPassword", reflect.TypeOf((*MockUserStorage)(nil).FindByLoginPassword), ctx, login, password)
 }
+
+// FindIDByLogin mocks base method.
+func (m *MockUserStorage) FindIDByLogin(ctx context.Context, login string) (int64, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindIDByLogin", ctx, login)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// FindIDByLogin indicates an expected call of FindIDByLogin.
+func (mr *MockUserStorageMockRecorder) FindIDByLogin(ctx, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindIDByLogin", reflect.TypeOf((*MockUserStorage)(nil).FindIDByLogin), ctx, login)
+}
+
+// ResetPassword mocks base method.
+func (m *MockUserStorage) ResetPassword(ctx context.Context, tokenHash, newPassword string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetPassword", ctx, tokenHash, newPassword)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResetPassword indicates an expected call of ResetPassword.
+func (mr *MockUserStorageMockRecorder) ResetPassword(ctx, tokenHash, newPassword interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetPassword", reflect.TypeOf((*MockUserStorage)(nil).ResetPassword), ctx, tokenHash, newPassword)
+}
+
+// SessionState mocks base method.
+func (m *MockUserStorage) SessionState(ctx context.Context, id int64) (time.Time, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SessionState", ctx, id)
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// SessionState indicates an expected call of SessionState.
+func (mr *MockUserStorageMockRecorder) SessionState(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SessionState", reflect.TypeOf((*MockUserStorage)(nil).SessionState), ctx, id)
+}
+
+// SetRole mocks base method.
+func (m *MockUserStorage) SetRole(ctx context.Context, id int64, role string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetRole", ctx, id, role)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetRole indicates an expected call of SetRole.
+func (mr *MockUserStorageMockRecorder) SetRole(ctx, id, role interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRole", reflect.TypeOf((*MockUserStorage)(nil).SetRole), ctx, id, role)
+}
+
+// SetRoleByLogin mocks base method.
+func (m *MockUserStorage) SetRoleByLogin(ctx context.Context, login, role string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetRoleByLogin", ctx, login, role)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetRoleByLogin indicates an expected call of SetRoleByLogin.
+func (mr *MockUserStorageMockRecorder) SetRoleByLogin(ctx, login, role interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRoleByLogin", reflect.TypeOf((*MockUserStorage)(nil).SetRoleByLogin), ctx, login, role)
+}
+
+// UpdateLastLogin mocks base method.
+func (m *MockUserStorage) UpdateLastLogin(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateLastLogin", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateLastLogin indicates an expected call of UpdateLastLogin.
+func (mr *MockUserStorageMockRecorder) UpdateLastLogin(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateLastLogin", reflect.TypeOf((*MockUserStorage)(nil).UpdateLastLogin), ctx, id)
+}
+
+// UpdatePassword mocks base method.
+func (m *MockUserStorage) UpdatePassword(ctx context.Context, id int64, oldPassword, newPassword string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePassword", ctx, id, oldPassword, newPassword)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdatePassword indicates an expected call of UpdatePassword.
+func (mr *MockUserStorageMockRecorder) UpdatePassword(ctx, id, oldPassword, newPassword interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePassword", reflect.TypeOf((*MockUserStorage)(nil).UpdatePassword), ctx, id, oldPassword, newPassword)
+}
+
+// UpdateProfile mocks base method.
+func (m *MockUserStorage) UpdateProfile(ctx context.Context, id int64, displayName, email, locale *string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateProfile", ctx, id, displayName, email, locale)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateProfile indicates an expected call of UpdateProfile.
+func (mr *MockUserStorageMockRecorder) UpdateProfile(ctx, id, displayName, email, locale interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateProfile", reflect.TypeOf((*MockUserStorage)(nil).UpdateProfile), ctx, id, displayName, email, locale)
+}
+
+// UserRole mocks base method.
+func (m *MockUserStorage) UserRole(ctx context.Context, id int64) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UserRole", ctx, id)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UserRole indicates an expected call of UserRole.
+func (mr *MockUserStorageMockRecorder) UserRole(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserRole", reflect.TypeOf((*MockUserStorage)(nil).UserRole), ctx, id)
+}
+
+// MockResetNotifier is a mock of ResetNotifier interface.
+type MockResetNotifier struct {
+	ctrl     *gomock.Controller
+	recorder *MockResetNotifierMockRecorder
+}
+
+// MockResetNotifierMockRecorder is the mock recorder for MockResetNotifier.
+type MockResetNotifierMockRecorder struct {
+	mock *MockResetNotifier
+}
+
+// NewMockResetNotifier creates a new mock instance.
+func NewMockResetNotifier(ctrl *gomock.Controller) *MockResetNotifier {
+	mock := &MockResetNotifier{ctrl: ctrl}
+	mock.recorder = &MockResetNotifierMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockResetNotifier) EXPECT() *MockResetNotifierMockRecorder {
+	return m.recorder
+}
+
+// SendPasswordReset mocks base method.
+func (m *MockResetNotifier) SendPasswordReset(ctx context.Context, login, token string, expiresAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendPasswordReset", ctx, login, token, expiresAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendPasswordReset indicates an expected call of SendPasswordReset.
+func (mr *MockResetNotifierMockRecorder) SendPasswordReset(ctx, login, token, expiresAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendPasswordReset", reflect.TypeOf((*MockResetNotifier)(nil).SendPasswordReset), ctx, login, token, expiresAt)
+}
@@ -0,0 +1,155 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/gophermart/domain/webhook.go
+
+// Package mock_domain is a generated GoMock package.
+package mock_domain
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	storage "github.com/dnsoftware/gophermart2/internal/storage"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockWebhookStorage is a mock of WebhookStorage interface.
+type MockWebhookStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockWebhookStorageMockRecorder
+}
+
+// MockWebhookStorageMockRecorder is the mock recorder for MockWebhookStorage.
+type MockWebhookStorageMockRecorder struct {
+	mock *MockWebhookStorage
+}
+
+// NewMockWebhookStorage creates a new mock instance.
+func NewMockWebhookStorage(ctrl *gomock.Controller) *MockWebhookStorage {
+	mock := &MockWebhookStorage{ctrl: ctrl}
+	mock.recorder = &MockWebhookStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWebhookStorage) EXPECT() *MockWebhookStorageMockRecorder {
+	return m.recorder
+}
+
+// ClaimDeliveries mocks base method.
+func (m *MockWebhookStorage) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]storage.WebhookDeliveryRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimDeliveries", ctx, limit, lease)
+	ret0, _ := ret[0].([]storage.WebhookDeliveryRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimDeliveries indicates an expected call of ClaimDeliveries.
+func (mr *MockWebhookStorageMockRecorder) ClaimDeliveries(ctx, limit, lease interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimDeliveries", reflect.TypeOf((*MockWebhookStorage)(nil).ClaimDeliveries), ctx, limit, lease)
+}
+
+// CreateWebhook mocks base method.
+func (m *MockWebhookStorage) CreateWebhook(ctx context.Context, w storage.WebhookRow) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWebhook", ctx, w)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateWebhook indicates an expected call of CreateWebhook.
+func (mr *MockWebhookStorageMockRecorder) CreateWebhook(ctx, w interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWebhook", reflect.TypeOf((*MockWebhookStorage)(nil).CreateWebhook), ctx, w)
+}
+
+// DeleteWebhook mocks base method.
+func (m *MockWebhookStorage) DeleteWebhook(ctx context.Context, apiKeyID, id int64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhook", ctx, apiKeyID, id)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteWebhook indicates an expected call of DeleteWebhook.
+func (mr *MockWebhookStorageMockRecorder) DeleteWebhook(ctx, apiKeyID, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhook", reflect.TypeOf((*MockWebhookStorage)(nil).DeleteWebhook), ctx, apiKeyID, id)
+}
+
+// DeliveryList mocks base method.
+func (m *MockWebhookStorage) DeliveryList(ctx context.Context, apiKeyID, webhookID int64, limit int) ([]storage.WebhookDeliveryRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeliveryList", ctx, apiKeyID, webhookID, limit)
+	ret0, _ := ret[0].([]storage.WebhookDeliveryRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeliveryList indicates an expected call of DeliveryList.
+func (mr *MockWebhookStorageMockRecorder) DeliveryList(ctx, apiKeyID, webhookID, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeliveryList", reflect.TypeOf((*MockWebhookStorage)(nil).DeliveryList), ctx, apiKeyID, webhookID, limit)
+}
+
+// EnqueueDeliveries mocks base method.
+func (m *MockWebhookStorage) EnqueueDeliveries(ctx context.Context, eventID int64, eventType string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnqueueDeliveries", ctx, eventID, eventType)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnqueueDeliveries indicates an expected call of EnqueueDeliveries.
+func (mr *MockWebhookStorageMockRecorder) EnqueueDeliveries(ctx, eventID, eventType interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnqueueDeliveries", reflect.TypeOf((*MockWebhookStorage)(nil).EnqueueDeliveries), ctx, eventID, eventType)
+}
+
+// FinishDelivery mocks base method.
+func (m *MockWebhookStorage) FinishDelivery(ctx context.Context, d storage.WebhookDeliveryRow) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FinishDelivery", ctx, d)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FinishDelivery indicates an expected call of FinishDelivery.
+func (mr *MockWebhookStorageMockRecorder) FinishDelivery(ctx, d interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FinishDelivery", reflect.TypeOf((*MockWebhookStorage)(nil).FinishDelivery), ctx, d)
+}
+
+// SendWebhook mocks base method.
+func (m *MockWebhookStorage) SendWebhook(ctx context.Context, url string, headers map[string]string, body []byte) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendWebhook", ctx, url, headers, body)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SendWebhook indicates an expected call of SendWebhook.
+func (mr *MockWebhookStorageMockRecorder) SendWebhook(ctx, url, headers, body interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendWebhook", reflect.TypeOf((*MockWebhookStorage)(nil).SendWebhook), ctx, url, headers, body)
+}
+
+// WebhookList mocks base method.
+func (m *MockWebhookStorage) WebhookList(ctx context.Context, apiKeyID int64) ([]storage.WebhookRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WebhookList", ctx, apiKeyID)
+	ret0, _ := ret[0].([]storage.WebhookRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WebhookList indicates an expected call of WebhookList.
+func (mr *MockWebhookStorageMockRecorder) WebhookList(ctx, apiKeyID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WebhookList", reflect.TypeOf((*MockWebhookStorage)(nil).WebhookList), ctx, apiKeyID)
+}
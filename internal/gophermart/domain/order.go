@@ -2,33 +2,44 @@ package domain
 
 import (
 	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"github.com/dnsoftware/gophermart2/internal/constants"
 	"github.com/dnsoftware/gophermart2/internal/logger"
 	"github.com/dnsoftware/gophermart2/internal/storage"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type OrderStorage interface {
-	Create(ctx context.Context, userID, number int64) (int, error)
+	Create(ctx context.Context, userID int64, number string, apiKeyID int64) (int, error)
+	CreateBatch(ctx context.Context, userID int64, numbers []string) ([]int, error)
 	List(ctx context.Context, userID int64) ([]storage.OrderRow, int, error)
+	ListPage(ctx context.Context, userID int64, filter storage.OrderFilter) ([]storage.OrderRow, int, error)
 	GetUnchecked(ctx context.Context) ([]storage.OrderRow, error)
-	UpdateStatus(ctx context.Context, orderNumber int64, orderStatus string) error
-	GetOrderByNumber(ctx context.Context, orderNumber int64) (storage.OrderRow, error)
+	UpdateStatus(ctx context.Context, orderNumber string, from []string, orderStatus string, accrualStatus string, source string) error
+	GetOrderByNumber(ctx context.Context, orderNumber string) (storage.OrderRow, error)
+	StatusHistory(ctx context.Context, orderNumber string) ([]storage.OrderStatusRow, error)
+	Delete(ctx context.Context, userID int64, orderNumber string, from []string) (bool, error)
+	CreateDispute(ctx context.Context, d storage.DisputeRow) (int64, int, error)
+	DisputeList(ctx context.Context, userID int64, status string) ([]storage.DisputeRow, error)
+	ResolveDispute(ctx context.Context, id int64, adminID int64, approve bool, resolution string) (storage.DisputeRow, int, error)
 }
 
 // для работы с каналом непроверенных ордеров
 type UncheckedOrders interface {
-	Push(number int64)
+	Push(number string)
 }
 
 type CheckedOrders interface {
-	Pop(ctx context.Context) (int64, string, float32)
+	Pop(ctx context.Context) (string, string, string, float32)
 }
 
 type BalanceAdd interface {
-	AddTransaction(ctx context.Context, orderNumber int64, amount float32) error
+	AddTransaction(ctx context.Context, orderNumber string, amount float32, from []string, source string) error
 }
 
 type Order struct {
@@ -36,6 +47,8 @@ type Order struct {
 	ordersToCheck UncheckedOrders // сюда кидаем номера ордеров на проверку в Accrual
 	ordersToSave  CheckedOrders   // отсюда берем проверенные и сохраняем в базу
 	balanceAdd    BalanceAdd      // для внесения начислений из проверенных ордеров на баланс
+	states        *OrderStateMachine
+	savers        workerPool // обработчики проверенных заказов
 }
 
 // OrderItem plain structure
@@ -46,18 +59,67 @@ type OrderItem struct {
 	UploadedAt string  `json:"uploaded_at"`
 }
 
+// BatchOrderResult результат загрузки одного номера в пакете
+type BatchOrderResult struct {
+	Number  string `json:"number"`
+	Result  string `json:"result"` // constants.BatchResult*
+	Code    int    `json:"code"`   // HTTP код, который вернула бы загрузка этого номера по одному
+	Message string `json:"message"`
+}
+
+// OrderDetail заказ с историей смены статусов
+type OrderDetail struct {
+	OrderItem
+	History []OrderStatusItem `json:"history"`
+}
+
+// OrderStatusItem запись истории статусов заказа
+type OrderStatusItem struct {
+	Status        string `json:"status"`
+	AccrualStatus string `json:"accrual_status,omitempty"` // статус, полученный от системы расчета
+	Source        string `json:"source"`
+	ChangedAt     string `json:"changed_at"`
+}
+
+// OrderListQuery параметры постраничного списка заказов
+type OrderListQuery struct {
+	Statuses []string  // фильтр по статусам, пустой - все
+	From     time.Time // загруженные начиная с этого момента
+	To       time.Time // загруженные до этого момента (не включительно)
+	Sort     string    // OrdersSortNewest или OrdersSortOldest
+	Cursor   string    // курсор из предыдущей страницы, пустой - первая страница
+	Limit    int
+}
+
+// OrderPage страница списка заказов
+type OrderPage struct {
+	Items      []OrderItem
+	Total      int    // кол-во заказов, подходящих под фильтр
+	NextCursor string // пустой - страница последняя
+}
+
 func NewOrderModel(storage OrderStorage, uncheckedCh UncheckedOrders, checkedCh CheckedOrders, balanceAdd BalanceAdd) *Order {
 	order := &Order{
 		storage:       storage,
 		ordersToCheck: uncheckedCh,
 		ordersToSave:  checkedCh,
 		balanceAdd:    balanceAdd,
+		states:        NewOrderStateMachine(),
 	}
 
 	return order
 }
 
-func (o *Order) AddOrder(ctx context.Context, userID, number int64) (int, error) {
+func (o *Order) AddOrder(ctx context.Context, userID int64, number string) (int, error) {
+	return o.addOrder(ctx, userID, number, 0)
+}
+
+// AddPartnerOrder загрузка заказа партнером за пользователя, партнер получает события по этому заказу
+func (o *Order) AddPartnerOrder(ctx context.Context, apiKeyID int64, userID int64, number string) (int, error) {
+	return o.addOrder(ctx, userID, number, apiKeyID)
+}
+
+func (o *Order) addOrder(ctx context.Context, userID int64, number string, apiKeyID int64) (int, error) {
 	status := constants.OrderInternalError
 
 	// проверка Луна
@@ -67,26 +129,97 @@ func (o *Order) AddOrder(ctx context.Context, userID, number int64) (int, error)
 	}
 
 	// сохраняем в базу
-	status, err := o.storage.Create(ctx, userID, number)
-	if err != nil {
-		return status, err
+	return o.storage.Create(ctx, userID, number, apiKeyID)
+}
+
+// AddOrders пакетная загрузка номеров заказов
+// неверные номера отмечаются в результате, остальные сохраняются в одной транзакции
+func (o *Order) AddOrders(ctx context.Context, userID int64, numbers []string) ([]BatchOrderResult, int, error) {
+	if len(numbers) == 0 {
+		return nil, constants.OrdersBatchBadFormat, fmt.Errorf("не передано ни одного номера заказа")
+	}
+	if len(numbers) > constants.OrdersBatchMaxSize {
+		return nil, constants.OrdersBatchTooLarge, fmt.Errorf("в пакете больше %v номеров", constants.OrdersBatchMaxSize)
+	}
+
+	results := make([]BatchOrderResult, len(numbers))
+	valid := make([]string, 0, len(numbers))
+	validIdx := make([]int, 0, len(numbers))
+
+	for i, number := range numbers {
+		results[i].Number = number
+
+		if !IsLuhnValid(number) {
+			results[i].setStatus(constants.OrderBadNumberFormat)
+			continue
+		}
+
+		valid = append(valid, number)
+		validIdx = append(validIdx, i)
 	}
 
-	logger.Log().Info(fmt.Sprintf("Добавлен заказ %v", number))
+	if len(valid) > 0 {
+		statuses, err := o.storage.CreateBatch(ctx, userID, valid)
+		if err != nil {
+			return nil, constants.OrdersBatchInternalError, err
+		}
+
+		for i, status := range statuses {
+			results[validIdx[i]].setStatus(status)
+		}
+	}
+
+	logger.Log().WithContext(ctx).Info(fmt.Sprintf("Пакетная загрузка заказов пользователя %v: %v номеров, %v корректных", userID, len(numbers), len(valid)))
+
+	return results, constants.OrdersBatchOk, nil
+}
+
+func (r *BatchOrderResult) setStatus(status int) {
+	switch status {
+	case constants.OrderAccepted:
+		r.Result = constants.BatchResultAccepted
+	case constants.OrderOk:
+		r.Result = constants.BatchResultAlreadyYours
+	case constants.OrderAlreadyUpload:
+		r.Result = constants.BatchResultOwnedByOther
+	default:
+		r.Result = constants.BatchResultInvalid
+	}
 
-	return status, nil
+	r.Code, r.Message = constants.StatusData(status)
 }
 
-func (o *Order) SetStatus(ctx context.Context, orderNumber int64, orderStatus string) error {
+// SetStatus смена статуса заказа по правилам OrderStateMachine
+// для PROCESSED вместе со статусом на баланс вносится начисление accrual
+// accrualStatus - статус, полученный от Accrual, source - кто меняет статус (constants.StatusSource*)
+func (o *Order) SetStatus(ctx context.Context, orderNumber string, orderStatus string, accrualStatus string, accrual float32, source string) error {
+	// переход возможен только из статусов, разрешенных OrderStateMachine,
+	// так запоздавший ответ Accrual не вернет обработанный заказ в PROCESSING
+	from := o.states.Sources(orderStatus, source)
+
+	// клиенты и получатели событий оповещаются через EventBus, событие пишется в той же транзакции
+	var err error
+	if orderStatus == constants.OrderProcessed {
+		err = o.balanceAdd.AddTransaction(ctx, orderNumber, accrual, from, source)
+	} else {
+		err = o.storage.UpdateStatus(ctx, orderNumber, from, orderStatus, accrualStatus, source)
+	}
 
-	err := o.storage.UpdateStatus(ctx, orderNumber, orderStatus)
+	if errors.Is(err, storage.ErrTransitionRejected) {
+		o.states.Reject(ctx, orderNumber, orderStatus)
+	}
 	if err != nil {
-		return fmt.Errorf("Ошибка при смене статуса заказа: " + err.Error())
+		return fmt.Errorf("Ошибка при смене статуса заказа: %w", err)
 	}
 
 	return nil
 }
 
+// RejectedTransitions кол-во отклоненных переходов статусов заказов
+func (o *Order) RejectedTransitions() int64 {
+	return o.states.Rejected()
+}
+
 func (o *Order) OrdersList(ctx context.Context, userID int64) ([]OrderItem, int, error) {
 	orders := make([]OrderItem, 0)
 
@@ -99,7 +232,7 @@ func (o *Order) OrdersList(ctx context.Context, userID int64) ([]OrderItem, int,
 	for _, item := range list {
 		upAt := item.UploadedAt.Format(time.RFC3339)
 		orderItem := OrderItem{
-			Number:     strconv.FormatInt(item.Num, 10),
+			Number:     item.Num,
 			Status:     item.Status,
 			Accrual:    item.Accrual,
 			UploadedAt: upAt,
@@ -111,18 +244,196 @@ func (o *Order) OrdersList(ctx context.Context, userID int64) ([]OrderItem, int,
 	return orders, status, nil
 }
 
+// OrderDetail заказ пользователя с историей статусов
+// чужой заказ для пользователя не существует
+func (o *Order) OrderDetail(ctx context.Context, userID int64, number string) (*OrderDetail, int, error) {
+	row, err := o.storage.GetOrderByNumber(ctx, number)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, constants.OrderDetailNotFound, fmt.Errorf("заказ %v не найден", number)
+	}
+	if err != nil {
+		return nil, constants.OrderDetailInternalError, err
+	}
+	if row.UserID != userID {
+		return nil, constants.OrderDetailNotFound, fmt.Errorf("заказ %v не найден", number)
+	}
+
+	history, err := o.storage.StatusHistory(ctx, number)
+	if err != nil {
+		return nil, constants.OrderDetailInternalError, err
+	}
+
+	detail := &OrderDetail{
+		OrderItem: OrderItem{
+			Number:     row.Num,
+			Status:     row.Status,
+			Accrual:    row.Accrual,
+			UploadedAt: row.UploadedAt.Format(time.RFC3339),
+		},
+		History: make([]OrderStatusItem, 0, len(history)),
+	}
+
+	for _, h := range history {
+		detail.History = append(detail.History, OrderStatusItem{
+			Status:        h.Status,
+			AccrualStatus: h.AccrualStatus,
+			Source:        h.Source,
+			ChangedAt:     h.CreatedAt.Format(time.RFC3339),
+		})
+	}
+
+	return detail, constants.OrderDetailOk, nil
+}
+
+// CancelOrder удаление заказа пользователем
+// удалить можно только свой заказ, который еще не принят в обработку системой расчета
+func (o *Order) CancelOrder(ctx context.Context, userID int64, number string) (int, error) {
+	row, err := o.storage.GetOrderByNumber(ctx, number)
+	if errors.Is(err, sql.ErrNoRows) {
+		return constants.OrderCancelNotFound, fmt.Errorf("заказ %v не найден", number)
+	}
+	if err != nil {
+		return constants.OrderCancelInternalError, err
+	}
+	if row.UserID != userID {
+		return constants.OrderCancelNotFound, fmt.Errorf("заказ %v не найден", number)
+	}
+	if row.Status != constants.OrderNew {
+		return constants.OrderCancelNotAllowed, fmt.Errorf("заказ %v в статусе %v", number, row.Status)
+	}
+
+	// статус мог смениться после проверки, поэтому удаление тоже только из NEW
+	deleted, err := o.storage.Delete(ctx, userID, number, []string{constants.OrderNew})
+	if err != nil {
+		return constants.OrderCancelInternalError, err
+	}
+	if !deleted {
+		return constants.OrderCancelNotAllowed, fmt.Errorf("заказ %v уже принят в обработку", number)
+	}
+
+	logger.Log().WithContext(ctx).Info(fmt.Sprintf("Пользователь %v удалил заказ %v", userID, number))
+
+	return constants.OrderCancelOk, nil
+}
+
+// OrdersPage постраничный список заказов с фильтрами и сортировкой
+func (o *Order) OrdersPage(ctx context.Context, userID int64, q OrderListQuery) (*OrderPage, int, error) {
+	filter := storage.OrderFilter{
+		Statuses: q.Statuses,
+		From:     q.From,
+		To:       q.To,
+		Limit:    q.Limit,
+	}
+
+	for _, s := range q.Statuses {
+		if !orderStatusValidate(s) {
+			return nil, constants.OrdersListBadFormat, fmt.Errorf("неизвестный статус заказа %v", s)
+		}
+	}
+
+	if q.Limit < 1 || q.Limit > constants.OrdersPageMaxLimit {
+		return nil, constants.OrdersListBadFormat, fmt.Errorf("размер страницы должен быть от 1 до %v", constants.OrdersPageMaxLimit)
+	}
+
+	switch q.Sort {
+	case "", constants.OrdersSortNewest:
+	case constants.OrdersSortOldest:
+		filter.Ascending = true
+	default:
+		return nil, constants.OrdersListBadFormat, fmt.Errorf("неизвестная сортировка %v", q.Sort)
+	}
+
+	if q.Cursor != "" {
+		var err error
+		filter.AfterTime, filter.AfterID, err = decodeOrderCursor(q.Cursor)
+		if err != nil {
+			return nil, constants.OrdersListBadFormat, err
+		}
+	}
+
+	// запрашиваем на одну запись больше, чтобы узнать, есть ли следующая страница
+	filter.Limit++
+	list, total, err := o.storage.ListPage(ctx, userID, filter)
+	if err != nil {
+		return nil, constants.OrdersListInternalError, err
+	}
+
+	page := &OrderPage{
+		Items: make([]OrderItem, 0, len(list)),
+		Total: total,
+	}
+
+	if len(list) > q.Limit {
+		list = list[:q.Limit]
+		last := list[len(list)-1]
+		page.NextCursor = encodeOrderCursor(last.UploadedAt, last.ID)
+	}
+
+	for _, item := range list {
+		page.Items = append(page.Items, OrderItem{
+			Number:     item.Num,
+			Status:     item.Status,
+			Accrual:    item.Accrual,
+			UploadedAt: item.UploadedAt.Format(time.RFC3339),
+		})
+	}
+
+	return page, constants.OrdersListOk, nil
+}
+
+// курсор страницы: время загрузки (в микросекундах, как хранит postgres) и id последнего заказа
+func encodeOrderCursor(uploadedAt time.Time, id int64) string {
+	raw := strconv.FormatInt(uploadedAt.UnixMicro(), 10) + ":" + strconv.FormatInt(id, 10)
+
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeOrderCursor(cursor string) (time.Time, int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("неверный курсор")
+	}
+
+	micro, id, found := strings.Cut(string(raw), ":")
+	if !found {
+		return time.Time{}, 0, fmt.Errorf("неверный курсор")
+	}
+
+	ts, err := strconv.ParseInt(micro, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("неверный курсор")
+	}
+
+	orderID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || orderID <= 0 {
+		return time.Time{}, 0, fmt.Errorf("неверный курсор")
+	}
+
+	return time.UnixMicro(ts), orderID, nil
+}
+
+// Статус должен быть одним из известных статусов заказа
+func orderStatusValidate(status string) bool {
+	switch status {
+	case constants.OrderNew, constants.OrderProcessing, constants.OrderInvalid, constants.OrderProcessed:
+		return true
+	}
+
+	return false
+}
+
 // постановка необработанных ордеров в очередь на проверку Accrual
 func (o *Order) ProcessUnchecked(ctx context.Context) {
 	go func() {
 		for {
 			select {
 			case <-ctx.Done():
-				logger.Log().Info("ProcessUnchecked DONE!!!")
+				logger.Log().WithContext(ctx).Info("ProcessUnchecked DONE!!!")
 				return
 			default:
 				orders, err := o.storage.GetUnchecked(ctx)
 				if err != nil {
-					logger.Log().Error(err.Error())
+					logger.Log().WithContext(ctx).Error(err.Error())
 				}
 
 				for _, order := range orders {
@@ -133,33 +444,43 @@ func (o *Order) ProcessUnchecked(ctx context.Context) {
 	}()
 }
 
-// Получение обработанных и сохранение в базу
-func (o *Order) ProcessChecked(ctx context.Context) {
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				logger.Log().Info("ProcessChecked DONE!!!")
-				return
-			default:
-				orderID, orderStatus, orderAccrual := o.ordersToSave.Pop(ctx)
-
-				switch orderStatus {
-				case constants.OrderInvalid, constants.OrderProcessing: // просто меняем статуc
-					err := o.storage.UpdateStatus(ctx, orderID, orderStatus)
-					if err != nil {
-						logger.Log().Error(err.Error())
-					}
-
-				case constants.OrderProcessed: // меняем статус и отправляем в базу балансов
-					// сохраняем в балансы
-					err := o.balanceAdd.AddTransaction(ctx, orderID, orderAccrual)
-					if err != nil {
-						logger.Log().Error(err.Error())
-					}
-				}
+// Получение обработанных и сохранение в базу, workers - кол-во параллельных обработчиков
+func (o *Order) ProcessChecked(ctx context.Context, workers int) {
+	o.savers.start(ctx, workers, o.saveChecked)
+}
+
+// SetSaveWorkers изменение кол-ва обработчиков проверенных заказов без остановки службы
+// лишние обработчики завершаются после сохранения текущего заказа, заказы в очереди не теряются
+func (o *Order) SetSaveWorkers(workers int) {
+	o.savers.resize(workers)
+}
 
+// SaveWorkers кол-во обработчиков проверенных заказов
+func (o *Order) SaveWorkers() int {
+	return o.savers.count()
+}
+
+// обработчик проверенных заказов: stop прерывает только ожидание очереди, взятый заказ сохраняется в контексте ctx
+func (o *Order) saveChecked(ctx context.Context, stop context.Context) {
+	for {
+		select {
+		case <-stop.Done():
+			logger.Log().WithContext(ctx).Info("ProcessChecked DONE!!!")
+			return
+		default:
+			orderID, orderStatus, accrualStatus, orderAccrual := o.ordersToSave.Pop(stop)
+			if orderID == "" { // обработчик остановлен
+				continue
+			}
+
+			switch orderStatus {
+			case constants.OrderInvalid, constants.OrderProcessing, constants.OrderProcessed:
+				// отклоненный переход (в т.ч. заказ удален пользователем, пока был в очереди) уже учтен в SetStatus
+				err := o.SetStatus(ctx, orderID, orderStatus, accrualStatus, orderAccrual, constants.StatusSourceAccrual)
+				if err != nil && !errors.Is(err, storage.ErrTransitionRejected) {
+					logger.Log().WithContext(ctx).Error(err.Error())
+				}
 			}
 		}
-	}()
+	}
 }
@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"github.com/dnsoftware/gophermart2/internal/constants"
 	mock_domain "github.com/dnsoftware/gophermart2/internal/gophermart/domain/mocks"
@@ -10,7 +11,6 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"net/http"
-	"strconv"
 	"testing"
 	"time"
 )
@@ -25,18 +25,18 @@ func TestOrder_AddOrder(t *testing.T) {
 	type args struct {
 		ctx    context.Context
 		userID int64
-		number int64
+		number string
 	}
 
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	ctx := context.Background()
-	chanUnchecked := NewOrdersUnchecked()
-	chanChecked := NewOrdersChecked()
+	chanUnchecked := NewOrdersUnchecked(constants.OrdersChannelCapacity)
+	chanChecked := NewOrdersChecked(constants.OrdersChannelCapacity)
 
 	mockOrder := mock_domain.NewMockOrderStorage(ctrl)
-	mockOrder.EXPECT().Create(ctx, int64(1), int64(3840576627)).Return(constants.OrderAccepted, nil)
+	mockOrder.EXPECT().Create(ctx, int64(1), "3840576627", int64(0)).Return(constants.OrderAccepted, nil)
 
 	mockBalance := mock_domain.NewMockBalanceAdd(ctrl)
 
@@ -55,7 +55,7 @@ func TestOrder_AddOrder(t *testing.T) {
 				ordersToSave:  chanChecked,
 				balanceAdd:    mockBalance,
 			},
-			args:    args{ctx, 1, 3840576627},
+			args:    args{ctx, 1, "3840576627"},
 			want:    constants.OrderAccepted,
 			wantErr: assert.NoError,
 		},
@@ -67,6 +67,7 @@ func TestOrder_AddOrder(t *testing.T) {
 				ordersToCheck: tt.fields.ordersToCheck,
 				ordersToSave:  tt.fields.ordersToSave,
 				balanceAdd:    tt.fields.balanceAdd,
+				states:        NewOrderStateMachine(),
 			}
 			got, err := o.AddOrder(tt.args.ctx, tt.args.userID, tt.args.number)
 			if !tt.wantErr(t, err, fmt.Sprintf("AddOrder(%v, %v, %v)", tt.args.ctx, tt.args.userID, tt.args.number)) {
@@ -86,7 +87,7 @@ func TestOrder_SetStatus(t *testing.T) {
 	}
 	type args struct {
 		ctx         context.Context
-		orderNumber int64
+		orderNumber string
 		orderStatus string
 	}
 
@@ -94,11 +95,11 @@ func TestOrder_SetStatus(t *testing.T) {
 	defer ctrl.Finish()
 
 	ctx := context.Background()
-	chanUnchecked := NewOrdersUnchecked()
-	chanChecked := NewOrdersChecked()
+	chanUnchecked := NewOrdersUnchecked(constants.OrdersChannelCapacity)
+	chanChecked := NewOrdersChecked(constants.OrdersChannelCapacity)
 
 	mockOrder := mock_domain.NewMockOrderStorage(ctrl)
-	mockOrder.EXPECT().UpdateStatus(ctx, int64(3840576627), constants.OrderProcessing).Return(nil)
+	mockOrder.EXPECT().UpdateStatus(ctx, "3840576627", []string{constants.OrderNew, constants.OrderProcessing}, constants.OrderProcessing, constants.AccrualRegistered, constants.StatusSourceAccrual).Return(nil)
 
 	mockBalance := mock_domain.NewMockBalanceAdd(ctrl)
 
@@ -118,7 +119,7 @@ func TestOrder_SetStatus(t *testing.T) {
 			},
 			args: args{
 				ctx:         ctx,
-				orderNumber: 3840576627,
+				orderNumber: "3840576627",
 				orderStatus: constants.OrderProcessing,
 			},
 			wantErr: assert.NoError,
@@ -131,8 +132,9 @@ func TestOrder_SetStatus(t *testing.T) {
 				ordersToCheck: tt.fields.ordersToCheck,
 				ordersToSave:  tt.fields.ordersToSave,
 				balanceAdd:    tt.fields.balanceAdd,
+				states:        NewOrderStateMachine(),
 			}
-			tt.wantErr(t, o.SetStatus(tt.args.ctx, tt.args.orderNumber, tt.args.orderStatus), fmt.Sprintf("SetStatus(%v, %v, %v)", tt.args.ctx, tt.args.orderNumber, tt.args.orderStatus))
+			tt.wantErr(t, o.SetStatus(tt.args.ctx, tt.args.orderNumber, tt.args.orderStatus, constants.AccrualRegistered, 0, constants.StatusSourceAccrual), fmt.Sprintf("SetStatus(%v, %v, %v)", tt.args.ctx, tt.args.orderNumber, tt.args.orderStatus))
 		})
 	}
 }
@@ -153,8 +155,8 @@ func TestOrder_OrdersList(t *testing.T) {
 	defer ctrl.Finish()
 
 	ctx := context.Background()
-	chanUnchecked := NewOrdersUnchecked()
-	chanChecked := NewOrdersChecked()
+	chanUnchecked := NewOrdersUnchecked(constants.OrdersChannelCapacity)
+	chanChecked := NewOrdersChecked(constants.OrdersChannelCapacity)
 
 	tm, _ := time.Parse(time.RFC3339, "2024-03-19T15:24:39-07:00")
 
@@ -162,7 +164,7 @@ func TestOrder_OrdersList(t *testing.T) {
 	mockOrder.EXPECT().List(ctx, int64(1)).Return([]storage.OrderRow{{
 		ID:         1,
 		UserID:     1,
-		Num:        3840576627,
+		Num:        "3840576627",
 		Status:     constants.OrderProcessed,
 		Accrual:    729.98,
 		UploadedAt: tm,
@@ -207,6 +209,7 @@ func TestOrder_OrdersList(t *testing.T) {
 				ordersToCheck: tt.fields.ordersToCheck,
 				ordersToSave:  tt.fields.ordersToSave,
 				balanceAdd:    tt.fields.balanceAdd,
+				states:        NewOrderStateMachine(),
 			}
 			got, got1, err := o.OrdersList(tt.args.ctx, tt.args.userID)
 			if !tt.wantErr(t, err, fmt.Sprintf("OrdersList(%v, %v)", tt.args.ctx, tt.args.userID)) {
@@ -230,15 +233,15 @@ func TestOrder_ProcessUnchecked(t *testing.T) {
 	defer ctrl.Finish()
 
 	ctx := context.Background()
-	chanUnchecked := NewOrdersUnchecked()
-	chanChecked := NewOrdersChecked()
+	chanUnchecked := NewOrdersUnchecked(constants.OrdersChannelCapacity)
+	chanChecked := NewOrdersChecked(constants.OrdersChannelCapacity)
 
 	mockOrder := mock_domain.NewMockOrderStorage(ctrl)
 	tm, _ := time.Parse(time.RFC3339, "2024-03-19T15:24:39-07:00")
 	mockOrder.EXPECT().GetUnchecked(ctx).Return([]storage.OrderRow{{
 		ID:         1,
 		UserID:     1,
-		Num:        3840576627,
+		Num:        "3840576627",
 		Status:     constants.OrderProcessed,
 		Accrual:    729.98,
 		UploadedAt: tm,
@@ -247,12 +250,12 @@ func TestOrder_ProcessUnchecked(t *testing.T) {
 	mockBalance := mock_domain.NewMockBalanceAdd(ctrl)
 
 	mockAccrual := mock_domain.NewMockAccrualStorage(ctrl)
-	mockAccrual.EXPECT().GetOrder(int64(3840576627)).Return(&storage.AccrualRow{
+	mockAccrual.EXPECT().GetOrder(gomock.Any(), "3840576627").Return(&storage.AccrualRow{
 		Order:   "3840576627",
 		Status:  constants.OrderProcessed,
 		Accrual: 729.98,
 	}, http.StatusOK, nil).AnyTimes()
-	accrual := NewAccrualModel(mockAccrual, chanUnchecked, chanChecked)
+	accrual := NewAccrualModel(mockAccrual, chanUnchecked, chanChecked, constants.AccrualServiceQueryLimit)
 	go accrual.StartAccrualChecker(ctx)
 
 	type args struct {
@@ -281,11 +284,12 @@ func TestOrder_ProcessUnchecked(t *testing.T) {
 				ordersToCheck: tt.fields.ordersToCheck,
 				ordersToSave:  tt.fields.ordersToSave,
 				balanceAdd:    tt.fields.balanceAdd,
+				states:        NewOrderStateMachine(),
 			}
 			o.ProcessUnchecked(tt.args.ctx)
 			number := accrual.ordersToCheck.Pop(ctx)
 
-			require.Equal(t, int64(3840576627), number, "Номера ордеров на проверку должны совпадать")
+			require.Equal(t, "3840576627", number, "Номера ордеров на проверку должны совпадать")
 
 		})
 	}
@@ -303,20 +307,20 @@ func TestOrder_ProcessChecked(t *testing.T) {
 	defer ctrl.Finish()
 
 	ctx := context.Background()
-	chanUnchecked := NewOrdersUnchecked()
-	chanChecked := NewOrdersChecked()
+	chanUnchecked := NewOrdersUnchecked(constants.OrdersChannelCapacity)
+	chanChecked := NewOrdersChecked(constants.OrdersChannelCapacity)
 
 	mockOrder := mock_domain.NewMockOrderStorage(ctrl)
 	mockBalance := mock_domain.NewMockBalanceAdd(ctrl)
-	mockBalance.EXPECT().AddTransaction(ctx, int64(3840576627), float32(729.98)).Return(nil)
+	mockBalance.EXPECT().AddTransaction(ctx, "3840576627", float32(729.98), []string{constants.OrderNew, constants.OrderProcessing}, constants.StatusSourceAccrual).Return(nil)
 
 	mockAccrual := mock_domain.NewMockAccrualStorage(ctrl)
-	mockAccrual.EXPECT().GetOrder(int64(3840576627)).Return(&storage.AccrualRow{
+	mockAccrual.EXPECT().GetOrder(gomock.Any(), "3840576627").Return(&storage.AccrualRow{
 		Order:   "3840576627",
 		Status:  "PROCESSED",
 		Accrual: 729.98,
 	}, http.StatusOK, nil).AnyTimes()
-	accrual := NewAccrualModel(mockAccrual, chanUnchecked, chanChecked)
+	accrual := NewAccrualModel(mockAccrual, chanUnchecked, chanChecked, constants.AccrualServiceQueryLimit)
 	go accrual.StartAccrualChecker(ctx)
 
 	type args struct {
@@ -345,15 +349,162 @@ func TestOrder_ProcessChecked(t *testing.T) {
 				ordersToCheck: tt.fields.ordersToCheck,
 				ordersToSave:  tt.fields.ordersToSave,
 				balanceAdd:    tt.fields.balanceAdd,
+				states:        NewOrderStateMachine(),
 			}
-			o.ProcessChecked(tt.args.ctx)
-			accRow, status, err := accrual.storage.GetOrder(int64(3840576627))
+			o.ProcessChecked(tt.args.ctx, 1)
+			accRow, status, err := accrual.storage.GetOrder(ctx, "3840576627")
 			require.NoError(t, err)
 			require.Equal(t, http.StatusOK, status)
 
-			accRowInt64, _ := strconv.ParseInt(accRow.Order, 10, 64)
-			accrual.ordersToSave.Push(accRowInt64, accRow.Status, accRow.Accrual)
+			accrual.ordersToSave.Push(accRow.Order, accRow.Status, accRow.Status, accRow.Accrual)
 			time.Sleep(3 * time.Second)
 		})
 	}
 }
+
+func TestOrder_OrdersPage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockOrder := mock_domain.NewMockOrderStorage(ctrl)
+	order := NewOrderModel(mockOrder, nil, nil, nil)
+
+	uploaded := time.Date(2024, 1, 10, 12, 0, 0, 123456000, time.UTC)
+	rows := []storage.OrderRow{
+		{ID: 3, Num: "3840576627", Status: constants.OrderProcessed, Accrual: 50, UploadedAt: uploaded},
+		{ID: 2, Num: "12345678903", Status: constants.OrderProcessed, UploadedAt: uploaded.Add(-time.Hour)},
+		{ID: 1, Num: "79927398713", Status: constants.OrderProcessed, UploadedAt: uploaded.Add(-2 * time.Hour)},
+	}
+
+	// первая страница: запрашивается на одну запись больше, курсор указывает на последнюю запись страницы
+	mockOrder.EXPECT().ListPage(ctx, int64(1), storage.OrderFilter{
+		Statuses: []string{constants.OrderProcessed},
+		Limit:    3,
+	}).Return(rows, 5, nil)
+
+	page, status, err := order.OrdersPage(ctx, 1, OrderListQuery{Statuses: []string{constants.OrderProcessed}, Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, constants.OrdersListOk, status)
+	require.Equal(t, 5, page.Total)
+	require.Len(t, page.Items, 2)
+	require.Equal(t, "3840576627", page.Items[0].Number)
+	require.NotEmpty(t, page.NextCursor)
+
+	// следующая страница по курсору, сортировка от старых к новым
+	mockOrder.EXPECT().ListPage(ctx, int64(1), storage.OrderFilter{
+		Ascending: true,
+		AfterTime: time.UnixMicro(rows[1].UploadedAt.UnixMicro()),
+		AfterID:   2,
+		Limit:     3,
+	}).Return(rows[2:], 5, nil)
+
+	page, _, err = order.OrdersPage(ctx, 1, OrderListQuery{Sort: constants.OrdersSortOldest, Cursor: page.NextCursor, Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	require.Empty(t, page.NextCursor)
+
+	// negative
+	tests := []struct {
+		name string
+		q    OrderListQuery
+	}{
+		{name: "Неизвестный статус", q: OrderListQuery{Statuses: []string{"DONE"}, Limit: 10}},
+		{name: "Нулевой размер страницы", q: OrderListQuery{}},
+		{name: "Слишком большая страница", q: OrderListQuery{Limit: constants.OrdersPageMaxLimit + 1}},
+		{name: "Неизвестная сортировка", q: OrderListQuery{Sort: "random", Limit: 10}},
+		{name: "Неверный курсор", q: OrderListQuery{Cursor: "!!!", Limit: 10}},
+	}
+
+	for _, test := range tests {
+		_, status, err = order.OrdersPage(ctx, 1, test.q)
+		require.Error(t, err, test.name)
+		require.Equal(t, constants.OrdersListBadFormat, status, test.name)
+	}
+}
+
+func TestOrder_OrderDetail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockOrder := mock_domain.NewMockOrderStorage(ctrl)
+	order := NewOrderModel(mockOrder, nil, nil, nil)
+
+	uploaded := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	mockOrder.EXPECT().GetOrderByNumber(ctx, "3840576627").Return(storage.OrderRow{
+		ID: 1, UserID: 1, Num: "3840576627", Status: constants.OrderProcessed, Accrual: 500, UploadedAt: uploaded,
+	}, nil).Times(2)
+	mockOrder.EXPECT().StatusHistory(ctx, "3840576627").Return([]storage.OrderStatusRow{
+		{Status: constants.OrderNew, Source: constants.StatusSourceUser, CreatedAt: uploaded},
+		{Status: constants.OrderProcessing, AccrualStatus: constants.AccrualRegistered, Source: constants.StatusSourceAccrual, CreatedAt: uploaded.Add(time.Minute)},
+		{Status: constants.OrderProcessed, AccrualStatus: constants.AccrualProcessed, Source: constants.StatusSourceAccrual, CreatedAt: uploaded.Add(2 * time.Minute)},
+	}, nil)
+
+	// positive
+	detail, status, err := order.OrderDetail(ctx, 1, "3840576627")
+	require.NoError(t, err)
+	require.Equal(t, constants.OrderDetailOk, status)
+	require.Equal(t, "3840576627", detail.Number)
+	require.Len(t, detail.History, 3)
+	require.Equal(t, constants.AccrualRegistered, detail.History[1].AccrualStatus)
+
+	// заказ другого пользователя
+	_, status, err = order.OrderDetail(ctx, 2, "3840576627")
+	require.Error(t, err)
+	require.Equal(t, constants.OrderDetailNotFound, status)
+
+	// заказ не найден
+	mockOrder.EXPECT().GetOrderByNumber(ctx, "79927398713").Return(storage.OrderRow{}, fmt.Errorf("GetOrder Scan: %w", sql.ErrNoRows))
+	_, status, err = order.OrderDetail(ctx, 1, "79927398713")
+	require.Error(t, err)
+	require.Equal(t, constants.OrderDetailNotFound, status)
+}
+
+func TestOrder_AddOrders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockOrder := mock_domain.NewMockOrderStorage(ctrl)
+	order := NewOrderModel(mockOrder, nil, nil, nil)
+
+	// неверные номера в базу не передаются, порядок результатов совпадает с порядком номеров
+	mockOrder.EXPECT().CreateBatch(ctx, int64(1), []string{"3840576627", "12345678903", "79927398713"}).
+		Return([]int{constants.OrderAccepted, constants.OrderOk, constants.OrderAlreadyUpload}, nil)
+
+	results, status, err := order.AddOrders(ctx, 1, []string{"3840576627", "1234", "12345678903", "abc", "79927398713"})
+	require.NoError(t, err)
+	require.Equal(t, constants.OrdersBatchOk, status)
+	require.Len(t, results, 5)
+
+	want := []struct {
+		result string
+		code   int
+	}{
+		{constants.BatchResultAccepted, http.StatusAccepted},
+		{constants.BatchResultInvalid, http.StatusUnprocessableEntity},
+		{constants.BatchResultAlreadyYours, http.StatusOK},
+		{constants.BatchResultInvalid, http.StatusUnprocessableEntity},
+		{constants.BatchResultOwnedByOther, http.StatusConflict},
+	}
+	for i, w := range want {
+		require.Equal(t, w.result, results[i].Result, results[i].Number)
+		require.Equal(t, w.code, results[i].Code, results[i].Number)
+	}
+
+	// все номера неверные - в базу не обращаемся
+	results, status, err = order.AddOrders(ctx, 1, []string{"1234"})
+	require.NoError(t, err)
+	require.Equal(t, constants.OrdersBatchOk, status)
+	require.Equal(t, constants.BatchResultInvalid, results[0].Result)
+
+	// пустой и слишком большой пакет
+	_, status, err = order.AddOrders(ctx, 1, nil)
+	require.Error(t, err)
+	require.Equal(t, constants.OrdersBatchBadFormat, status)
+
+	_, status, err = order.AddOrders(ctx, 1, make([]string, constants.OrdersBatchMaxSize+1))
+	require.Error(t, err)
+	require.Equal(t, constants.OrdersBatchTooLarge, status)
+}
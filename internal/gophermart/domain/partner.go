@@ -0,0 +1,88 @@
+package domain
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	"github.com/dnsoftware/gophermart2/internal/logger"
+	"time"
+)
+
+// Partner операции партнерских систем, работающих по ключам API
+type Partner struct {
+	keys    APIKeyStorage
+	limiter *rateLimiter
+	users   *User
+	orders  *Order
+}
+
+func NewPartnerModel(keys APIKeyStorage, users *User, orders *Order) *Partner {
+	partner := &Partner{
+		keys:    keys,
+		limiter: newRateLimiter(),
+		users:   users,
+		orders:  orders,
+	}
+
+	return partner
+}
+
+// Authenticate проверка ключа API и учет лимита запросов по нему
+func (p *Partner) Authenticate(ctx context.Context, key string) (*APIClient, int, error) {
+	keyID, ok := parseAPIKey(key)
+	if !ok {
+		return nil, constants.APIKeyInvalid, fmt.Errorf("неверный формат ключа API")
+	}
+
+	row, err := p.keys.FindAPIKey(ctx, keyID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, constants.APIKeyInvalid, fmt.Errorf("ключ API %v не найден", keyID)
+	}
+	if err != nil {
+		return nil, constants.APIKeyInternalError, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(row.KeyHash), []byte(TokenHash(key))) != 1 {
+		return nil, constants.APIKeyInvalid, fmt.Errorf("неверный ключ API %v", keyID)
+	}
+
+	if !row.RevokedAt.IsZero() {
+		return nil, constants.APIKeyInvalid, fmt.Errorf("ключ API %v отозван", keyID)
+	}
+
+	if !p.limiter.Allow(row.ID, row.RateLimit, time.Now()) {
+		return nil, constants.APIKeyRateLimited, fmt.Errorf("превышен лимит запросов по ключу API %v", keyID)
+	}
+
+	client := &APIClient{
+		ID:         row.ID,
+		Name:       row.Name,
+		Scopes:     row.Scopes,
+		SigningKey: row.SigningKey,
+	}
+
+	return client, constants.APIKeyOk, nil
+}
+
+// UploadOrder загрузка заказа от имени пользователя с заданным логином
+func (p *Partner) UploadOrder(ctx context.Context, client *APIClient, login string, number string) (int, error) {
+	userID, found, err := p.users.IDByLogin(ctx, login)
+	if err != nil {
+		return constants.OrderInternalError, err
+	}
+	if !found {
+		return constants.PartnerUserNotFound, fmt.Errorf("пользователь %v не найден", login)
+	}
+
+	status, err := p.orders.AddPartnerOrder(ctx, client.ID, userID, number)
+	if err != nil {
+		return status, err
+	}
+
+	logger.Log().WithContext(ctx).Info(fmt.Sprintf("Партнер %v (%v) загрузил заказ %v для пользователя %v", client.Name, client.ID, number, login))
+
+	return status, nil
+}
@@ -0,0 +1,105 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	mock_domain "github.com/dnsoftware/gophermart2/internal/gophermart/domain/mocks"
+	"github.com/dnsoftware/gophermart2/internal/storage"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestPartner_Authenticate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockKeys := mock_domain.NewMockAPIKeyStorage(ctrl)
+	partner := NewPartnerModel(mockKeys, nil, nil)
+
+	key := constants.APIKeyPrefix + "_0a1b2c3d_secret"
+	row := storage.APIKeyRow{
+		ID:        5,
+		Name:      "shop",
+		KeyID:     "0a1b2c3d",
+		KeyHash:   TokenHash(key),
+		Scopes:    []string{constants.ScopeOrdersWrite},
+		RateLimit: 1,
+	}
+
+	// positive
+	mockKeys.EXPECT().FindAPIKey(ctx, "0a1b2c3d").Return(row, nil).Times(2)
+
+	client, status, err := partner.Authenticate(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, constants.APIKeyOk, status)
+	require.Equal(t, int64(5), client.ID)
+	require.True(t, client.HasScope(constants.ScopeOrdersWrite))
+
+	// лимит запросов исчерпан
+	_, status, err = partner.Authenticate(ctx, key)
+	require.Error(t, err)
+	require.Equal(t, constants.APIKeyRateLimited, status)
+
+	// неверный формат
+	_, status, err = partner.Authenticate(ctx, "secret")
+	require.Error(t, err)
+	require.Equal(t, constants.APIKeyInvalid, status)
+
+	// неизвестный ключ
+	mockKeys.EXPECT().FindAPIKey(ctx, "ffffffff").Return(storage.APIKeyRow{}, fmt.Errorf("FindAPIKey: %w", sql.ErrNoRows))
+	_, status, err = partner.Authenticate(ctx, constants.APIKeyPrefix+"_ffffffff_secret")
+	require.Error(t, err)
+	require.Equal(t, constants.APIKeyInvalid, status)
+
+	// неверная секретная часть
+	mockKeys.EXPECT().FindAPIKey(ctx, "0a1b2c3d").Return(row, nil)
+	_, status, err = partner.Authenticate(ctx, constants.APIKeyPrefix+"_0a1b2c3d_wrong")
+	require.Error(t, err)
+	require.Equal(t, constants.APIKeyInvalid, status)
+
+	// отозванный ключ
+	revoked := row
+	revoked.RevokedAt = time.Now()
+	mockKeys.EXPECT().FindAPIKey(ctx, "0a1b2c3d").Return(revoked, nil)
+	_, status, err = partner.Authenticate(ctx, key)
+	require.Error(t, err)
+	require.Equal(t, constants.APIKeyInvalid, status)
+}
+
+func TestPartner_UploadOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockUser := mock_domain.NewMockUserStorage(ctrl)
+	mockOrder := mock_domain.NewMockOrderStorage(ctrl)
+	user := NewUserModel(mockUser, nil)
+	order := NewOrderModel(mockOrder, NewOrdersUnchecked(constants.OrdersChannelCapacity), NewOrdersChecked(constants.OrdersChannelCapacity), nil)
+	partner := NewPartnerModel(nil, user, order)
+	client := &APIClient{ID: 5, Name: "shop", Scopes: []string{constants.ScopeOrdersWrite}}
+
+	// positive: заказ загружается от имени найденного пользователя и запоминается ключ партнера
+	mockUser.EXPECT().FindIDByLogin(ctx, "user").Return(int64(2), true, nil)
+	mockOrder.EXPECT().Create(ctx, int64(2), "3840576627", int64(5)).Return(constants.OrderAccepted, nil)
+
+	status, err := partner.UploadOrder(ctx, client, "user", "3840576627")
+	require.NoError(t, err)
+	require.Equal(t, constants.OrderAccepted, status)
+
+	// пользователь не найден
+	mockUser.EXPECT().FindIDByLogin(ctx, "nobody").Return(int64(0), false, nil)
+	status, err = partner.UploadOrder(ctx, client, "nobody", "3840576627")
+	require.Error(t, err)
+	require.Equal(t, constants.PartnerUserNotFound, status)
+
+	// неверный номер заказа
+	mockUser.EXPECT().FindIDByLogin(ctx, "user").Return(int64(2), true, nil)
+	status, err = partner.UploadOrder(ctx, client, "user", "1234")
+	require.Error(t, err)
+	require.Equal(t, constants.OrderBadNumberFormat, status)
+}
@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"context"
+	"sync"
+)
+
+// workerPool набор одинаковых обработчиков очереди, кол-во которых меняется без остановки службы
+// обработчик получает два контекста: ctx службы - для обработки взятого из очереди,
+// и stop - только для ожидания очереди; отмена stop не прерывает обработку уже взятого элемента
+type workerPool struct {
+	mu      sync.Mutex
+	ctx     context.Context // nil - пул еще не запущен
+	work    func(ctx context.Context, stop context.Context)
+	cancels []context.CancelFunc
+	size    int
+}
+
+// запуск пула, до запуска resize только запоминает размер
+func (p *workerPool) start(ctx context.Context, size int, work func(ctx context.Context, stop context.Context)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.ctx = ctx
+	p.work = work
+	p.size = size
+	p.adjust()
+}
+
+// изменение кол-ва обработчиков: лишние завершаются после обработки текущего элемента
+func (p *workerPool) resize(size int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.size = size
+	if p.ctx != nil {
+		p.adjust()
+	}
+}
+
+// текущее кол-во обработчиков
+func (p *workerPool) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.size
+}
+
+func (p *workerPool) adjust() {
+	for len(p.cancels) < p.size {
+		stop, cancel := context.WithCancel(p.ctx)
+		p.cancels = append(p.cancels, cancel)
+		go p.work(p.ctx, stop)
+	}
+
+	for len(p.cancels) > p.size {
+		last := len(p.cancels) - 1
+		p.cancels[last]()
+		p.cancels = p.cancels[:last]
+	}
+}
@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkerPool(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	queue := make(chan int, 100)
+	var running atomic.Int32
+	var mu sync.Mutex
+	var done []int
+
+	work := func(ctx context.Context, stop context.Context) {
+		running.Add(1)
+		defer running.Add(-1)
+
+		for {
+			select {
+			case <-stop.Done():
+				return
+			case item := <-queue:
+				// взятый элемент обрабатывается до конца, даже если обработчик уже остановлен
+				time.Sleep(10 * time.Millisecond)
+				mu.Lock()
+				done = append(done, item)
+				mu.Unlock()
+			}
+		}
+	}
+
+	var pool workerPool
+	pool.resize(3) // до запуска только запоминается
+	require.Equal(t, 3, pool.count())
+	require.Equal(t, int32(0), running.Load())
+
+	pool.start(ctx, 2, work)
+	require.Eventually(t, func() bool { return running.Load() == 2 }, time.Second, time.Millisecond)
+
+	pool.resize(5)
+	require.Eventually(t, func() bool { return running.Load() == 5 }, time.Second, time.Millisecond)
+
+	for i := 0; i < 50; i++ {
+		queue <- i
+	}
+	pool.resize(1)
+	require.Eventually(t, func() bool { return running.Load() == 1 }, time.Second, time.Millisecond)
+	require.Equal(t, 1, pool.count())
+
+	// оставшийся обработчик разбирает очередь, ни один элемент не потерян
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(done) == 50
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.Eventually(t, func() bool { return running.Load() == 0 }, time.Second, time.Millisecond)
+}
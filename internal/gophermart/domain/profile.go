@@ -0,0 +1,109 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	"github.com/dnsoftware/gophermart2/internal/logger"
+	"regexp"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	emailRe  = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	localeRe = regexp.MustCompile(`^[a-z]{2}(-[A-Z]{2})?$`)
+)
+
+// UserProfile сведения о пользователе для ответа "кто я"
+type UserProfile struct {
+	Login            string `json:"login"`
+	DisplayName      string `json:"display_name,omitempty"`
+	Email            string `json:"email,omitempty"`
+	Locale           string `json:"locale,omitempty"`
+	RegisteredAt     string `json:"registered_at"`
+	LastLoginAt      string `json:"last_login_at,omitempty"`
+	OrdersCount      int64  `json:"orders_count"`
+	WithdrawalsCount int64  `json:"withdrawals_count"`
+	DeletionAt       string `json:"deletion_scheduled_at,omitempty"` // когда аккаунт будет обезличен
+}
+
+// ProfileUpdate редактируемые поля профиля, nil - поле не меняется
+type ProfileUpdate struct {
+	DisplayName *string `json:"display_name"`
+	Email       *string `json:"email"`
+	Locale      *string `json:"locale"`
+}
+
+// Profile профиль пользователя со статистикой
+func (u *User) Profile(ctx context.Context, userID int64) (*UserProfile, int, error) {
+	row, err := u.storage.FindByID(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, constants.ProfileNotFound, fmt.Errorf("пользователь %v не найден", userID)
+	}
+	if err != nil {
+		logger.Log().WithContext(ctx).Error("Profile: " + err.Error())
+		return nil, constants.ProfileInternalError, err
+	}
+
+	orders, withdrawals, err := u.storage.ActivityCounts(ctx, userID)
+	if err != nil {
+		logger.Log().WithContext(ctx).Error("Profile ActivityCounts: " + err.Error())
+		return nil, constants.ProfileInternalError, err
+	}
+
+	profile := &UserProfile{
+		Login:            row.Login,
+		DisplayName:      row.DisplayName,
+		Email:            row.Email,
+		Locale:           row.Locale,
+		RegisteredAt:     row.CreatedAt.Format(time.RFC3339),
+		OrdersCount:      orders,
+		WithdrawalsCount: withdrawals,
+	}
+	if !row.LastLoginAt.IsZero() {
+		profile.LastLoginAt = row.LastLoginAt.Format(time.RFC3339)
+	}
+	if !row.DeletionAt.IsZero() {
+		profile.DeletionAt = row.DeletionAt.Add(constants.AccountDeletionGracePeriod).Format(time.RFC3339)
+	}
+
+	return profile, constants.ProfileOk, nil
+}
+
+// UpdateProfile изменение редактируемых полей профиля, возвращает обновленный профиль
+func (u *User) UpdateProfile(ctx context.Context, userID int64, upd ProfileUpdate) (*UserProfile, int, error) {
+	err := profileValidate(upd)
+	if err != nil {
+		return nil, constants.ProfileBadFormat, err
+	}
+
+	err = u.storage.UpdateProfile(ctx, userID, upd.DisplayName, upd.Email, upd.Locale)
+	if err != nil {
+		logger.Log().WithContext(ctx).Error("UpdateProfile: " + err.Error())
+		return nil, constants.ProfileInternalError, err
+	}
+
+	return u.Profile(ctx, userID)
+}
+
+// пустая строка допустима и означает очистку поля
+func profileValidate(upd ProfileUpdate) error {
+	if upd.DisplayName != nil && utf8.RuneCountInString(*upd.DisplayName) > constants.MaxDisplayNameLength {
+		return fmt.Errorf("отображаемое имя длиннее %v символов", constants.MaxDisplayNameLength)
+	}
+
+	if upd.Email != nil && *upd.Email != "" {
+		if len(*upd.Email) > constants.MaxEmailLength || !emailRe.MatchString(*upd.Email) {
+			return fmt.Errorf("некорректный email")
+		}
+	}
+
+	if upd.Locale != nil && *upd.Locale != "" && !localeRe.MatchString(*upd.Locale) {
+		return fmt.Errorf("некорректная локаль, ожидается формат ru или ru-RU")
+	}
+
+	return nil
+}
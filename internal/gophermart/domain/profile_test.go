@@ -0,0 +1,90 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	mock_domain "github.com/dnsoftware/gophermart2/internal/gophermart/domain/mocks"
+	"github.com/dnsoftware/gophermart2/internal/storage"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestUser_Profile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_domain.NewMockUserStorage(ctrl)
+
+	ctx := context.Background()
+	userModel := NewUserModel(m, nil)
+
+	created, _ := time.Parse(time.RFC3339, "2024-03-19T15:24:39-07:00")
+
+	// positive
+	m.EXPECT().FindByID(ctx, int64(1)).Return(storage.UserRow{
+		ID:          1,
+		Login:       "user",
+		DisplayName: "Иван",
+		CreatedAt:   created,
+	}, nil)
+	m.EXPECT().ActivityCounts(ctx, int64(1)).Return(int64(5), int64(2), nil)
+
+	profile, status, err := userModel.Profile(ctx, 1)
+
+	require.NoError(t, err)
+	require.Equal(t, constants.ProfileOk, status)
+	assert.Equal(t, &UserProfile{
+		Login:            "user",
+		DisplayName:      "Иван",
+		RegisteredAt:     "2024-03-19T15:24:39-07:00",
+		OrdersCount:      5,
+		WithdrawalsCount: 2,
+	}, profile)
+
+	// пользователь не найден
+	m.EXPECT().FindByID(ctx, int64(2)).Return(storage.UserRow{}, fmt.Errorf("FindByID Scan: %w", sql.ErrNoRows))
+
+	_, status, err = userModel.Profile(ctx, 2)
+
+	require.Error(t, err)
+	require.Equal(t, constants.ProfileNotFound, status)
+}
+
+func TestProfileValidate(t *testing.T) {
+	str := func(s string) *string { return &s }
+
+	tests := []struct {
+		name    string
+		upd     ProfileUpdate
+		wantErr bool
+	}{
+		{
+			name: "Все поля корректны",
+			upd:  ProfileUpdate{DisplayName: str("Иван"), Email: str("ivan@example.com"), Locale: str("ru-RU")},
+		},
+		{
+			name: "Очистка полей",
+			upd:  ProfileUpdate{Email: str(""), Locale: str("")},
+		},
+		{
+			name:    "Некорректный email",
+			upd:     ProfileUpdate{Email: str("ivan.example.com")},
+			wantErr: true,
+		},
+		{
+			name:    "Некорректная локаль",
+			upd:     ProfileUpdate{Locale: str("russian")},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		err := profileValidate(test.upd)
+		assert.Equal(t, test.wantErr, err != nil, test.name)
+	}
+}
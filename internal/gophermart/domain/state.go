@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	"github.com/dnsoftware/gophermart2/internal/logger"
+	"slices"
+	"sync/atomic"
+)
+
+// OrderStateMachine допустимые переходы между статусами заказа
+// INVALID и PROCESSED - конечные статусы, из них заказ никуда не переходит
+// правила действуют и для ручной смены статуса администратором, см. Admin.SetOrderStatus и Admin.RecheckOrder;
+// администратору дополнительно доступен возврат незавершенного заказа в NEW для повторной проверки
+type OrderStateMachine struct {
+	transitions map[string][]string // статус -> статусы, в которые из него можно перейти
+	admin       map[string][]string // переходы, доступные только администратору
+	rejected    atomic.Int64        // кол-во отклоненных переходов
+}
+
+func NewOrderStateMachine() *OrderStateMachine {
+	m := &OrderStateMachine{
+		transitions: map[string][]string{
+			constants.OrderNew: {constants.OrderProcessing, constants.OrderInvalid, constants.OrderProcessed},
+			// Accrual может несколько раз подряд вернуть незавершенный статус (REGISTERED, PROCESSING)
+			constants.OrderProcessing: {constants.OrderProcessing, constants.OrderInvalid, constants.OrderProcessed},
+			constants.OrderInvalid:    {},
+			constants.OrderProcessed:  {},
+		},
+		admin: map[string][]string{
+			constants.OrderNew:        {constants.OrderNew},
+			constants.OrderProcessing: {constants.OrderNew},
+		},
+	}
+
+	return m
+}
+
+// CanTransition разрешен ли переход из статуса from в статус to
+// source - кто меняет статус, constants.StatusSource*
+func (m *OrderStateMachine) CanTransition(from string, to string, source string) bool {
+	if slices.Contains(m.transitions[from], to) {
+		return true
+	}
+
+	return source == constants.StatusSourceAdmin && slices.Contains(m.admin[from], to)
+}
+
+// Sources статусы, из которых source разрешен переход в статус to
+// используются в условии обновления в базе (WHERE status = ANY(...))
+func (m *OrderStateMachine) Sources(to string, source string) []string {
+	sources := make([]string, 0)
+
+	// порядок статусов фиксирован, чтобы условие запроса было детерминированным
+	for _, from := range []string{constants.OrderNew, constants.OrderProcessing, constants.OrderInvalid, constants.OrderProcessed} {
+		if m.CanTransition(from, to, source) {
+			sources = append(sources, from)
+		}
+	}
+
+	return sources
+}
+
+// Reject учет отклоненного перехода
+func (m *OrderStateMachine) Reject(ctx context.Context, orderNumber string, to string) {
+	m.rejected.Add(1)
+
+	logger.Log().WithContext(ctx).Warn(fmt.Sprintf("Отклонен переход заказа %v в статус %v: текущий статус не допускает перехода", orderNumber, to))
+}
+
+// Rejected кол-во отклоненных переходов с момента запуска
+func (m *OrderStateMachine) Rejected() int64 {
+	return m.rejected.Load()
+}
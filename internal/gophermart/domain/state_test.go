@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	mock_domain "github.com/dnsoftware/gophermart2/internal/gophermart/domain/mocks"
+	"github.com/dnsoftware/gophermart2/internal/storage"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestOrderStateMachine_CanTransition(t *testing.T) {
+	m := NewOrderStateMachine()
+
+	tests := []struct {
+		from   string
+		to     string
+		source string
+		want   bool
+	}{
+		{constants.OrderNew, constants.OrderProcessing, constants.StatusSourceAccrual, true},
+		{constants.OrderNew, constants.OrderInvalid, constants.StatusSourceAccrual, true},
+		{constants.OrderNew, constants.OrderProcessed, constants.StatusSourceAccrual, true},
+		{constants.OrderProcessing, constants.OrderProcessing, constants.StatusSourceAccrual, true},
+		{constants.OrderProcessing, constants.OrderProcessed, constants.StatusSourceAccrual, true},
+		{constants.OrderProcessing, constants.OrderNew, constants.StatusSourceAccrual, false},
+		{constants.OrderProcessing, constants.OrderNew, constants.StatusSourceAdmin, true},
+		{constants.OrderNew, constants.OrderNew, constants.StatusSourceAdmin, true},
+		{constants.OrderProcessed, constants.OrderNew, constants.StatusSourceAdmin, false},
+		{constants.OrderInvalid, constants.OrderNew, constants.StatusSourceAdmin, false},
+		{constants.OrderProcessed, constants.OrderProcessing, constants.StatusSourceAdmin, false},
+		{constants.OrderProcessed, constants.OrderInvalid, constants.StatusSourceAccrual, false},
+		{constants.OrderInvalid, constants.OrderProcessed, constants.StatusSourceAdmin, false},
+		{"UNKNOWN", constants.OrderProcessed, constants.StatusSourceAccrual, false},
+	}
+
+	for _, test := range tests {
+		require.Equal(t, test.want, m.CanTransition(test.from, test.to, test.source), "%v -> %v (%v)", test.from, test.to, test.source)
+	}
+
+	require.Equal(t, []string{constants.OrderNew, constants.OrderProcessing}, m.Sources(constants.OrderProcessed, constants.StatusSourceAccrual))
+	require.Empty(t, m.Sources(constants.OrderNew, constants.StatusSourceAccrual))
+	// возврат в NEW для повторной проверки доступен только администратору
+	require.Equal(t, []string{constants.OrderNew, constants.OrderProcessing}, m.Sources(constants.OrderNew, constants.StatusSourceAdmin))
+}
+
+func TestOrder_SetStatusRejected(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockOrder := mock_domain.NewMockOrderStorage(ctrl)
+	order := NewOrderModel(mockOrder, nil, nil, nil)
+
+	// обработанный заказ не возвращается в PROCESSING, отказ учитывается
+	mockOrder.EXPECT().UpdateStatus(ctx, "3840576627", []string{constants.OrderNew, constants.OrderProcessing}, constants.OrderProcessing, constants.AccrualProcessing, constants.StatusSourceAccrual).
+		Return(fmt.Errorf("UpdateStatus 3840576627: %w", storage.ErrTransitionRejected))
+
+	err := order.SetStatus(ctx, "3840576627", constants.OrderProcessing, constants.AccrualProcessing, 0, constants.StatusSourceAccrual)
+	require.ErrorIs(t, err, storage.ErrTransitionRejected)
+	require.Equal(t, int64(1), order.RejectedTransitions())
+}
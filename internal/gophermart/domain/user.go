@@ -2,12 +2,15 @@ package domain
 
 import (
 	"context"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"github.com/dnsoftware/gophermart2/internal/constants"
 	"github.com/dnsoftware/gophermart2/internal/logger"
 	"github.com/dnsoftware/gophermart2/internal/storage"
+	"strings"
+	"time"
 	"unicode"
 	"unicode/utf8"
 )
@@ -16,10 +19,27 @@ type UserStorage interface {
 	Create(ctx context.Context, login string, password string) (int64, int, error)
 	FindByID(ctx context.Context, id int64) (storage.UserRow, error)
 	FindByLoginPassword(ctx context.Context, login string, password string) (int64, int, error)
+	UpdatePassword(ctx context.Context, id int64, oldPassword string, newPassword string) (int, error)
+	CreatePasswordReset(ctx context.Context, login string, tokenHash string, expiresAt time.Time) (int64, error)
+	ResetPassword(ctx context.Context, tokenHash string, newPassword string) (int, error)
+	SessionState(ctx context.Context, id int64) (time.Time, bool, error)
+	ActivityCounts(ctx context.Context, id int64) (int64, int64, error)
+	UpdateProfile(ctx context.Context, id int64, displayName *string, email *string, locale *string) error
+	UpdateLastLogin(ctx context.Context, id int64) error
+	UserRole(ctx context.Context, id int64) (string, error)
+	SetRole(ctx context.Context, id int64, role string) (bool, error)
+	SetRoleByLogin(ctx context.Context, login string, role string) (bool, error)
+	FindIDByLogin(ctx context.Context, login string) (int64, bool, error)
+}
+
+// ResetNotifier доставка токена сброса пароля пользователю
+type ResetNotifier interface {
+	SendPasswordReset(ctx context.Context, login string, token string, expiresAt time.Time) error
 }
 
 type User struct {
-	storage UserStorage
+	storage  UserStorage
+	notifier ResetNotifier
 }
 
 // UserItem plain structure
@@ -29,9 +49,10 @@ type UserItem struct {
 	Password string `json:"password"`
 }
 
-func NewUserModel(storage UserStorage) *User {
+func NewUserModel(storage UserStorage, notifier ResetNotifier) *User {
 	user := &User{
-		storage: storage,
+		storage:  storage,
+		notifier: notifier,
 	}
 
 	return user
@@ -47,6 +68,10 @@ func (u *User) AddUser(ctx context.Context, login string, password string) (stri
 		status = constants.RegisterBadFormat
 		return "", status, fmt.Errorf("некорректный логин, длина должна быть больше/равна %v", constants.MinLoginLength)
 	}
+	if loginReserved(login) {
+		status = constants.RegisterBadFormat
+		return "", status, fmt.Errorf("некорректный логин, префикс %v зарезервирован", constants.DeletedLoginPrefix)
+	}
 	if !passwordValidate(password) {
 		status = constants.RegisterBadFormat
 		return "", status, fmt.Errorf("некорректный пароль, должен иметь заглавные, цифры, буквы и быть определенной длины >= %v", constants.MinPasswordLength)
@@ -54,11 +79,11 @@ func (u *User) AddUser(ctx context.Context, login string, password string) (stri
 
 	id, status, err := u.storage.Create(ctx, login, passCrypted)
 	if err != nil {
-		logger.Log().Error("AddUser: " + err.Error())
+		logger.Log().WithContext(ctx).Error("AddUser: " + err.Error())
 		return "", status, err
 	}
 
-	token, err := BuildJWTString(id)
+	token, err := BuildJWTString(id, constants.RoleUser)
 	if err != nil {
 		return "", constants.RegisterInternalError, fmt.Errorf("JWT error")
 	}
@@ -79,24 +104,216 @@ func (u *User) LoginUser(ctx context.Context, login string, password string) (st
 
 	id, status, err := u.storage.FindByLoginPassword(ctx, login, passCrypted)
 	if err != nil {
-		logger.Log().Error("LoginUser: " + err.Error())
+		logger.Log().WithContext(ctx).Error("LoginUser: " + err.Error())
+		return "", status, err
+	}
+
+	// неудача фиксации времени входа не должна мешать самому входу
+	err = u.storage.UpdateLastLogin(ctx, id)
+	if err != nil {
+		logger.Log().WithContext(ctx).Error("LoginUser UpdateLastLogin: " + err.Error())
+	}
+
+	token, err := u.issueToken(ctx, id)
+	if err != nil {
+		return "", constants.LoginInternalError, err
+	}
+
+	return token, status, nil
+}
+
+// смена пароля авторизованным пользователем
+// возвращает новый токен (старые становятся недействительными), статус завершения операции и ошибку
+func (u *User) ChangePassword(ctx context.Context, userID int64, oldPassword string, newPassword string) (string, int, error) {
+	if !passwordValidate(newPassword) {
+		return "", constants.PasswordChangeBadFormat, fmt.Errorf("некорректный пароль, должен иметь заглавные, цифры, буквы и быть определенной длины >= %v", constants.MinPasswordLength)
+	}
+
+	status, err := u.storage.UpdatePassword(ctx, userID, PassHash(oldPassword), PassHash(newPassword))
+	if err != nil {
+		logger.Log().WithContext(ctx).Error("ChangePassword: " + err.Error())
 		return "", status, err
 	}
 
-	token, err := BuildJWTString(id)
+	token, err := u.issueToken(ctx, userID)
 	if err != nil {
-		return "", constants.LoginInternalError, fmt.Errorf("JWT error")
+		return "", constants.PasswordChangeInternalError, err
 	}
 
 	return token, status, nil
 }
 
+// запрос на сброс пароля: генерирует одноразовый токен и отправляет его пользователю
+// для несуществующего логина возвращает тот же статус, чтобы не раскрывать наличие пользователя
+func (u *User) RequestPasswordReset(ctx context.Context, login string) (int, error) {
+	if !loginValidate(login) {
+		return constants.PasswordResetBadFormat, fmt.Errorf("некорректный логин, длина должна быть больше/равна %v", constants.MinLoginLength)
+	}
+
+	token, err := resetToken()
+	if err != nil {
+		return constants.PasswordResetInternalError, fmt.Errorf("ошибка генерации токена сброса: %w", err)
+	}
+
+	expiresAt := time.Now().Add(authSettings.PasswordResetTTL)
+
+	id, err := u.storage.CreatePasswordReset(ctx, login, TokenHash(token), expiresAt)
+	if err != nil {
+		logger.Log().WithContext(ctx).Error("RequestPasswordReset: " + err.Error())
+		return constants.PasswordResetInternalError, err
+	}
+
+	if id == 0 {
+		logger.Log().WithContext(ctx).Info(fmt.Sprintf("Сброс пароля для несуществующего логина %v", login))
+		return constants.PasswordResetAccepted, nil
+	}
+
+	// ошибка доставки не возвращается клиенту: ответ для существующего логина не должен отличаться
+	err = u.notifier.SendPasswordReset(ctx, login, token, expiresAt)
+	if err != nil {
+		logger.Log().WithContext(ctx).Error("RequestPasswordReset notify: " + err.Error())
+	}
+
+	return constants.PasswordResetAccepted, nil
+}
+
+// установка нового пароля по токену сброса
+func (u *User) ResetPassword(ctx context.Context, token string, password string) (int, error) {
+	if token == "" {
+		return constants.PasswordResetBadFormat, fmt.Errorf("пустой токен сброса пароля")
+	}
+	if !passwordValidate(password) {
+		return constants.PasswordResetBadFormat, fmt.Errorf("некорректный пароль, должен иметь заглавные, цифры, буквы и быть определенной длины >= %v", constants.MinPasswordLength)
+	}
+
+	status, err := u.storage.ResetPassword(ctx, TokenHash(token), PassHash(password))
+	if err != nil {
+		logger.Log().WithContext(ctx).Error("ResetPassword: " + err.Error())
+		return status, err
+	}
+
+	return status, nil
+}
+
+// Authenticate проверка токена пользователя, возвращает ID и роль пользователя
+// токены заблокированных пользователей и выданные до последней смены пароля или роли не принимаются
+func (u *User) Authenticate(ctx context.Context, tokenString string) (int64, string, error) {
+	claims, err := GetClaims(tokenString)
+	if err != nil {
+		return -1, "", err
+	}
+
+	resetAt, blocked, err := u.storage.SessionState(ctx, claims.UserID)
+	if err != nil {
+		return -1, "", err
+	}
+
+	if blocked {
+		return -1, "", fmt.Errorf("пользователь %v заблокирован", claims.UserID)
+	}
+
+	if !resetAt.IsZero() {
+		// время сброса и время выдачи в токене ставятся по часам приложения с точностью до секунды,
+		// токен, выданный в ту же секунду, что и сброс (например, при смене пароля), действителен
+		if claims.IssuedAt == nil || claims.IssuedAt.Time.Before(resetAt.Truncate(time.Second)) {
+			return -1, "", fmt.Errorf("токен выдан до смены пароля или роли")
+		}
+	}
+
+	return claims.UserID, claims.Role, nil
+}
+
+// SetRole смена роли пользователя администратором
+func (u *User) SetRole(ctx context.Context, userID int64, role string) (int, error) {
+	if !roleValidate(role) {
+		return constants.RoleSetBadFormat, fmt.Errorf("неизвестная роль %v", role)
+	}
+
+	found, err := u.storage.SetRole(ctx, userID, role)
+	if err != nil {
+		logger.Log().WithContext(ctx).Error("SetRole: " + err.Error())
+		return constants.RoleSetInternalError, err
+	}
+
+	if !found {
+		return constants.RoleSetNotFound, fmt.Errorf("пользователь %v не найден", userID)
+	}
+
+	logger.Log().WithContext(ctx).Info(fmt.Sprintf("Пользователю %v назначена роль %v", userID, role))
+
+	return constants.RoleSetOk, nil
+}
+
+// PromoteAdmin назначение роли администратора пользователю с заданным логином
+// используется при старте сервера для первоначального администратора
+func (u *User) PromoteAdmin(ctx context.Context, login string) error {
+	changed, err := u.storage.SetRoleByLogin(ctx, login, constants.RoleAdmin)
+	if err != nil {
+		return fmt.Errorf("PromoteAdmin: %w", err)
+	}
+
+	if changed {
+		logger.Log().WithContext(ctx).Info(fmt.Sprintf("Пользователю %v назначена роль %v", login, constants.RoleAdmin))
+	}
+
+	return nil
+}
+
+// IDByLogin поиск ID пользователя по логину, false - пользователь не найден
+func (u *User) IDByLogin(ctx context.Context, login string) (int64, bool, error) {
+	return u.storage.FindIDByLogin(ctx, login)
+}
+
+// выдача токена с актуальной ролью пользователя
+func (u *User) issueToken(ctx context.Context, userID int64) (string, error) {
+	role, err := u.storage.UserRole(ctx, userID)
+	if err != nil {
+		return "", fmt.Errorf("ошибка получения роли пользователя: %w", err)
+	}
+
+	token, err := BuildJWTString(userID, role)
+	if err != nil {
+		return "", fmt.Errorf("JWT error")
+	}
+
+	return token, nil
+}
+
 func PassHash(password string) string {
-	data := sha256.Sum256([]byte(password + constants.PasswordSalt))
+	data := sha256.Sum256([]byte(password + authSettings.PasswordSalt))
+
+	return hex.EncodeToString(data[:])
+}
+
+// TokenHash хэш одноразового токена для хранения в базе
+func TokenHash(token string) string {
+	data := sha256.Sum256([]byte(token))
 
 	return hex.EncodeToString(data[:])
 }
 
+// генерация случайного токена сброса пароля
+func resetToken() (string, error) {
+	b := make([]byte, constants.PasswordResetTokenLength)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// Роль должна быть одной из известных
+func roleValidate(role string) bool {
+	switch role {
+	case constants.RoleUser, constants.RoleSupport, constants.RoleAdmin:
+		return true
+	}
+
+	return false
+}
+
 // Логин должен быть определенной длины
 func loginValidate(login string) bool {
 
@@ -105,6 +322,12 @@ func loginValidate(login string) bool {
 	return length >= constants.MinLoginLength
 }
 
+// Префикс логинов обезличенных аккаунтов недоступен при регистрации
+// пользователи, зарегистрировавшиеся с ним раньше, по-прежнему входят и сбрасывают пароль
+func loginReserved(login string) bool {
+	return strings.HasPrefix(login, constants.DeletedLoginPrefix)
+}
+
 // Пароль должен иметь заглавные, цифры, спецсимволы, буквы и быть определенной длины
 /*
 возможное доп.требование - наличие спецсимвола
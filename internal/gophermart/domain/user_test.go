@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"errors"
 	"github.com/dnsoftware/gophermart2/internal/constants"
 	mock_domain "github.com/dnsoftware/gophermart2/internal/gophermart/domain/mocks"
 	"github.com/golang/mock/gomock"
@@ -9,6 +10,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestPasswordHash(t *testing.T) {
@@ -17,6 +19,31 @@ func TestPasswordHash(t *testing.T) {
 	assert.Equal(t, "59e5bf62c83b5b521dc91f5baff2eb17215b43e877739571df4cd80f1b3d9d29", hash)
 }
 
+func TestSetAuthSettings(t *testing.T) {
+	defaults := authSettings
+	defer SetAuthSettings(defaults)
+
+	token, err := BuildJWTString(1, constants.RoleUser)
+	require.NoError(t, err)
+
+	SetAuthSettings(AuthSettings{
+		SecretKey:        "anothersecretkey",
+		PasswordSalt:     "anothersalt",
+		TokenTTL:         time.Minute,
+		PasswordResetTTL: time.Minute,
+	})
+
+	_, err = GetClaims(token)
+	require.Error(t, err, "токен, подписанный прежним ключом, не должен приниматься")
+	require.NotEqual(t, "59e5bf62c83b5b521dc91f5baff2eb17215b43e877739571df4cd80f1b3d9d29", PassHash("pass#$%word"))
+
+	token, err = BuildJWTString(1, constants.RoleUser)
+	require.NoError(t, err)
+	claims, err := GetClaims(token)
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now().Add(time.Minute), claims.ExpiresAt.Time, 2*time.Second)
+}
+
 func TestLoginValidate(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -86,7 +113,7 @@ func TestAddUser(t *testing.T) {
 	passCrypted := PassHash(password)
 	m.EXPECT().Create(ctx, login, passCrypted).Return(int64(1), constants.RegisterOk, nil)
 
-	userModel := NewUserModel(m)
+	userModel := NewUserModel(m, nil)
 	token, status, err := userModel.AddUser(ctx, login, password)
 
 	partsToken := strings.Split(token, ".")
@@ -101,18 +128,24 @@ func TestAddUser(t *testing.T) {
 	passCrypted = PassHash(password)
 	m.EXPECT().Create(ctx, login, passCrypted).Return(int64(0), constants.RegisterOk, nil).AnyTimes()
 
-	userModel = NewUserModel(m)
+	userModel = NewUserModel(m, nil)
 	_, _, err = userModel.AddUser(ctx, login, password)
 
 	require.Error(t, err, "Должна быть ошибка длины логина")
 
+	// negative зарезервированный префикс, вход с таким логином при этом не запрещен
+	_, status, err = userModel.AddUser(ctx, "deleted-17", password)
+
+	require.Error(t, err, "Должна быть ошибка зарезервированного префикса")
+	require.Equal(t, constants.RegisterBadFormat, status, "Неверный статус")
+
 	// negative некорректный пароль
 	login = "usddd"
 	password = "f7456789"
 	passCrypted = PassHash(password)
 	m.EXPECT().Create(ctx, login, passCrypted).Return(int64(0), constants.RegisterOk, nil).AnyTimes()
 
-	userModel = NewUserModel(m)
+	userModel = NewUserModel(m, nil)
 	_, _, err = userModel.AddUser(ctx, login, password)
 
 	require.Error(t, err, "Должна быть ошибка некорретный пароль")
@@ -132,8 +165,10 @@ func TestLoginUser(t *testing.T) {
 	password := "f7H456789"
 	passCrypted := PassHash(password)
 	m.EXPECT().FindByLoginPassword(ctx, login, passCrypted).Return(int64(1), constants.LoginOk, nil)
+	m.EXPECT().UpdateLastLogin(ctx, int64(1)).Return(nil)
+	m.EXPECT().UserRole(ctx, int64(1)).Return(constants.RoleUser, nil)
 
-	userModel := NewUserModel(m)
+	userModel := NewUserModel(m, nil)
 	token, status, err := userModel.LoginUser(ctx, login, password)
 
 	partsToken := strings.Split(token, ".")
@@ -142,4 +177,165 @@ func TestLoginUser(t *testing.T) {
 	require.Equal(t, constants.LoginOk, status, "Неверный статус")
 	require.NoError(t, err)
 
+	// логин с зарезервированным префиксом, зарегистрированный до резервирования
+	m.EXPECT().FindByLoginPassword(ctx, "deleted-17", passCrypted).Return(int64(2), constants.LoginOk, nil)
+	m.EXPECT().UpdateLastLogin(ctx, int64(2)).Return(nil)
+	m.EXPECT().UserRole(ctx, int64(2)).Return(constants.RoleUser, nil)
+
+	_, status, err = userModel.LoginUser(ctx, "deleted-17", password)
+
+	require.NoError(t, err)
+	require.Equal(t, constants.LoginOk, status, "Неверный статус")
+}
+
+func TestChangePassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_domain.NewMockUserStorage(ctrl)
+
+	ctx := context.Background()
+	userModel := NewUserModel(m, nil)
+
+	// positive
+	m.EXPECT().UpdatePassword(ctx, int64(1), PassHash("f7H456789"), PassHash("n3Wpassword")).Return(constants.PasswordChangeOk, nil)
+	m.EXPECT().UserRole(ctx, int64(1)).Return(constants.RoleUser, nil)
+
+	token, status, err := userModel.ChangePassword(ctx, 1, "f7H456789", "n3Wpassword")
+
+	require.NoError(t, err)
+	require.Equal(t, constants.PasswordChangeOk, status, "Неверный статус")
+	require.Equal(t, 3, len(strings.Split(token, ".")), "должен быть JWT токен")
+
+	// negative неверный текущий пароль
+	m.EXPECT().UpdatePassword(ctx, int64(1), PassHash("wrong"), PassHash("n3Wpassword")).Return(constants.PasswordChangeBadPair, errors.New("неверный текущий пароль"))
+
+	_, status, err = userModel.ChangePassword(ctx, 1, "wrong", "n3Wpassword")
+
+	require.Error(t, err)
+	require.Equal(t, constants.PasswordChangeBadPair, status, "Неверный статус")
+
+	// negative новый пароль не проходит валидацию
+	_, status, err = userModel.ChangePassword(ctx, 1, "f7H456789", "short")
+
+	require.Error(t, err)
+	require.Equal(t, constants.PasswordChangeBadFormat, status, "Неверный статус")
+}
+
+func TestRequestPasswordReset(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_domain.NewMockUserStorage(ctrl)
+	n := mock_domain.NewMockResetNotifier(ctrl)
+
+	ctx := context.Background()
+	userModel := NewUserModel(m, n)
+
+	// positive: токен доставлен, в базу ушел хэш именно этого токена
+	var sentToken string
+	var savedHash string
+	m.EXPECT().CreatePasswordReset(ctx, "user", gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, tokenHash string, _ time.Time) (int64, error) {
+			savedHash = tokenHash
+			return int64(1), nil
+		})
+	n.EXPECT().SendPasswordReset(ctx, "user", gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, token string, _ time.Time) error {
+			sentToken = token
+			return nil
+		})
+
+	status, err := userModel.RequestPasswordReset(ctx, "user")
+
+	require.NoError(t, err)
+	require.Equal(t, constants.PasswordResetAccepted, status, "Неверный статус")
+	require.Equal(t, TokenHash(sentToken), savedHash, "в базе должен храниться хэш токена")
+
+	// несуществующий логин: тот же статус, уведомление не отправляется
+	m.EXPECT().CreatePasswordReset(ctx, "nobody", gomock.Any(), gomock.Any()).Return(int64(0), nil)
+
+	status, err = userModel.RequestPasswordReset(ctx, "nobody")
+
+	require.NoError(t, err)
+	require.Equal(t, constants.PasswordResetAccepted, status, "Неверный статус")
+
+	// уведомление не доставлено: ответ тот же, что и для несуществующего логина
+	m.EXPECT().CreatePasswordReset(ctx, "user", gomock.Any(), gomock.Any()).Return(int64(1), nil)
+	n.EXPECT().SendPasswordReset(ctx, "user", gomock.Any(), gomock.Any()).Return(errors.New("smtp unavailable"))
+
+	status, err = userModel.RequestPasswordReset(ctx, "user")
+
+	require.NoError(t, err)
+	require.Equal(t, constants.PasswordResetAccepted, status, "Неверный статус")
+}
+
+func TestAuthenticate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_domain.NewMockUserStorage(ctrl)
+
+	ctx := context.Background()
+	userModel := NewUserModel(m, nil)
+
+	token, err := BuildJWTString(1, constants.RoleSupport)
+	require.NoError(t, err)
+
+	// пароль не менялся
+	m.EXPECT().SessionState(ctx, int64(1)).Return(time.Time{}, false, nil)
+	uid, role, err := userModel.Authenticate(ctx, token)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), uid)
+	require.Equal(t, constants.RoleSupport, role, "роль должна передаваться в токене")
+
+	// пароль сменили после выдачи токена
+	m.EXPECT().SessionState(ctx, int64(1)).Return(time.Now().Add(time.Minute), false, nil)
+	_, _, err = userModel.Authenticate(ctx, token)
+	require.Error(t, err, "токен, выданный до смены пароля, не должен приниматься")
+
+	// токен выдан в ту же секунду, что и сброс: время выдачи совпадает с временем сброса
+	claims, err := GetClaims(token)
+	require.NoError(t, err)
+	m.EXPECT().SessionState(ctx, int64(1)).Return(claims.IssuedAt.Time, false, nil)
+	_, _, err = userModel.Authenticate(ctx, token)
+	require.NoError(t, err, "токен, выданный в секунду сброса, должен приниматься")
+
+	// пользователь заблокирован
+	m.EXPECT().SessionState(ctx, int64(1)).Return(time.Time{}, true, nil)
+	_, _, err = userModel.Authenticate(ctx, token)
+	require.Error(t, err, "токен заблокированного пользователя не должен приниматься")
+
+	// мусор вместо токена
+	_, _, err = userModel.Authenticate(ctx, "bad.token.value")
+	require.Error(t, err)
+}
+
+func TestSetRole(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_domain.NewMockUserStorage(ctrl)
+
+	ctx := context.Background()
+	userModel := NewUserModel(m, nil)
+
+	// positive
+	m.EXPECT().SetRole(ctx, int64(2), constants.RoleSupport).Return(true, nil)
+
+	status, err := userModel.SetRole(ctx, 2, constants.RoleSupport)
+	require.NoError(t, err)
+	require.Equal(t, constants.RoleSetOk, status)
+
+	// неизвестная роль
+	status, err = userModel.SetRole(ctx, 2, "root")
+	require.Error(t, err)
+	require.Equal(t, constants.RoleSetBadFormat, status)
+
+	// пользователь не найден
+	m.EXPECT().SetRole(ctx, int64(3), constants.RoleAdmin).Return(false, nil)
+
+	status, err = userModel.SetRole(ctx, 3, constants.RoleAdmin)
+	require.Error(t, err)
+	require.Equal(t, constants.RoleSetNotFound, status)
 }
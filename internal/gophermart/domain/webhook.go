@@ -0,0 +1,408 @@
+package domain
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	"github.com/dnsoftware/gophermart2/internal/logger"
+	"github.com/dnsoftware/gophermart2/internal/storage"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type WebhookStorage interface {
+	CreateWebhook(ctx context.Context, w storage.WebhookRow) (int64, error)
+	WebhookList(ctx context.Context, apiKeyID int64) ([]storage.WebhookRow, error)
+	DeleteWebhook(ctx context.Context, apiKeyID int64, id int64) (bool, error)
+	EnqueueDeliveries(ctx context.Context, eventID int64, eventType string) error
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]storage.WebhookDeliveryRow, error)
+	FinishDelivery(ctx context.Context, d storage.WebhookDeliveryRow) error
+	DeliveryList(ctx context.Context, apiKeyID int64, webhookID int64, limit int) ([]storage.WebhookDeliveryRow, error)
+	SendWebhook(ctx context.Context, url string, headers map[string]string, body []byte) (int, error)
+}
+
+// WebhookRequest заявка на подписку
+type WebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"` // ключ подписи HMAC, в ответах не показывается
+}
+
+// WebhookItem подписка клиента
+type WebhookItem struct {
+	ID        int64    `json:"id"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	CreatedAt string   `json:"created_at"`
+}
+
+// WebhookDeliveryItem запись журнала доставки
+type WebhookDeliveryItem struct {
+	ID            int64  `json:"id"`
+	EventID       int64  `json:"event_id"`
+	Event         string `json:"event"`
+	Status        string `json:"status"`
+	Attempts      int    `json:"attempts"`
+	ResponseCode  int    `json:"response_code,omitempty"`
+	LastError     string `json:"last_error,omitempty"`
+	CreatedAt     string `json:"created_at"`
+	LastAttemptAt string `json:"last_attempt_at,omitempty"`
+	NextAttemptAt string `json:"next_attempt_at,omitempty"` // только для ожидающих доставки
+}
+
+// WebhookEvent тело запроса к получателю
+type WebhookEvent struct {
+	ID        int64            `json:"id"` // одинаков для всех попыток доставки
+	Type      string           `json:"type"`
+	CreatedAt string           `json:"created_at"`
+	Data      WebhookEventData `json:"data"`
+}
+
+type WebhookEventData struct {
+	Login   string  `json:"login"`
+	Order   string  `json:"order"`
+	Accrual float32 `json:"accrual,omitempty"` // для order.processed
+	Sum     float32 `json:"sum,omitempty"`     // для points.withdrawn
+}
+
+// Webhooks подписки партнеров на события и доставка событий им
+// получает доменные события от EventBus, доставка - не менее одного раза
+type Webhooks struct {
+	storage WebhookStorage
+	resolve func(ctx context.Context, host string) ([]net.IPAddr, error) // адреса хоста получателя
+}
+
+func NewWebhooksModel(storage WebhookStorage) *Webhooks {
+	webhooks := &Webhooks{
+		storage: storage,
+		resolve: net.DefaultResolver.LookupIPAddr,
+	}
+
+	return webhooks
+}
+
+// CreateWebhook подписка клиента на события
+func (w *Webhooks) CreateWebhook(ctx context.Context, client *APIClient, req WebhookRequest) (int64, int, error) {
+	if !webhookURLValidate(req.URL) {
+		return 0, constants.WebhookBadFormat, fmt.Errorf("неверный адрес получателя %v", req.URL)
+	}
+	if err := w.publicHost(ctx, req.URL); err != nil {
+		return 0, constants.WebhookBadFormat, err
+	}
+	if len(req.Events) == 0 {
+		return 0, constants.WebhookBadFormat, fmt.Errorf("не указаны события")
+	}
+	for _, event := range req.Events {
+		if !webhookEventValidate(event) {
+			return 0, constants.WebhookBadFormat, fmt.Errorf("неизвестное событие %v", event)
+		}
+	}
+	if len(req.Secret) < constants.MinSigningKeyLength {
+		return 0, constants.WebhookBadFormat, fmt.Errorf("ключ подписи короче %v символов", constants.MinSigningKeyLength)
+	}
+
+	list, err := w.storage.WebhookList(ctx, client.ID)
+	if err != nil {
+		return 0, constants.WebhookInternalError, err
+	}
+	if len(list) >= constants.WebhookMaxSubscriptions {
+		return 0, constants.WebhookLimitExceeded, fmt.Errorf("у клиента %v уже %v подписок", client.ID, len(list))
+	}
+
+	id, err := w.storage.CreateWebhook(ctx, storage.WebhookRow{
+		APIKeyID:   client.ID,
+		URL:        req.URL,
+		EventTypes: req.Events,
+		Secret:     req.Secret,
+	})
+	if err != nil {
+		return 0, constants.WebhookInternalError, err
+	}
+
+	logger.Log().WithContext(ctx).Info(fmt.Sprintf("Партнер %v (%v) подписался на события %v", client.Name, client.ID, req.Events))
+
+	return id, constants.WebhookCreated, nil
+}
+
+// Webhooks список подписок клиента
+func (w *Webhooks) Webhooks(ctx context.Context, client *APIClient) ([]WebhookItem, int, error) {
+	rows, err := w.storage.WebhookList(ctx, client.ID)
+	if err != nil {
+		return nil, constants.WebhookInternalError, err
+	}
+
+	items := make([]WebhookItem, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, WebhookItem{
+			ID:        row.ID,
+			URL:       row.URL,
+			Events:    row.EventTypes,
+			CreatedAt: row.CreatedAt.Format(time.RFC3339),
+		})
+	}
+
+	return items, constants.WebhookOk, nil
+}
+
+// DeleteWebhook удаление подписки клиента
+func (w *Webhooks) DeleteWebhook(ctx context.Context, client *APIClient, id int64) (int, error) {
+	found, err := w.storage.DeleteWebhook(ctx, client.ID, id)
+	if err != nil {
+		return constants.WebhookInternalError, err
+	}
+	if !found {
+		return constants.WebhookNotFound, fmt.Errorf("подписка %v не найдена", id)
+	}
+
+	return constants.WebhookOk, nil
+}
+
+// Deliveries журнал доставки по подписке клиента
+func (w *Webhooks) Deliveries(ctx context.Context, client *APIClient, id int64) ([]WebhookDeliveryItem, int, error) {
+	rows, err := w.storage.DeliveryList(ctx, client.ID, id, constants.WebhookDeliveryLogLimit)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, constants.WebhookNotFound, fmt.Errorf("подписка %v не найдена", id)
+	}
+	if err != nil {
+		return nil, constants.WebhookInternalError, err
+	}
+
+	items := make([]WebhookDeliveryItem, 0, len(rows))
+	for _, row := range rows {
+		item := WebhookDeliveryItem{
+			ID:           row.ID,
+			EventID:      row.EventID,
+			Event:        row.EventType,
+			Status:       row.Status,
+			Attempts:     row.Attempts,
+			ResponseCode: row.ResponseCode,
+			LastError:    row.LastError,
+			CreatedAt:    row.CreatedAt.Format(time.RFC3339),
+		}
+		if !row.LastAttemptAt.IsZero() {
+			item.LastAttemptAt = row.LastAttemptAt.Format(time.RFC3339)
+		}
+		if row.Status == constants.DeliveryPending {
+			item.NextAttemptAt = row.NextAttemptAt.Format(time.RFC3339)
+		}
+
+		items = append(items, item)
+	}
+
+	return items, constants.WebhookOk, nil
+}
+
+func (w *Webhooks) Name() string {
+	return "webhook"
+}
+
+// Handle создание доставок доменного события по подпискам
+// из доменных событий партнерам передаются только начисления, отклонения заказов и списания
+func (w *Webhooks) Handle(ctx context.Context, event DomainEvent) error {
+	var eventType string
+
+	switch data := event.Data.(type) {
+	case PointsAccrued:
+		eventType = constants.WebhookOrderProcessed
+	case OrderStatusChanged:
+		if data.Status != constants.OrderInvalid {
+			return nil
+		}
+		eventType = constants.WebhookOrderInvalid
+	case PointsWithdrawn:
+		eventType = constants.WebhookPointsWithdrawn
+	default:
+		return nil
+	}
+
+	return w.storage.EnqueueDeliveries(ctx, event.ID, eventType)
+}
+
+// StartDeliveryWorker служба доставки событий подписчикам
+func (w *Webhooks) StartDeliveryWorker(ctx context.Context) {
+	ticker := time.NewTicker(constants.WebhookDeliveryPeriod)
+	defer ticker.Stop()
+
+	for {
+		w.deliver(ctx)
+
+		select {
+		case <-ctx.Done():
+			logger.Log().WithContext(ctx).Info("WebhookDeliveryWorker DONE!!!")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// отправка доставок, время которых наступило
+func (w *Webhooks) deliver(ctx context.Context) {
+	deliveries, err := w.storage.ClaimDeliveries(ctx, constants.WebhookBatchSize, constants.WebhookClaimLease)
+	if err != nil {
+		logger.Log().WithContext(ctx).Error("ClaimDeliveries: " + err.Error())
+		return
+	}
+
+	for _, d := range deliveries {
+		if ctx.Err() != nil { // не отправленные доставки повторятся после истечения lease
+			return
+		}
+
+		d = w.attempt(ctx, d, time.Now())
+
+		// результат сохраняется и при остановке сервиса, чтобы отправленное не ушло повторно
+		ctxDB, cancel := context.WithTimeout(context.WithoutCancel(ctx), constants.DBContextTimeout)
+		err = w.storage.FinishDelivery(ctxDB, d)
+		cancel()
+		if err != nil {
+			logger.Log().WithContext(ctx).Error("FinishDelivery: " + err.Error())
+		}
+	}
+}
+
+// попытка доставки, возвращает доставку с обновленным статусом и временем следующей попытки
+func (w *Webhooks) attempt(ctx context.Context, d storage.WebhookDeliveryRow, now time.Time) storage.WebhookDeliveryRow {
+	d.Attempts++
+	d.ResponseCode = 0
+	d.LastError = ""
+
+	body, err := json.Marshal(webhookEvent(d))
+	if err != nil { // повтор не поможет
+		d.Status = constants.DeliveryFailed
+		d.LastError = err.Error()
+		return d
+	}
+
+	headers := map[string]string{
+		constants.HashHeaderName:     webhookSignature(body, d.Secret),
+		constants.HeaderWebhookEvent: d.EventType,
+		constants.HeaderWebhookID:    strconv.FormatInt(d.EventID, 10),
+	}
+
+	code, err := w.storage.SendWebhook(ctx, d.URL, headers, body)
+	d.ResponseCode = code
+
+	switch {
+	case err != nil:
+		d.LastError = err.Error()
+	case code < 200 || code > 299:
+		d.LastError = fmt.Sprintf("получатель ответил %v", code)
+	default:
+		d.Status = constants.DeliveryDelivered
+		return d
+	}
+
+	if d.Attempts >= constants.WebhookMaxAttempts {
+		d.Status = constants.DeliveryFailed
+		logger.Log().WithContext(ctx).Info(fmt.Sprintf("Доставка %v по подписке %v прекращена: %v", d.ID, d.WebhookID, d.LastError))
+		return d
+	}
+
+	d.Status = constants.DeliveryPending
+	d.NextAttemptAt = now.Add(webhookBackoff(d.Attempts))
+
+	return d
+}
+
+func webhookEvent(d storage.WebhookDeliveryRow) WebhookEvent {
+	event := WebhookEvent{
+		ID:        d.EventID,
+		Type:      d.EventType,
+		CreatedAt: d.EventAt.Format(time.RFC3339),
+		Data: WebhookEventData{
+			Login: d.Login,
+			Order: d.OrderNumber,
+		},
+	}
+
+	switch d.EventType {
+	case constants.WebhookOrderProcessed:
+		event.Data.Accrual = d.Amount
+	case constants.WebhookPointsWithdrawn:
+		event.Data.Sum = d.Amount
+	}
+
+	return event
+}
+
+// задержка перед следующей попыткой: удваивается после каждой неудачной, но не больше WebhookRetryMax
+func webhookBackoff(attempts int) time.Duration {
+	delay := constants.WebhookRetryBase
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= constants.WebhookRetryMax {
+			return constants.WebhookRetryMax
+		}
+	}
+
+	return delay
+}
+
+// подпись HMAC-SHA256 тела запроса в шестнадцатеричном виде, как и для запросов партнеров к нам
+func webhookSignature(body []byte, secret string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Адрес получателя должен быть абсолютным http(s) адресом
+func webhookURLValidate(address string) bool {
+	if address == "" || len(address) > constants.MaxWebhookURLLength {
+		return false
+	}
+
+	u, err := url.Parse(address)
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	return u.Scheme == "https" || u.Scheme == "http"
+}
+
+// получатель должен быть в интернете, а не в сети сервиса: иначе подпиской можно обращаться к внутренним адресам
+// при отправке адрес проверяется еще раз, см. storage.NewWebhookRepo
+func (w *Webhooks) publicHost(ctx context.Context, address string) error {
+	u, err := url.Parse(address)
+	if err != nil {
+		return fmt.Errorf("неверный адрес получателя %v", address)
+	}
+
+	host := u.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if !storage.PublicIP(ip) {
+			return fmt.Errorf("получатель %v: %w", host, storage.ErrWebhookAddress)
+		}
+		return nil
+	}
+
+	addrs, err := w.resolve(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return fmt.Errorf("адрес получателя %v не найден", host)
+	}
+	for _, addr := range addrs {
+		if !storage.PublicIP(addr.IP) {
+			return fmt.Errorf("получатель %v (%v): %w", host, addr.IP, storage.ErrWebhookAddress)
+		}
+	}
+
+	return nil
+}
+
+// Событие должно быть одним из известных
+func webhookEventValidate(event string) bool {
+	switch event {
+	case constants.WebhookOrderProcessed, constants.WebhookOrderInvalid, constants.WebhookPointsWithdrawn:
+		return true
+	}
+
+	return false
+}
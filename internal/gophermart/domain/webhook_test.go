@@ -0,0 +1,165 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	mock_domain "github.com/dnsoftware/gophermart2/internal/gophermart/domain/mocks"
+	"github.com/dnsoftware/gophermart2/internal/storage"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestWebhooks_CreateWebhook(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockWebhook := mock_domain.NewMockWebhookStorage(ctrl)
+	webhooks := NewWebhooksModel(mockWebhook)
+	webhooks.resolve = func(_ context.Context, host string) ([]net.IPAddr, error) {
+		switch host {
+		case "partner.example":
+			return []net.IPAddr{{IP: net.ParseIP("203.0.113.10")}}, nil
+		case "internal.partner.example":
+			return []net.IPAddr{{IP: net.ParseIP("203.0.113.10")}, {IP: net.ParseIP("10.0.0.5")}}, nil
+		}
+		return nil, fmt.Errorf("no such host %v", host)
+	}
+	client := &APIClient{ID: 3, Name: "partner"}
+
+	// positive
+	req := WebhookRequest{
+		URL:    "https://partner.example/hooks",
+		Events: []string{constants.WebhookOrderProcessed, constants.WebhookPointsWithdrawn},
+		Secret: "0123456789abcdef",
+	}
+	mockWebhook.EXPECT().WebhookList(ctx, int64(3)).Return([]storage.WebhookRow{}, nil)
+	mockWebhook.EXPECT().CreateWebhook(ctx, storage.WebhookRow{
+		APIKeyID:   3,
+		URL:        req.URL,
+		EventTypes: req.Events,
+		Secret:     req.Secret,
+	}).Return(int64(7), nil)
+
+	id, status, err := webhooks.CreateWebhook(ctx, client, req)
+	require.NoError(t, err)
+	require.Equal(t, constants.WebhookCreated, status)
+	require.Equal(t, int64(7), id)
+
+	// неверный адрес, событие, ключ подписи
+	for _, bad := range []WebhookRequest{
+		{URL: "partner.example/hooks", Events: req.Events, Secret: req.Secret},
+		{URL: "ftp://partner.example/hooks", Events: req.Events, Secret: req.Secret},
+		{URL: req.URL, Events: []string{"order.deleted"}, Secret: req.Secret},
+		{URL: req.URL, Secret: req.Secret},
+		{URL: req.URL, Events: req.Events, Secret: "short"},
+		// получатель во внутренней сети сервиса
+		{URL: "http://localhost:9091/metrics", Events: req.Events, Secret: req.Secret},
+		{URL: "http://127.0.0.1:8081/api/admin/status", Events: req.Events, Secret: req.Secret},
+		{URL: "http://[::1]/hooks", Events: req.Events, Secret: req.Secret},
+		{URL: "http://169.254.169.254/latest/meta-data", Events: req.Events, Secret: req.Secret},
+		{URL: "https://192.168.1.10/hooks", Events: req.Events, Secret: req.Secret},
+		{URL: "https://internal.partner.example/hooks", Events: req.Events, Secret: req.Secret},
+		{URL: "https://unknown.partner.example/hooks", Events: req.Events, Secret: req.Secret},
+	} {
+		_, status, err = webhooks.CreateWebhook(ctx, client, bad)
+		require.Error(t, err)
+		require.Equal(t, constants.WebhookBadFormat, status)
+	}
+
+	// превышено кол-во подписок
+	mockWebhook.EXPECT().WebhookList(ctx, int64(3)).Return(make([]storage.WebhookRow, constants.WebhookMaxSubscriptions), nil)
+
+	_, status, err = webhooks.CreateWebhook(ctx, client, req)
+	require.Error(t, err)
+	require.Equal(t, constants.WebhookLimitExceeded, status)
+}
+
+func TestWebhooks_Deliveries(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockWebhook := mock_domain.NewMockWebhookStorage(ctrl)
+	webhooks := NewWebhooksModel(mockWebhook)
+	client := &APIClient{ID: 3}
+
+	created := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	mockWebhook.EXPECT().DeliveryList(ctx, int64(3), int64(7), constants.WebhookDeliveryLogLimit).Return([]storage.WebhookDeliveryRow{
+		{ID: 2, WebhookID: 7, EventID: 11, EventType: constants.WebhookOrderInvalid, Status: constants.DeliveryPending,
+			Attempts: 1, ResponseCode: 503, CreatedAt: created, NextAttemptAt: created.Add(time.Minute), LastAttemptAt: created},
+		{ID: 1, WebhookID: 7, EventID: 10, EventType: constants.WebhookOrderProcessed, Status: constants.DeliveryDelivered,
+			Attempts: 1, ResponseCode: 200, CreatedAt: created, NextAttemptAt: created, LastAttemptAt: created},
+	}, nil)
+
+	list, status, err := webhooks.Deliveries(ctx, client, 7)
+	require.NoError(t, err)
+	require.Equal(t, constants.WebhookOk, status)
+	require.Len(t, list, 2)
+	require.Equal(t, "2024-01-10T12:01:00Z", list[0].NextAttemptAt)
+	require.Equal(t, "", list[1].NextAttemptAt)
+
+	// чужая подписка
+	mockWebhook.EXPECT().DeliveryList(ctx, int64(3), int64(8), constants.WebhookDeliveryLogLimit).
+		Return(nil, fmt.Errorf("DeliveryList: %w", sql.ErrNoRows))
+
+	_, status, err = webhooks.Deliveries(ctx, client, 8)
+	require.Error(t, err)
+	require.Equal(t, constants.WebhookNotFound, status)
+}
+
+func TestWebhooks_attempt(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockWebhook := mock_domain.NewMockWebhookStorage(ctrl)
+	webhooks := NewWebhooksModel(mockWebhook)
+
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	d := storage.WebhookDeliveryRow{
+		ID: 1, WebhookID: 7, EventID: 10, EventType: constants.WebhookOrderProcessed, Status: constants.DeliveryPending,
+		URL: "https://partner.example/hooks", Secret: "0123456789abcdef",
+		Login: "user", OrderNumber: "3840576627", Amount: 729.98, EventAt: now,
+	}
+	body := `{"id":10,"type":"order.processed","created_at":"2024-01-10T12:00:00Z","data":{"login":"user","order":"3840576627","accrual":729.98}}`
+	headers := map[string]string{
+		constants.HashHeaderName:     webhookSignature([]byte(body), d.Secret),
+		constants.HeaderWebhookEvent: constants.WebhookOrderProcessed,
+		constants.HeaderWebhookID:    "10",
+	}
+
+	// positive
+	mockWebhook.EXPECT().SendWebhook(ctx, d.URL, headers, []byte(body)).Return(200, nil)
+
+	res := webhooks.attempt(ctx, d, now)
+	require.Equal(t, constants.DeliveryDelivered, res.Status)
+	require.Equal(t, 1, res.Attempts)
+
+	// ошибка получателя - повтор с задержкой
+	mockWebhook.EXPECT().SendWebhook(ctx, d.URL, headers, []byte(body)).Return(503, nil)
+
+	res = webhooks.attempt(ctx, d, now)
+	require.Equal(t, constants.DeliveryPending, res.Status)
+	require.Equal(t, 503, res.ResponseCode)
+	require.Equal(t, now.Add(constants.WebhookRetryBase), res.NextAttemptAt)
+
+	// последняя попытка
+	d.Attempts = constants.WebhookMaxAttempts - 1
+	mockWebhook.EXPECT().SendWebhook(ctx, d.URL, headers, []byte(body)).Return(0, fmt.Errorf("connection refused"))
+
+	res = webhooks.attempt(ctx, d, now)
+	require.Equal(t, constants.DeliveryFailed, res.Status)
+	require.Equal(t, "connection refused", res.LastError)
+}
+
+func Test_webhookBackoff(t *testing.T) {
+	require.Equal(t, constants.WebhookRetryBase, webhookBackoff(1))
+	require.Equal(t, 4*constants.WebhookRetryBase, webhookBackoff(3))
+	require.Equal(t, constants.WebhookRetryMax, webhookBackoff(20))
+}
@@ -0,0 +1,573 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	"github.com/dnsoftware/gophermart2/internal/gophermart/domain"
+	"github.com/dnsoftware/gophermart2/internal/logger"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"net/http"
+	"regexp"
+	"strconv"
+)
+
+// поиск пользователей по части логина: /users?login=...
+func (h *Server) adminSearchUsers(res http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	adminID, ok := ctx.Value(constants.UserIDKey).(int64)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	list, status, err := h.adminMart.SearchUsers(ctx, adminID, req.URL.Query().Get("login"))
+	writeAdminJSON(res, list, status, err)
+}
+
+// заказы пользователя
+func (h *Server) adminUserOrders(res http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	adminID, ok := ctx.Value(constants.UserIDKey).(int64)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := strconv.ParseInt(chi.URLParam(req, "id"), 10, 64)
+	if err != nil {
+		code, message := constants.StatusData(constants.AdminBadFormat)
+		http.Error(res, message, code)
+		return
+	}
+
+	list, status, err := h.adminMart.UserOrders(ctx, adminID, userID)
+	writeAdminJSON(res, list, status, err)
+}
+
+// движения по балансу пользователя
+func (h *Server) adminUserLedger(res http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	adminID, ok := ctx.Value(constants.UserIDKey).(int64)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := strconv.ParseInt(chi.URLParam(req, "id"), 10, 64)
+	if err != nil {
+		code, message := constants.StatusData(constants.AdminBadFormat)
+		http.Error(res, message, code)
+		return
+	}
+
+	list, status, err := h.adminMart.UserLedger(ctx, adminID, userID)
+	writeAdminJSON(res, list, status, err)
+}
+
+// повторная проверка заказа в Accrual
+func (h *Server) adminRecheckOrder(res http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	adminID, ok := ctx.Value(constants.UserIDKey).(int64)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	orderNumber := chi.URLParam(req, "number")
+	re := regexp.MustCompile(`^\d+$`)
+	if !re.MatchString(orderNumber) {
+		code, message := constants.StatusData(constants.AdminBadFormat)
+		http.Error(res, message, code)
+		return
+	}
+
+	status, err := h.adminMart.RecheckOrder(ctx, adminID, orderNumber)
+	writeAdminMessage(res, status, err)
+}
+
+// ручная установка статуса заказа
+func (h *Server) adminSetOrderStatus(res http.ResponseWriter, req *http.Request) {
+	type sReq struct {
+		Status  string   `json:"status"`
+		Accrual *float32 `json:"accrual"` // начисление, обязательно для PROCESSED
+		Reason  string   `json:"reason"`
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	adminID, ok := ctx.Value(constants.UserIDKey).(int64)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	orderNumber := chi.URLParam(req, "number")
+	re := regexp.MustCompile(`^\d+$`)
+	if !re.MatchString(orderNumber) {
+		code, message := constants.StatusData(constants.AdminBadFormat)
+		http.Error(res, message, code)
+		return
+	}
+
+	var reqData sReq
+	if err := readJSON(req, &reqData); err != nil {
+		code, message := constants.StatusData(constants.AdminBadFormat)
+		http.Error(res, message, code)
+		return
+	}
+
+	status, err := h.adminMart.SetOrderStatus(ctx, adminID, orderNumber, reqData.Status, reqData.Accrual, reqData.Reason)
+	writeAdminMessage(res, status, err)
+}
+
+// блокировка пользователя
+func (h *Server) adminBlockUser(res http.ResponseWriter, req *http.Request) {
+	type bReq struct {
+		Reason string `json:"reason"`
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	adminID, ok := ctx.Value(constants.UserIDKey).(int64)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := strconv.ParseInt(chi.URLParam(req, "id"), 10, 64)
+	if err != nil {
+		code, message := constants.StatusData(constants.AdminBadFormat)
+		http.Error(res, message, code)
+		return
+	}
+
+	var reqData bReq
+	if err = readJSON(req, &reqData); err != nil {
+		code, message := constants.StatusData(constants.AdminBadFormat)
+		http.Error(res, message, code)
+		return
+	}
+
+	status, err := h.adminMart.BlockUser(ctx, adminID, userID, reqData.Reason)
+	writeAdminMessage(res, status, err)
+}
+
+// снятие блокировки пользователя
+func (h *Server) adminUnblockUser(res http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	adminID, ok := ctx.Value(constants.UserIDKey).(int64)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := strconv.ParseInt(chi.URLParam(req, "id"), 10, 64)
+	if err != nil {
+		code, message := constants.StatusData(constants.AdminBadFormat)
+		http.Error(res, message, code)
+		return
+	}
+
+	status, err := h.adminMart.UnblockUser(ctx, adminID, userID)
+	writeAdminMessage(res, status, err)
+}
+
+// назначение роли пользователю
+func (h *Server) adminSetRole(res http.ResponseWriter, req *http.Request) {
+	type rReq struct {
+		Role string `json:"role"`
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	adminID, ok := ctx.Value(constants.UserIDKey).(int64)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := strconv.ParseInt(chi.URLParam(req, "id"), 10, 64)
+	if err != nil {
+		code, message := constants.StatusData(constants.RoleSetBadFormat)
+		http.Error(res, message, code)
+		return
+	}
+
+	var reqData rReq
+	if err = readJSON(req, &reqData); err != nil {
+		code, message := constants.StatusData(constants.RoleSetBadFormat)
+		http.Error(res, message, code)
+		return
+	}
+
+	status, err := h.adminMart.SetRole(ctx, adminID, userID, reqData.Role)
+	writeAdminMessage(res, status, err)
+}
+
+// журнал аудита
+func (h *Server) adminAuditList(res http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	list, status, err := h.adminMart.AuditList(ctx)
+	writeAdminJSON(res, list, status, err)
+}
+
+// список корректировок баланса: /adjustments?status=PENDING
+func (h *Server) adminAdjustments(res http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	list, status, err := h.adminMart.Adjustments(ctx, req.URL.Query().Get("status"))
+	writeAdminJSON(res, list, status, err)
+}
+
+// создание корректировки баланса
+func (h *Server) adminCreateAdjustment(res http.ResponseWriter, req *http.Request) {
+	type cResp struct {
+		ID int64 `json:"id"`
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	adminID, ok := ctx.Value(constants.UserIDKey).(int64)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	var reqData domain.AdjustmentRequest
+	if err := readJSON(req, &reqData); err != nil {
+		code, message := constants.StatusData(constants.AdjustmentBadFormat)
+		http.Error(res, message, code)
+		return
+	}
+
+	id, status, err := h.adminMart.CreateAdjustment(ctx, adminID, reqData)
+	writeAdminJSON(res, cResp{ID: id}, status, err)
+}
+
+// подтверждение корректировки баланса
+func (h *Server) adminApproveAdjustment(res http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	adminID, ok := ctx.Value(constants.UserIDKey).(int64)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(req, "id"), 10, 64)
+	if err != nil {
+		code, message := constants.StatusData(constants.AdjustmentBadFormat)
+		http.Error(res, message, code)
+		return
+	}
+
+	status, err := h.adminMart.ApproveAdjustment(ctx, adminID, id)
+	writeAdminMessage(res, status, err)
+}
+
+// отклонение корректировки баланса
+func (h *Server) adminRejectAdjustment(res http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	adminID, ok := ctx.Value(constants.UserIDKey).(int64)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(req, "id"), 10, 64)
+	if err != nil {
+		code, message := constants.StatusData(constants.AdjustmentBadFormat)
+		http.Error(res, message, code)
+		return
+	}
+
+	status, err := h.adminMart.RejectAdjustment(ctx, adminID, id)
+	writeAdminMessage(res, status, err)
+}
+
+// список споров о принадлежности заказов: /disputes?status=PENDING
+func (h *Server) adminDisputes(res http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	list, status, err := h.adminMart.Disputes(ctx, req.URL.Query().Get("status"))
+	writeAdminJSON(res, list, status, err)
+}
+
+// удовлетворение спора: заказ передается заявителю
+func (h *Server) adminApproveDispute(res http.ResponseWriter, req *http.Request) {
+	h.adminResolveDispute(res, req, h.adminMart.ApproveDispute)
+}
+
+// отклонение спора
+func (h *Server) adminRejectDispute(res http.ResponseWriter, req *http.Request) {
+	h.adminResolveDispute(res, req, h.adminMart.RejectDispute)
+}
+
+func (h *Server) adminResolveDispute(res http.ResponseWriter, req *http.Request,
+	resolve func(ctx context.Context, adminID int64, id int64, resolution string) (int, error)) {
+	type rReq struct {
+		Resolution string `json:"resolution"`
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	adminID, ok := ctx.Value(constants.UserIDKey).(int64)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(req, "id"), 10, 64)
+	if err != nil {
+		code, message := constants.StatusData(constants.DisputeBadFormat)
+		http.Error(res, message, code)
+		return
+	}
+
+	var reqData rReq
+	if req.ContentLength != 0 {
+		if err = readJSON(req, &reqData); err != nil {
+			code, message := constants.StatusData(constants.DisputeBadFormat)
+			http.Error(res, message, code)
+			return
+		}
+	}
+
+	status, err := resolve(ctx, adminID, id, reqData.Resolution)
+	writeAdminMessage(res, status, err)
+}
+
+// список ключей API партнеров
+func (h *Server) adminAPIKeys(res http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	list, status, err := h.adminMart.APIKeys(ctx)
+	writeAdminJSON(res, list, status, err)
+}
+
+// выпуск ключа API, полное значение ключа возвращается только в этом ответе
+func (h *Server) adminCreateAPIKey(res http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	adminID, ok := ctx.Value(constants.UserIDKey).(int64)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	var reqData domain.APIKeyRequest
+	if err := readJSON(req, &reqData); err != nil {
+		code, message := constants.StatusData(constants.APIKeyBadFormat)
+		http.Error(res, message, code)
+		return
+	}
+
+	key, status, err := h.adminMart.CreateAPIKey(ctx, adminID, reqData)
+	writeAdminJSON(res, key, status, err)
+}
+
+// перевыпуск ключа API, старый ключ перестает действовать
+func (h *Server) adminRotateAPIKey(res http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	adminID, ok := ctx.Value(constants.UserIDKey).(int64)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(req, "id"), 10, 64)
+	if err != nil {
+		code, message := constants.StatusData(constants.APIKeyBadFormat)
+		http.Error(res, message, code)
+		return
+	}
+
+	key, status, err := h.adminMart.RotateAPIKey(ctx, adminID, id)
+	writeAdminJSON(res, key, status, err)
+}
+
+// отзыв ключа API
+func (h *Server) adminRevokeAPIKey(res http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	adminID, ok := ctx.Value(constants.UserIDKey).(int64)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(req, "id"), 10, 64)
+	if err != nil {
+		code, message := constants.StatusData(constants.APIKeyBadFormat)
+		http.Error(res, message, code)
+		return
+	}
+
+	status, err := h.adminMart.RevokeAPIKey(ctx, adminID, id)
+	writeAdminMessage(res, status, err)
+}
+
+// установка ключа подписи HMAC для ключа API, пустой ключ отключает подпись
+func (h *Server) adminSetSigningKey(res http.ResponseWriter, req *http.Request) {
+	type sReq struct {
+		Key string `json:"key"`
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	adminID, ok := ctx.Value(constants.UserIDKey).(int64)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(req, "id"), 10, 64)
+	if err != nil {
+		code, message := constants.StatusData(constants.APIKeyBadFormat)
+		http.Error(res, message, code)
+		return
+	}
+
+	var reqData sReq
+	if err = readJSON(req, &reqData); err != nil {
+		code, message := constants.StatusData(constants.APIKeyBadFormat)
+		http.Error(res, message, code)
+		return
+	}
+
+	status, err := h.adminMart.SetSigningKey(ctx, adminID, id, reqData.Key)
+	writeAdminMessage(res, status, err)
+}
+
+// текущий уровень журнала
+func (h *Server) adminLogLevel(res http.ResponseWriter, req *http.Request) {
+	writeLogLevel(res)
+}
+
+// изменение уровня журнала без перезапуска сервиса
+func (h *Server) adminSetLogLevel(res http.ResponseWriter, req *http.Request) {
+	type rReq struct {
+		Level string `json:"level"`
+	}
+
+	var reqData rReq
+	if err := readJSON(req, &reqData); err != nil {
+		http.Error(res, constants.StatusBadRequestFormat, http.StatusBadRequest)
+		return
+	}
+
+	previous := logger.Log().LevelName()
+	if err := logger.Log().SetLevel(reqData.Level); err != nil {
+		http.Error(res, constants.StatusBadRequestFormat+", "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	logger.Log().WithContext(req.Context()).Warn("log level changed",
+		zap.String("from", previous),
+		zap.String("to", logger.Log().LevelName()),
+	)
+
+	writeLogLevel(res)
+}
+
+func writeLogLevel(res http.ResponseWriter) {
+	body, _ := json.Marshal(map[string]string{"level": logger.Log().LevelName()})
+
+	res.Header().Set("Content-Type", constants.ApplicationJSON)
+	res.WriteHeader(http.StatusOK)
+	res.Write(body)
+}
+
+// действующие параметры, которые меняются без перезапуска
+func (h *Server) adminRuntimeConfig(res http.ResponseWriter, req *http.Request) {
+	writeAdminJSON(res, h.configMart.Runtime(), constants.AdminOk, nil)
+}
+
+// перечитывание файла конфигурации; при ошибке в параметрах работающий сервис не меняется
+func (h *Server) adminReloadConfig(res http.ResponseWriter, req *http.Request) {
+	report, err := h.configMart.Reload()
+	if err != nil {
+		logger.Log().WithContext(req.Context()).Error("Конфигурация не применена: " + err.Error())
+		http.Error(res, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	logger.Log().WithContext(req.Context()).Warn("Конфигурация перечитана",
+		zap.Any("applied", report.Applied),
+		zap.Strings("restart_required", report.RestartRequired),
+	)
+
+	writeAdminJSON(res, report, constants.AdminOk, nil)
+}
+
+// чтение JSON тела запроса
+func readJSON(req *http.Request, v any) error {
+	var buf bytes.Buffer
+
+	_, err := buf.ReadFrom(req.Body)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(buf.Bytes(), v)
+}
+
+// ответ служебного API с данными в JSON
+func writeAdminJSON(res http.ResponseWriter, data any, status int, err error) {
+	if err != nil {
+		logger.Log().Error(err.Error())
+		code, message := constants.StatusData(status)
+		http.Error(res, message+", "+err.Error(), code)
+		return
+	}
+
+	body, err := json.Marshal(data)
+	if err != nil {
+		http.Error(res, "", http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", constants.ApplicationJSON)
+	code, _ := constants.StatusData(status)
+	res.WriteHeader(code)
+	res.Write(body)
+}
+
+// ответ служебного API с текстовым сообщением
+func writeAdminMessage(res http.ResponseWriter, status int, err error) {
+	code, message := constants.StatusData(status)
+	if err != nil {
+		logger.Log().Error(err.Error())
+		http.Error(res, message+", "+err.Error(), code)
+		return
+	}
+
+	res.Header().Set("Content-Type", constants.ApplicationJSON)
+	res.WriteHeader(code)
+	res.Write([]byte(message))
+}
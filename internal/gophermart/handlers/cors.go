@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/dnsoftware/gophermart2/internal/constants"
+)
+
+// WithCORS разрешение запросов из браузера с адресов, перечисленных в конфигурации
+// запросы с других адресов обрабатываются как обычно, но без заголовков CORS, и браузер не отдает ответ странице
+func (h *Server) WithCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		if !h.configMart.Runtime().AllowOrigin(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Expose-Headers", constants.CORSExposeHeaders)
+
+		// предварительный запрос браузера отвечается здесь, до маршрутизации
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", constants.CORSAllowMethods)
+			w.Header().Set("Access-Control-Allow-Headers", constants.CORSAllowHeaders)
+			w.Header().Set("Access-Control-Max-Age", constants.CORSMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	"github.com/dnsoftware/gophermart2/internal/gophermart/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithCORS(t *testing.T) {
+	cfg := &stubConfig{}
+	h := &Server{configMart: cfg}
+
+	var called bool
+	handler := h.WithCORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name          string
+		origins       []string
+		method        string
+		headers       map[string]string
+		wantCode      int
+		wantCalled    bool
+		wantOrigin    string
+		wantVary      bool
+		wantPreflight bool
+	}{
+		{
+			name:       "No Origin",
+			origins:    []string{"https://mart.example.com"},
+			method:     http.MethodGet,
+			wantCode:   http.StatusOK,
+			wantCalled: true,
+		},
+		{
+			name:       "CORS disabled",
+			origins:    nil,
+			method:     http.MethodGet,
+			headers:    map[string]string{"Origin": "https://mart.example.com"},
+			wantCode:   http.StatusOK,
+			wantCalled: true,
+			wantVary:   true,
+		},
+		{
+			name:       "Other origin",
+			origins:    []string{"https://mart.example.com"},
+			method:     http.MethodGet,
+			headers:    map[string]string{"Origin": "https://evil.example.com"},
+			wantCode:   http.StatusOK,
+			wantCalled: true,
+			wantVary:   true,
+		},
+		{
+			name:       "Allowed origin",
+			origins:    []string{"https://mart.example.com"},
+			method:     http.MethodGet,
+			headers:    map[string]string{"Origin": "https://MART.example.com"},
+			wantCode:   http.StatusOK,
+			wantCalled: true,
+			wantOrigin: "https://MART.example.com",
+			wantVary:   true,
+		},
+		{
+			name:       "Any origin",
+			origins:    []string{"*"},
+			method:     http.MethodGet,
+			headers:    map[string]string{"Origin": "http://localhost:3000"},
+			wantCode:   http.StatusOK,
+			wantCalled: true,
+			wantOrigin: "http://localhost:3000",
+			wantVary:   true,
+		},
+		{
+			name:    "Preflight",
+			origins: []string{"https://mart.example.com"},
+			method:  http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                        "https://mart.example.com",
+				"Access-Control-Request-Method": http.MethodPost,
+			},
+			wantCode:      http.StatusNoContent,
+			wantCalled:    false,
+			wantOrigin:    "https://mart.example.com",
+			wantVary:      true,
+			wantPreflight: true,
+		},
+		{
+			name:       "Preflight from other origin",
+			origins:    []string{"https://mart.example.com"},
+			method:     http.MethodOptions,
+			headers:    map[string]string{"Origin": "https://evil.example.com", "Access-Control-Request-Method": http.MethodPost},
+			wantCode:   http.StatusOK,
+			wantCalled: true,
+			wantVary:   true,
+		},
+		{
+			name:       "OPTIONS without preflight",
+			origins:    []string{"https://mart.example.com"},
+			method:     http.MethodOptions,
+			headers:    map[string]string{"Origin": "https://mart.example.com"},
+			wantCode:   http.StatusOK,
+			wantCalled: true,
+			wantOrigin: "https://mart.example.com",
+			wantVary:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.runtime = &config.Runtime{CORSOrigins: tt.origins}
+			called = false
+
+			req := httptest.NewRequest(tt.method, constants.UserOrdersListRoute, nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			res := httptest.NewRecorder()
+			handler.ServeHTTP(res, req)
+
+			require.Equal(t, tt.wantCode, res.Code)
+			require.Equal(t, tt.wantCalled, called)
+			require.Equal(t, tt.wantOrigin, res.Header().Get("Access-Control-Allow-Origin"))
+			require.Equal(t, tt.wantVary, res.Header().Get("Vary") == "Origin")
+			if tt.wantOrigin != "" {
+				require.Equal(t, constants.CORSExposeHeaders, res.Header().Get("Access-Control-Expose-Headers"))
+			}
+			if tt.wantPreflight {
+				require.Equal(t, constants.CORSAllowMethods, res.Header().Get("Access-Control-Allow-Methods"))
+				require.Equal(t, constants.CORSAllowHeaders, res.Header().Get("Access-Control-Allow-Headers"))
+				require.Equal(t, constants.CORSMaxAge, res.Header().Get("Access-Control-Max-Age"))
+			} else {
+				require.Empty(t, res.Header().Get("Access-Control-Allow-Methods"))
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	"github.com/dnsoftware/gophermart2/internal/gophermart/domain"
+	"github.com/dnsoftware/gophermart2/internal/logger"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// поток событий пользователя (Server-Sent Events)
+// новый поток начинается с текущего момента; после переподключения клиент передает Last-Event-ID
+// и получает пропущенные события из журнала
+func (h *Server) userEvents(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	userID, ok := ctx.Value(constants.UserIDKey).(int64)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	flusher, ok := res.(http.Flusher)
+	if !ok {
+		http.Error(res, "потоковая передача не поддерживается", http.StatusInternalServerError)
+		return
+	}
+
+	// браузерный EventSource передает Last-Event-ID заголовком, для остальных клиентов - параметр запроса
+	lastEventID := req.Header.Get(constants.HeaderLastEventID)
+	if lastEventID == "" {
+		lastEventID = req.URL.Query().Get("last_event_id")
+	}
+
+	var lastID int64
+	replay := lastEventID != ""
+	if replay {
+		var err error
+		lastID, err = strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || lastID < 0 {
+			http.Error(res, constants.StatusBadRequestFormat, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// подписка до чтения журнала, чтобы не потерять события между чтением и подпиской
+	events, unsubscribe := h.eventsMart.Subscribe(userID)
+	defer unsubscribe()
+
+	// без Last-Event-ID прошлые события не отправляются, только те, что появятся после подключения
+	if !replay {
+		ctxDB, cancel := context.WithTimeout(ctx, h.requestTimeout)
+		id, err := h.eventsMart.LastID(ctxDB, userID)
+		cancel()
+		if err != nil {
+			logger.Log().WithContext(ctx).Error(err.Error())
+			http.Error(res, constants.StatusInternalServerError, http.StatusInternalServerError)
+			return
+		}
+		lastID = id
+	}
+
+	res.Header().Set("Content-Type", constants.TextEventStream)
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for replay {
+		ctxDB, cancel := context.WithTimeout(ctx, h.requestTimeout)
+		list, err := h.eventsMart.Replay(ctxDB, userID, lastID)
+		cancel()
+		if err != nil {
+			logger.Log().WithContext(ctx).Error(err.Error())
+			return
+		}
+
+		for _, event := range list {
+			if err = writeEvent(res, event); err != nil {
+				return
+			}
+			lastID = event.ID
+		}
+		flusher.Flush()
+
+		if len(list) < constants.EventsReplayLimit {
+			break
+		}
+	}
+
+	ticker := time.NewTicker(constants.EventsHeartbeatPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case event, ok := <-events:
+			if !ok { // клиент не успевал забирать события и был отключен
+				return
+			}
+			// уже отправлено из журнала
+			if event.ID <= lastID {
+				continue
+			}
+
+			if err := writeEvent(res, event); err != nil {
+				return
+			}
+			lastID = event.ID
+			flusher.Flush()
+
+		case <-ticker.C:
+			if _, err := fmt.Fprint(res, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(res http.ResponseWriter, event domain.UserEvent) error {
+	_, err := fmt.Fprintf(res, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+
+	return err
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	"github.com/dnsoftware/gophermart2/internal/gophermart/domain"
+	"github.com/stretchr/testify/require"
+)
+
+// журнал событий пользователя; поток завершается, как только отправлено все из канала подписки
+type stubEvents struct {
+	stored   []domain.UserEvent
+	live     []domain.UserEvent
+	replayed []int64 // afterID вызовов Replay
+}
+
+func (s *stubEvents) Subscribe(userID int64) (<-chan domain.UserEvent, func()) {
+	ch := make(chan domain.UserEvent, len(s.live))
+	for _, event := range s.live {
+		ch <- event
+	}
+	close(ch)
+
+	return ch, func() {}
+}
+
+func (s *stubEvents) Replay(ctx context.Context, userID int64, afterID int64) ([]domain.UserEvent, error) {
+	s.replayed = append(s.replayed, afterID)
+
+	list := make([]domain.UserEvent, 0)
+	for _, event := range s.stored {
+		if event.ID > afterID {
+			list = append(list, event)
+		}
+	}
+
+	return list, nil
+}
+
+func (s *stubEvents) LastID(ctx context.Context, userID int64) (int64, error) {
+	return s.stored[len(s.stored)-1].ID, nil
+}
+
+func TestUserEvents(t *testing.T) {
+	tests := []struct {
+		name        string
+		lastEventID string
+		wantReplay  bool
+		wantIDs     []string
+	}{
+		{
+			name:       "New connection",
+			wantReplay: false,
+			wantIDs:    []string{"id: 7\n"},
+		},
+		{
+			name:        "Reconnect",
+			lastEventID: "4",
+			wantReplay:  true,
+			wantIDs:     []string{"id: 5\n", "id: 6\n", "id: 7\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := &stubEvents{
+				stored: []domain.UserEvent{
+					{ID: 4, Type: constants.EventTypeOrder, Data: "{}"},
+					{ID: 5, Type: constants.EventTypeOrder, Data: "{}"},
+					{ID: 6, Type: constants.EventTypeBalance, Data: "{}"},
+				},
+				// событие 6 пришло и подписчику, повторно не отправляется
+				live: []domain.UserEvent{
+					{ID: 6, Type: constants.EventTypeBalance, Data: "{}"},
+					{ID: 7, Type: constants.EventTypeOrder, Data: "{}"},
+				},
+			}
+			h := &Server{eventsMart: events, requestTimeout: time.Second}
+
+			req := httptest.NewRequest(http.MethodGet, constants.UserEventsRoute, nil)
+			req = req.WithContext(context.WithValue(req.Context(), constants.UserIDKey, int64(1)))
+			if tt.lastEventID != "" {
+				req.Header.Set(constants.HeaderLastEventID, tt.lastEventID)
+			}
+			res := httptest.NewRecorder()
+			h.userEvents(res, req)
+
+			require.Equal(t, http.StatusOK, res.Code)
+			require.Equal(t, tt.wantReplay, len(events.replayed) > 0)
+
+			body := res.Body.String()
+			for _, id := range tt.wantIDs {
+				require.Contains(t, body, id)
+			}
+			require.NotContains(t, body, "id: 4\n")
+			if !tt.wantReplay {
+				require.NotContains(t, body, "id: 5\n", "новое подключение не получает прошлые события")
+			}
+		})
+	}
+}
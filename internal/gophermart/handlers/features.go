@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/dnsoftware/gophermart2/internal/constants"
+)
+
+// RequireFeature пропускает запрос дальше, только если функция включена в конфигурации
+func (h *Server) RequireFeature(name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !h.configMart.Runtime().Feature(name) {
+				http.Error(w, constants.StatusFeatureDisabled, http.StatusNotFound)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
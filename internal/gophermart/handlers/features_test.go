@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	"github.com/dnsoftware/gophermart2/internal/gophermart/config"
+	"github.com/stretchr/testify/require"
+)
+
+// параметры, которые тест меняет между запросами, как при перечитывании конфигурации
+type stubConfig struct {
+	runtime *config.Runtime
+}
+
+func (s *stubConfig) Runtime() *config.Runtime {
+	return s.runtime
+}
+
+func (s *stubConfig) Reload() (*config.ReloadReport, error) {
+	return &config.ReloadReport{Applied: s.runtime}, nil
+}
+
+func TestRequireFeature(t *testing.T) {
+	cfg := &stubConfig{runtime: &config.Runtime{}}
+	h := &Server{configMart: cfg}
+
+	handler := h.RequireFeature(constants.FeatureWebSocket)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name     string
+		features map[string]bool
+		want     int
+	}{
+		{
+			name:     "Not mentioned",
+			features: map[string]bool{},
+			want:     http.StatusOK,
+		},
+		{
+			name:     "Enabled",
+			features: map[string]bool{constants.FeatureWebSocket: true},
+			want:     http.StatusOK,
+		},
+		{
+			name:     "Disabled",
+			features: map[string]bool{constants.FeatureWebSocket: false},
+			want:     http.StatusNotFound,
+		},
+		{
+			name:     "Other disabled",
+			features: map[string]bool{constants.FeatureWebSocket: true, constants.FeaturePartnerAPI: false},
+			want:     http.StatusOK,
+		},
+	}
+
+	// один и тот же обработчик: конфигурация читается при каждом запросе
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.runtime = &config.Runtime{Features: tt.features}
+
+			res := httptest.NewRecorder()
+			handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, constants.UserWebSocketRoute, nil))
+
+			require.Equal(t, tt.want, res.Code)
+		})
+	}
+}
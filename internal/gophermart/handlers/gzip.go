@@ -27,6 +27,8 @@ func (c *compressWriter) Header() http.Header {
 }
 
 func (c *compressWriter) Write(p []byte) (int, error) {
+	// обработчик мог не вызвать WriteHeader, тогда заголовки уйдут вместе с первой записью
+	c.w.Header().Set("Content-Encoding", constants.EncodingGzip)
 	return c.zw.Write(p)
 }
 
@@ -35,6 +37,14 @@ func (c *compressWriter) WriteHeader(statusCode int) {
 	c.w.WriteHeader(statusCode)
 }
 
+// Flush отправляет клиенту уже сжатые данные, не закрывая поток
+func (c *compressWriter) Flush() {
+	c.zw.Flush()
+	if f, ok := c.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 // Close закрывает gzip.Writer и досылает все данные из буфера.
 func (c *compressWriter) Close() error {
 	return c.zw.Close()
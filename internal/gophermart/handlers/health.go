@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/dnsoftware/gophermart2/internal/constants"
+)
+
+// процесс жив, если способен ответить
+func (h *Server) healthz(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	res.WriteHeader(http.StatusOK)
+	res.Write([]byte("ok"))
+}
+
+// готовность принимать запросы; при отказе - 503 и причины по каждой проверке
+func (h *Server) readyz(res http.ResponseWriter, req *http.Request) {
+	report := h.healthMart.Ready(req.Context())
+
+	code := http.StatusOK
+	if !report.Ready {
+		code = http.StatusServiceUnavailable
+	}
+
+	writeHealthJSON(res, report, code)
+}
+
+// подробное состояние сервиса, только для администраторов: раскрывает внутреннее устройство
+func (h *Server) debugStatus(res http.ResponseWriter, req *http.Request) {
+	writeHealthJSON(res, h.healthMart.Status(req.Context()), http.StatusOK)
+}
+
+func writeHealthJSON(res http.ResponseWriter, data any, code int) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		http.Error(res, "", http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", constants.ApplicationJSON)
+	res.Header().Set("Cache-Control", "no-store")
+	res.WriteHeader(code)
+	res.Write(body)
+}
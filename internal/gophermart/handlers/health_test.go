@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	"github.com/dnsoftware/gophermart2/internal/gophermart/domain"
+	"github.com/stretchr/testify/require"
+)
+
+// пользователи: токен - роль пользователя
+type stubUsers struct {
+	UserMart
+	roles map[string]string
+}
+
+func (s *stubUsers) Authenticate(ctx context.Context, token string) (int64, string, error) {
+	role, ok := s.roles[token]
+	if !ok {
+		return -1, "", fmt.Errorf("неизвестный токен")
+	}
+
+	return 1, role, nil
+}
+
+type stubHealth struct{}
+
+func (s *stubHealth) Ready(ctx context.Context) domain.ReadyReport {
+	return domain.ReadyReport{}
+}
+
+func (s *stubHealth) Status(ctx context.Context) domain.DebugStatus {
+	return domain.DebugStatus{}
+}
+
+func TestDebugStatus(t *testing.T) {
+	users := &stubUsers{roles: map[string]string{
+		"admin":   constants.RoleAdmin,
+		"support": constants.RoleSupport,
+		"user":    constants.RoleUser,
+	}}
+	srv := NewServer("", 0, users, nil, nil, nil, nil, nil, nil, nil, &stubHealth{}, &stubConfig{})
+
+	tests := []struct {
+		name     string
+		token    string
+		wantCode int
+	}{
+		{name: "Admin", token: "admin", wantCode: http.StatusOK},
+		{name: "Support", token: "support", wantCode: http.StatusForbidden},
+		{name: "User", token: "user", wantCode: http.StatusForbidden},
+		{name: "No token", token: "", wantCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, constants.DebugStatusRoute, nil)
+			if tt.token != "" {
+				req.Header.Set(constants.HeaderAuthorization, "Bearer "+tt.token)
+			}
+
+			res := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(res, req)
+
+			require.Equal(t, tt.wantCode, res.Code)
+		})
+	}
+}
@@ -1,35 +1,121 @@
 package handlers
 
 import (
+	"bufio"
 	"context"
+	"fmt"
 	"github.com/dnsoftware/gophermart2/internal/constants"
+	"github.com/dnsoftware/gophermart2/internal/gophermart/config"
 	"github.com/dnsoftware/gophermart2/internal/gophermart/domain"
+	"github.com/dnsoftware/gophermart2/internal/metrics"
 	"github.com/go-chi/chi/v5"
+	"net"
 	"net/http"
+	"time"
 )
 
 type UserMart interface {
 	AddUser(ctx context.Context, login string, password string) (string, int, error)
 	LoginUser(ctx context.Context, login string, password string) (string, int, error)
+	ChangePassword(ctx context.Context, userID int64, oldPassword string, newPassword string) (string, int, error)
+	RequestPasswordReset(ctx context.Context, login string) (int, error)
+	ResetPassword(ctx context.Context, token string, password string) (int, error)
+	Authenticate(ctx context.Context, tokenString string) (int64, string, error)
+	Profile(ctx context.Context, userID int64) (*domain.UserProfile, int, error)
+	UpdateProfile(ctx context.Context, userID int64, upd domain.ProfileUpdate) (*domain.UserProfile, int, error)
 }
 
 type OrderMart interface {
-	AddOrder(ctx context.Context, userID int64, orderID int64) (int, error)
+	AddOrder(ctx context.Context, userID int64, number string) (int, error)
+	AddOrders(ctx context.Context, userID int64, numbers []string) ([]domain.BatchOrderResult, int, error)
 	OrdersList(ctx context.Context, userID int64) ([]domain.OrderItem, int, error)
+	OrdersPage(ctx context.Context, userID int64, q domain.OrderListQuery) (*domain.OrderPage, int, error)
+	OrderDetail(ctx context.Context, userID int64, number string) (*domain.OrderDetail, int, error)
+	CancelOrder(ctx context.Context, userID int64, number string) (int, error)
+	OpenDispute(ctx context.Context, userID int64, number string, comment string) (int64, int, error)
+	Disputes(ctx context.Context, userID int64) ([]domain.DisputeItem, int, error)
 }
 
 type BalanceMart interface {
-	AddTransaction(ctx context.Context, orderNumber int64, amount float32) error
+	AddTransaction(ctx context.Context, orderNumber string, amount float32, from []string, source string) error
 	UserBalance(ctx context.Context, userID int64) (*domain.CurrentBalance, error)
 	UserWithrawalsList(ctx context.Context, userID int64) ([]domain.WithdrawItem, error)
-	Withraw(ctx context.Context, userID int64, number int64, amount float32) (int, error)
+	Withraw(ctx context.Context, userID int64, number string, amount float32) (int, error)
+	UserLedger(ctx context.Context, userID int64) ([]domain.LedgerItem, error)
+}
+
+type AccountMart interface {
+	Export(ctx context.Context, userID int64) (*domain.AccountExport, int, error)
+	RequestDeletion(ctx context.Context, userID int64) (time.Time, int, error)
+	CancelDeletion(ctx context.Context, userID int64) (int, error)
+}
+
+type AdminMart interface {
+	SearchUsers(ctx context.Context, adminID int64, login string) ([]domain.AdminUserItem, int, error)
+	UserOrders(ctx context.Context, adminID int64, userID int64) ([]domain.OrderItem, int, error)
+	UserLedger(ctx context.Context, adminID int64, userID int64) ([]domain.LedgerItem, int, error)
+	RecheckOrder(ctx context.Context, adminID int64, orderNumber string) (int, error)
+	SetOrderStatus(ctx context.Context, adminID int64, orderNumber string, orderStatus string, accrual *float32, reason string) (int, error)
+	BlockUser(ctx context.Context, adminID int64, userID int64, reason string) (int, error)
+	UnblockUser(ctx context.Context, adminID int64, userID int64) (int, error)
+	SetRole(ctx context.Context, adminID int64, userID int64, role string) (int, error)
+	AuditList(ctx context.Context) ([]domain.AuditItem, int, error)
+	CreateAdjustment(ctx context.Context, adminID int64, req domain.AdjustmentRequest) (int64, int, error)
+	Adjustments(ctx context.Context, status string) ([]domain.AdjustmentItem, int, error)
+	ApproveAdjustment(ctx context.Context, adminID int64, id int64) (int, error)
+	RejectAdjustment(ctx context.Context, adminID int64, id int64) (int, error)
+	Disputes(ctx context.Context, status string) ([]domain.DisputeItem, int, error)
+	ApproveDispute(ctx context.Context, adminID int64, id int64, resolution string) (int, error)
+	RejectDispute(ctx context.Context, adminID int64, id int64, resolution string) (int, error)
+	CreateAPIKey(ctx context.Context, adminID int64, req domain.APIKeyRequest) (domain.APIKeySecret, int, error)
+	APIKeys(ctx context.Context) ([]domain.APIKeyItem, int, error)
+	RotateAPIKey(ctx context.Context, adminID int64, id int64) (domain.APIKeySecret, int, error)
+	RevokeAPIKey(ctx context.Context, adminID int64, id int64) (int, error)
+	SetSigningKey(ctx context.Context, adminID int64, id int64, signingKey string) (int, error)
+}
+
+type PartnerMart interface {
+	Authenticate(ctx context.Context, key string) (*domain.APIClient, int, error)
+	UploadOrder(ctx context.Context, client *domain.APIClient, login string, number string) (int, error)
+}
+
+type WebhookMart interface {
+	CreateWebhook(ctx context.Context, client *domain.APIClient, req domain.WebhookRequest) (int64, int, error)
+	Webhooks(ctx context.Context, client *domain.APIClient) ([]domain.WebhookItem, int, error)
+	DeleteWebhook(ctx context.Context, client *domain.APIClient, id int64) (int, error)
+	Deliveries(ctx context.Context, client *domain.APIClient, id int64) ([]domain.WebhookDeliveryItem, int, error)
+}
+
+type HealthMart interface {
+	Ready(ctx context.Context) domain.ReadyReport
+	Status(ctx context.Context) domain.DebugStatus
+}
+
+type ConfigMart interface {
+	Runtime() *config.Runtime
+	Reload() (*config.ReloadReport, error)
+}
+
+type EventsMart interface {
+	Subscribe(userID int64) (<-chan domain.UserEvent, func())
+	Replay(ctx context.Context, userID int64, afterID int64) ([]domain.UserEvent, error)
+	LastID(ctx context.Context, userID int64) (int64, error)
 }
 
 type Server struct {
 	userMart    UserMart
 	orderMart   OrderMart
 	balanceMart BalanceMart
+	accountMart AccountMart
+	adminMart   AdminMart
+	partnerMart PartnerMart
+	eventsMart  EventsMart
+	webhookMart WebhookMart
+	healthMart  HealthMart
+	configMart  ConfigMart
 	Router      chi.Router
+
+	requestTimeout time.Duration // время на обработку запроса к API
 }
 
 type (
@@ -46,24 +132,104 @@ type (
 	}
 )
 
-func NewServer(runAddr string, userMart UserMart, orderMart OrderMart, balanceMart BalanceMart) *http.Server {
+func NewServer(runAddr string, requestTimeout time.Duration, userMart UserMart, orderMart OrderMart, balanceMart BalanceMart, accountMart AccountMart, adminMart AdminMart, partnerMart PartnerMart, eventsMart EventsMart, webhookMart WebhookMart, healthMart HealthMart, configMart ConfigMart) *http.Server {
 	h := Server{
 		userMart:    userMart,
 		orderMart:   orderMart,
 		balanceMart: balanceMart,
+		accountMart: accountMart,
+		adminMart:   adminMart,
+		partnerMart: partnerMart,
+		eventsMart:  eventsMart,
+		webhookMart: webhookMart,
+		healthMart:  healthMart,
+		configMart:  configMart,
 		Router:      NewRouter(),
+
+		requestTimeout: requestTimeout,
 	}
+	h.Router.Use(WithRequestID)
+	h.Router.Use(WithTracing)
+	h.Router.Use(WithMetrics)
 	h.Router.Use(trimEnd)
+	h.Router.Use(h.WithCORS)
 	h.Router.Use(GzipMiddleware)
 	h.Router.Use(WithLogging)
 
+	h.Router.Get(constants.HealthRoute, h.healthz)
+	h.Router.Get(constants.ReadyRoute, h.readyz)
+	h.Router.With(h.AuthMiddleware, RequireRole(constants.RoleAdmin)).Get(constants.DebugStatusRoute, h.debugStatus)
+
 	h.Router.Post(constants.UserRegisterRoute, h.userRegister)
 	h.Router.Post(constants.UserLoginRoute, h.userLogin)
-	h.Router.With(AuthMiddleware).Post(constants.UserOrderUploadRoute, h.userOrderUpload)
-	h.Router.With(AuthMiddleware).Get(constants.UserOrdersListRoute, h.userOrdersList)
-	h.Router.With(AuthMiddleware).Get(constants.UserBalanceRoute, h.userBalance)
-	h.Router.With(AuthMiddleware).Get(constants.UserWithdrawalsRoute, h.userWithdrawals)
-	h.Router.With(AuthMiddleware).Post(constants.UserWithdrawRoute, h.userWithdraw)
+	h.Router.Post(constants.UserPasswordResetRoute, h.userPasswordResetRequest)
+	h.Router.Post(constants.UserPasswordResetConfirmRoute, h.userPasswordResetConfirm)
+	h.Router.With(h.AuthMiddleware).Post(constants.UserOrderUploadRoute, h.userOrderUpload)
+	h.Router.With(h.RequireFeature(constants.FeatureBatchUpload), h.AuthMiddleware).Post(constants.UserOrdersBatchRoute, h.userOrdersBatchUpload)
+	h.Router.With(h.AuthMiddleware).Get(constants.UserOrdersListRoute, h.userOrdersList)
+	h.Router.With(h.AuthMiddleware).Get(constants.UserOrderRoute, h.userOrderDetail)
+	h.Router.With(h.AuthMiddleware).Delete(constants.UserOrderRoute, h.userOrderCancel)
+	h.Router.With(h.AuthMiddleware).Post(constants.UserOrderDisputeRoute, h.userOrderDispute)
+	h.Router.With(h.AuthMiddleware).Get(constants.UserDisputesRoute, h.userDisputes)
+	h.Router.With(h.AuthMiddleware).Get(constants.UserBalanceRoute, h.userBalance)
+	h.Router.With(h.AuthMiddleware).Get(constants.UserWithdrawalsRoute, h.userWithdrawals)
+	h.Router.With(h.AuthMiddleware).Post(constants.UserWithdrawRoute, h.userWithdraw)
+	h.Router.With(h.AuthMiddleware).Get(constants.UserLedgerRoute, h.userLedger)
+	h.Router.With(h.RequireFeature(constants.FeatureEventsStream), h.AuthMiddleware).Get(constants.UserEventsRoute, h.userEvents)
+	h.Router.With(h.RequireFeature(constants.FeatureWebSocket)).Get(constants.UserWebSocketRoute, h.userWebSocket) // авторизация внутри: браузер не передает заголовки при подключении
+	h.Router.With(h.AuthMiddleware).Post(constants.UserPasswordRoute, h.userPasswordChange)
+	h.Router.With(h.AuthMiddleware).Get(constants.UserProfileRoute, h.userProfile)
+	h.Router.With(h.AuthMiddleware).Patch(constants.UserProfileRoute, h.userProfileUpdate)
+	h.Router.With(h.AuthMiddleware).Get(constants.UserExportRoute, h.userExport)
+	h.Router.With(h.AuthMiddleware).Delete(constants.UserAccountRoute, h.userDelete)
+	h.Router.With(h.AuthMiddleware).Post(constants.UserDeletionCancelRoute, h.userDeletionCancel)
+
+	// служебные маршруты: только для сотрудников поддержки и администраторов
+	h.Router.Route(constants.AdminRoute, func(r chi.Router) {
+		r.Use(h.AuthMiddleware)
+		r.Use(RequireRole(constants.RoleSupport, constants.RoleAdmin))
+
+		r.Get(constants.AdminUsersRoute, h.adminSearchUsers)
+		r.Get(constants.AdminUserOrdersRoute, h.adminUserOrders)
+		r.Get(constants.AdminUserLedgerRoute, h.adminUserLedger)
+		r.Post(constants.AdminOrderRecheckRoute, h.adminRecheckOrder)
+		r.Get(constants.AdminAdjustmentsRoute, h.adminAdjustments)
+		r.Post(constants.AdminAdjustmentsRoute, h.adminCreateAdjustment)
+		r.Get(constants.AdminDisputesRoute, h.adminDisputes)
+		r.Post(constants.AdminDisputeApprove, h.adminApproveDispute)
+		r.Post(constants.AdminDisputeReject, h.adminRejectDispute)
+
+		r.With(RequireRole(constants.RoleAdmin)).Put(constants.AdminUserRoleRoute, h.adminSetRole)
+		r.With(RequireRole(constants.RoleAdmin)).Post(constants.AdminUserBlockRoute, h.adminBlockUser)
+		r.With(RequireRole(constants.RoleAdmin)).Post(constants.AdminUserUnblockRoute, h.adminUnblockUser)
+		r.With(RequireRole(constants.RoleAdmin)).Put(constants.AdminOrderStatusRoute, h.adminSetOrderStatus)
+		r.With(RequireRole(constants.RoleAdmin)).Get(constants.AdminAuditRoute, h.adminAuditList)
+		r.With(RequireRole(constants.RoleAdmin)).Post(constants.AdminAdjustmentApprove, h.adminApproveAdjustment)
+		r.With(RequireRole(constants.RoleAdmin)).Post(constants.AdminAdjustmentReject, h.adminRejectAdjustment)
+		r.With(RequireRole(constants.RoleAdmin)).Get(constants.AdminAPIKeysRoute, h.adminAPIKeys)
+		r.With(RequireRole(constants.RoleAdmin)).Post(constants.AdminAPIKeysRoute, h.adminCreateAPIKey)
+		r.With(RequireRole(constants.RoleAdmin)).Post(constants.AdminAPIKeyRotateRoute, h.adminRotateAPIKey)
+		r.With(RequireRole(constants.RoleAdmin)).Delete(constants.AdminAPIKeyRoute, h.adminRevokeAPIKey)
+		r.With(RequireRole(constants.RoleAdmin)).Put(constants.AdminAPIKeySigningKey, h.adminSetSigningKey)
+		r.With(RequireRole(constants.RoleAdmin)).Get(constants.AdminLogLevelRoute, h.adminLogLevel)
+		r.With(RequireRole(constants.RoleAdmin)).Put(constants.AdminLogLevelRoute, h.adminSetLogLevel)
+		r.With(RequireRole(constants.RoleAdmin)).Get(constants.AdminConfigRoute, h.adminRuntimeConfig)
+		r.With(RequireRole(constants.RoleAdmin)).Post(constants.AdminConfigReloadRoute, h.adminReloadConfig)
+	})
+
+	// маршруты партнерских систем: доступ по ключу API
+	h.Router.Route(constants.PartnerRoute, func(r chi.Router) {
+		r.Use(h.RequireFeature(constants.FeaturePartnerAPI))
+		r.Use(h.APIKeyMiddleware)
+		r.Use(SignatureMiddleware)
+
+		r.With(RequireScope(constants.ScopeOrdersWrite)).Post(constants.PartnerOrdersRoute, h.partnerOrderUpload)
+
+		r.With(RequireScope(constants.ScopeWebhooks)).Post(constants.PartnerWebhooksRoute, h.partnerWebhookCreate)
+		r.With(RequireScope(constants.ScopeWebhooks)).Get(constants.PartnerWebhooksRoute, h.partnerWebhooks)
+		r.With(RequireScope(constants.ScopeWebhooks)).Delete(constants.PartnerWebhookRoute, h.partnerWebhookDelete)
+		r.With(RequireScope(constants.ScopeWebhooks)).Get(constants.PartnerWebhookDelivery, h.partnerWebhookDeliveries)
+	})
 
 	srv := &http.Server{
 		Addr:    runAddr,
@@ -73,6 +239,30 @@ func NewServer(runAddr string, userMart UserMart, orderMart OrderMart, balanceMa
 	return srv
 }
 
+// NewMetricsServer отдача метрик на отдельном адресе
+// метрики раскрывают внутреннее устройство сервиса, поэтому не обслуживаются публичным сервером
+func NewMetricsServer(addr string) *http.Server {
+	router := NewRouter()
+	router.Use(GzipMiddleware)
+	router.Handle(constants.MetricsRoute, metrics.Handler())
+
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: router,
+	}
+
+	return srv
+}
+
+// код ответа; если обработчик не вызвал WriteHeader, ответ ушел с кодом 200
+func (d *responseData) code() int {
+	if d.status == 0 {
+		return http.StatusOK
+	}
+
+	return d.status
+}
+
 func (r *loggingResponseWriter) Write(b []byte) (int, error) {
 	// записываем ответ, используя оригинальный http.ResponseWriter
 	size, err := r.ResponseWriter.Write(b)
@@ -86,3 +276,21 @@ func (r *loggingResponseWriter) WriteHeader(statusCode int) {
 	r.ResponseWriter.WriteHeader(statusCode)
 	r.responseData.status = statusCode // захватываем код статуса
 }
+
+// Flush нужен потоковым ответам (Server-Sent Events)
+func (r *loggingResponseWriter) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack нужен для перехода на протокол WebSocket
+func (r *loggingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("ResponseWriter не поддерживает Hijack")
+	}
+	r.responseData.status = http.StatusSwitchingProtocols
+
+	return hj.Hijack()
+}
@@ -3,16 +3,26 @@ package handlers
 import (
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
-	"github.com/dnsoftware/gophermart2/internal/gophermart/domain"
+	"fmt"
 	"io"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/dnsoftware/gophermart2/internal/constants"
+	"github.com/dnsoftware/gophermart2/internal/gophermart/domain"
 	"github.com/dnsoftware/gophermart2/internal/logger"
+	"github.com/dnsoftware/gophermart2/internal/metrics"
+	"github.com/dnsoftware/gophermart2/internal/tracing"
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.25.0"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -30,6 +40,55 @@ func trimEnd(next http.Handler) http.Handler {
 	})
 }
 
+// WithRequestID идентификатор запроса: берется из X-Request-ID клиента или генерируется,
+// возвращается клиенту в том же заголовке и попадает во все записи журнала, сделанные в контексте запроса
+func WithRequestID(h http.Handler) http.Handler {
+	requestIDFn := func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(constants.HeaderRequestID)
+		if !requestIDValidate(requestID) {
+			requestID = newRequestID()
+		}
+		w.Header().Set(constants.HeaderRequestID, requestID)
+
+		ctx := r.Context()
+		ctx = logger.NewRequestContext(ctx, requestID, func() string {
+			if rctx := chi.RouteContext(ctx); rctx != nil {
+				return rctx.RoutePattern()
+			}
+			return ""
+		})
+
+		h.ServeHTTP(w, r.WithContext(ctx))
+	}
+
+	return http.HandlerFunc(requestIDFn)
+}
+
+// идентификатор клиента принимается, только если он короткий и из безопасных символов,
+// иначе через него можно было бы подделать записи журнала
+func requestIDValidate(requestID string) bool {
+	if requestID == "" || len(requestID) > constants.MaxRequestIDLength {
+		return false
+	}
+
+	for _, c := range requestID {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
 // WithLogging добавляет дополнительный код для регистрации сведений о запросе
 // и возвращает новый http.Handler.
 func WithLogging(h http.Handler) http.Handler {
@@ -59,14 +118,14 @@ func WithLogging(h http.Handler) http.Handler {
 		// время выполнения запроса.
 		duration := time.Since(start)
 
-		// отправляем сведения о запросе в лог
-		logger.Log().Info("request",
+		// отправляем сведения о запросе в лог; при большом потоке запросов часть записей пропускается
+		logger.Log().Requests(r.Context()).Info("request",
 			zap.String("uri", uri),
 			zap.String("method", method),
 			zap.Duration("duration", duration),
 			zap.Int("status", rd.status),
 			zap.Int("size", rd.size),
-			zap.String("body", buf.String()),
+			zap.String("body", redactBody(buf.Bytes())),
 		)
 	}
 
@@ -74,12 +133,81 @@ func WithLogging(h http.Handler) http.Handler {
 	return http.HandlerFunc(logFn)
 }
 
+// WithMetrics учет количества и времени обработки запросов по шаблонам маршрутов
+func WithMetrics(h http.Handler) http.Handler {
+	metricsFn := func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		rd := &responseData{}
+		lw := loggingResponseWriter{
+			ResponseWriter: w,
+			responseData:   rd,
+		}
+
+		h.ServeHTTP(&lw, r)
+
+		metrics.ObserveHTTPRequest(r.Method, routePattern(r), rd.code(), time.Since(start))
+	}
+
+	return http.HandlerFunc(metricsFn)
+}
+
+// WithTracing спан на каждый запрос, продолжает трассировку из заголовков traceparent/tracestate
+func WithTracing(h http.Handler) http.Handler {
+	tracingFn := func(w http.ResponseWriter, r *http.Request) {
+		ctx := tracing.Extract(r.Context(), r.Header)
+		ctx, span := tracing.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		rd := &responseData{}
+		lw := loggingResponseWriter{
+			ResponseWriter: w,
+			responseData:   rd,
+		}
+
+		h.ServeHTTP(&lw, r.WithContext(ctx))
+
+		route := routePattern(r)
+		span.SetName(r.Method + " " + route)
+		span.SetAttributes(
+			semconv.HTTPRoute(route),
+			semconv.HTTPResponseStatusCode(rd.code()),
+		)
+		if rd.code() >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rd.code()))
+		}
+	}
+
+	return http.HandlerFunc(tracingFn)
+}
+
+// шаблон маршрута chi; известен только после того, как запрос прошел через роутер
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+		return rctx.RoutePattern()
+	}
+
+	return constants.MetricsNoRoute
+}
+
 func GzipMiddleware(h http.Handler) http.Handler {
 	gzipFn := func(w http.ResponseWriter, r *http.Request) {
 		// по умолчанию устанавливаем оригинальный http.ResponseWriter как тот,
 		// который будем передавать следующей функции
 		outWriter := w
 
+		// соединение WebSocket передается обработчику целиком, сжимать нечего
+		if websocket.IsWebSocketUpgrade(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
 		// проверяем, что клиент умеет получать от сервера сжатые данные в формате gzip
 		acceptEncoding := r.Header.Get("Accept-Encoding")
 
@@ -116,32 +244,153 @@ func GzipMiddleware(h http.Handler) http.Handler {
 	return http.HandlerFunc(gzipFn)
 }
 
-func AuthMiddleware(next http.Handler) http.Handler {
+func (h *Server) AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-		h := r.Header.Get(constants.HeaderAuthorization)
-		if h == "" {
+		header := r.Header.Get(constants.HeaderAuthorization)
+		token, found := strings.CutPrefix(header, "Bearer ")
+		if !found || token == "" {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		token := strings.Split(h, "Bearer ")
-		uid := domain.GetUserID(token[1])
-
-		if uid == -1 {
+		uid, role, err := h.userMart.Authenticate(r.Context(), token)
+		if err != nil {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		ctx := context.WithValue(r.Context(), constants.UserIDKey, uid)
+		ctx = context.WithValue(ctx, constants.UserRoleKey, role)
+		logger.AddFields(ctx, zap.Int64("user_id", uid))
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// RequireRole пропускает запрос дальше, только если роль пользователя одна из перечисленных
+// должен стоять после AuthMiddleware
+func RequireRole(roles ...string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role, _ := r.Context().Value(constants.UserRoleKey).(string)
+
+			for _, allowed := range roles {
+				if role == allowed {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			http.Error(w, constants.StatusForbidden, http.StatusForbidden)
+		})
+	}
+}
+
+// APIKeyMiddleware аутентификация партнерской системы по ключу API
+func (h *Server) APIKeyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		key := r.Header.Get(constants.HeaderAPIKey)
+		if key == "" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		client, status, err := h.partnerMart.Authenticate(r.Context(), key)
+		if err != nil {
+			logger.Log().WithContext(r.Context()).Info(err.Error())
+			code, message := constants.StatusData(status)
+			http.Error(w, message, code)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), constants.APIClientKey, client)
+		logger.AddFields(ctx, zap.Int64("api_client_id", client.ID))
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequireScope пропускает запрос дальше, только если у ключа API есть нужное право
+// должен стоять после APIKeyMiddleware
+func RequireScope(scope string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			client, ok := r.Context().Value(constants.APIClientKey).(*domain.APIClient)
+			if !ok || !client.HasScope(scope) {
+				http.Error(w, constants.StatusForbidden, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// SignatureMiddleware проверка подписи HMAC-SHA256 тела запроса из заголовка HashSHA256
+// и подпись тела ответа тем же ключом
+// действует только для клиентов, у которых задан ключ подписи; должен стоять после APIKeyMiddleware
+func SignatureMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, ok := r.Context().Value(constants.APIClientKey).(*domain.APIClient)
+		if !ok || client.SigningKey == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var buf bytes.Buffer
+		_, err := buf.ReadFrom(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewBuffer(buf.Bytes()))
+
+		sign, err := hex.DecodeString(r.Header.Get(constants.HashHeaderName))
+		if err != nil || !hmac.Equal(sign, hashSum(buf.Bytes(), client.SigningKey)) {
+			logger.Log().WithContext(r.Context()).Info(fmt.Sprintf("Неверная подпись запроса клиента %v (%v)", client.Name, client.ID))
+			http.Error(w, "неверная подпись запроса", http.StatusBadRequest)
+			return
+		}
+
+		// ответ накапливается целиком, чтобы подписать его до отправки
+		sw := &signingResponseWriter{
+			ResponseWriter: w,
+			status:         http.StatusOK,
+		}
+
+		next.ServeHTTP(sw, r)
+
+		w.Header().Set(constants.HashHeaderName, hash(sw.body.Bytes(), client.SigningKey))
+		w.WriteHeader(sw.status)
+		w.Write(sw.body.Bytes())
+	})
+}
+
+// ResponseWriter, накапливающий ответ для подписи
+type signingResponseWriter struct {
+	http.ResponseWriter
+	body   bytes.Buffer
+	status int
+}
+
+func (s *signingResponseWriter) Write(b []byte) (int, error) {
+	return s.body.Write(b)
+}
+
+func (s *signingResponseWriter) WriteHeader(statusCode int) {
+	s.status = statusCode
+}
+
+// hash подпись HMAC-SHA256 в шестнадцатеричном виде
 func hash(value []byte, key string) string {
-	data := append(value, []byte(key)...)
-	h := sha256.Sum256(data)
+	return hex.EncodeToString(hashSum(value, key))
+}
+
+func hashSum(value []byte, key string) []byte {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write(value)
 
-	return hex.EncodeToString(h[:])
+	return h.Sum(nil)
 }
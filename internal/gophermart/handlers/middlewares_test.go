@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	"github.com/dnsoftware/gophermart2/internal/logger"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithMetrics(t *testing.T) {
+	router := chi.NewRouter()
+	router.Use(WithMetrics)
+	router.Post("/metrics-test/{number}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	for _, path := range []string{"/metrics-test/1", "/metrics-test/2", "/metrics-test-missing"} {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodPost, path, nil))
+	}
+
+	res := httptest.NewRecorder()
+	NewMetricsServer("").Handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, constants.MetricsRoute, nil))
+	require.Equal(t, http.StatusOK, res.Code)
+
+	body := res.Body.String()
+	// метка маршрута - шаблон, а не фактический путь
+	require.Contains(t, body, `gophermart_http_requests_total{code="202",method="POST",route="/metrics-test/{number}"} 2`)
+	require.Contains(t, body, `gophermart_http_requests_total{code="404",method="POST",route="`+constants.MetricsNoRoute+`"}`)
+	require.NotContains(t, body, "/metrics-test/1")
+}
+
+func TestWithLogging(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "requests.log")
+	require.NoError(t, logger.Init(logger.Options{
+		Level:   "info",
+		Format:  constants.LogFormatJSON,
+		Outputs: []string{path},
+		MaxSize: 1,
+	}))
+	defer logger.Init(logger.Options{Level: "info", Outputs: []string{constants.LogOutputStderr}, MaxSize: 1})
+
+	var received string
+	handler := WithLogging(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created"))
+	}))
+
+	body := `{"login":"user","password":"f7H456789"}`
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/api/user/register", strings.NewReader(body)))
+
+	require.Equal(t, http.StatusCreated, res.Code)
+	require.Equal(t, body, received, "обработчик должен получить тело запроса целиком")
+
+	logger.Log().Sync()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "f7H456789", "пароль не должен попадать в журнал")
+
+	var entry struct {
+		Msg    string `json:"msg"`
+		URI    string `json:"uri"`
+		Method string `json:"method"`
+		Status int    `json:"status"`
+		Size   int    `json:"size"`
+		Body   string `json:"body"`
+	}
+	require.NoError(t, json.Unmarshal(bytes.TrimSpace(data), &entry))
+	require.Equal(t, "request", entry.Msg)
+	require.Equal(t, "/api/user/register", entry.URI)
+	require.Equal(t, http.MethodPost, entry.Method)
+	require.Equal(t, http.StatusCreated, entry.Status)
+	require.Equal(t, len("created"), entry.Size)
+	require.JSONEq(t, `{"login":"user","password":"`+constants.LogRedacted+`"}`, entry.Body)
+}
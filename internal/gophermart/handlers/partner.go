@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	"github.com/dnsoftware/gophermart2/internal/gophermart/domain"
+	"github.com/dnsoftware/gophermart2/internal/logger"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"regexp"
+	"strconv"
+)
+
+// загрузка заказа партнером от имени пользователя
+func (h *Server) partnerOrderUpload(res http.ResponseWriter, req *http.Request) {
+	type pReq struct {
+		Login string `json:"login"`
+		Order string `json:"order"`
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	client, ok := ctx.Value(constants.APIClientKey).(*domain.APIClient)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	var reqData pReq
+	if err := readJSON(req, &reqData); err != nil || reqData.Login == "" {
+		http.Error(res, constants.StatusBadRequestFormat, http.StatusBadRequest)
+		return
+	}
+
+	re := regexp.MustCompile(`^\d+$`)
+	if !re.MatchString(reqData.Order) {
+		http.Error(res, constants.StatusBadRequestFormat, http.StatusBadRequest)
+		return
+	}
+
+	status, err := h.partnerMart.UploadOrder(ctx, client, reqData.Login, reqData.Order)
+	if err != nil {
+		logger.Log().WithContext(ctx).Info(err.Error())
+		code, message := constants.StatusData(status)
+		http.Error(res, message, code)
+		return
+	}
+
+	res.Header().Set("Content-Type", constants.ApplicationJSON)
+	code, message := constants.StatusData(status)
+	res.WriteHeader(code)
+	res.Write([]byte(message))
+}
+
+// подписка партнера на события
+func (h *Server) partnerWebhookCreate(res http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	client, ok := ctx.Value(constants.APIClientKey).(*domain.APIClient)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	var reqData domain.WebhookRequest
+	if err := readJSON(req, &reqData); err != nil {
+		http.Error(res, constants.StatusBadRequestFormat, http.StatusBadRequest)
+		return
+	}
+
+	id, status, err := h.webhookMart.CreateWebhook(ctx, client, reqData)
+	writePartnerJSON(res, map[string]int64{"id": id}, status, err)
+}
+
+// список подписок партнера
+func (h *Server) partnerWebhooks(res http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	client, ok := ctx.Value(constants.APIClientKey).(*domain.APIClient)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	list, status, err := h.webhookMart.Webhooks(ctx, client)
+	writePartnerJSON(res, list, status, err)
+}
+
+// удаление подписки партнера
+func (h *Server) partnerWebhookDelete(res http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	client, ok := ctx.Value(constants.APIClientKey).(*domain.APIClient)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(req, "id"), 10, 64)
+	if err != nil {
+		code, message := constants.StatusData(constants.WebhookBadFormat)
+		http.Error(res, message, code)
+		return
+	}
+
+	status, err := h.webhookMart.DeleteWebhook(ctx, client, id)
+	if err != nil {
+		logger.Log().WithContext(ctx).Info(err.Error())
+	}
+
+	code, message := constants.StatusData(status)
+	if err != nil {
+		http.Error(res, message, code)
+		return
+	}
+
+	res.Header().Set("Content-Type", constants.ApplicationJSON)
+	res.WriteHeader(code)
+	res.Write([]byte(message))
+}
+
+// журнал доставки событий по подписке партнера
+func (h *Server) partnerWebhookDeliveries(res http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	client, ok := ctx.Value(constants.APIClientKey).(*domain.APIClient)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(req, "id"), 10, 64)
+	if err != nil {
+		code, message := constants.StatusData(constants.WebhookBadFormat)
+		http.Error(res, message, code)
+		return
+	}
+
+	list, status, err := h.webhookMart.Deliveries(ctx, client, id)
+	writePartnerJSON(res, list, status, err)
+}
+
+// ответ партнерского API с данными в JSON
+// в отличие от служебного API текст внутренней ошибки клиенту не передается
+func writePartnerJSON(res http.ResponseWriter, data any, status int, err error) {
+	if err != nil {
+		logger.Log().Info(err.Error())
+		code, message := constants.StatusData(status)
+		http.Error(res, message, code)
+		return
+	}
+
+	body, err := json.Marshal(data)
+	if err != nil {
+		http.Error(res, "", http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", constants.ApplicationJSON)
+	code, _ := constants.StatusData(status)
+	res.WriteHeader(code)
+	res.Write(body)
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/dnsoftware/gophermart2/internal/constants"
+)
+
+// поля тел запросов, значения которых не пишутся в журнал
+var redactedFields = map[string]bool{
+	"password":     true,
+	"old_password": true,
+	"new_password": true,
+	"token":        true,
+	"secret":       true,
+	"signing_key":  true,
+	"api_key":      true,
+}
+
+// redactBody тело запроса для журнала: значения секретных полей JSON заменяются, длина ограничивается
+// тело, которое не удалось разобрать как JSON, но в котором встречается имя секретного поля, не пишется совсем
+func redactBody(body []byte) string {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return ""
+	}
+
+	// UseNumber: длинные номера заказов не должны превращаться в числа с плавающей точкой
+	decoder := json.NewDecoder(bytes.NewReader(trimmed))
+	decoder.UseNumber()
+
+	var data any
+	if err := decoder.Decode(&data); err != nil || decoder.More() {
+		lower := strings.ToLower(string(trimmed))
+		for field := range redactedFields {
+			if strings.Contains(lower, field) {
+				return constants.LogRedacted
+			}
+		}
+		return truncateBody(string(trimmed))
+	}
+
+	redacted, err := json.Marshal(redactValue(data))
+	if err != nil {
+		return constants.LogRedacted
+	}
+
+	return truncateBody(string(redacted))
+}
+
+func redactValue(v any) any {
+	switch value := v.(type) {
+	case map[string]any:
+		for k, item := range value {
+			if redactedFields[strings.ToLower(k)] {
+				value[k] = constants.LogRedacted
+				continue
+			}
+			value[k] = redactValue(item)
+		}
+	case []any:
+		for i, item := range value {
+			value[i] = redactValue(item)
+		}
+	}
+
+	return v
+}
+
+func truncateBody(body string) string {
+	if len(body) <= constants.LogBodyLimit {
+		return body
+	}
+
+	return body[:constants.LogBodyLimit] + "..."
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactBody(t *testing.T) {
+	long := `{"number":"` + strings.Repeat("1", constants.LogBodyLimit) + `"}`
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "Empty",
+			body: "  \n",
+			want: "",
+		},
+		{
+			name: "No secrets",
+			body: `{"login":"user","sum":751}`,
+			want: `{"login":"user","sum":751}`,
+		},
+		{
+			name: "Password",
+			body: `{"login":"user","password":"f7H456789"}`,
+			want: `{"login":"user","password":"******"}`,
+		},
+		{
+			name: "Field name case",
+			body: `{"Old_Password":"f7H456789","NEW_PASSWORD":"n3Wpassword"}`,
+			want: `{"NEW_PASSWORD":"******","Old_Password":"******"}`,
+		},
+		{
+			name: "Nested",
+			body: `{"keys":[{"name":"partner","secret":"abc"}],"webhook":{"signing_key":"def"}}`,
+			want: `{"keys":[{"name":"partner","secret":"******"}],"webhook":{"signing_key":"******"}}`,
+		},
+		{
+			name: "Long order number stays intact",
+			body: `{"order":12345678903123456789}`,
+			want: `{"order":12345678903123456789}`,
+		},
+		{
+			name: "Order number as text",
+			body: "12345678903",
+			want: "12345678903",
+		},
+		{
+			name: "Broken JSON with secret",
+			body: `{"login":"user","password":"f7H4`,
+			want: constants.LogRedacted,
+		},
+		{
+			name: "Several JSON values with secret",
+			body: `{"login":"user"} {"token":"abc"}`,
+			want: constants.LogRedacted,
+		},
+		{
+			name: "Truncated",
+			body: long,
+			want: long[:constants.LogBodyLimit] + "...",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, redactBody([]byte(tt.body)))
+		})
+	}
+}
@@ -11,6 +11,8 @@ import (
 	"net/http"
 	"regexp"
 	"strconv"
+	"strings"
+	"time"
 )
 
 func NewRouter() chi.Router {
@@ -20,7 +22,7 @@ func NewRouter() chi.Router {
 
 // регистрация пользователя
 func (h *Server) userRegister(res http.ResponseWriter, req *http.Request) {
-	ctx, cancel := context.WithTimeout(req.Context(), constants.DBContextTimeout)
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
 	defer cancel()
 
 	var buf bytes.Buffer
@@ -41,7 +43,7 @@ func (h *Server) userRegister(res http.ResponseWriter, req *http.Request) {
 
 	token, status, err := h.userMart.AddUser(ctx, user.Login, user.Password)
 	if err != nil {
-		logger.Log().Error(err.Error())
+		logger.Log().WithContext(ctx).Error(err.Error())
 		code, message := constants.StatusData(status)
 		http.Error(res, message+", "+err.Error(), code)
 		return
@@ -64,7 +66,7 @@ func (h *Server) userRegister(res http.ResponseWriter, req *http.Request) {
 
 // вход пользователя
 func (h *Server) userLogin(res http.ResponseWriter, req *http.Request) {
-	ctx, cancel := context.WithTimeout(req.Context(), constants.DBContextTimeout)
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
 	defer cancel()
 
 	var buf bytes.Buffer
@@ -85,7 +87,7 @@ func (h *Server) userLogin(res http.ResponseWriter, req *http.Request) {
 
 	token, status, err := h.userMart.LoginUser(ctx, user.Login, user.Password)
 	if err != nil {
-		logger.Log().Error(err.Error())
+		logger.Log().WithContext(ctx).Error(err.Error())
 		code, message := constants.StatusData(status)
 		http.Error(res, message+", "+err.Error(), code)
 		return
@@ -106,8 +108,296 @@ func (h *Server) userLogin(res http.ResponseWriter, req *http.Request) {
 
 }
 
+// смена пароля авторизованным пользователем
+func (h *Server) userPasswordChange(res http.ResponseWriter, req *http.Request) {
+	type pReq struct {
+		OldPassword string `json:"old_password"`
+		NewPassword string `json:"new_password"`
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	uid := ctx.Value(constants.UserIDKey)
+	userID, ok := uid.(int64)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	var buf bytes.Buffer
+	var reqData pReq
+
+	_, err := buf.ReadFrom(req.Body)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err = json.Unmarshal(buf.Bytes(), &reqData); err != nil {
+		code, message := constants.StatusData(constants.PasswordChangeBadFormat)
+		http.Error(res, message, code)
+		return
+	}
+
+	token, status, err := h.userMart.ChangePassword(ctx, userID, reqData.OldPassword, reqData.NewPassword)
+	if err != nil {
+		code, message := constants.StatusData(status)
+		http.Error(res, message, code)
+		return
+	}
+
+	res.Header().Set("Content-Type", constants.ApplicationJSON)
+	bearer := "Bearer " + token
+	res.Header().Set(constants.HeaderAuthorization, bearer)
+	code, message := constants.StatusData(status)
+	res.WriteHeader(code)
+	res.Write([]byte(message))
+}
+
+// запрос токена сброса пароля
+func (h *Server) userPasswordResetRequest(res http.ResponseWriter, req *http.Request) {
+	type rReq struct {
+		Login string `json:"login"`
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	var buf bytes.Buffer
+	var reqData rReq
+
+	_, err := buf.ReadFrom(req.Body)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err = json.Unmarshal(buf.Bytes(), &reqData); err != nil {
+		code, message := constants.StatusData(constants.PasswordResetBadFormat)
+		http.Error(res, message, code)
+		return
+	}
+
+	status, err := h.userMart.RequestPasswordReset(ctx, reqData.Login)
+	if err != nil {
+		code, message := constants.StatusData(status)
+		http.Error(res, message, code)
+		return
+	}
+
+	res.Header().Set("Content-Type", constants.ApplicationJSON)
+	code, message := constants.StatusData(status)
+	res.WriteHeader(code)
+	res.Write([]byte(message))
+}
+
+// установка нового пароля по токену сброса
+func (h *Server) userPasswordResetConfirm(res http.ResponseWriter, req *http.Request) {
+	type cReq struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	var buf bytes.Buffer
+	var reqData cReq
+
+	_, err := buf.ReadFrom(req.Body)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err = json.Unmarshal(buf.Bytes(), &reqData); err != nil {
+		code, message := constants.StatusData(constants.PasswordResetBadFormat)
+		http.Error(res, message, code)
+		return
+	}
+
+	status, err := h.userMart.ResetPassword(ctx, reqData.Token, reqData.Password)
+	if err != nil {
+		code, message := constants.StatusData(status)
+		http.Error(res, message, code)
+		return
+	}
+
+	res.Header().Set("Content-Type", constants.ApplicationJSON)
+	code, message := constants.StatusData(status)
+	res.WriteHeader(code)
+	res.Write([]byte(message))
+}
+
+// профиль текущего пользователя
+func (h *Server) userProfile(res http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	uid := ctx.Value(constants.UserIDKey)
+	userID, ok := uid.(int64)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	profile, status, err := h.userMart.Profile(ctx, userID)
+	if err != nil {
+		code, message := constants.StatusData(status)
+		http.Error(res, message, code)
+		return
+	}
+
+	body, err := json.Marshal(profile)
+	if err != nil {
+		http.Error(res, "", http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", constants.ApplicationJSON)
+	code, _ := constants.StatusData(status)
+	res.WriteHeader(code)
+	res.Write(body)
+}
+
+// изменение редактируемых полей профиля текущего пользователя
+func (h *Server) userProfileUpdate(res http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	uid := ctx.Value(constants.UserIDKey)
+	userID, ok := uid.(int64)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	var buf bytes.Buffer
+	var upd domain.ProfileUpdate
+
+	_, err := buf.ReadFrom(req.Body)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err = json.Unmarshal(buf.Bytes(), &upd); err != nil {
+		code, message := constants.StatusData(constants.ProfileBadFormat)
+		http.Error(res, message, code)
+		return
+	}
+
+	profile, status, err := h.userMart.UpdateProfile(ctx, userID, upd)
+	if err != nil {
+		code, message := constants.StatusData(status)
+		http.Error(res, message+", "+err.Error(), code)
+		return
+	}
+
+	body, err := json.Marshal(profile)
+	if err != nil {
+		http.Error(res, "", http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", constants.ApplicationJSON)
+	code, _ := constants.StatusData(status)
+	res.WriteHeader(code)
+	res.Write(body)
+}
+
+// выгрузка всех данных пользователя в JSON
+func (h *Server) userExport(res http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	uid := ctx.Value(constants.UserIDKey)
+	userID, ok := uid.(int64)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	export, status, err := h.accountMart.Export(ctx, userID)
+	if err != nil {
+		logger.Log().WithContext(ctx).Error(err.Error())
+		code, message := constants.StatusData(status)
+		http.Error(res, message, code)
+		return
+	}
+
+	body, err := json.Marshal(export)
+	if err != nil {
+		http.Error(res, "", http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", constants.ApplicationJSON)
+	res.Header().Set("Content-Disposition", `attachment; filename="gophermart-export.json"`)
+	code, _ := constants.StatusData(status)
+	res.WriteHeader(code)
+	res.Write(body)
+}
+
+// запрос на удаление аккаунта (с льготным периодом)
+func (h *Server) userDelete(res http.ResponseWriter, req *http.Request) {
+	type dResp struct {
+		DeletionAt string `json:"deletion_scheduled_at"`
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	uid := ctx.Value(constants.UserIDKey)
+	userID, ok := uid.(int64)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	deletionAt, status, err := h.accountMart.RequestDeletion(ctx, userID)
+	if err != nil {
+		code, message := constants.StatusData(status)
+		http.Error(res, message, code)
+		return
+	}
+
+	body, err := json.Marshal(dResp{DeletionAt: deletionAt.Format(time.RFC3339)})
+	if err != nil {
+		http.Error(res, "", http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", constants.ApplicationJSON)
+	code, _ := constants.StatusData(status)
+	res.WriteHeader(code)
+	res.Write(body)
+}
+
+// отмена запланированного удаления аккаунта
+func (h *Server) userDeletionCancel(res http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	uid := ctx.Value(constants.UserIDKey)
+	userID, ok := uid.(int64)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	status, err := h.accountMart.CancelDeletion(ctx, userID)
+	if err != nil {
+		code, message := constants.StatusData(status)
+		http.Error(res, message, code)
+		return
+	}
+
+	res.Header().Set("Content-Type", constants.ApplicationJSON)
+	code, message := constants.StatusData(status)
+	res.WriteHeader(code)
+	res.Write([]byte(message))
+}
+
 func (h *Server) userOrderUpload(res http.ResponseWriter, req *http.Request) {
-	ctx, cancel := context.WithTimeout(req.Context(), constants.DBContextTimeout)
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
 	defer cancel()
 
 	var buf bytes.Buffer
@@ -132,9 +422,7 @@ func (h *Server) userOrderUpload(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	orderID, _ := strconv.ParseInt(number, 10, 64)
-
-	status, err := h.orderMart.AddOrder(ctx, userID, orderID)
+	status, err := h.orderMart.AddOrder(ctx, userID, number)
 	if err != nil {
 		code, message := constants.StatusData(status)
 		http.Error(res, message, code)
@@ -147,8 +435,66 @@ func (h *Server) userOrderUpload(res http.ResponseWriter, req *http.Request) {
 	res.Write([]byte(message))
 }
 
+// пакетная загрузка номеров заказов
+// тело - JSON массив строк или номера через перевод строки
+func (h *Server) userOrdersBatchUpload(res http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	var buf bytes.Buffer
+
+	uid := ctx.Value(constants.UserIDKey)
+	userID, ok := uid.(int64)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	_, err := buf.ReadFrom(req.Body)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var numbers []string
+	body := bytes.TrimSpace(buf.Bytes())
+
+	if strings.HasPrefix(req.Header.Get("Content-Type"), constants.ApplicationJSON) || bytes.HasPrefix(body, []byte("[")) {
+		if err = json.Unmarshal(body, &numbers); err != nil {
+			code, message := constants.StatusData(constants.OrdersBatchBadFormat)
+			http.Error(res, message, code)
+			return
+		}
+	} else {
+		for _, line := range strings.Split(string(body), "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" {
+				numbers = append(numbers, line)
+			}
+		}
+	}
+
+	results, status, err := h.orderMart.AddOrders(ctx, userID, numbers)
+	if err != nil {
+		code, message := constants.StatusData(status)
+		http.Error(res, message, code)
+		return
+	}
+
+	resp, err := json.Marshal(results)
+	if err != nil {
+		http.Error(res, "", http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", constants.ApplicationJSON)
+	code, _ := constants.StatusData(status)
+	res.WriteHeader(code)
+	res.Write(resp)
+}
+
 func (h *Server) userOrdersList(res http.ResponseWriter, req *http.Request) {
-	ctx, cancel := context.WithTimeout(req.Context(), constants.DBContextTimeout)
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
 	defer cancel()
 
 	uid := ctx.Value(constants.UserIDKey)
@@ -158,6 +504,12 @@ func (h *Server) userOrdersList(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	// без параметров отдаем весь список, как раньше
+	if len(req.URL.Query()) > 0 {
+		h.userOrdersPage(ctx, res, req, userID)
+		return
+	}
+
 	list, status, err := h.orderMart.OrdersList(ctx, userID)
 	if err != nil {
 		code, message := constants.StatusData(status)
@@ -177,8 +529,229 @@ func (h *Server) userOrdersList(res http.ResponseWriter, req *http.Request) {
 	res.Write(body)
 }
 
+// постраничный список заказов
+// параметры: limit, cursor, status (через запятую), from и to (RFC3339), sort (newest, oldest)
+func (h *Server) userOrdersPage(ctx context.Context, res http.ResponseWriter, req *http.Request, userID int64) {
+	params := req.URL.Query()
+
+	q := domain.OrderListQuery{
+		Sort:   params.Get("sort"),
+		Cursor: params.Get("cursor"),
+		Limit:  constants.OrdersPageDefaultLimit,
+	}
+
+	var err error
+	if v := params.Get("limit"); v != "" {
+		q.Limit, err = strconv.Atoi(v)
+		if err != nil {
+			http.Error(res, constants.StatusBadRequestFormat, http.StatusBadRequest)
+			return
+		}
+	}
+	if v := params.Get("status"); v != "" {
+		q.Statuses = strings.Split(strings.ToUpper(v), ",")
+	}
+	if v := params.Get("from"); v != "" {
+		q.From, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(res, constants.StatusBadRequestFormat, http.StatusBadRequest)
+			return
+		}
+	}
+	if v := params.Get("to"); v != "" {
+		q.To, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(res, constants.StatusBadRequestFormat, http.StatusBadRequest)
+			return
+		}
+	}
+
+	page, status, err := h.orderMart.OrdersPage(ctx, userID, q)
+	if err != nil {
+		code, message := constants.StatusData(status)
+		http.Error(res, message+", "+err.Error(), code)
+		return
+	}
+
+	body, err := json.Marshal(page.Items)
+	if err != nil {
+		http.Error(res, "", http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", constants.ApplicationJSON)
+	res.Header().Set(constants.HeaderTotalCount, strconv.Itoa(page.Total))
+	if page.NextCursor != "" {
+		res.Header().Set(constants.HeaderNextCursor, page.NextCursor)
+	}
+	code, _ := constants.StatusData(status)
+	res.WriteHeader(code)
+	res.Write(body)
+}
+
+// заказ пользователя с историей смены статусов
+func (h *Server) userOrderDetail(res http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	uid := ctx.Value(constants.UserIDKey)
+	userID, ok := uid.(int64)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	number := chi.URLParam(req, "number")
+	re := regexp.MustCompile(`^\d+$`)
+	if !re.MatchString(number) {
+		code, message := constants.StatusData(constants.OrderDetailBadFormat)
+		http.Error(res, message, code)
+		return
+	}
+
+	detail, status, err := h.orderMart.OrderDetail(ctx, userID, number)
+	if err != nil {
+		code, message := constants.StatusData(status)
+		http.Error(res, message, code)
+		return
+	}
+
+	body, err := json.Marshal(detail)
+	if err != nil {
+		http.Error(res, "", http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", constants.ApplicationJSON)
+	code, _ := constants.StatusData(status)
+	res.WriteHeader(code)
+	res.Write(body)
+}
+
+// удаление заказа, еще не принятого в обработку
+func (h *Server) userOrderCancel(res http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	uid := ctx.Value(constants.UserIDKey)
+	userID, ok := uid.(int64)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	number := chi.URLParam(req, "number")
+	re := regexp.MustCompile(`^\d+$`)
+	if !re.MatchString(number) {
+		code, message := constants.StatusData(constants.OrderCancelBadFormat)
+		http.Error(res, message, code)
+		return
+	}
+
+	status, err := h.orderMart.CancelOrder(ctx, userID, number)
+	if err != nil {
+		logger.Log().WithContext(ctx).Info(err.Error())
+		code, message := constants.StatusData(status)
+		http.Error(res, message, code)
+		return
+	}
+
+	res.Header().Set("Content-Type", constants.ApplicationJSON)
+	code, message := constants.StatusData(status)
+	res.WriteHeader(code)
+	res.Write([]byte(message))
+}
+
+// оспаривание заказа, загруженного другим пользователем
+func (h *Server) userOrderDispute(res http.ResponseWriter, req *http.Request) {
+	type dReq struct {
+		Comment string `json:"comment"`
+	}
+	type dResp struct {
+		ID int64 `json:"id"`
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	uid := ctx.Value(constants.UserIDKey)
+	userID, ok := uid.(int64)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	number := chi.URLParam(req, "number")
+	re := regexp.MustCompile(`^\d+$`)
+	if !re.MatchString(number) {
+		code, message := constants.StatusData(constants.DisputeBadFormat)
+		http.Error(res, message, code)
+		return
+	}
+
+	// комментарий необязателен, тело запроса может быть пустым
+	var reqData dReq
+	if req.ContentLength != 0 {
+		if err := readJSON(req, &reqData); err != nil {
+			code, message := constants.StatusData(constants.DisputeBadFormat)
+			http.Error(res, message, code)
+			return
+		}
+	}
+
+	id, status, err := h.orderMart.OpenDispute(ctx, userID, number, reqData.Comment)
+	if err != nil {
+		logger.Log().WithContext(ctx).Info(err.Error())
+		code, message := constants.StatusData(status)
+		http.Error(res, message, code)
+		return
+	}
+
+	body, err := json.Marshal(dResp{ID: id})
+	if err != nil {
+		http.Error(res, "", http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", constants.ApplicationJSON)
+	code, _ := constants.StatusData(status)
+	res.WriteHeader(code)
+	res.Write(body)
+}
+
+// споры, открытые пользователем
+func (h *Server) userDisputes(res http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	uid := ctx.Value(constants.UserIDKey)
+	userID, ok := uid.(int64)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	list, status, err := h.orderMart.Disputes(ctx, userID)
+	if err != nil {
+		logger.Log().WithContext(ctx).Error(err.Error())
+		code, message := constants.StatusData(status)
+		http.Error(res, message, code)
+		return
+	}
+
+	body, err := json.Marshal(list)
+	if err != nil {
+		http.Error(res, "", http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", constants.ApplicationJSON)
+	code, _ := constants.StatusData(status)
+	res.WriteHeader(code)
+	res.Write(body)
+}
+
 func (h *Server) userBalance(res http.ResponseWriter, req *http.Request) {
-	ctx, cancel := context.WithTimeout(req.Context(), constants.DBContextTimeout)
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
 	defer cancel()
 
 	uid := ctx.Value(constants.UserIDKey)
@@ -206,7 +779,7 @@ func (h *Server) userBalance(res http.ResponseWriter, req *http.Request) {
 }
 
 func (h *Server) userWithdrawals(res http.ResponseWriter, req *http.Request) {
-	ctx, cancel := context.WithTimeout(req.Context(), constants.DBContextTimeout)
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
 	defer cancel()
 
 	uid := ctx.Value(constants.UserIDKey)
@@ -239,12 +812,41 @@ func (h *Server) userWithdrawals(res http.ResponseWriter, req *http.Request) {
 	res.Write(body)
 }
 
+// история всех движений по балансу, включая ручные корректировки
+func (h *Server) userLedger(res http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
+	defer cancel()
+
+	uid := ctx.Value(constants.UserIDKey)
+	userID, ok := uid.(int64)
+	if !ok {
+		http.Error(res, "", http.StatusUnauthorized)
+		return
+	}
+
+	ledger, err := h.balanceMart.UserLedger(ctx, userID)
+	if err != nil {
+		http.Error(res, "", http.StatusInternalServerError)
+		return
+	}
+
+	body, err := json.Marshal(ledger)
+	if err != nil {
+		http.Error(res, "", http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", constants.ApplicationJSON)
+	res.WriteHeader(http.StatusOK)
+	res.Write(body)
+}
+
 func (h *Server) userWithdraw(res http.ResponseWriter, req *http.Request) {
 	type wReq struct {
 		Order string  `json:"order"`
 		Sum   float32 `json:"sum"`
 	}
-	ctx, cancel := context.WithTimeout(req.Context(), constants.DBContextTimeout)
+	ctx, cancel := context.WithTimeout(req.Context(), h.requestTimeout)
 	defer cancel()
 
 	uid := ctx.Value(constants.UserIDKey)
@@ -268,10 +870,16 @@ func (h *Server) userWithdraw(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	orderNum, _ := strconv.ParseInt(reqData.Order, 10, 64)
-	status, err := h.balanceMart.Withraw(ctx, userID, orderNum, reqData.Sum)
+	// номер заказа - строка цифр произвольной длины, остальное проверяет Withraw
+	re := regexp.MustCompile(`^\d+$`)
+	if !re.MatchString(reqData.Order) {
+		http.Error(res, constants.StatusBadNumberFormat, http.StatusUnprocessableEntity)
+		return
+	}
+
+	status, err := h.balanceMart.Withraw(ctx, userID, reqData.Order, reqData.Sum)
 	if err != nil {
-		logger.Log().Error(err.Error())
+		logger.Log().WithContext(ctx).Error(err.Error())
 	}
 
 	var httpStatus int
@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	"github.com/dnsoftware/gophermart2/internal/logger"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// доступ только по явно переданному токену, cookie не используются,
+	// поэтому страница с чужого домена не получит данных пользователя
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// команда клиента
+type wsRequest struct {
+	Action      string   `json:"action"`
+	Token       string   `json:"token,omitempty"`
+	Topics      []string `json:"topics,omitempty"`
+	LastEventID int64    `json:"last_event_id,omitempty"`
+}
+
+// сообщение сервера
+type wsResponse struct {
+	Type    string          `json:"type"`
+	ID      int64           `json:"id,omitempty"`
+	Topic   string          `json:"topic,omitempty"`
+	Topics  []string        `json:"topics,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Message string          `json:"message,omitempty"`
+}
+
+// уведомления пользователя через WebSocket
+// токен передается заголовком Authorization или, если клиент не может задать заголовок (браузер),
+// первым сообщением {"action":"auth","token":"..."}
+func (h *Server) userWebSocket(res http.ResponseWriter, req *http.Request) {
+	var userID int64
+
+	header := req.Header.Get(constants.HeaderAuthorization)
+	if header != "" {
+		token, found := strings.CutPrefix(header, "Bearer ")
+		if !found || token == "" {
+			http.Error(res, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		uid, _, err := h.userMart.Authenticate(req.Context(), token)
+		if err != nil {
+			http.Error(res, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		userID = uid
+	}
+
+	// при ошибке Upgrade сам отвечает клиенту
+	conn, err := wsUpgrader.Upgrade(res, req, nil)
+	if err != nil {
+		logger.Log().WithContext(req.Context()).Info("WebSocket Upgrade: " + err.Error())
+		return
+	}
+	defer conn.Close()
+
+	conn.SetReadLimit(constants.WSMaxMessageSize)
+
+	if userID == 0 {
+		userID, err = h.wsAuthenticate(req.Context(), conn)
+		if err != nil {
+			logger.Log().WithContext(req.Context()).Info("WebSocket auth: " + err.Error())
+			wsClose(conn, websocket.ClosePolicyViolation, "Unauthorized")
+			return
+		}
+	}
+
+	logger.AddFields(req.Context(), zap.Int64("user_id", userID))
+	h.wsSession(req.Context(), conn, userID)
+}
+
+func (h *Server) wsAuthenticate(ctx context.Context, conn *websocket.Conn) (int64, error) {
+	conn.SetReadDeadline(time.Now().Add(constants.WSAuthTimeout))
+
+	var cmd wsRequest
+	if err := conn.ReadJSON(&cmd); err != nil {
+		return 0, err
+	}
+	if cmd.Action != constants.WSActionAuth || cmd.Token == "" {
+		return 0, fmt.Errorf("первой должна быть команда %v", constants.WSActionAuth)
+	}
+
+	userID, _, err := h.userMart.Authenticate(ctx, cmd.Token)
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// сеанс клиента: чтение команд в отдельной горутине, вся запись в соединение - здесь
+func (h *Server) wsSession(ctx context.Context, conn *websocket.Conn, userID int64) {
+	// подписка до чтения журнала, чтобы не потерять события между чтением и подпиской
+	events, unsubscribe := h.eventsMart.Subscribe(userID)
+	defer unsubscribe()
+
+	commands := make(chan wsRequest, constants.WSCommandBuffer)
+	go wsReadLoop(conn, commands)
+
+	// подписанные темы и ID последнего отправленного по теме события
+	topics := make(map[string]int64)
+
+	ticker := time.NewTicker(constants.WSPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case cmd, ok := <-commands:
+			if !ok { // клиент отключился или не успевает отправлять команды
+				return
+			}
+			if err := h.wsCommand(ctx, conn, userID, cmd, topics); err != nil {
+				return
+			}
+
+		case event, ok := <-events:
+			if !ok { // клиент не успевал забирать события или сервер останавливается
+				wsClose(conn, websocket.CloseTryAgainLater, "reconnect with last_event_id")
+				return
+			}
+
+			lastID, subscribed := topics[event.Type]
+			if !subscribed || event.ID <= lastID {
+				continue
+			}
+			if err := wsWriteEvent(conn, event.ID, event.Type, event.Data); err != nil {
+				return
+			}
+			topics[event.Type] = event.ID
+
+		case <-ticker.C:
+			err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(constants.WSWriteWait))
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// чтение команд клиента, канал закрывается при разрыве соединения
+func wsReadLoop(conn *websocket.Conn, commands chan<- wsRequest) {
+	defer close(commands)
+
+	conn.SetReadDeadline(time.Now().Add(constants.WSPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(constants.WSPongWait))
+	})
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				logger.Log().Info("WebSocket read: " + err.Error())
+			}
+			return
+		}
+
+		// нераспознанная команда передается пустой, на нее ответят ошибкой
+		var cmd wsRequest
+		if err = json.Unmarshal(message, &cmd); err != nil {
+			cmd = wsRequest{}
+		}
+
+		select {
+		case commands <- cmd:
+		default:
+			logger.Log().Info("WebSocket: клиент отправляет команды быстрее, чем они обрабатываются, отключен")
+			return
+		}
+	}
+}
+
+func (h *Server) wsCommand(ctx context.Context, conn *websocket.Conn, userID int64, cmd wsRequest, topics map[string]int64) error {
+	switch cmd.Action {
+	case constants.WSActionSubscribe:
+		if len(cmd.Topics) == 0 || !wsValidTopics(cmd.Topics) || cmd.LastEventID < 0 {
+			return wsWrite(conn, wsResponse{Type: constants.WSMessageError, Message: constants.StatusBadRequestFormat})
+		}
+
+		for _, topic := range cmd.Topics {
+			// повторная подписка без last_event_id не сбрасывает позицию
+			if _, ok := topics[topic]; !ok || cmd.LastEventID > 0 {
+				topics[topic] = cmd.LastEventID
+			}
+		}
+
+		if err := wsWrite(conn, wsResponse{Type: constants.WSMessageSubscribed, Topics: wsTopicList(topics)}); err != nil {
+			return err
+		}
+
+		if cmd.LastEventID > 0 {
+			return h.wsReplay(ctx, conn, userID, cmd.LastEventID, cmd.Topics, topics)
+		}
+
+		return nil
+
+	case constants.WSActionUnsubscribe:
+		if !wsValidTopics(cmd.Topics) {
+			return wsWrite(conn, wsResponse{Type: constants.WSMessageError, Message: constants.StatusBadRequestFormat})
+		}
+
+		for _, topic := range cmd.Topics {
+			delete(topics, topic)
+		}
+
+		return wsWrite(conn, wsResponse{Type: constants.WSMessageUnsubscribed, Topics: wsTopicList(topics)})
+
+	case constants.WSActionAuth:
+		return wsWrite(conn, wsResponse{Type: constants.WSMessageError, Message: "уже авторизован"})
+
+	default:
+		return wsWrite(conn, wsResponse{Type: constants.WSMessageError, Message: "неизвестная команда"})
+	}
+}
+
+// отправка пропущенных событий из журнала по темам replayTopics
+func (h *Server) wsReplay(ctx context.Context, conn *websocket.Conn, userID int64, afterID int64, replayTopics []string, topics map[string]int64) error {
+	wanted := make(map[string]bool, len(replayTopics))
+	for _, topic := range replayTopics {
+		wanted[topic] = true
+	}
+
+	for {
+		ctxDB, cancel := context.WithTimeout(ctx, h.requestTimeout)
+		list, err := h.eventsMart.Replay(ctxDB, userID, afterID)
+		cancel()
+		if err != nil {
+			logger.Log().WithContext(ctx).Error(err.Error())
+			return wsWrite(conn, wsResponse{Type: constants.WSMessageError, Message: constants.StatusInternalServerError})
+		}
+
+		for _, event := range list {
+			afterID = event.ID
+			if !wanted[event.Type] || event.ID <= topics[event.Type] {
+				continue
+			}
+
+			if err = wsWriteEvent(conn, event.ID, event.Type, event.Data); err != nil {
+				return err
+			}
+			topics[event.Type] = event.ID
+		}
+
+		if len(list) < constants.EventsReplayLimit {
+			return nil
+		}
+	}
+}
+
+func wsValidTopics(topics []string) bool {
+	for _, topic := range topics {
+		if topic != constants.EventTypeOrder && topic != constants.EventTypeBalance {
+			return false
+		}
+	}
+
+	return true
+}
+
+func wsTopicList(topics map[string]int64) []string {
+	list := make([]string, 0, len(topics))
+	for topic := range topics {
+		list = append(list, topic)
+	}
+	sort.Strings(list)
+
+	return list
+}
+
+func wsWriteEvent(conn *websocket.Conn, id int64, topic string, data string) error {
+	return wsWrite(conn, wsResponse{
+		Type:  constants.WSMessageEvent,
+		ID:    id,
+		Topic: topic,
+		Data:  json.RawMessage(data),
+	})
+}
+
+func wsWrite(conn *websocket.Conn, msg wsResponse) error {
+	conn.SetWriteDeadline(time.Now().Add(constants.WSWriteWait))
+
+	return conn.WriteJSON(msg)
+}
+
+func wsClose(conn *websocket.Conn, code int, text string) {
+	msg := websocket.FormatCloseMessage(code, text)
+	conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(constants.WSWriteWait))
+}
@@ -0,0 +1,63 @@
+package logger
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+type requestKey struct{}
+
+// сведения о запросе, которые добавляются ко всем записям, сделанным в его контексте
+// поля дополняются по ходу обработки (например, после авторизации), поэтому хранятся по указателю
+type requestInfo struct {
+	mu     sync.Mutex
+	fields []zap.Field
+	route  func() string // маршрут известен только после разбора пути роутером
+}
+
+// NewRequestContext контекст запроса с идентификатором requestID
+// route вызывается при каждой записи и возвращает шаблон маршрута, если он уже известен
+func NewRequestContext(ctx context.Context, requestID string, route func() string) context.Context {
+	info := &requestInfo{
+		fields: []zap.Field{zap.String("request_id", requestID)},
+		route:  route,
+	}
+
+	return context.WithValue(ctx, requestKey{}, info)
+}
+
+// AddFields дополнение полей, которые попадут во все последующие записи в контексте запроса
+// (в том числе в итоговую запись о запросе, сделанную внешним middleware)
+func AddFields(ctx context.Context, fields ...zap.Field) {
+	info, ok := ctx.Value(requestKey{}).(*requestInfo)
+	if !ok {
+		return
+	}
+
+	info.mu.Lock()
+	defer info.mu.Unlock()
+
+	info.fields = append(info.fields, fields...)
+}
+
+func requestFields(ctx context.Context) []zap.Field {
+	info, ok := ctx.Value(requestKey{}).(*requestInfo)
+	if !ok {
+		return nil
+	}
+
+	info.mu.Lock()
+	fields := make([]zap.Field, len(info.fields), len(info.fields)+1)
+	copy(fields, info.fields)
+	info.mu.Unlock()
+
+	if info.route != nil {
+		if route := info.route(); route != "" {
+			fields = append(fields, zap.String("route", route))
+		}
+	}
+
+	return fields
+}
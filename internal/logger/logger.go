@@ -1,69 +1,260 @@
 package logger
 
 import (
+	"context"
 	"fmt"
 	"github.com/dnsoftware/gophermart2/internal/constants"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
 	"log"
 	"os"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// Options параметры журнала
+type Options struct {
+	Level            string        // debug, info, warn, error
+	Format           string        // json, console или auto - JSON в файлы, текст в консоль
+	Outputs          []string      // stdout, stderr или пути к файлам
+	MaxSize          int           // размер файла в мегабайтах, после которого он ротируется
+	MaxAge           int           // срок хранения старых файлов в днях, 0 - не удалять по сроку
+	MaxBackups       int           // кол-во хранимых старых файлов, 0 - все
+	Compress         bool          // сжимать старые файлы
+	RotateInterval   time.Duration // ротация по времени, 0 - только по размеру
+	SampleInitial    int           // из записей о запросах за секунду пишутся первые SampleInitial
+	SampleThereafter int           // и далее каждая SampleThereafter-я, 0 - записи о запросах пишутся все
+}
+
 type logger struct {
-	filename string
+	level    zap.AtomicLevel
+	requests *zap.Logger // журнал запросов с выборкой
+	files    []*lumberjack.Logger
+	stop     chan struct{}
+	stopOnce sync.Once
 	*zap.Logger
 }
 
-var projectLogger *logger
+// текущий логгер; Init может заменить его, пока другие горутины пишут в журнал
+var projectLogger atomic.Pointer[logger]
 var once sync.Once
 
 // Log Получение синглтона логгера
+// если журнал не был настроен через Init, используются параметры по умолчанию
 func Log() *logger {
-	once.Do(func() {
-		var err error
+	if l := projectLogger.Load(); l != nil {
+		return l
+	}
 
-		projectLogger, err = createLogger(constants.LogFile, constants.LogLevel)
+	once.Do(func() {
+		l, err := createLogger(DefaultOptions())
 		if err != nil {
 			log.Fatal(err)
 		}
+
+		// Init успел настроить журнал раньше
+		if !projectLogger.CompareAndSwap(nil, l) {
+			l.Close()
+		}
 	})
 
-	return projectLogger
+	return projectLogger.Load()
+}
+
+// Init настройка журнала, вызывается при старте
+// прежний логгер не закрывается: полученные через Log() ссылки на него могут еще использоваться,
+// у него только сбрасываются буферы и останавливается ротация по времени
+func Init(opts Options) error {
+	l, err := createLogger(opts)
+	if err != nil {
+		return fmt.Errorf("Init: %w", err)
+	}
+
+	if old := projectLogger.Swap(l); old != nil {
+		old.stopRotate()
+		old.Sync()
+	}
+
+	return nil
+}
+
+// DefaultOptions параметры журнала по умолчанию
+func DefaultOptions() Options {
+	return Options{
+		Level:            constants.LogLevel.String(),
+		Format:           constants.LogFormatAuto,
+		Outputs:          strings.Split(constants.LogOutputs, ","),
+		MaxSize:          constants.LogMaxSize,
+		MaxAge:           constants.LogMaxAge,
+		MaxBackups:       constants.LogMaxBackups,
+		SampleInitial:    constants.LogSampleInitial,
+		SampleThereafter: constants.LogSampleThereafter,
+	}
+}
+
+// WithContext логгер запроса: добавляет к записям сведения о запросе (идентификатор, пользователь, маршрут)
+// и идентификаторы трассировки из контекста
+func (l *logger) WithContext(ctx context.Context) *zap.Logger {
+	return withFields(l.Logger, ctx)
+}
+
+// Requests журнал запросов: то же, что WithContext, но при большом потоке запросов часть записей пропускается
+func (l *logger) Requests(ctx context.Context) *zap.Logger {
+	return withFields(l.requests, ctx)
+}
+
+// LevelName текущий уровень журнала
+func (l *logger) LevelName() string {
+	return l.level.Level().String()
+}
+
+// SetLevel изменение уровня журнала во время работы
+func (l *logger) SetLevel(level string) error {
+	lvl, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return fmt.Errorf("SetLevel: %w", err)
+	}
+
+	l.level.SetLevel(lvl)
+
+	return nil
 }
 
-// логирование в файл и в консоль
-func createLogger(filename string, logLevel zapcore.Level) (*logger, error) {
+// Close сброс буферов, остановка ротации по времени и закрытие файлов
+func (l *logger) Close() {
+	l.stopRotate()
+	l.Sync()
+
+	for _, f := range l.files {
+		f.Close()
+	}
+}
+
+// остановка ротации по времени
+func (l *logger) stopRotate() {
+	l.stopOnce.Do(func() {
+		close(l.stop)
+	})
+}
+
+func withFields(base *zap.Logger, ctx context.Context) *zap.Logger {
+	fields := requestFields(ctx)
+
+	sc := trace.SpanContextFromContext(ctx)
+	if sc.IsValid() {
+		fields = append(fields,
+			zap.String("trace_id", sc.TraceID().String()),
+			zap.String("span_id", sc.SpanID().String()),
+		)
+	}
+
+	if len(fields) == 0 {
+		return base
+	}
+
+	return base.With(fields...)
+}
+
+// логирование в консоль и файлы с ротацией
+func createLogger(opts Options) (*logger, error) {
+	level, err := zapcore.ParseLevel(opts.Level)
+	if err != nil {
+		return nil, fmt.Errorf("createLogger: %w", err)
+	}
+
 	// формат времени "2006-01-02T15:04:05.000Z0700"
 	config := zap.NewProductionEncoderConfig()
 	config.EncodeTime = zapcore.ISO8601TimeEncoder
 
-	// создание кодировщиков для вывода в файл и в консоль
-	fileEncoder := zapcore.NewJSONEncoder(config)
-	consoleEncoder := zapcore.NewConsoleEncoder(config)
-
-	// Открываем лог файл
-	logFile, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open log file: %w", err)
+	l := &logger{
+		level: zap.NewAtomicLevelAt(level),
+		stop:  make(chan struct{}),
 	}
 
-	// Create writers for file and console
-	fileWriter := zapcore.AddSync(logFile)
-	consoleWriter := zapcore.AddSync(os.Stdout)
+	cores := make([]zapcore.Core, 0, len(opts.Outputs))
+	for _, output := range opts.Outputs {
+		output = strings.TrimSpace(output)
+
+		var writer zapcore.WriteSyncer
+		console := true
+		switch output {
+		case "":
+			continue
+		case constants.LogOutputStdout:
+			writer = zapcore.Lock(os.Stdout)
+		case constants.LogOutputStderr:
+			writer = zapcore.Lock(os.Stderr)
+		default:
+			file := &lumberjack.Logger{
+				Filename:   output,
+				MaxSize:    opts.MaxSize,
+				MaxAge:     opts.MaxAge,
+				MaxBackups: opts.MaxBackups,
+				Compress:   opts.Compress,
+			}
+			l.files = append(l.files, file)
+			writer = zapcore.AddSync(file)
+			console = false
+		}
 
-	// Create cores for writing to the file and console
-	fileCore := zapcore.NewCore(fileEncoder, fileWriter, logLevel)
-	consoleCore := zapcore.NewCore(consoleEncoder, consoleWriter, logLevel)
+		var encoder zapcore.Encoder
+		switch opts.Format {
+		case constants.LogFormatJSON:
+			encoder = zapcore.NewJSONEncoder(config)
+		case constants.LogFormatConsole:
+			encoder = zapcore.NewConsoleEncoder(config)
+		case constants.LogFormatAuto, "":
+			if console {
+				encoder = zapcore.NewConsoleEncoder(config)
+			} else {
+				encoder = zapcore.NewJSONEncoder(config)
+			}
+		default:
+			return nil, fmt.Errorf("createLogger: неизвестный формат журнала %q", opts.Format)
+		}
 
-	// Combine cores
-	core := zapcore.NewTee(fileCore, consoleCore)
+		cores = append(cores, zapcore.NewCore(encoder, writer, l.level))
+	}
+	if len(cores) == 0 {
+		return nil, fmt.Errorf("createLogger: не задано ни одного вывода журнала")
+	}
 
 	// Create the logger with additional context information (caller, stack trace)
-	l := &logger{
-		filename,
-		zap.New(core, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel)),
+	l.Logger = zap.New(zapcore.NewTee(cores...), zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel))
+
+	l.requests = l.Logger
+	if opts.SampleThereafter > 0 {
+		l.requests = l.Logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewSamplerWithOptions(core, time.Second, opts.SampleInitial, opts.SampleThereafter)
+		}))
+	}
+
+	if opts.RotateInterval > 0 && len(l.files) > 0 {
+		go l.rotate(opts.RotateInterval)
 	}
 
 	return l, nil
 }
+
+// ротация файлов журнала по времени, в дополнение к ротации по размеру
+func (l *logger) rotate(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			for _, f := range l.files {
+				if err := f.Rotate(); err != nil {
+					l.Error("log rotate: " + err.Error())
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	"github.com/stretchr/testify/require"
+)
+
+func testOptions(path string) Options {
+	return Options{
+		Level:   "info",
+		Format:  constants.LogFormatAuto,
+		Outputs: []string{path},
+		MaxSize: 1,
+	}
+}
+
+// строки журнала после сброса буферов
+func readLines(t *testing.T, l *logger, path string) []string {
+	t.Helper()
+
+	l.Sync()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	require.NoError(t, err)
+
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestCreateLogger_BadOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	tests := []struct {
+		name   string
+		modify func(opts *Options)
+	}{
+		{
+			name:   "Unknown level",
+			modify: func(opts *Options) { opts.Level = "verbose" },
+		},
+		{
+			name:   "No outputs",
+			modify: func(opts *Options) { opts.Outputs = []string{" ", ""} },
+		},
+		{
+			name:   "Unknown format",
+			modify: func(opts *Options) { opts.Format = "xml" },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := testOptions(path)
+			tt.modify(&opts)
+
+			_, err := createLogger(opts)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestCreateLogger_Format(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		json   bool
+	}{
+		{name: "Auto file", format: constants.LogFormatAuto, json: true},
+		{name: "JSON", format: constants.LogFormatJSON, json: true},
+		{name: "Console", format: constants.LogFormatConsole, json: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "app.log")
+			opts := testOptions(path)
+			opts.Format = tt.format
+
+			l, err := createLogger(opts)
+			require.NoError(t, err)
+			defer l.Close()
+
+			l.Info("started")
+
+			lines := readLines(t, l, path)
+			require.Len(t, lines, 1)
+			require.Contains(t, lines[0], "started")
+			require.Equal(t, tt.json, json.Valid([]byte(lines[0])))
+		})
+	}
+}
+
+func TestLogger_SetLevel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	opts := testOptions(path)
+	opts.Level = "warn"
+
+	l, err := createLogger(opts)
+	require.NoError(t, err)
+	defer l.Close()
+
+	l.Info("hidden")
+	require.Empty(t, readLines(t, l, path), "запись ниже уровня журнала не пишется")
+
+	require.NoError(t, l.SetLevel("info"))
+	require.Equal(t, "info", l.LevelName())
+	l.Info("visible")
+	require.Len(t, readLines(t, l, path), 1)
+
+	require.Error(t, l.SetLevel("verbose"))
+	require.Equal(t, "info", l.LevelName())
+}
+
+func TestLogger_Requests(t *testing.T) {
+	tests := []struct {
+		name             string
+		sampleInitial    int
+		sampleThereafter int
+		want             int
+	}{
+		{name: "No sampling", sampleInitial: 2, sampleThereafter: 0, want: 10},
+		{name: "Sampling", sampleInitial: 2, sampleThereafter: 4, want: 4}, // 2 первых, затем 4-я и 8-я из оставшихся
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "app.log")
+			opts := testOptions(path)
+			opts.SampleInitial = tt.sampleInitial
+			opts.SampleThereafter = tt.sampleThereafter
+
+			l, err := createLogger(opts)
+			require.NoError(t, err)
+			defer l.Close()
+
+			ctx := NewRequestContext(context.Background(), "req-1", func() string { return "/api/user/orders" })
+			for i := 0; i < 10; i++ {
+				l.Requests(ctx).Info("request")
+			}
+			// остальные записи выборке не подлежат
+			l.Info("other")
+
+			lines := readLines(t, l, path)
+			require.Len(t, lines, tt.want+1)
+			require.Contains(t, lines[0], `"request_id":"req-1"`)
+			require.Contains(t, lines[0], `"route":"/api/user/orders"`)
+		})
+	}
+}
+
+func TestInit(t *testing.T) {
+	defer Init(Options{Level: "info", Outputs: []string{constants.LogOutputStderr}, MaxSize: 1})
+
+	require.Error(t, Init(Options{Level: "verbose", Outputs: []string{constants.LogOutputStderr}}))
+
+	// запись в журнал во время замены логгера
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				Log().Debug("concurrent")
+			}
+		}
+	}()
+
+	dir := t.TempDir()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, Init(testOptions(filepath.Join(dir, "app.log"))))
+	}
+	close(stop)
+	wg.Wait()
+
+	path := filepath.Join(dir, "last.log")
+	require.NoError(t, Init(testOptions(path)))
+	Log().Info("after init")
+
+	lines := readLines(t, Log(), path)
+	require.Len(t, lines, 1)
+	require.Contains(t, lines[0], "after init")
+}
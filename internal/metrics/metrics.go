@@ -0,0 +1,159 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	"github.com/dnsoftware/gophermart2/internal/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// собственный реестр, чтобы не зависеть от глобального реестра сторонних пакетов
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = promauto.With(registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: constants.MetricsNamespace,
+		Name:      "http_requests_total",
+		Help:      "Количество HTTP запросов по маршрутам и кодам ответа",
+	}, []string{"method", "route", "code"})
+
+	httpDuration = promauto.With(registry).NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: constants.MetricsNamespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Время обработки HTTP запросов по маршрутам",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	accrualRequests = promauto.With(registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: constants.MetricsNamespace,
+		Name:      "accrual_requests_total",
+		Help:      "Количество запросов к системе расчета начислений по кодам ответа (error - ответ не получен)",
+	}, []string{"code"})
+
+	accrualRateLimit = promauto.With(registry).NewGauge(prometheus.GaugeOpts{
+		Namespace: constants.MetricsNamespace,
+		Name:      "accrual_rate_limit",
+		Help:      "Допустимое кол-во запросов к системе расчета начислений за интервал",
+	})
+
+	accrualBudget = promauto.With(registry).NewGauge(prometheus.GaugeOpts{
+		Namespace: constants.MetricsNamespace,
+		Name:      "accrual_rate_limit_remaining",
+		Help:      "Оставшееся кол-во запросов к системе расчета начислений в текущем интервале",
+	})
+)
+
+func init() {
+	registry.MustRegister(collectors.NewGoCollector())
+	registry.MustRegister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+}
+
+// Handler отдача метрик в формате Prometheus
+// сжатие отключено: ответ сжимает GzipMiddleware
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{DisableCompression: true})
+}
+
+// ObserveHTTPRequest учет обработанного HTTP запроса
+// route - шаблон маршрута chi, а не фактический путь, чтобы не плодить метки
+func ObserveHTTPRequest(method string, route string, code int, duration time.Duration) {
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(code)).Inc()
+	httpDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// ObserveAccrualResponse учет ответа системы расчета начислений, code = 0 - ответ не получен
+func ObserveAccrualResponse(code int) {
+	label := "error"
+	if code > 0 {
+		label = strconv.Itoa(code)
+	}
+
+	accrualRequests.WithLabelValues(label).Inc()
+}
+
+// SetAccrualBudget текущий лимит запросов к системе расчета начислений и его остаток
+func SetAccrualBudget(limit int, remaining int) {
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	accrualRateLimit.Set(float64(limit))
+	accrualBudget.Set(float64(remaining))
+}
+
+// RegisterDB статистика пула соединений с БД
+func RegisterDB(db *sql.DB) {
+	register(collectors.NewDBStatsCollector(db, constants.MetricsNamespace))
+}
+
+// RegisterQueue текущая длина очереди name
+func RegisterQueue(name string, length func() int) {
+	register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   constants.MetricsNamespace,
+		Name:        "queue_length",
+		Help:        "Кол-во элементов в очереди",
+		ConstLabels: prometheus.Labels{"queue": name},
+	}, func() float64 {
+		return float64(length())
+	}))
+}
+
+// RegisterRejectedTransitions кол-во переходов статусов заказов, отклоненных конечным автоматом
+func RegisterRejectedTransitions(count func() int64) {
+	register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: constants.MetricsNamespace,
+		Name:      "order_transitions_rejected_total",
+		Help:      "Кол-во отклоненных переходов статусов заказов",
+	}, func() float64 {
+		return float64(count())
+	}))
+}
+
+// RegisterLedger суммы операций по счетам всех пользователей, по видам операций
+// запрашиваются из БД при каждом сборе метрик
+func RegisterLedger(totals func(ctx context.Context) (map[string]float64, error)) {
+	register(&ledgerCollector{totals: totals})
+}
+
+func register(c prometheus.Collector) {
+	if err := registry.Register(c); err != nil {
+		logger.Log().Error("metrics register: " + err.Error())
+	}
+}
+
+var ledgerDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(constants.MetricsNamespace, "ledger", "amount"),
+	"Сумма операций по счетам пользователей по видам операций",
+	[]string{"operation"}, nil,
+)
+
+type ledgerCollector struct {
+	totals func(ctx context.Context) (map[string]float64, error)
+}
+
+func (c *ledgerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- ledgerDesc
+}
+
+func (c *ledgerCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), constants.DBContextTimeout)
+	defer cancel()
+
+	totals, err := c.totals(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(ledgerDesc, err)
+		return
+	}
+
+	for operation, amount := range totals {
+		ch <- prometheus.MustNewConstMetric(ledgerDesc, prometheus.GaugeValue, amount, operation)
+	}
+}
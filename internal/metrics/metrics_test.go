@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterRejectedTransitions(t *testing.T) {
+	var rejected int64 = 3
+	RegisterRejectedTransitions(func() int64 { return rejected })
+
+	scrape := func() string {
+		res := httptest.NewRecorder()
+		Handler().ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		require.Equal(t, http.StatusOK, res.Code)
+		return res.Body.String()
+	}
+
+	require.Contains(t, scrape(), "gophermart_order_transitions_rejected_total 3")
+
+	// значение снимается в момент запроса
+	rejected = 5
+	require.Contains(t, scrape(), "gophermart_order_transitions_rejected_total 5")
+}
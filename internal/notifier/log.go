@@ -0,0 +1,28 @@
+package notifier
+
+import (
+	"context"
+	"time"
+
+	"github.com/dnsoftware/gophermart2/internal/logger"
+	"go.uber.org/zap"
+)
+
+// LogNotifier доставка токенов сброса пароля в лог-файл проекта
+// используется для локальной разработки вместо почты/SMS
+type LogNotifier struct {
+}
+
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+func (n *LogNotifier) SendPasswordReset(ctx context.Context, login string, token string, expiresAt time.Time) error {
+	logger.Log().Info("password reset token",
+		zap.String("login", login),
+		zap.String("token", token),
+		zap.String("expires_at", expiresAt.Format(time.RFC3339)),
+	)
+
+	return nil
+}
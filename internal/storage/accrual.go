@@ -6,9 +6,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/dnsoftware/gophermart2/internal/constants"
+	"github.com/dnsoftware/gophermart2/internal/metrics"
+	"github.com/dnsoftware/gophermart2/internal/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.25.0"
+	"go.opentelemetry.io/otel/trace"
 	"io"
 	"net/http"
-	"strconv"
+	"net/url"
 	"strings"
 )
 
@@ -31,23 +35,41 @@ func NewAccrualRepo(address string) *AccrualRepo {
 }
 
 // Получить данные по заказу
-func (a *AccrualRepo) GetOrder(orderNum int64) (*AccrualRow, int, error) {
-	ctx := context.Background()
+// контекст трассировки передается системе расчета в заголовках W3C Trace Context
+func (a *AccrualRepo) GetOrder(ctx context.Context, orderNum string) (*AccrualRow, int, error) {
+	ctx, span := tracing.Start(ctx, "AccrualRepo.GetOrder", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	row, status, err := a.getOrder(ctx, orderNum)
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	if err != nil {
+		tracing.Error(span, err)
+	}
+
+	return row, status, err
+}
+
+func (a *AccrualRepo) getOrder(ctx context.Context, orderNum string) (*AccrualRow, int, error) {
 	buf := &bytes.Buffer{}
 
-	url := a.orderEndpoint(orderNum)
-	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, buf)
+	endpoint := a.orderEndpoint(orderNum)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, buf)
 	if err != nil {
 		return nil, http.StatusInternalServerError, err
 	}
 	request.Header.Set("Content-Type", constants.ApplicationJSON)
+	tracing.Inject(ctx, request.Header)
 
 	resp, err := a.client.Do(request)
 	if err != nil {
+		metrics.ObserveAccrualResponse(0)
 		return nil, http.StatusInternalServerError, err
 	}
 	defer resp.Body.Close()
 
+	metrics.ObserveAccrualResponse(resp.StatusCode)
+
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, http.StatusInternalServerError, err
@@ -70,8 +92,6 @@ func (a *AccrualRepo) GetOrder(orderNum int64) (*AccrualRow, int, error) {
 	return row, resp.StatusCode, nil
 }
 
-func (a *AccrualRepo) orderEndpoint(orderNum int64) string {
-	strNum := strconv.FormatInt(orderNum, 10)
-
-	return strings.Replace(a.orderEndpointTemplate, "{number}", strNum, -1)
+func (a *AccrualRepo) orderEndpoint(orderNum string) string {
+	return strings.Replace(a.orderEndpointTemplate, "{number}", url.PathEscape(orderNum), -1)
 }
@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	"github.com/dnsoftware/gophermart2/internal/tracing"
+	"time"
+)
+
+type AdjustmentRow struct {
+	ID          int64
+	UserID      int64
+	OrderNumber string // пустой, если корректировка не относится к заказу
+	Amount      float32
+	ReasonCode  string
+	Comment     string
+	Status      string
+	CreatedBy   int64
+	CreatedAt   time.Time
+	DecidedBy   int64     // 0, если решение еще не принято
+	DecidedAt   time.Time // нулевое время, если решение еще не принято
+}
+
+// Создание ручной корректировки баланса в статусе ожидания подтверждения
+// возвращает ID корректировки
+func (b *BalanceRepo) CreateAdjustment(ctx context.Context, adj AdjustmentRow) (int64, error) {
+	ctx, span := tracing.Start(ctx, "BalanceRepo.CreateAdjustment")
+	defer span.End()
+
+	query := `INSERT INTO balance_adjustments (user_id, order_number, amount, reason_code, comment, status, created_by, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, now())
+			  RETURNING id`
+	row := b.storage.db.QueryRowContext(ctx, query, adj.UserID, adj.OrderNumber, adj.Amount, adj.ReasonCode,
+		adj.Comment, constants.AdjustmentPending, adj.CreatedBy)
+
+	var id int64
+
+	err := row.Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("CreateAdjustment: %w", err)
+	}
+
+	return id, nil
+}
+
+// Список корректировок в заданном статусе (пустой статус - все)
+func (b *BalanceRepo) AdjustmentList(ctx context.Context, status string) ([]AdjustmentRow, error) {
+	ctx, span := tracing.Start(ctx, "BalanceRepo.AdjustmentList")
+	defer span.End()
+
+	list := make([]AdjustmentRow, 0)
+
+	query := `SELECT id, user_id, order_number, amount, reason_code, comment, status, created_by, created_at,
+				decided_by, decided_at
+			  FROM balance_adjustments
+			  WHERE $1 = '' OR status = $1
+			  ORDER BY created_at ASC, id ASC`
+	rows, err := b.storage.db.QueryContext(ctx, query, status)
+	if err != nil {
+		return nil, fmt.Errorf("AdjustmentList error: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a AdjustmentRow
+		var decidedBy sql.NullInt64
+		var decidedAt sql.NullTime
+
+		err = rows.Scan(&a.ID, &a.UserID, &a.OrderNumber, &a.Amount, &a.ReasonCode, &a.Comment, &a.Status,
+			&a.CreatedBy, &a.CreatedAt, &decidedBy, &decidedAt)
+		if err != nil {
+			return nil, fmt.Errorf("AdjustmentList rows.Next: %w", err)
+		}
+		a.DecidedBy = decidedBy.Int64
+		a.DecidedAt = decidedAt.Time
+
+		list = append(list, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("AdjustmentList rows.Err: %w", err)
+	}
+
+	return list, nil
+}
+
+// Подтверждение корректировки и занесение ее на баланс пользователя
+// подтверждать должен не тот, кто создал корректировку
+// возвращает статус операции и ошибку
+func (b *BalanceRepo) ApproveAdjustment(ctx context.Context, id int64, approverID int64) (int, error) {
+	ctx, span := tracing.Start(ctx, "BalanceRepo.ApproveAdjustment")
+	defer span.End()
+
+	tx, err := b.storage.db.BeginTx(ctx, nil)
+	if err != nil {
+		return constants.AdjustmentInternalError, fmt.Errorf("ApproveAdjustment | BeginTx: %w", err)
+	}
+	defer tx.Rollback()
+
+	adj, status, err := lockPendingAdjustment(ctx, tx, id, approverID)
+	if err != nil {
+		return status, err
+	}
+
+	// списание не должно уводить баланс в минус, в т.ч. вместе с одновременным списанием пользователя
+	current, err := lockUserBalance(ctx, tx, adj.UserID)
+	if err != nil {
+		return constants.AdjustmentInternalError, fmt.Errorf("ApproveAdjustment: %w", err)
+	}
+	if current+adj.Amount < 0 {
+		return constants.AdjustmentNotEnoughFunds, fmt.Errorf("ApproveAdjustment: баланс %v, корректировка %v", current, adj.Amount)
+	}
+
+	query := `UPDATE balance_adjustments SET status = $1, decided_by = $2, decided_at = now()
+			  WHERE id = $3`
+	_, err = tx.ExecContext(ctx, query, constants.AdjustmentApproved, approverID, id)
+	if err != nil {
+		return constants.AdjustmentInternalError, fmt.Errorf("ApproveAdjustment | update: %w", err)
+	}
+
+	query = `INSERT INTO balances (user_id, order_number, amount, operation, adjustment_id, processed_at)
+			  VALUES ($1, $2, $3, $4, $5, now())`
+	_, err = tx.ExecContext(ctx, query, adj.UserID, adj.OrderNumber, adj.Amount, constants.OperationAdjustment, id)
+	if err != nil {
+		return constants.AdjustmentInternalError, fmt.Errorf("ApproveAdjustment | insert: %w", err)
+	}
+
+	err = addDomainEvent(ctx, tx, OutboxRow{
+		Type:        constants.EventPointsAdjusted,
+		UserID:      adj.UserID,
+		OrderNumber: adj.OrderNumber,
+		Amount:      adj.Amount,
+	})
+	if err != nil {
+		return constants.AdjustmentInternalError, fmt.Errorf("ApproveAdjustment: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return constants.AdjustmentInternalError, fmt.Errorf("ApproveAdjustment | Commit: %w", err)
+	}
+
+	return constants.AdjustmentOk, nil
+}
+
+// Отклонение корректировки
+func (b *BalanceRepo) RejectAdjustment(ctx context.Context, id int64, approverID int64) (int, error) {
+	ctx, span := tracing.Start(ctx, "BalanceRepo.RejectAdjustment")
+	defer span.End()
+
+	tx, err := b.storage.db.BeginTx(ctx, nil)
+	if err != nil {
+		return constants.AdjustmentInternalError, fmt.Errorf("RejectAdjustment | BeginTx: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, status, err := lockPendingAdjustment(ctx, tx, id, approverID)
+	if err != nil {
+		return status, err
+	}
+
+	query := `UPDATE balance_adjustments SET status = $1, decided_by = $2, decided_at = now()
+			  WHERE id = $3`
+	_, err = tx.ExecContext(ctx, query, constants.AdjustmentRejected, approverID, id)
+	if err != nil {
+		return constants.AdjustmentInternalError, fmt.Errorf("RejectAdjustment | update: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return constants.AdjustmentInternalError, fmt.Errorf("RejectAdjustment | Commit: %w", err)
+	}
+
+	return constants.AdjustmentOk, nil
+}
+
+// блокировка корректировки, ожидающей решения, с проверкой, что решение принимает не ее автор
+func lockPendingAdjustment(ctx context.Context, tx *sql.Tx, id int64, approverID int64) (AdjustmentRow, int, error) {
+	var adj AdjustmentRow
+
+	query := `SELECT id, user_id, order_number, amount, status, created_by
+			  FROM balance_adjustments WHERE id = $1
+			  FOR UPDATE`
+	err := tx.QueryRowContext(ctx, query, id).Scan(&adj.ID, &adj.UserID, &adj.OrderNumber, &adj.Amount, &adj.Status, &adj.CreatedBy)
+	if err == sql.ErrNoRows {
+		return adj, constants.AdjustmentNotFound, fmt.Errorf("корректировка %v не найдена", id)
+	}
+	if err != nil {
+		return adj, constants.AdjustmentInternalError, fmt.Errorf("lockPendingAdjustment Scan: %w", err)
+	}
+
+	if adj.Status != constants.AdjustmentPending {
+		return adj, constants.AdjustmentNotPending, fmt.Errorf("корректировка %v уже в статусе %v", id, adj.Status)
+	}
+
+	if adj.CreatedBy == approverID {
+		return adj, constants.AdjustmentSelfApproval, fmt.Errorf("корректировка %v создана этим же пользователем", id)
+	}
+
+	return adj, constants.AdjustmentOk, nil
+}
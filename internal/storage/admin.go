@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/dnsoftware/gophermart2/internal/tracing"
+	"strings"
+	"time"
+)
+
+type AdminRepo struct {
+	storage *MartStorage
+}
+
+type AuditRow struct {
+	ID        int64
+	AdminID   int64
+	Action    string
+	Target    string
+	Details   string
+	CreatedAt time.Time
+}
+
+// экранирование символов шаблона LIKE
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+type auditKey struct{}
+
+// WithAudit запись аудита, которая сохраняется в одной транзакции с действием администратора
+// (смена статуса заказа, начисление по заказу); без записи действие не фиксируется
+func WithAudit(ctx context.Context, row AuditRow) context.Context {
+	return context.WithValue(ctx, auditKey{}, row)
+}
+
+// AuditFromContext запись аудита, переданная через WithAudit
+func AuditFromContext(ctx context.Context) (AuditRow, bool) {
+	row, ok := ctx.Value(auditKey{}).(AuditRow)
+	return row, ok
+}
+
+// Запись аудита из контекста в транзакции действия, если она передана
+func addAudit(ctx context.Context, db execer) error {
+	row, ok := AuditFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	query := `INSERT INTO admin_audit (admin_id, action, target, details, created_at)
+			  VALUES ($1, $2, $3, $4, now())`
+
+	_, err := db.ExecContext(ctx, query, row.AdminID, row.Action, row.Target, row.Details)
+	if err != nil {
+		return fmt.Errorf("addAudit %v: %w", row.Action, err)
+	}
+
+	return nil
+}
+
+func NewAdminRepo(storage *MartStorage) *AdminRepo {
+
+	repo := AdminRepo{
+		storage: storage,
+	}
+
+	return &repo
+}
+
+// Поиск пользователей по части логина
+func (p *AdminRepo) SearchUsers(ctx context.Context, login string, limit int) ([]UserRow, error) {
+	ctx, span := tracing.Start(ctx, "AdminRepo.SearchUsers")
+	defer span.End()
+
+	users := make([]UserRow, 0)
+
+	// символы шаблона в строке поиска ищутся как обычные символы
+	query := `SELECT id, login, role, created_at, last_login_at, blocked_at
+			  FROM users WHERE login ILIKE '%' || $1 || '%' ESCAPE '\'
+			  ORDER BY login ASC
+			  LIMIT $2`
+	rows, err := p.storage.db.QueryContext(ctx, query, likeEscaper.Replace(login), limit)
+	if err != nil {
+		return nil, fmt.Errorf("SearchUsers error: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var u UserRow
+		var lastLoginAt, blockedAt sql.NullTime
+
+		err = rows.Scan(&u.ID, &u.Login, &u.Role, &u.CreatedAt, &lastLoginAt, &blockedAt)
+		if err != nil {
+			return nil, fmt.Errorf("SearchUsers rows.Next: %w", err)
+		}
+		u.LastLoginAt = lastLoginAt.Time
+		u.BlockedAt = blockedAt.Time
+
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("SearchUsers rows.Err: %w", err)
+	}
+
+	return users, nil
+}
+
+// Блокировка/разблокировка пользователя
+// при блокировке выданные токены становятся недействительными
+// возвращает false, если пользователь не найден
+func (p *AdminRepo) SetBlocked(ctx context.Context, userID int64, blocked bool, reason string) (bool, error) {
+	ctx, span := tracing.Start(ctx, "AdminRepo.SetBlocked")
+	defer span.End()
+
+	query := `UPDATE users SET blocked_at = NULL, block_reason = NULL, updated_at = now()
+			  WHERE id = $1`
+	args := []any{userID}
+
+	if blocked {
+		query = `UPDATE users SET blocked_at = now(), block_reason = $2, sessions_reset_at = $3, updated_at = now()
+				  WHERE id = $1`
+		args = append(args, reason, sessionsResetAt())
+	}
+
+	res, err := p.storage.retryExecResult(ctx, query, args...)
+	if err != nil {
+		return false, fmt.Errorf("SetBlocked: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("SetBlocked | RowsAffected: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+// Запись действия администратора в журнал аудита
+func (p *AdminRepo) AddAudit(ctx context.Context, adminID int64, action string, target string, details string) error {
+	ctx, span := tracing.Start(ctx, "AdminRepo.AddAudit")
+	defer span.End()
+
+	query := `INSERT INTO admin_audit (admin_id, action, target, details, created_at)
+			  VALUES ($1, $2, $3, $4, now())`
+
+	err := p.storage.retryExec(ctx, query, adminID, action, target, details)
+	if err != nil {
+		return fmt.Errorf("AddAudit: %w", err)
+	}
+
+	return nil
+}
+
+// Последние записи журнала аудита
+func (p *AdminRepo) AuditList(ctx context.Context, limit int) ([]AuditRow, error) {
+	ctx, span := tracing.Start(ctx, "AdminRepo.AuditList")
+	defer span.End()
+
+	list := make([]AuditRow, 0)
+
+	query := `SELECT id, admin_id, action, target, details, created_at
+			  FROM admin_audit
+			  ORDER BY created_at DESC, id DESC
+			  LIMIT $1`
+	rows, err := p.storage.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("AuditList error: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a AuditRow
+		err = rows.Scan(&a.ID, &a.AdminID, &a.Action, &a.Target, &a.Details, &a.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("AuditList rows.Next: %w", err)
+		}
+
+		list = append(list, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("AuditList rows.Err: %w", err)
+	}
+
+	return list, nil
+}
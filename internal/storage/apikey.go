@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/dnsoftware/gophermart2/internal/tracing"
+	"strings"
+	"time"
+)
+
+type APIKeyRepo struct {
+	storage *MartStorage
+}
+
+type APIKeyRow struct {
+	ID        int64
+	Name      string
+	KeyID     string // публичная часть ключа, по ней ищем запись
+	KeyHash   string // хэш полного ключа
+	Scopes    []string
+	RateLimit int
+	CreatedBy int64
+	CreatedAt time.Time
+	RotatedAt time.Time // нулевое время, если ключ не перевыпускался
+	RevokedAt time.Time // нулевое время, если ключ действует
+	// ключ подписи HMAC запросов и ответов, пустой - подпись не используется
+	SigningKey string
+}
+
+func NewAPIKeyRepo(storage *MartStorage) *APIKeyRepo {
+
+	repo := APIKeyRepo{
+		storage: storage,
+	}
+
+	return &repo
+}
+
+// Сохранение нового ключа, возвращает ID записи
+func (p *APIKeyRepo) CreateAPIKey(ctx context.Context, key APIKeyRow) (int64, error) {
+	ctx, span := tracing.Start(ctx, "APIKeyRepo.CreateAPIKey")
+	defer span.End()
+
+	query := `INSERT INTO api_keys (name, key_id, key_hash, scopes, rate_limit, created_by, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, now())
+			  RETURNING id`
+	row := p.storage.db.QueryRowContext(ctx, query, key.Name, key.KeyID, key.KeyHash,
+		strings.Join(key.Scopes, ","), key.RateLimit, key.CreatedBy)
+
+	var id int64
+
+	err := row.Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("CreateAPIKey: %w", err)
+	}
+
+	return id, nil
+}
+
+// Поиск ключа по публичной части
+// возвращает sql.ErrNoRows (обернутую), если ключ не найден
+func (p *APIKeyRepo) FindAPIKey(ctx context.Context, keyID string) (APIKeyRow, error) {
+	ctx, span := tracing.Start(ctx, "APIKeyRepo.FindAPIKey")
+	defer span.End()
+
+	query := `SELECT id, name, key_id, key_hash, scopes, rate_limit, created_by, created_at, rotated_at, revoked_at, signing_key
+			  FROM api_keys WHERE key_id = $1`
+	row := p.storage.db.QueryRowContext(ctx, query, keyID)
+
+	key, err := scanAPIKey(row)
+	if err != nil {
+		return APIKeyRow{}, fmt.Errorf("FindAPIKey: %w", err)
+	}
+
+	return key, nil
+}
+
+// Список всех ключей
+func (p *APIKeyRepo) APIKeyList(ctx context.Context) ([]APIKeyRow, error) {
+	ctx, span := tracing.Start(ctx, "APIKeyRepo.APIKeyList")
+	defer span.End()
+
+	list := make([]APIKeyRow, 0)
+
+	query := `SELECT id, name, key_id, key_hash, scopes, rate_limit, created_by, created_at, rotated_at, revoked_at, signing_key
+			  FROM api_keys
+			  ORDER BY id ASC`
+	rows, err := p.storage.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("APIKeyList error: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("APIKeyList rows.Next: %w", err)
+		}
+
+		list = append(list, key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("APIKeyList rows.Err: %w", err)
+	}
+
+	return list, nil
+}
+
+// Перевыпуск действующего ключа: старый ключ перестает работать сразу
+// возвращает false, если действующий ключ не найден
+func (p *APIKeyRepo) RotateAPIKey(ctx context.Context, id int64, keyID string, keyHash string) (bool, error) {
+	ctx, span := tracing.Start(ctx, "APIKeyRepo.RotateAPIKey")
+	defer span.End()
+
+	query := `UPDATE api_keys SET key_id = $1, key_hash = $2, rotated_at = now()
+			  WHERE id = $3 AND revoked_at IS NULL`
+
+	res, err := p.storage.retryExecResult(ctx, query, keyID, keyHash, id)
+	if err != nil {
+		return false, fmt.Errorf("RotateAPIKey: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("RotateAPIKey | RowsAffected: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+// Отзыв ключа
+// возвращает false, если действующий ключ не найден
+func (p *APIKeyRepo) RevokeAPIKey(ctx context.Context, id int64) (bool, error) {
+	ctx, span := tracing.Start(ctx, "APIKeyRepo.RevokeAPIKey")
+	defer span.End()
+
+	query := `UPDATE api_keys SET revoked_at = now()
+			  WHERE id = $1 AND revoked_at IS NULL`
+
+	res, err := p.storage.retryExecResult(ctx, query, id)
+	if err != nil {
+		return false, fmt.Errorf("RevokeAPIKey: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("RevokeAPIKey | RowsAffected: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+// Установка ключа подписи запросов, пустой ключ отключает подпись
+// возвращает false, если действующий ключ не найден
+func (p *APIKeyRepo) SetSigningKey(ctx context.Context, id int64, signingKey string) (bool, error) {
+	ctx, span := tracing.Start(ctx, "APIKeyRepo.SetSigningKey")
+	defer span.End()
+
+	query := `UPDATE api_keys SET signing_key = $1
+			  WHERE id = $2 AND revoked_at IS NULL`
+
+	res, err := p.storage.retryExecResult(ctx, query, signingKey, id)
+	if err != nil {
+		return false, fmt.Errorf("SetSigningKey: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("SetSigningKey | RowsAffected: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAPIKey(row rowScanner) (APIKeyRow, error) {
+	var key APIKeyRow
+	var scopes string
+	var rotatedAt, revokedAt sql.NullTime
+
+	err := row.Scan(&key.ID, &key.Name, &key.KeyID, &key.KeyHash, &scopes, &key.RateLimit, &key.CreatedBy,
+		&key.CreatedAt, &rotatedAt, &revokedAt, &key.SigningKey)
+	if err != nil {
+		return APIKeyRow{}, err
+	}
+
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, ",")
+	}
+	key.RotatedAt = rotatedAt.Time
+	key.RevokedAt = revokedAt.Time
+
+	return key, nil
+}
@@ -3,12 +3,17 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/dnsoftware/gophermart2/internal/constants"
+	"github.com/dnsoftware/gophermart2/internal/tracing"
 	"math"
 	"time"
 )
 
+// ErrNotEnoughFunds списание больше текущего баланса
+var ErrNotEnoughFunds = errors.New("недостаточно средств")
+
 type BalanceRepo struct {
 	storage *MartStorage
 }
@@ -16,13 +21,15 @@ type BalanceRepo struct {
 type BalanceRow struct {
 	ID          int64
 	UserID      int64
-	OrderNumber int64
+	OrderNumber string
 	Amount      float32
+	Operation   string
+	ReasonCode  string // только для ручных корректировок
 	ProcessedAt time.Time
 }
 
 type WithdrawRow struct {
-	Order       int64
+	Order       string
 	Sum         float32
 	ProcessedAt time.Time
 }
@@ -36,7 +43,12 @@ func NewBalanceRepo(storage *MartStorage) *BalanceRepo {
 	return &balance
 }
 
-func (b *BalanceRepo) SaveTransaction(ctx context.Context, orderNumber int64, amount float32) error {
+// Сохранение начисления по обработанному заказу
+// статус заказа меняется на PROCESSED, только если текущий статус один из from, иначе возвращается ErrTransitionRejected
+// source - кто меняет статус, constants.StatusSource*; запись аудита из WithAudit сохраняется в той же транзакции
+func (b *BalanceRepo) SaveTransaction(ctx context.Context, orderNumber string, amount float32, from []string, source string) error {
+	ctx, span := tracing.Start(ctx, "BalanceRepo.SaveTransaction")
+	defer span.End()
 
 	// получение ID владельца заказа
 	var userID int64
@@ -52,7 +64,7 @@ func (b *BalanceRepo) SaveTransaction(ctx context.Context, orderNumber int64, am
 	// проверка, что данные по этому заказу еще не вносились в базу
 	var a float32
 	query = `SELECT amount 
-			 FROM balances WHERE order_number = $1`
+			 FROM balances WHERE order_number = $1 AND operation = 'accrual'`
 	row = b.storage.db.QueryRowContext(ctx, query, orderNumber)
 
 	err = row.Scan(&a)
@@ -64,27 +76,70 @@ func (b *BalanceRepo) SaveTransaction(ctx context.Context, orderNumber int64, am
 	}
 
 	// стартуем транзакцию БД
-	tx, err := b.storage.db.Begin()
+	tx, err := b.storage.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
 	// обновление статуса ордера на обработанный
 	query = `UPDATE orders SET status = $1, accrual = $2
-			  WHERE num = $3`
+			  WHERE num = $3 AND status = ANY($4)`
+
+	res, err := tx.ExecContext(ctx, query, constants.OrderProcessed, amount, orderNumber, from)
+	if err != nil {
+		return err
+	}
 
-	err = b.storage.retryExec(ctx, query, constants.OrderProcessed, amount, orderNumber)
+	affected, err := res.RowsAffected()
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
+	if affected == 0 {
+		return fmt.Errorf("SaveTransaction %v: %w", orderNumber, ErrTransitionRejected)
+	}
 
 	// занесение начислений на баланс
-	query = `INSERT INTO balances (user_id, order_number, amount, processed_at)
-			  VALUES ($1, $2, $3, now())`
-	err = b.storage.retryExec(ctx, query, userID, orderNumber, amount)
+	query = `INSERT INTO balances (user_id, order_number, amount, operation, processed_at)
+			  VALUES ($1, $2, $3, $4, now())`
+	_, err = tx.ExecContext(ctx, query, userID, orderNumber, amount, constants.OperationAccrual)
+	if err != nil {
+		return err
+	}
+
+	// статус Accrual есть, только если заказ обработан в Accrual, а не проведен администратором
+	accrualStatus := ""
+	if source == constants.StatusSourceAccrual {
+		accrualStatus = constants.AccrualProcessed
+	}
+	err = addStatusHistory(ctx, tx, orderNumber, constants.OrderProcessed, accrualStatus, source)
+	if err != nil {
+		return err
+	}
+
+	err = addDomainEvent(ctx, tx, OutboxRow{
+		Type:        constants.EventOrderStatusChanged,
+		UserID:      userID,
+		OrderNumber: orderNumber,
+		Status:      constants.OrderProcessed,
+		Amount:      amount,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = addDomainEvent(ctx, tx, OutboxRow{
+		Type:        constants.EventPointsAccrued,
+		UserID:      userID,
+		OrderNumber: orderNumber,
+		Amount:      amount,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = addAudit(ctx, tx)
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
 
@@ -92,6 +147,9 @@ func (b *BalanceRepo) SaveTransaction(ctx context.Context, orderNumber int64, am
 }
 
 func (b *BalanceRepo) GetUserBalance(ctx context.Context, userID int64) (float32, error) {
+	ctx, span := tracing.Start(ctx, "BalanceRepo.GetUserBalance")
+	defer span.End()
+
 	query := `SELECT SUM(amount) curr_balance 
 			  FROM balances WHERE user_id = $1`
 	row := b.storage.db.QueryRowContext(ctx, query, userID)
@@ -109,8 +167,11 @@ func (b *BalanceRepo) GetUserBalance(ctx context.Context, userID int64) (float32
 }
 
 func (b *BalanceRepo) GetUserWithdrawn(ctx context.Context, userID int64) (float32, error) {
+	ctx, span := tracing.Start(ctx, "BalanceRepo.GetUserWithdrawn")
+	defer span.End()
+
 	query := `SELECT SUM(amount) curr_balance 
-			  FROM balances WHERE user_id = $1 AND amount < 0`
+			  FROM balances WHERE user_id = $1 AND operation = 'withdrawal'`
 	row := b.storage.db.QueryRowContext(ctx, query, userID)
 
 	var withdrawBalance sql.NullFloat64
@@ -125,9 +186,44 @@ func (b *BalanceRepo) GetUserWithdrawn(ctx context.Context, userID int64) (float
 	return float32(math.Abs(withdrawBalance.Float64)), nil
 }
 
+// Суммы операций по счетам всех пользователей по видам операций
+// списания возвращаются со знаком минус, как хранятся в журнале
+func (b *BalanceRepo) LedgerTotals(ctx context.Context) (map[string]float64, error) {
+	ctx, span := tracing.Start(ctx, "BalanceRepo.LedgerTotals")
+	defer span.End()
+
+	totals := make(map[string]float64)
+
+	query := `SELECT operation, COALESCE(SUM(amount), 0) FROM balances GROUP BY operation`
+	rows, err := b.storage.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("LedgerTotals: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var operation string
+		var amount float64
+		if err = rows.Scan(&operation, &amount); err != nil {
+			return nil, fmt.Errorf("LedgerTotals rows.Next: %w", err)
+		}
+
+		totals[operation] = amount
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("LedgerTotals rows.Err: %w", err)
+	}
+
+	return totals, nil
+}
+
 func (b *BalanceRepo) GetUserWithdrawList(ctx context.Context, userID int64) ([]WithdrawRow, error) {
+	ctx, span := tracing.Start(ctx, "BalanceRepo.GetUserWithdrawList")
+	defer span.End()
+
 	query := `SELECT order_number, amount, processed_at 
-			  FROM balances WHERE user_id = $1 AND amount < 0
+			  FROM balances WHERE user_id = $1 AND operation = 'withdrawal'
 			  ORDER BY processed_at ASC`
 	rows, err := b.storage.db.QueryContext(ctx, query, userID)
 	if err != nil {
@@ -153,14 +249,96 @@ func (b *BalanceRepo) GetUserWithdrawList(ctx context.Context, userID int64) ([]
 	return wd, nil
 }
 
-func (b *BalanceRepo) WithdrawTransaction(ctx context.Context, userID int64, orderNumber int64, amount float32) error {
+// Все движения по балансу пользователя (начисления и списания) в хронологическом порядке
+func (b *BalanceRepo) GetUserLedger(ctx context.Context, userID int64) ([]BalanceRow, error) {
+	ctx, span := tracing.Start(ctx, "BalanceRepo.GetUserLedger")
+	defer span.End()
+
+	query := `SELECT b.id, b.user_id, b.order_number, b.amount, b.operation, COALESCE(a.reason_code, ''), b.processed_at 
+			  FROM balances b
+			  LEFT JOIN balance_adjustments a ON a.id = b.adjustment_id
+			  WHERE b.user_id = $1
+			  ORDER BY b.processed_at ASC, b.id ASC`
+	rows, err := b.storage.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("GetUserLedger error: %w", err)
+	}
+	defer rows.Close()
+
+	var ledger []BalanceRow
+	for rows.Next() {
+		var r BalanceRow
+		err = rows.Scan(&r.ID, &r.UserID, &r.OrderNumber, &r.Amount, &r.Operation, &r.ReasonCode, &r.ProcessedAt)
+		if err != nil {
+			return nil, fmt.Errorf("GetUserLedger get row: %w", err)
+		}
+
+		ledger = append(ledger, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("GetUserLedger rows.Err: %w", err)
+	}
+
+	return ledger, nil
+}
+
+// Списание баллов
+// если баланс меньше amount, возвращается ErrNotEnoughFunds
+func (b *BalanceRepo) WithdrawTransaction(ctx context.Context, userID int64, orderNumber string, amount float32) error {
+	ctx, span := tracing.Start(ctx, "BalanceRepo.WithdrawTransaction")
+	defer span.End()
+
+	tx, err := b.storage.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	current, err := lockUserBalance(ctx, tx, userID)
+	if err != nil {
+		return fmt.Errorf("WithdrawTransaction: %w", err)
+	}
+	if amount > current {
+		return fmt.Errorf("WithdrawTransaction: баланс %v, списание %v: %w", current, amount, ErrNotEnoughFunds)
+	}
+
+	query := `INSERT INTO balances (user_id, order_number, amount, operation, processed_at)
+			  VALUES ($1, $2, $3, $4, now())`
+	_, err = tx.ExecContext(ctx, query, userID, orderNumber, -amount, constants.OperationWithdrawal)
+	if err != nil {
+		return err
+	}
 
-	query := `INSERT INTO balances (user_id, order_number, amount, processed_at)
-			  VALUES ($1, $2, $3, now())`
-	err := b.storage.retryExec(ctx, query, userID, orderNumber, -amount)
+	err = addDomainEvent(ctx, tx, OutboxRow{
+		Type:        constants.EventPointsWithdrawn,
+		UserID:      userID,
+		OrderNumber: orderNumber,
+		Amount:      amount,
+	})
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return tx.Commit()
+}
+
+// Текущий баланс пользователя с блокировкой до конца транзакции
+// списания и корректировки блокируют строку пользователя, поэтому их проверки баланса не пересекаются
+func lockUserBalance(ctx context.Context, tx *sql.Tx, userID int64) (float32, error) {
+	var id int64
+	query := `SELECT id FROM users WHERE id = $1 FOR UPDATE`
+	err := tx.QueryRowContext(ctx, query, userID).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("lockUserBalance | user: %w", err)
+	}
+
+	var current float32
+	query = `SELECT COALESCE(SUM(amount), 0) FROM balances WHERE user_id = $1`
+	err = tx.QueryRowContext(ctx, query, userID).Scan(&current)
+	if err != nil {
+		return 0, fmt.Errorf("lockUserBalance | balance: %w", err)
+	}
+
+	return current, nil
 }
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	"github.com/dnsoftware/gophermart2/internal/tracing"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"time"
+)
+
+// DisputeRow спор о принадлежности заказа
+// UserID - заявитель, OwnerID - владелец заказа на момент открытия спора
+type DisputeRow struct {
+	ID          int64
+	OrderNumber string
+	UserID      int64
+	OwnerID     int64
+	Comment     string
+	Status      string
+	Resolution  string // комментарий поддержки к решению
+	CreatedAt   time.Time
+	DecidedBy   int64     // 0, если решение еще не принято
+	DecidedAt   time.Time // нулевое время, если решение еще не принято
+}
+
+// Открытие спора о принадлежности заказа
+// у заявителя может быть только один открытый спор по заказу
+// возвращает ID спора, статус операции и ошибку
+func (p *OrderRepo) CreateDispute(ctx context.Context, d DisputeRow) (int64, int, error) {
+	ctx, span := tracing.Start(ctx, "OrderRepo.CreateDispute")
+	defer span.End()
+
+	query := `INSERT INTO order_disputes (order_num, user_id, owner_id, comment, status, created_at)
+			  VALUES ($1, $2, $3, $4, $5, now())
+			  RETURNING id`
+	row := p.storage.db.QueryRowContext(ctx, query, d.OrderNumber, d.UserID, d.OwnerID, d.Comment, constants.DisputePending)
+
+	var id int64
+
+	err := row.Scan(&id)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgerrcode.UniqueViolation == pgErr.Code {
+			return 0, constants.DisputeAlreadyOpen, fmt.Errorf("спор по заказу %v уже открыт", d.OrderNumber)
+		}
+		return 0, constants.DisputeInternalError, fmt.Errorf("CreateDispute: %w", err)
+	}
+
+	return id, constants.DisputeCreated, nil
+}
+
+// Список споров заявителя (userID = 0 - всех) в заданном статусе (пустой статус - все)
+func (p *OrderRepo) DisputeList(ctx context.Context, userID int64, status string) ([]DisputeRow, error) {
+	ctx, span := tracing.Start(ctx, "OrderRepo.DisputeList")
+	defer span.End()
+
+	list := make([]DisputeRow, 0)
+
+	query := `SELECT id, order_num, user_id, owner_id, comment, status, resolution, created_at, decided_by, decided_at
+			  FROM order_disputes
+			  WHERE ($1 = 0 OR user_id = $1) AND ($2 = '' OR status = $2)
+			  ORDER BY created_at ASC, id ASC`
+	rows, err := p.storage.db.QueryContext(ctx, query, userID, status)
+	if err != nil {
+		return nil, fmt.Errorf("DisputeList error: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d DisputeRow
+		var decidedBy sql.NullInt64
+		var decidedAt sql.NullTime
+
+		err = rows.Scan(&d.ID, &d.OrderNumber, &d.UserID, &d.OwnerID, &d.Comment, &d.Status, &d.Resolution,
+			&d.CreatedAt, &decidedBy, &decidedAt)
+		if err != nil {
+			return nil, fmt.Errorf("DisputeList rows.Next: %w", err)
+		}
+		d.DecidedBy = decidedBy.Int64
+		d.DecidedAt = decidedAt.Time
+
+		list = append(list, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("DisputeList rows.Err: %w", err)
+	}
+
+	return list, nil
+}
+
+// Решение по спору
+// при approve заказ передается заявителю, если он все еще у прежнего владельца и баллы за него не начислены
+// возвращает спор, статус операции и ошибку
+func (p *OrderRepo) ResolveDispute(ctx context.Context, id int64, adminID int64, approve bool, resolution string) (DisputeRow, int, error) {
+	ctx, span := tracing.Start(ctx, "OrderRepo.ResolveDispute")
+	defer span.End()
+
+	var d DisputeRow
+
+	tx, err := p.storage.db.BeginTx(ctx, nil)
+	if err != nil {
+		return d, constants.DisputeInternalError, fmt.Errorf("ResolveDispute | BeginTx: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `SELECT id, order_num, user_id, owner_id, status
+			  FROM order_disputes WHERE id = $1
+			  FOR UPDATE`
+	err = tx.QueryRowContext(ctx, query, id).Scan(&d.ID, &d.OrderNumber, &d.UserID, &d.OwnerID, &d.Status)
+	if err == sql.ErrNoRows {
+		return d, constants.DisputeNotFound, fmt.Errorf("спор %v не найден", id)
+	}
+	if err != nil {
+		return d, constants.DisputeInternalError, fmt.Errorf("ResolveDispute Scan: %w", err)
+	}
+
+	if d.Status != constants.DisputePending {
+		return d, constants.DisputeNotPending, fmt.Errorf("спор %v уже в статусе %v", id, d.Status)
+	}
+
+	status := constants.DisputeRejected
+	if approve {
+		status = constants.DisputeApproved
+
+		var ownerID int64
+		var orderStatus string
+		query = `SELECT user_id, status FROM orders WHERE num = $1 FOR UPDATE`
+		err = tx.QueryRowContext(ctx, query, d.OrderNumber).Scan(&ownerID, &orderStatus)
+		if err != nil && err != sql.ErrNoRows {
+			return d, constants.DisputeInternalError, fmt.Errorf("ResolveDispute | order: %w", err)
+		}
+
+		// заказ удален или уже передан - передавать нечего
+		if err == sql.ErrNoRows || ownerID != d.OwnerID {
+			return d, constants.DisputeOwnerChanged, fmt.Errorf("владелец заказа %v изменился", d.OrderNumber)
+		}
+
+		// начисленные баллы переносятся только корректировкой баланса
+		if orderStatus == constants.OrderProcessed {
+			return d, constants.DisputeOrderProcessed, fmt.Errorf("баллы за заказ %v уже начислены", d.OrderNumber)
+		}
+
+		query = `UPDATE orders SET user_id = $1 WHERE num = $2`
+		_, err = tx.ExecContext(ctx, query, d.UserID, d.OrderNumber)
+		if err != nil {
+			return d, constants.DisputeInternalError, fmt.Errorf("ResolveDispute | transfer: %w", err)
+		}
+	}
+
+	query = `UPDATE order_disputes SET status = $1, resolution = $2, decided_by = $3, decided_at = now()
+			  WHERE id = $4`
+	_, err = tx.ExecContext(ctx, query, status, resolution, adminID, id)
+	if err != nil {
+		return d, constants.DisputeInternalError, fmt.Errorf("ResolveDispute | update: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return d, constants.DisputeInternalError, fmt.Errorf("ResolveDispute | Commit: %w", err)
+	}
+
+	d.Status = status
+	d.Resolution = resolution
+
+	return d, constants.DisputeOk, nil
+}
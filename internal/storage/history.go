@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/dnsoftware/gophermart2/internal/tracing"
+	"time"
+)
+
+type OrderStatusRow struct {
+	Status        string
+	AccrualStatus string // статус в системе расчета, пустой - смена статуса не от Accrual
+	Source        string // constants.StatusSource*
+	CreatedAt     time.Time
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Запись смены статуса заказа в историю
+func addStatusHistory(ctx context.Context, db execer, orderNumber string, orderStatus string, accrualStatus string, source string) error {
+
+	query := `INSERT INTO order_status_history (order_num, status, accrual_status, source, created_at)
+			  VALUES ($1, $2, $3, $4, now())`
+
+	_, err := db.ExecContext(ctx, query, orderNumber, orderStatus, accrualStatus, source)
+	if err != nil {
+		return fmt.Errorf("addStatusHistory: %w", err)
+	}
+
+	return nil
+}
+
+// История статусов заказа в хронологическом порядке
+func (p *OrderRepo) StatusHistory(ctx context.Context, orderNumber string) ([]OrderStatusRow, error) {
+	ctx, span := tracing.Start(ctx, "OrderRepo.StatusHistory")
+	defer span.End()
+
+	history := make([]OrderStatusRow, 0)
+
+	query := `SELECT status, accrual_status, source, created_at
+			  FROM order_status_history WHERE order_num = $1
+			  ORDER BY id ASC`
+	rows, err := p.storage.db.QueryContext(ctx, query, orderNumber)
+	if err != nil {
+		return nil, fmt.Errorf("StatusHistory: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var h OrderStatusRow
+		err = rows.Scan(&h.Status, &h.AccrualStatus, &h.Source, &h.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("StatusHistory rows.Next: %w", err)
+		}
+
+		history = append(history, h)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("StatusHistory rows.Err: %w", err)
+	}
+
+	return history, nil
+}
@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NatsPublisher публикация сообщений в брокер по текстовому протоколу NATS
+// поддерживается только то, что нужно для публикации: CONNECT, PUB и PING/PONG
+type NatsPublisher struct {
+	address string
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func NewNatsPublisher(address string) *NatsPublisher {
+	return &NatsPublisher{
+		address: address,
+	}
+}
+
+// Публикация сообщения, возвращает управление после подтверждения брокером (PONG на PING)
+// при ошибке соединение закрывается и открывается заново при следующей публикации
+func (p *NatsPublisher) Publish(ctx context.Context, subject string, data []byte) error {
+	if subject == "" || strings.ContainsAny(subject, " \t\r\n") {
+		return fmt.Errorf("Publish: неверная тема %q", subject)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	err := p.publish(ctx, subject, data)
+	if err != nil {
+		p.close()
+		return fmt.Errorf("Publish %v: %w", subject, err)
+	}
+
+	return nil
+}
+
+func (p *NatsPublisher) publish(ctx context.Context, subject string, data []byte) error {
+	if p.conn == nil {
+		if err := p.connect(ctx); err != nil {
+			return err
+		}
+	}
+
+	deadline := time.Now().Add(constants.NatsTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	p.conn.SetDeadline(deadline)
+
+	msg := fmt.Sprintf("PUB %s %d\r\n%s\r\nPING\r\n", subject, len(data), data)
+	if _, err := p.conn.Write([]byte(msg)); err != nil {
+		return err
+	}
+
+	return p.waitPong()
+}
+
+func (p *NatsPublisher) connect(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: constants.NatsTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", p.address)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(constants.NatsTimeout))
+
+	p.conn = conn
+	p.reader = bufio.NewReader(conn)
+
+	// первым сервер присылает INFO
+	line, err := p.readLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO") {
+		return fmt.Errorf("неожиданный ответ брокера: %v", line)
+	}
+
+	_, err = conn.Write([]byte(`CONNECT {"verbose":false,"pedantic":false,"name":"gophermart"}` + "\r\n"))
+
+	return err
+}
+
+// ожидание PONG; ошибка публикации или авторизации приходит от сервера раньше как -ERR
+func (p *NatsPublisher) waitPong() error {
+	for {
+		line, err := p.readLine()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err = p.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("ошибка брокера: %v", line)
+		}
+	}
+}
+
+func (p *NatsPublisher) readLine() (string, error) {
+	line, err := p.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Close закрытие соединения с брокером
+func (p *NatsPublisher) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.close()
+}
+
+func (p *NatsPublisher) close() {
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+		p.reader = nil
+	}
+}
@@ -7,11 +7,15 @@ import (
 	"fmt"
 	"github.com/dnsoftware/gophermart2/internal/constants"
 	"github.com/dnsoftware/gophermart2/internal/logger"
+	"github.com/dnsoftware/gophermart2/internal/tracing"
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5/pgconn"
 	"time"
 )
 
+// ErrTransitionRejected заказ не в том статусе, из которого разрешен переход
+var ErrTransitionRejected = errors.New("переход статуса заказа отклонен")
+
 type OrderRepo struct {
 	storage *MartStorage
 }
@@ -19,7 +23,7 @@ type OrderRepo struct {
 type OrderRow struct {
 	ID         int64
 	UserID     int64
-	Num        int64
+	Num        string
 	Status     string
 	Accrual    float32
 	UploadedAt time.Time
@@ -35,8 +39,12 @@ func NewOrderRepo(storage *MartStorage) *OrderRepo {
 }
 
 // Загрузка номера заказа
+// apiKeyID - ключ API партнера, загрузившего заказ за пользователя, 0 - загрузил сам пользователь;
+// партнеру потом доставляются события только по его заказам
 // возвращает стутус операции и ошибку
-func (p *OrderRepo) Create(ctx context.Context, userID int64, number int64) (int, error) {
+func (p *OrderRepo) Create(ctx context.Context, userID int64, number string, apiKeyID int64) (int, error) {
+	ctx, span := tracing.Start(ctx, "OrderRepo.Create")
+	defer span.End()
 
 	query := `SELECT id, user_id FROM orders WHERE num = $1`
 	row := p.storage.db.QueryRowContext(ctx, query, number)
@@ -56,13 +64,19 @@ func (p *OrderRepo) Create(ctx context.Context, userID int64, number int64) (int
 		return constants.OrderAlreadyUpload, fmt.Errorf("другой пользователь уже добавил этот заказ")
 	}
 
-	// записи с заказом еще нет - добавляем
-	query = `INSERT INTO orders (user_id, num, status, accrual, uploaded_at)
-			  VALUES ($1, $2, $3, $4, now()) RETURNING id`
+	// записи с заказом еще нет - добавляем вместе с первой записью истории статусов
+	tx, err := p.storage.db.BeginTx(ctx, nil)
+	if err != nil {
+		return constants.OrderInternalError, fmt.Errorf("OrderRepo Create | BeginTx: %w", err)
+	}
+	defer tx.Rollback()
+
+	query = `INSERT INTO orders (user_id, num, status, accrual, uploaded_at, api_key_id)
+			  VALUES ($1, $2, $3, $4, now(), NULLIF($5, 0))`
 
 	status := constants.OrderInternalError
 
-	err = p.storage.retryExec(ctx, query, userID, number, constants.OrderNew, 0)
+	_, err = tx.ExecContext(ctx, query, userID, number, constants.OrderNew, 0, apiKeyID)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
@@ -73,21 +87,92 @@ func (p *OrderRepo) Create(ctx context.Context, userID int64, number int64) (int
 		return status, fmt.Errorf("OrderRepo Create: %w", err)
 	}
 
-	query = `SELECT currval('orders_id_seq')`
-	row = p.storage.db.QueryRowContext(ctx, query)
+	err = addStatusHistory(ctx, tx, number, constants.OrderNew, "", constants.StatusSourceUser)
+	if err != nil {
+		return status, fmt.Errorf("OrderRepo Create: %w", err)
+	}
 
-	var oid int64
-	err = row.Scan(&oid)
+	err = addDomainEvent(ctx, tx, OutboxRow{Type: constants.EventOrderUploaded, UserID: userID, OrderNumber: number})
 	if err != nil {
-		return status, fmt.Errorf("OrderRepo Create | GetAutoInc: %w", err)
+		return status, fmt.Errorf("OrderRepo Create: %w", err)
 	}
-	if oid == 0 {
-		fmt.Print("")
+
+	err = tx.Commit()
+	if err != nil {
+		return status, fmt.Errorf("OrderRepo Create | Commit: %w", err)
 	}
+
 	return constants.OrderAccepted, nil
 }
 
+// Пакетная загрузка номеров заказов в одной транзакции
+// возвращает статус операции по каждому номеру в том же порядке:
+// OrderAccepted - добавлен, OrderOk - уже загружен этим пользователем, OrderAlreadyUpload - загружен другим
+func (p *OrderRepo) CreateBatch(ctx context.Context, userID int64, numbers []string) ([]int, error) {
+	ctx, span := tracing.Start(ctx, "OrderRepo.CreateBatch")
+	defer span.End()
+
+	tx, err := p.storage.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("OrderRepo CreateBatch | BeginTx: %w", err)
+	}
+	defer tx.Rollback()
+
+	statuses := make([]int, 0, len(numbers))
+
+	for _, number := range numbers {
+		query := `INSERT INTO orders (user_id, num, status, accrual, uploaded_at)
+				  VALUES ($1, $2, $3, 0, now())
+				  ON CONFLICT (num) DO NOTHING
+				  RETURNING id`
+
+		var id int64
+		err = tx.QueryRowContext(ctx, query, userID, number, constants.OrderNew).Scan(&id)
+		if err == nil {
+			err = addStatusHistory(ctx, tx, number, constants.OrderNew, "", constants.StatusSourceUser)
+			if err != nil {
+				return nil, fmt.Errorf("OrderRepo CreateBatch: %w", err)
+			}
+
+			err = addDomainEvent(ctx, tx, OutboxRow{Type: constants.EventOrderUploaded, UserID: userID, OrderNumber: number})
+			if err != nil {
+				return nil, fmt.Errorf("OrderRepo CreateBatch: %w", err)
+			}
+
+			statuses = append(statuses, constants.OrderAccepted)
+			continue
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("OrderRepo CreateBatch | insert %v: %w", number, err)
+		}
+
+		// заказ уже есть - проверяем владельца
+		var uid int64
+		query = `SELECT user_id FROM orders WHERE num = $1`
+		err = tx.QueryRowContext(ctx, query, number).Scan(&uid)
+		if err != nil {
+			return nil, fmt.Errorf("OrderRepo CreateBatch | owner %v: %w", number, err)
+		}
+
+		if uid == userID {
+			statuses = append(statuses, constants.OrderOk)
+		} else {
+			statuses = append(statuses, constants.OrderAlreadyUpload)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("OrderRepo CreateBatch | Commit: %w", err)
+	}
+
+	return statuses, nil
+}
+
 func (p *OrderRepo) List(ctx context.Context, userID int64) ([]OrderRow, int, error) {
+	ctx, span := tracing.Start(ctx, "OrderRepo.List")
+	defer span.End()
+
 	orders := make([]OrderRow, 0)
 
 	query := `SELECT id, user_id, num, status, accrual, uploaded_at 
@@ -116,7 +201,93 @@ func (p *OrderRepo) List(ctx context.Context, userID int64) ([]OrderRow, int, er
 	return orders, constants.OrdersListOk, nil
 }
 
+// OrderFilter условия выборки страницы заказов
+type OrderFilter struct {
+	Statuses  []string  // пустой - все статусы
+	From      time.Time // нулевое время - без ограничения
+	To        time.Time // нулевое время - без ограничения
+	Ascending bool      // сортировка от старых к новым
+	AfterTime time.Time // курсор: uploaded_at последнего заказа предыдущей страницы
+	AfterID   int64     // курсор: id последнего заказа предыдущей страницы, 0 - первая страница
+	Limit     int
+}
+
+// Страница заказов пользователя, сортировка стабильна по (uploaded_at, id)
+// возвращает заказы страницы и общее кол-во заказов, подходящих под фильтр
+func (p *OrderRepo) ListPage(ctx context.Context, userID int64, filter OrderFilter) ([]OrderRow, int, error) {
+	ctx, span := tracing.Start(ctx, "OrderRepo.ListPage")
+	defer span.End()
+
+	orders := make([]OrderRow, 0)
+
+	where := "user_id = $1"
+	args := []any{userID}
+
+	if len(filter.Statuses) > 0 {
+		args = append(args, filter.Statuses)
+		where += fmt.Sprintf(" AND status = ANY($%d)", len(args))
+	}
+	if !filter.From.IsZero() {
+		args = append(args, filter.From)
+		where += fmt.Sprintf(" AND uploaded_at >= $%d", len(args))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To)
+		where += fmt.Sprintf(" AND uploaded_at < $%d", len(args))
+	}
+
+	query := `SELECT count(*) FROM orders WHERE ` + where
+	row := p.storage.db.QueryRowContext(ctx, query, args...)
+
+	var total int
+	err := row.Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("OrderRepo ListPage | count: %w", err)
+	}
+
+	order, cmp := "DESC", "<"
+	if filter.Ascending {
+		order, cmp = "ASC", ">"
+	}
+
+	if filter.AfterID > 0 {
+		args = append(args, filter.AfterTime, filter.AfterID)
+		where += fmt.Sprintf(" AND (uploaded_at, id) %s ($%d, $%d)", cmp, len(args)-1, len(args))
+	}
+
+	args = append(args, filter.Limit)
+	query = `SELECT id, user_id, num, status, accrual, uploaded_at
+			  FROM orders WHERE ` + where + `
+			  ORDER BY uploaded_at ` + order + `, id ` + order + fmt.Sprintf(`
+			  LIMIT $%d`, len(args))
+
+	rows, err := p.storage.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("OrderRepo ListPage: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var o OrderRow
+		err = rows.Scan(&o.ID, &o.UserID, &o.Num, &o.Status, &o.Accrual, &o.UploadedAt)
+		if err != nil {
+			return nil, 0, fmt.Errorf("OrderRepo ListPage rows.Next: %w", err)
+		}
+
+		orders = append(orders, o)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("OrderRepo ListPage rows.Err: %w", err)
+	}
+
+	return orders, total, nil
+}
+
 func (p *OrderRepo) GetUnchecked(ctx context.Context) ([]OrderRow, error) {
+	ctx, span := tracing.Start(ctx, "OrderRepo.GetUnchecked")
+	defer span.End()
+
 	orders := make([]OrderRow, 0)
 
 	query := `SELECT id, user_id, num, status, accrual, uploaded_at 
@@ -144,7 +315,10 @@ func (p *OrderRepo) GetUnchecked(ctx context.Context) ([]OrderRow, error) {
 	return orders, nil
 }
 
-func (p *OrderRepo) GetOrderByNumber(ctx context.Context, orderNumber int64) (OrderRow, error) {
+func (p *OrderRepo) GetOrderByNumber(ctx context.Context, orderNumber string) (OrderRow, error) {
+	ctx, span := tracing.Start(ctx, "OrderRepo.GetOrderByNumber")
+	defer span.End()
+
 	var order OrderRow
 
 	query := `SELECT id, user_id, num, status, accrual, uploaded_at 
@@ -159,16 +333,112 @@ func (p *OrderRepo) GetOrderByNumber(ctx context.Context, orderNumber int64) (Or
 	return order, nil
 }
 
-func (p *OrderRepo) UpdateStatus(ctx context.Context, orderNumber int64, orderStatus string) error {
+// Удаление заказа пользователем вместе с историей статусов, событие об удалении пишется в той же транзакции
+// заказ удаляется, только если он принадлежит пользователю и его текущий статус один из from
+// возвращает false, если удалять нечего
+func (p *OrderRepo) Delete(ctx context.Context, userID int64, orderNumber string, from []string) (bool, error) {
+	ctx, span := tracing.Start(ctx, "OrderRepo.Delete")
+	defer span.End()
+
+	tx, err := p.storage.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("OrderRepo Delete | BeginTx: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `DELETE FROM orders 
+			  WHERE num = $1 AND user_id = $2 AND status = ANY($3)`
+
+	res, err := tx.ExecContext(ctx, query, orderNumber, userID, from)
+	if err != nil {
+		return false, fmt.Errorf("OrderRepo Delete: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("OrderRepo Delete | RowsAffected: %w", err)
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	// история удаляется, чтобы при повторной загрузке номера она началась заново
+	query = `DELETE FROM order_status_history WHERE order_num = $1`
+	_, err = tx.ExecContext(ctx, query, orderNumber)
+	if err != nil {
+		return false, fmt.Errorf("OrderRepo Delete | history: %w", err)
+	}
+
+	err = addDomainEvent(ctx, tx, OutboxRow{Type: constants.EventOrderCancelled, UserID: userID, OrderNumber: orderNumber})
+	if err != nil {
+		return false, fmt.Errorf("OrderRepo Delete: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, fmt.Errorf("OrderRepo Delete | Commit: %w", err)
+	}
 
-	query := `UPDATE orders SET status = &1 
-			  WHERE num = $2`
+	return true, nil
+}
+
+// Смена статуса заказа с записью в историю статусов
+// статус меняется, только если текущий статус один из from, иначе возвращается ErrTransitionRejected
+// accrualStatus - статус, полученный от системы расчета (пустой, если смена не от Accrual)
+// source - кто меняет статус, constants.StatusSource*; запись аудита из WithAudit сохраняется в той же транзакции
+func (p *OrderRepo) UpdateStatus(ctx context.Context, orderNumber string, from []string, orderStatus string, accrualStatus string, source string) error {
+	ctx, span := tracing.Start(ctx, "OrderRepo.UpdateStatus")
+	defer span.End()
 
-	err := p.storage.retryExec(ctx, query, orderNumber, orderStatus)
+	tx, err := p.storage.db.BeginTx(ctx, nil)
 	if err != nil {
-		logger.Log().Error(fmt.Sprintf("order %v is not update", orderNumber))
+		return fmt.Errorf("UpdateStatus | BeginTx: %w", err)
+	}
+	defer tx.Rollback()
+
+	// прежние статусы нужны, чтобы не записывать и не сообщать о смене статуса на тот же самый:
+	// Accrual, пока считает начисление, на каждый опрос отвечает PROCESSING
+	query := `UPDATE orders o SET status = $1
+			  FROM (SELECT id, status FROM orders WHERE num = $2 FOR UPDATE) prev
+			  WHERE o.id = prev.id AND prev.status = ANY($3)
+			  RETURNING o.user_id, prev.status,
+			      COALESCE((SELECT accrual_status FROM order_status_history
+			                WHERE order_num = $2 ORDER BY id DESC LIMIT 1), '')`
+
+	var userID int64
+	var prevStatus, prevAccrualStatus string
+	err = tx.QueryRowContext(ctx, query, orderStatus, orderNumber, from).Scan(&userID, &prevStatus, &prevAccrualStatus)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("UpdateStatus %v: %w", orderNumber, ErrTransitionRejected)
+	}
+	if err != nil {
+		logger.Log().WithContext(ctx).Error(fmt.Sprintf("order %v is not update", orderNumber))
 		return fmt.Errorf("order is not update")
 	}
 
-	return nil
+	if prevStatus != orderStatus || prevAccrualStatus != accrualStatus {
+		err = addStatusHistory(ctx, tx, orderNumber, orderStatus, accrualStatus, source)
+		if err != nil {
+			return fmt.Errorf("UpdateStatus: %w", err)
+		}
+	}
+
+	if prevStatus != orderStatus {
+		err = addDomainEvent(ctx, tx, OutboxRow{
+			Type:        constants.EventOrderStatusChanged,
+			UserID:      userID,
+			OrderNumber: orderNumber,
+			Status:      orderStatus,
+		})
+		if err != nil {
+			return fmt.Errorf("UpdateStatus: %w", err)
+		}
+	}
+
+	err = addAudit(ctx, tx)
+	if err != nil {
+		return fmt.Errorf("UpdateStatus: %w", err)
+	}
+
+	return tx.Commit()
 }
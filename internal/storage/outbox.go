@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	"github.com/dnsoftware/gophermart2/internal/tracing"
+	"time"
+)
+
+type OutboxRepo struct {
+	storage *MartStorage
+}
+
+// OutboxRow доменное событие в очереди на рассылку получателям
+// набор заполненных полей зависит от типа события
+type OutboxRow struct {
+	ID          int64
+	Type        string
+	UserID      int64
+	Login       string // заполняется при чтении
+	OrderNumber string
+	Status      string
+	Amount      float32
+	CreatedAt   time.Time
+}
+
+func NewOutboxRepo(storage *MartStorage) *OutboxRepo {
+
+	repo := OutboxRepo{
+		storage: storage,
+	}
+
+	return &repo
+}
+
+// Запись доменного события в той же транзакции, что и изменение, о котором оно сообщает
+// так событие не теряется при сбое между изменением и рассылкой
+func addDomainEvent(ctx context.Context, db execer, e OutboxRow) error {
+
+	query := `INSERT INTO domain_events (event_type, user_id, order_number, status, amount, created_at)
+			  VALUES ($1, $2, $3, $4, $5, now())`
+
+	_, err := db.ExecContext(ctx, query, e.Type, e.UserID, e.OrderNumber, e.Status, e.Amount)
+	if err != nil {
+		return fmt.Errorf("addDomainEvent %v: %w", e.Type, err)
+	}
+
+	return nil
+}
+
+// События, еще не обработанные получателем sink, в порядке возникновения, не более limit
+func (p *OutboxRepo) PendingEvents(ctx context.Context, sink string, limit int) ([]OutboxRow, error) {
+	ctx, span := tracing.Start(ctx, "OutboxRepo.PendingEvents")
+	defer span.End()
+
+	events := make([]OutboxRow, 0)
+
+	query := `SELECT e.id, e.event_type, e.user_id, COALESCE(u.login, ''), e.order_number, e.status, e.amount, e.created_at
+			  FROM domain_events e
+			  LEFT JOIN users u ON u.id = e.user_id
+			  WHERE NOT EXISTS (
+			      SELECT 1 FROM domain_event_sinks s WHERE s.sink = $1 AND s.event_id = e.id
+			  )
+			  ORDER BY e.id ASC
+			  LIMIT $2`
+	rows, err := p.storage.db.QueryContext(ctx, query, sink, limit)
+	if err != nil {
+		return nil, fmt.Errorf("PendingEvents error: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e OutboxRow
+		err = rows.Scan(&e.ID, &e.Type, &e.UserID, &e.Login, &e.OrderNumber, &e.Status, &e.Amount, &e.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("PendingEvents rows.Next: %w", err)
+		}
+
+		events = append(events, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("PendingEvents rows.Err: %w", err)
+	}
+
+	return events, nil
+}
+
+// События пользователя с типами из eventTypes и ID больше afterID в порядке возрастания, не более limit
+// используются для продолжения потока событий клиента (Last-Event-ID)
+func (p *OutboxRepo) UserEvents(ctx context.Context, userID int64, afterID int64, eventTypes []string, limit int) ([]OutboxRow, error) {
+	ctx, span := tracing.Start(ctx, "OutboxRepo.UserEvents")
+	defer span.End()
+
+	events := make([]OutboxRow, 0)
+
+	query := `SELECT id, event_type, user_id, order_number, status, amount, created_at
+			  FROM domain_events
+			  WHERE user_id = $1 AND id > $2 AND event_type = ANY($3)
+			  ORDER BY id ASC
+			  LIMIT $4`
+	rows, err := p.storage.db.QueryContext(ctx, query, userID, afterID, eventTypes, limit)
+	if err != nil {
+		return nil, fmt.Errorf("UserEvents error: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e OutboxRow
+		err = rows.Scan(&e.ID, &e.Type, &e.UserID, &e.OrderNumber, &e.Status, &e.Amount, &e.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("UserEvents rows.Next: %w", err)
+		}
+
+		events = append(events, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("UserEvents rows.Err: %w", err)
+	}
+
+	return events, nil
+}
+
+// ID последнего события пользователя из eventTypes, 0 - событий нет
+func (p *OutboxRepo) LastUserEventID(ctx context.Context, userID int64, eventTypes []string) (int64, error) {
+	ctx, span := tracing.Start(ctx, "OutboxRepo.LastUserEventID")
+	defer span.End()
+
+	var id int64
+	query := `SELECT COALESCE(MAX(id), 0) FROM domain_events WHERE user_id = $1 AND event_type = ANY($2)`
+	err := p.storage.db.QueryRowContext(ctx, query, userID, eventTypes).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("LastUserEventID: %w", err)
+	}
+
+	return id, nil
+}
+
+// Отметка об обработке события получателем sink
+func (p *OutboxRepo) MarkHandled(ctx context.Context, sink string, eventID int64) error {
+	ctx, span := tracing.Start(ctx, "OutboxRepo.MarkHandled")
+	defer span.End()
+
+	query := `INSERT INTO domain_event_sinks (sink, event_id, handled_at)
+			  VALUES ($1, $2, now())
+			  ON CONFLICT (sink, event_id) DO NOTHING`
+
+	err := p.storage.retryExec(ctx, query, sink, eventID)
+	if err != nil {
+		return fmt.Errorf("MarkHandled: %w", err)
+	}
+
+	return nil
+}
+
+// Удаление событий старше before вместе с отметками об обработке
+// удаляются только события, обработанные всеми получателями sinks, по которым не осталось ожидающих доставок вебхуков:
+// доставка читает данные события из очереди и без него навсегда осталась бы в ожидании
+// возвращает кол-во удаленных событий
+func (p *OutboxRepo) PurgeOutbox(ctx context.Context, before time.Time, sinks []string) (int64, error) {
+	ctx, span := tracing.Start(ctx, "OutboxRepo.PurgeOutbox")
+	defer span.End()
+
+	query := `DELETE FROM domain_events e
+			  WHERE e.created_at < $1
+			    AND (SELECT count(*) FROM domain_event_sinks s WHERE s.event_id = e.id AND s.sink = ANY($2)) = cardinality($2::text[])
+			    AND NOT EXISTS (
+			        SELECT 1 FROM webhook_deliveries d WHERE d.event_id = e.id AND d.status = $3
+			    )`
+
+	res, err := p.storage.retryExecResult(ctx, query, before, sinks, constants.DeliveryPending)
+	if err != nil {
+		return 0, fmt.Errorf("PurgeOutbox: %w", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("PurgeOutbox | RowsAffected: %w", err)
+	}
+
+	return count, nil
+}
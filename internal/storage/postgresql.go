@@ -7,9 +7,11 @@ import (
 	"fmt"
 	"github.com/dnsoftware/gophermart2/internal/constants"
 	"github.com/dnsoftware/gophermart2/internal/logger"
+	"github.com/dnsoftware/gophermart2/internal/tracing"
 	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jackc/pgx/v5/stdlib"
 	"strings"
 	"time"
 )
@@ -18,15 +20,20 @@ type MartStorage struct {
 	db *sql.DB
 }
 
-func NewMartStorage(dsn string) (*MartStorage, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), constants.DBContextTimeout)
+// connectTimeout - время на подключение к БД и подготовку таблиц
+func NewMartStorage(dsn string, connectTimeout time.Duration) (*MartStorage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
 	defer cancel()
 
-	db, err := sql.Open("pgx", dsn)
+	// открываем через конфигурацию pgx, чтобы каждый запрос попадал в трассировку
+	connConfig, err := pgx.ParseConfig(dsn)
 	if err != nil {
-		logger.Log().Error(err.Error())
+		logger.Log().WithContext(ctx).Error(err.Error())
 		return nil, err
 	}
+	connConfig.Tracer = queryTracer{}
+
+	db := stdlib.OpenDB(*connConfig)
 
 	ps := &MartStorage{
 		db: db,
@@ -41,6 +48,11 @@ func NewMartStorage(dsn string) (*MartStorage, error) {
 	return ps, nil
 }
 
+// DB пул соединений, нужен для сбора статистики
+func (p *MartStorage) DB() *sql.DB {
+	return p.db
+}
+
 // формирование структуры БД
 func (p *MartStorage) createDatabaseTables(ctx context.Context) error {
 	var query string
@@ -52,19 +64,50 @@ func (p *MartStorage) createDatabaseTables(ctx context.Context) error {
 			    login character varying(128) UNIQUE NOT NULL,
 			    password character varying(64) NOT NULL,
 			    updated_at timestamp with time zone NOT NULL
-			)`
+			);
+
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS sessions_reset_at timestamp with time zone;
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS created_at timestamp with time zone NOT NULL DEFAULT now();
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS last_login_at timestamp with time zone;
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS display_name character varying(128);
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS email character varying(255);
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS locale character varying(16);
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_requested_at timestamp with time zone;
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at timestamp with time zone;
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS role character varying(16) NOT NULL DEFAULT 'user';
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS blocked_at timestamp with time zone;
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS block_reason text;`
 
 	err := p.retryExec(ctx, query)
 	if err != nil {
 		return err
 	}
 
+	// password_resets
+	query = `CREATE TABLE IF NOT EXISTS password_resets
+			(
+			    id SERIAL PRIMARY KEY,
+			    user_id integer NOT NULL,
+			    token_hash character varying(64) UNIQUE NOT NULL,
+			    expires_at timestamp with time zone NOT NULL,
+			    used_at timestamp with time zone,
+			    created_at timestamp with time zone NOT NULL
+			);
+
+			CREATE INDEX IF NOT EXISTS password_resets_user_id_index
+				ON password_resets (user_id);`
+
+	err = p.retryExec(ctx, query)
+	if err != nil {
+		return err
+	}
+
 	// orders
 	query = `CREATE TABLE IF NOT EXISTS orders
 			(
 			    id SERIAL PRIMARY KEY,
 			    user_id integer NOT NULL, 
-			    num bigint UNIQUE NOT NULL,
+			    num text UNIQUE NOT NULL,
 			    status character varying(16) NOT NULL,
     			accrual numeric(10,2) DEFAULT 0,
 			    uploaded_at timestamp with time zone NOT NULL
@@ -75,7 +118,78 @@ func (p *MartStorage) createDatabaseTables(ctx context.Context) error {
 			CREATE INDEX IF NOT EXISTS orders_status_index
 				ON orders (status);
 			CREATE INDEX IF NOT EXISTS orders_uploaded_at_index
-				ON orders (uploaded_at);`
+				ON orders (uploaded_at);
+			CREATE INDEX IF NOT EXISTS orders_user_page_index
+				ON orders (user_id, uploaded_at, id);
+
+			-- ключ API партнера, загрузившего заказ; NULL - заказ загрузил сам пользователь
+			ALTER TABLE orders ADD COLUMN IF NOT EXISTS api_key_id integer;`
+
+	err = p.retryExec(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	err = p.migrateOrderNumberColumn(ctx, "orders", "num")
+	if err != nil {
+		return err
+	}
+
+	// order_status_history
+	query = `CREATE TABLE IF NOT EXISTS order_status_history
+			(
+			    id SERIAL PRIMARY KEY,
+			    order_num text NOT NULL,
+			    status character varying(16) NOT NULL,
+			    accrual_status character varying(16) NOT NULL DEFAULT '',
+			    source character varying(16) NOT NULL,
+			    created_at timestamp with time zone NOT NULL
+			);
+
+			CREATE INDEX IF NOT EXISTS order_status_history_order_num_index
+				ON order_status_history (order_num);`
+
+	err = p.retryExec(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	err = p.migrateOrderNumberColumn(ctx, "order_status_history", "order_num")
+	if err != nil {
+		return err
+	}
+
+	// для заказов, загруженных до появления истории, известна только загрузка
+	query = `INSERT INTO order_status_history (order_num, status, accrual_status, source, created_at)
+			  SELECT o.num, 'NEW', '', 'user', o.uploaded_at FROM orders o
+			  WHERE NOT EXISTS (SELECT 1 FROM order_status_history h WHERE h.order_num = o.num)`
+
+	err = p.retryExec(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	// order_disputes
+	query = `CREATE TABLE IF NOT EXISTS order_disputes
+			(
+			    id SERIAL PRIMARY KEY,
+			    order_num text NOT NULL,
+			    user_id integer NOT NULL,
+			    owner_id integer NOT NULL,
+			    comment text NOT NULL DEFAULT '',
+			    status character varying(16) NOT NULL,
+			    resolution text NOT NULL DEFAULT '',
+			    created_at timestamp with time zone NOT NULL,
+			    decided_by integer,
+			    decided_at timestamp with time zone
+			);
+
+			CREATE INDEX IF NOT EXISTS order_disputes_user_id_index
+				ON order_disputes (user_id);
+			CREATE INDEX IF NOT EXISTS order_disputes_status_index
+				ON order_disputes (status);
+			CREATE UNIQUE INDEX IF NOT EXISTS order_disputes_pending_index
+				ON order_disputes (order_num, user_id) WHERE status = 'PENDING';`
 
 	err = p.retryExec(ctx, query)
 	if err != nil {
@@ -87,7 +201,7 @@ func (p *MartStorage) createDatabaseTables(ctx context.Context) error {
 			(
 			    id SERIAL PRIMARY KEY,
 			    user_id integer NOT NULL, 
-			    order_number bigint NOT NULL,
+			    order_number text NOT NULL,
     			amount numeric(10,2) DEFAULT 0,
 			    processed_at timestamp with time zone NOT NULL
 			); 
@@ -97,20 +211,274 @@ func (p *MartStorage) createDatabaseTables(ctx context.Context) error {
 			CREATE INDEX IF NOT EXISTS balances_order_number_index
 				ON balances (order_number);
 			CREATE INDEX IF NOT EXISTS balances_processed_at_index
-				ON balances (processed_at);`
+				ON balances (processed_at);
+
+			ALTER TABLE balances ADD COLUMN IF NOT EXISTS operation character varying(16) NOT NULL DEFAULT '';
+			ALTER TABLE balances ADD COLUMN IF NOT EXISTS adjustment_id integer;
+			UPDATE balances SET operation = CASE WHEN amount < 0 THEN 'withdrawal' ELSE 'accrual' END
+				WHERE operation = '';`
 
 	err = p.retryExec(ctx, query)
 	if err != nil {
 		return err
 	}
 
+	err = p.migrateOrderNumberColumn(ctx, "balances", "order_number")
+	if err != nil {
+		return err
+	}
+
+	// balance_adjustments
+	query = `CREATE TABLE IF NOT EXISTS balance_adjustments
+			(
+			    id SERIAL PRIMARY KEY,
+			    user_id integer NOT NULL,
+			    order_number text NOT NULL DEFAULT '',
+			    amount numeric(10,2) NOT NULL,
+			    reason_code character varying(32) NOT NULL,
+			    comment text NOT NULL DEFAULT '',
+			    status character varying(16) NOT NULL,
+			    created_by integer NOT NULL,
+			    created_at timestamp with time zone NOT NULL,
+			    decided_by integer,
+			    decided_at timestamp with time zone
+			);
+
+			CREATE INDEX IF NOT EXISTS balance_adjustments_user_id_index
+				ON balance_adjustments (user_id);
+			CREATE INDEX IF NOT EXISTS balance_adjustments_status_index
+				ON balance_adjustments (status);`
+
+	err = p.retryExec(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	err = p.migrateOrderNumberColumn(ctx, "balance_adjustments", "order_number")
+	if err != nil {
+		return err
+	}
+
+	query = `ALTER TABLE balance_adjustments ALTER COLUMN order_number SET DEFAULT ''`
+
+	err = p.retryExec(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	// api_keys
+	query = `CREATE TABLE IF NOT EXISTS api_keys
+			(
+			    id SERIAL PRIMARY KEY,
+			    name character varying(128) NOT NULL,
+			    key_id character varying(16) UNIQUE NOT NULL,
+			    key_hash character varying(64) NOT NULL,
+			    scopes text NOT NULL DEFAULT '',
+			    rate_limit integer NOT NULL,
+			    created_by integer NOT NULL,
+			    created_at timestamp with time zone NOT NULL,
+			    rotated_at timestamp with time zone,
+			    revoked_at timestamp with time zone
+			);`
+
+	err = p.retryExec(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	query = `ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS signing_key character varying(128) NOT NULL DEFAULT ''`
+
+	err = p.retryExec(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	// domain_events, domain_event_sinks
+	query = `CREATE TABLE IF NOT EXISTS domain_events
+			(
+			    id BIGSERIAL PRIMARY KEY,
+			    event_type character varying(32) NOT NULL,
+			    user_id integer NOT NULL,
+			    order_number text NOT NULL DEFAULT '',
+			    status character varying(16) NOT NULL DEFAULT '',
+			    amount double precision NOT NULL DEFAULT 0,
+			    created_at timestamp with time zone NOT NULL
+			);
+
+			CREATE INDEX IF NOT EXISTS domain_events_created_at_index
+				ON domain_events (created_at);
+			CREATE INDEX IF NOT EXISTS domain_events_user_id_index
+				ON domain_events (user_id, id);
+
+			CREATE TABLE IF NOT EXISTS domain_event_sinks
+			(
+			    sink character varying(32) NOT NULL,
+			    event_id bigint NOT NULL REFERENCES domain_events (id) ON DELETE CASCADE,
+			    handled_at timestamp with time zone NOT NULL,
+			    PRIMARY KEY (sink, event_id)
+			);`
+
+	err = p.retryExec(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	// webhook_subscriptions, webhook_deliveries
+	query = `CREATE TABLE IF NOT EXISTS webhook_subscriptions
+			(
+			    id SERIAL PRIMARY KEY,
+			    api_key_id integer NOT NULL,
+			    url character varying(2048) NOT NULL,
+			    event_types text NOT NULL,
+			    secret character varying(128) NOT NULL,
+			    created_at timestamp with time zone NOT NULL,
+			    deleted_at timestamp with time zone
+			);
+
+			CREATE INDEX IF NOT EXISTS webhook_subscriptions_api_key_id_index
+				ON webhook_subscriptions (api_key_id);
+
+			CREATE TABLE IF NOT EXISTS webhook_deliveries
+			(
+			    id BIGSERIAL PRIMARY KEY,
+			    subscription_id integer NOT NULL,
+			    event_id bigint NOT NULL,
+			    event_type character varying(32) NOT NULL,
+			    status character varying(16) NOT NULL,
+			    attempts integer NOT NULL DEFAULT 0,
+			    response_code integer NOT NULL DEFAULT 0,
+			    last_error text NOT NULL DEFAULT '',
+			    created_at timestamp with time zone NOT NULL,
+			    next_attempt_at timestamp with time zone NOT NULL,
+			    last_attempt_at timestamp with time zone,
+			    UNIQUE (subscription_id, event_id)
+			);
+
+			CREATE INDEX IF NOT EXISTS webhook_deliveries_due_index
+				ON webhook_deliveries (next_attempt_at) WHERE status = 'PENDING';`
+
+	err = p.retryExec(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	// admin_audit
+	query = `CREATE TABLE IF NOT EXISTS admin_audit
+			(
+			    id SERIAL PRIMARY KEY,
+			    admin_id integer NOT NULL,
+			    action character varying(64) NOT NULL,
+			    target character varying(64) NOT NULL,
+			    details text NOT NULL DEFAULT '',
+			    created_at timestamp with time zone NOT NULL
+			);
+
+			CREATE INDEX IF NOT EXISTS admin_audit_admin_id_index
+				ON admin_audit (admin_id);
+			CREATE INDEX IF NOT EXISTS admin_audit_created_at_index
+				ON admin_audit (created_at);`
+
+	err = p.retryExec(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	// версия структуры БД, записывается последней: если она есть, все предыдущие шаги выполнены
+	query = `CREATE TABLE IF NOT EXISTS schema_version
+			(
+			    id integer PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+			    version integer NOT NULL,
+			    updated_at timestamp with time zone NOT NULL
+			);`
+
+	err = p.retryExec(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	query = `INSERT INTO schema_version (id, version, updated_at) VALUES (1, $1, now())
+			 ON CONFLICT (id) DO UPDATE SET version = GREATEST(schema_version.version, EXCLUDED.version), updated_at = now()`
+
+	err = p.retryExec(ctx, query, constants.SchemaVersion)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Ping проверка доступности БД
+func (p *MartStorage) Ping(ctx context.Context) error {
+	ctx, span := tracing.Start(ctx, "MartStorage.Ping")
+	defer span.End()
+
+	return p.db.PingContext(ctx)
+}
+
+// SchemaVersion версия структуры БД, 0 - структура не создана
+func (p *MartStorage) SchemaVersion(ctx context.Context) (int, error) {
+	ctx, span := tracing.Start(ctx, "MartStorage.SchemaVersion")
+	defer span.End()
+
+	var version int
+	err := p.db.QueryRowContext(ctx, `SELECT version FROM schema_version WHERE id = 1`).Scan(&version)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.Is(err, sql.ErrNoRows) || (errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UndefinedTable) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("SchemaVersion: %w", err)
+	}
+
+	return version, nil
+}
+
+// Stats статистика пула соединений
+func (p *MartStorage) Stats() sql.DBStats {
+	return p.db.Stats()
+}
+
+// migrateOrderNumberColumn перевод колонки с номером заказа из bigint в text
+// номера длиннее int64 в bigint не помещаются; нулевой номер (заказ не указан) становится пустой строкой
+func (p *MartStorage) migrateOrderNumberColumn(ctx context.Context, table string, column string) error {
+	var dataType string
+
+	query := `SELECT data_type FROM information_schema.columns WHERE table_name = $1 AND column_name = $2`
+	err := p.db.QueryRowContext(ctx, query, table, column).Scan(&dataType)
+	if err != nil {
+		return fmt.Errorf("migrateOrderNumberColumn %v.%v: %w", table, column, err)
+	}
+
+	if dataType != "bigint" {
+		return nil
+	}
+
+	query = fmt.Sprintf(`ALTER TABLE %[1]s ALTER COLUMN %[2]s DROP DEFAULT;
+			ALTER TABLE %[1]s ALTER COLUMN %[2]s TYPE text
+				USING CASE WHEN %[2]s = 0 THEN '' ELSE %[2]s::text END;`, table, column)
+
+	err = p.retryExec(ctx, query)
+	if err != nil {
+		return fmt.Errorf("migrateOrderNumberColumn %v.%v: %w", table, column, err)
+	}
+
+	logger.Log().WithContext(ctx).Info(fmt.Sprintf("Колонка %v.%v переведена в text", table, column))
+
 	return nil
 }
 
 func (p *MartStorage) retryExec(ctx context.Context, query string, args ...any) error {
+	_, err := p.retryExecResult(ctx, query, args...)
+
+	return err
+}
+
+// retryExecResult то же, что retryExec, но возвращает результат выполнения запроса
+// (например, для получения количества затронутых строк)
+func (p *MartStorage) retryExecResult(ctx context.Context, query string, args ...any) (sql.Result, error) {
 	durations := strings.Split(constants.HTTPAttemtPeriods, ",")
 
-	_, err := p.db.ExecContext(ctx, query, args...)
+	res, err := p.db.ExecContext(ctx, query, args...)
 
 	var pgErr *pgconn.PgError
 	if errors.As(err, &pgErr) && pgerrcode.IsConnectionException(pgErr.Code) {
@@ -118,20 +486,20 @@ func (p *MartStorage) retryExec(ctx context.Context, query string, args ...any)
 			d, _ := time.ParseDuration(duration)
 			time.Sleep(d)
 
-			_, err = p.db.ExecContext(ctx, query, args...)
+			res, err = p.db.ExecContext(ctx, query, args...)
 			if err == nil {
 				break
 			}
 		}
 
 		if err != nil {
-			return fmt.Errorf("retryExec | ConnectionException: %w", err)
+			return nil, fmt.Errorf("retryExec | ConnectionException: %w", err)
 		}
 	}
 
 	if err != nil {
-		return fmt.Errorf("retryExec: %w", err)
+		return nil, fmt.Errorf("retryExec: %w", err)
 	}
 
-	return nil
+	return res, nil
 }
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"context"
+	"strings"
+
+	"github.com/dnsoftware/gophermart2/internal/tracing"
+	"github.com/jackc/pgx/v5"
+	semconv "go.opentelemetry.io/otel/semconv/v1.25.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// queryTracer спан на каждый SQL запрос, дочерний к спану метода репозитория
+type queryTracer struct{}
+
+func (queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	ctx, _ = tracing.Start(ctx, "SQL "+sqlOperation(data.SQL),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBStatement(data.SQL),
+		),
+	)
+
+	return ctx
+}
+
+func (queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	if data.Err != nil && data.Err != pgx.ErrNoRows {
+		tracing.Error(span, data.Err)
+	}
+	span.End()
+}
+
+// первое слово запроса (SELECT, INSERT, WITH...) для имени спана
+func sqlOperation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return ""
+	}
+
+	return strings.ToUpper(fields[0])
+}
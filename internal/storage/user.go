@@ -6,8 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"github.com/dnsoftware/gophermart2/internal/constants"
+	"github.com/dnsoftware/gophermart2/internal/tracing"
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5/pgconn"
+	"time"
 )
 
 type UserRepo struct {
@@ -15,9 +17,17 @@ type UserRepo struct {
 }
 
 type UserRow struct {
-	ID       int64
-	Login    string
-	Password string
+	ID          int64
+	Login       string
+	Password    string
+	Role        string
+	DisplayName string
+	Email       string
+	Locale      string
+	CreatedAt   time.Time
+	LastLoginAt time.Time // нулевое время, если пользователь ни разу не входил
+	DeletionAt  time.Time // когда запрошено удаление аккаунта, нулевое время - не запрошено
+	BlockedAt   time.Time // когда пользователь заблокирован, нулевое время - не заблокирован
 }
 
 func NewUserRepo(storage *MartStorage) *UserRepo {
@@ -32,13 +42,23 @@ func NewUserRepo(storage *MartStorage) *UserRepo {
 // Создание пользователя
 // возвращает вторым параметром статус-код операции
 func (p *UserRepo) Create(ctx context.Context, login string, password string) (int64, int, error) {
+	ctx, span := tracing.Start(ctx, "UserRepo.Create")
+	defer span.End()
+
+	status := constants.RegisterInternalError
+
+	tx, err := p.storage.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, status, fmt.Errorf("UserRepo Create | BeginTx: %w", err)
+	}
+	defer tx.Rollback()
 
 	query := `INSERT INTO users (login, password, updated_at)
 			  VALUES ($1, $2, now()) RETURNING id`
 
-	status := constants.RegisterInternalError
+	var id int64
 
-	err := p.storage.retryExec(ctx, query, login, password)
+	err = tx.QueryRowContext(ctx, query, login, password).Scan(&id)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
@@ -49,34 +69,117 @@ func (p *UserRepo) Create(ctx context.Context, login string, password string) (i
 		return 0, status, fmt.Errorf("UserRepo Create: %w", err)
 	}
 
-	query = `SELECT currval('users_id_seq')`
-	row := p.storage.db.QueryRowContext(ctx, query)
-
-	var id int64
+	err = addDomainEvent(ctx, tx, OutboxRow{Type: constants.EventUserRegistered, UserID: id})
+	if err != nil {
+		return 0, status, fmt.Errorf("UserRepo Create: %w", err)
+	}
 
-	err = row.Scan(&id)
+	err = tx.Commit()
 	if err != nil {
-		return 0, status, fmt.Errorf("UserRepo Create | GetAutoInc: %w", err)
+		return 0, status, fmt.Errorf("UserRepo Create | Commit: %w", err)
 	}
 
 	return id, constants.RegisterOk, nil
 }
 
+// Получение пользователя по ID
+// возвращает sql.ErrNoRows (обернутую), если пользователь не найден
 func (p *UserRepo) FindByID(ctx context.Context, id int64) (UserRow, error) {
+	ctx, span := tracing.Start(ctx, "UserRepo.FindByID")
+	defer span.End()
+
+	query := `SELECT id, login, password, role, display_name, email, locale, created_at, last_login_at,
+				deletion_requested_at, blocked_at
+			  FROM users WHERE id = $1`
+	row := p.storage.db.QueryRowContext(ctx, query, id)
+
+	var item UserRow
+	var displayName, email, locale sql.NullString
+	var lastLoginAt, deletionAt, blockedAt sql.NullTime
+
+	err := row.Scan(&item.ID, &item.Login, &item.Password, &item.Role, &displayName, &email, &locale,
+		&item.CreatedAt, &lastLoginAt, &deletionAt, &blockedAt)
+	if err != nil {
+		return UserRow{}, fmt.Errorf("FindByID Scan: %w", err)
+	}
 
-	item := UserRow{}
+	item.DisplayName = displayName.String
+	item.Email = email.String
+	item.Locale = locale.String
+	item.LastLoginAt = lastLoginAt.Time
+	item.DeletionAt = deletionAt.Time
+	item.BlockedAt = blockedAt.Time
 
 	return item, nil
 }
 
+// Количество загруженных заказов и списаний пользователя
+func (p *UserRepo) ActivityCounts(ctx context.Context, id int64) (int64, int64, error) {
+	ctx, span := tracing.Start(ctx, "UserRepo.ActivityCounts")
+	defer span.End()
+
+	query := `SELECT
+				(SELECT COUNT(*) FROM orders WHERE user_id = $1),
+				(SELECT COUNT(*) FROM balances WHERE user_id = $1 AND operation = 'withdrawal')`
+	row := p.storage.db.QueryRowContext(ctx, query, id)
+
+	var orders, withdrawals int64
+
+	err := row.Scan(&orders, &withdrawals)
+	if err != nil {
+		return 0, 0, fmt.Errorf("ActivityCounts Scan: %w", err)
+	}
+
+	return orders, withdrawals, nil
+}
+
+// Обновление редактируемых полей профиля
+// nil означает, что поле не меняется
+func (p *UserRepo) UpdateProfile(ctx context.Context, id int64, displayName *string, email *string, locale *string) error {
+	ctx, span := tracing.Start(ctx, "UserRepo.UpdateProfile")
+	defer span.End()
+
+	query := `UPDATE users SET
+				display_name = COALESCE($1, display_name),
+				email = COALESCE($2, email),
+				locale = COALESCE($3, locale),
+				updated_at = now()
+			  WHERE id = $4`
+
+	err := p.storage.retryExec(ctx, query, displayName, email, locale, id)
+	if err != nil {
+		return fmt.Errorf("UpdateProfile: %w", err)
+	}
+
+	return nil
+}
+
+// Фиксация времени последнего входа
+func (p *UserRepo) UpdateLastLogin(ctx context.Context, id int64) error {
+	ctx, span := tracing.Start(ctx, "UserRepo.UpdateLastLogin")
+	defer span.End()
+
+	query := `UPDATE users SET last_login_at = now() WHERE id = $1`
+
+	err := p.storage.retryExec(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("UpdateLastLogin: %w", err)
+	}
+
+	return nil
+}
+
 func (p *UserRepo) FindByLoginPassword(ctx context.Context, login string, password string) (int64, int, error) {
+	ctx, span := tracing.Start(ctx, "UserRepo.FindByLoginPassword")
+	defer span.End()
 
-	query := `SELECT id FROM users WHERE login = $1 AND password = $2`
+	query := `SELECT id, blocked_at IS NOT NULL FROM users WHERE login = $1 AND password = $2`
 	row := p.storage.db.QueryRowContext(ctx, query, login, password)
 
 	var id int64
+	var blocked bool
 
-	err := row.Scan(&id)
+	err := row.Scan(&id, &blocked)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return 0, constants.LoginBadPair, fmt.Errorf("FindByLoginPassword ErrNoRows: %w", err)
@@ -85,5 +188,313 @@ func (p *UserRepo) FindByLoginPassword(ctx context.Context, login string, passwo
 		return 0, constants.LoginInternalError, fmt.Errorf("FindByLoginPassword Scan: %w", err)
 	}
 
+	if blocked {
+		return 0, constants.LoginBlocked, fmt.Errorf("FindByLoginPassword: пользователь %v заблокирован", login)
+	}
+
 	return id, constants.LoginOk, nil
 }
+
+// Получение ID пользователя по логину
+// возвращает false, если пользователь не найден или удален
+func (p *UserRepo) FindIDByLogin(ctx context.Context, login string) (int64, bool, error) {
+	ctx, span := tracing.Start(ctx, "UserRepo.FindIDByLogin")
+	defer span.End()
+
+	query := `SELECT id FROM users WHERE login = $1 AND deleted_at IS NULL`
+	row := p.storage.db.QueryRowContext(ctx, query, login)
+
+	var id int64
+
+	err := row.Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("FindIDByLogin Scan: %w", err)
+	}
+
+	return id, true, nil
+}
+
+// Смена пароля пользователя при совпадении текущего пароля
+// все выданные ранее токены пользователя становятся недействительными
+func (p *UserRepo) UpdatePassword(ctx context.Context, id int64, oldPassword string, newPassword string) (int, error) {
+	ctx, span := tracing.Start(ctx, "UserRepo.UpdatePassword")
+	defer span.End()
+
+	query := `UPDATE users SET password = $1, sessions_reset_at = $4, updated_at = now()
+			  WHERE id = $2 AND password = $3`
+
+	res, err := p.storage.retryExecResult(ctx, query, newPassword, id, oldPassword, sessionsResetAt())
+	if err != nil {
+		return constants.PasswordChangeInternalError, fmt.Errorf("UpdatePassword: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return constants.PasswordChangeInternalError, fmt.Errorf("UpdatePassword | RowsAffected: %w", err)
+	}
+
+	if affected == 0 {
+		return constants.PasswordChangeBadPair, fmt.Errorf("UpdatePassword: неверный текущий пароль")
+	}
+
+	return constants.PasswordChangeOk, nil
+}
+
+// Сохранение токена сброса пароля (хранится только хэш токена)
+// возвращает ID пользователя, 0 если пользователь с таким логином не найден
+func (p *UserRepo) CreatePasswordReset(ctx context.Context, login string, tokenHash string, expiresAt time.Time) (int64, error) {
+	ctx, span := tracing.Start(ctx, "UserRepo.CreatePasswordReset")
+	defer span.End()
+
+	query := `SELECT id FROM users WHERE login = $1`
+	row := p.storage.db.QueryRowContext(ctx, query, login)
+
+	var id int64
+
+	err := row.Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("CreatePasswordReset Scan: %w", err)
+	}
+
+	query = `INSERT INTO password_resets (user_id, token_hash, expires_at, created_at)
+			  VALUES ($1, $2, $3, now())`
+
+	err = p.storage.retryExec(ctx, query, id, tokenHash, expiresAt)
+	if err != nil {
+		return 0, fmt.Errorf("CreatePasswordReset: %w", err)
+	}
+
+	return id, nil
+}
+
+// Установка нового пароля по одноразовому токену сброса
+// токен помечается использованным, все выданные ранее токены пользователя становятся недействительными
+func (p *UserRepo) ResetPassword(ctx context.Context, tokenHash string, newPassword string) (int, error) {
+	ctx, span := tracing.Start(ctx, "UserRepo.ResetPassword")
+	defer span.End()
+
+	tx, err := p.storage.db.BeginTx(ctx, nil)
+	if err != nil {
+		return constants.PasswordResetInternalError, fmt.Errorf("ResetPassword | BeginTx: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `SELECT user_id FROM password_resets
+			  WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+			  FOR UPDATE`
+	row := tx.QueryRowContext(ctx, query, tokenHash)
+
+	var userID int64
+
+	err = row.Scan(&userID)
+	if err == sql.ErrNoRows {
+		return constants.PasswordResetBadToken, fmt.Errorf("ResetPassword: токен недействителен")
+	}
+	if err != nil {
+		return constants.PasswordResetInternalError, fmt.Errorf("ResetPassword Scan: %w", err)
+	}
+
+	// гасим все неиспользованные токены пользователя, включая текущий
+	query = `UPDATE password_resets SET used_at = now()
+			  WHERE user_id = $1 AND used_at IS NULL`
+	_, err = tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return constants.PasswordResetInternalError, fmt.Errorf("ResetPassword | use token: %w", err)
+	}
+
+	query = `UPDATE users SET password = $1, sessions_reset_at = $3, updated_at = now()
+			  WHERE id = $2`
+	_, err = tx.ExecContext(ctx, query, newPassword, userID, sessionsResetAt())
+	if err != nil {
+		return constants.PasswordResetInternalError, fmt.Errorf("ResetPassword | update password: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return constants.PasswordResetInternalError, fmt.Errorf("ResetPassword | Commit: %w", err)
+	}
+
+	return constants.PasswordResetOk, nil
+}
+
+// Момент сброса сессий пользователя по часам приложения, а не базы:
+// с ним сравнивается время выдачи токена, которое ставит приложение с точностью до секунды
+func sessionsResetAt() time.Time {
+	return time.Now().Truncate(time.Second)
+}
+
+// Состояние сессий пользователя: момент, до которого выданные токены считаются недействительными
+// (нулевое время, если пароль ни разу не менялся), и признак блокировки
+func (p *UserRepo) SessionState(ctx context.Context, id int64) (time.Time, bool, error) {
+	ctx, span := tracing.Start(ctx, "UserRepo.SessionState")
+	defer span.End()
+
+	query := `SELECT sessions_reset_at, blocked_at IS NOT NULL FROM users WHERE id = $1`
+	row := p.storage.db.QueryRowContext(ctx, query, id)
+
+	var resetAt sql.NullTime
+	var blocked bool
+
+	err := row.Scan(&resetAt, &blocked)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("SessionState Scan: %w", err)
+	}
+
+	return resetAt.Time, blocked, nil
+}
+
+// Запрос на удаление аккаунта, возвращает время запроса
+// повторный запрос не сдвигает начало льготного периода
+func (p *UserRepo) RequestDeletion(ctx context.Context, id int64) (time.Time, error) {
+	ctx, span := tracing.Start(ctx, "UserRepo.RequestDeletion")
+	defer span.End()
+
+	query := `UPDATE users SET deletion_requested_at = COALESCE(deletion_requested_at, now()), updated_at = now()
+			  WHERE id = $1 AND deleted_at IS NULL
+			  RETURNING deletion_requested_at`
+	row := p.storage.db.QueryRowContext(ctx, query, id)
+
+	var requestedAt time.Time
+
+	err := row.Scan(&requestedAt)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("RequestDeletion Scan: %w", err)
+	}
+
+	return requestedAt, nil
+}
+
+// Отмена запроса на удаление аккаунта
+// возвращает false, если удаление не было запланировано
+func (p *UserRepo) CancelDeletion(ctx context.Context, id int64) (bool, error) {
+	ctx, span := tracing.Start(ctx, "UserRepo.CancelDeletion")
+	defer span.End()
+
+	query := `UPDATE users SET deletion_requested_at = NULL, updated_at = now()
+			  WHERE id = $1 AND deletion_requested_at IS NOT NULL AND deleted_at IS NULL`
+
+	res, err := p.storage.retryExecResult(ctx, query, id)
+	if err != nil {
+		return false, fmt.Errorf("CancelDeletion: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("CancelDeletion | RowsAffected: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+// Обезличивание аккаунтов, удаление которых запрошено раньше requestedBefore
+// заказы и записи баланса остаются нетронутыми для бухгалтерии
+// возвращает количество обезличенных аккаунтов
+func (p *UserRepo) AnonymizeExpired(ctx context.Context, requestedBefore time.Time) (int64, error) {
+	ctx, span := tracing.Start(ctx, "UserRepo.AnonymizeExpired")
+	defer span.End()
+
+	tx, err := p.storage.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("AnonymizeExpired | BeginTx: %w", err)
+	}
+	defer tx.Rollback()
+
+	// пустой пароль не совпадет ни с одним хэшем, вход невозможен
+	// новый логин со случайным UUID не совпадет и с логинами, зарегистрированными до резервирования префикса
+	query := `UPDATE users SET login = $2::text || gen_random_uuid(), password = '',
+				display_name = NULL, email = NULL, locale = NULL,
+				sessions_reset_at = $3, deleted_at = now(), updated_at = now()
+			  WHERE deletion_requested_at < $1 AND deleted_at IS NULL`
+	res, err := tx.ExecContext(ctx, query, requestedBefore, constants.DeletedLoginPrefix, sessionsResetAt())
+	if err != nil {
+		return 0, fmt.Errorf("AnonymizeExpired | users: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("AnonymizeExpired | RowsAffected: %w", err)
+	}
+
+	query = `DELETE FROM password_resets
+			  WHERE user_id IN (SELECT id FROM users WHERE deleted_at IS NOT NULL)`
+	_, err = tx.ExecContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("AnonymizeExpired | password_resets: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, fmt.Errorf("AnonymizeExpired | Commit: %w", err)
+	}
+
+	return affected, nil
+}
+
+// Роль пользователя
+func (p *UserRepo) UserRole(ctx context.Context, id int64) (string, error) {
+	ctx, span := tracing.Start(ctx, "UserRepo.UserRole")
+	defer span.End()
+
+	query := `SELECT role FROM users WHERE id = $1`
+	row := p.storage.db.QueryRowContext(ctx, query, id)
+
+	var role string
+
+	err := row.Scan(&role)
+	if err != nil {
+		return "", fmt.Errorf("UserRole Scan: %w", err)
+	}
+
+	return role, nil
+}
+
+// Смена роли пользователя
+// выданные ранее токены (с прежней ролью) становятся недействительными
+// возвращает false, если пользователь не найден
+func (p *UserRepo) SetRole(ctx context.Context, id int64, role string) (bool, error) {
+	ctx, span := tracing.Start(ctx, "UserRepo.SetRole")
+	defer span.End()
+
+	query := `UPDATE users SET role = $1, sessions_reset_at = $3, updated_at = now()
+			  WHERE id = $2 AND deleted_at IS NULL`
+
+	res, err := p.storage.retryExecResult(ctx, query, role, id, sessionsResetAt())
+	if err != nil {
+		return false, fmt.Errorf("SetRole: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("SetRole | RowsAffected: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+// Смена роли пользователя по логину (для первоначального назначения администратора)
+// если роль уже такая, токены пользователя не сбрасываются
+func (p *UserRepo) SetRoleByLogin(ctx context.Context, login string, role string) (bool, error) {
+	ctx, span := tracing.Start(ctx, "UserRepo.SetRoleByLogin")
+	defer span.End()
+
+	query := `UPDATE users SET role = $1, sessions_reset_at = $3, updated_at = now()
+			  WHERE login = $2 AND role <> $1 AND deleted_at IS NULL`
+
+	res, err := p.storage.retryExecResult(ctx, query, role, login, sessionsResetAt())
+	if err != nil {
+		return false, fmt.Errorf("SetRoleByLogin: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("SetRoleByLogin | RowsAffected: %w", err)
+	}
+
+	return affected > 0, nil
+}
@@ -0,0 +1,369 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	"github.com/dnsoftware/gophermart2/internal/tracing"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+type WebhookRepo struct {
+	storage *MartStorage
+	client  *http.Client
+}
+
+// WebhookRow подписка клиента API на события
+type WebhookRow struct {
+	ID         int64
+	APIKeyID   int64
+	URL        string
+	EventTypes []string
+	Secret     string // ключ подписи HMAC тела запроса
+	CreatedAt  time.Time
+}
+
+// WebhookDeliveryRow доставка события по подписке
+// поля события и подписки заполняются только при взятии доставки в работу
+type WebhookDeliveryRow struct {
+	ID            int64
+	WebhookID     int64
+	EventID       int64
+	EventType     string
+	Status        string
+	Attempts      int
+	ResponseCode  int
+	LastError     string
+	CreatedAt     time.Time
+	NextAttemptAt time.Time
+	LastAttemptAt time.Time // нулевое время, если попыток еще не было
+
+	URL         string
+	Secret      string
+	Login       string
+	OrderNumber string
+	Amount      float32
+	EventAt     time.Time
+}
+
+// ErrWebhookAddress адрес получателя вебхука не публичный
+var ErrWebhookAddress = errors.New("адрес получателя не публичный")
+
+// timeout - время ожидания ответа получателя
+func NewWebhookRepo(storage *MartStorage, timeout time.Duration) *WebhookRepo {
+
+	// адрес проверяется при каждом подключении: имя получателя могли перенаправить на внутренний адрес после подписки
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if !PublicIP(net.ParseIP(host)) {
+				return fmt.Errorf("%v: %w", host, ErrWebhookAddress)
+			}
+			return nil
+		},
+	}
+
+	repo := WebhookRepo{
+		storage: storage,
+		client: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				Proxy:               nil, // прокси из окружения обошел бы проверку адреса
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: timeout,
+			},
+			// перенаправление не выполняется: адрес получателя задается только подпиской
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+
+	return &repo
+}
+
+// Создание подписки, возвращает ID записи
+func (p *WebhookRepo) CreateWebhook(ctx context.Context, w WebhookRow) (int64, error) {
+	ctx, span := tracing.Start(ctx, "WebhookRepo.CreateWebhook")
+	defer span.End()
+
+	query := `INSERT INTO webhook_subscriptions (api_key_id, url, event_types, secret, created_at)
+			  VALUES ($1, $2, $3, $4, now())
+			  RETURNING id`
+	row := p.storage.db.QueryRowContext(ctx, query, w.APIKeyID, w.URL, strings.Join(w.EventTypes, ","), w.Secret)
+
+	var id int64
+
+	err := row.Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("CreateWebhook: %w", err)
+	}
+
+	return id, nil
+}
+
+// Действующие подписки клиента
+func (p *WebhookRepo) WebhookList(ctx context.Context, apiKeyID int64) ([]WebhookRow, error) {
+	ctx, span := tracing.Start(ctx, "WebhookRepo.WebhookList")
+	defer span.End()
+
+	list := make([]WebhookRow, 0)
+
+	query := `SELECT id, api_key_id, url, event_types, created_at
+			  FROM webhook_subscriptions WHERE api_key_id = $1 AND deleted_at IS NULL
+			  ORDER BY id ASC`
+	rows, err := p.storage.db.QueryContext(ctx, query, apiKeyID)
+	if err != nil {
+		return nil, fmt.Errorf("WebhookList error: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var w WebhookRow
+		var eventTypes string
+		err = rows.Scan(&w.ID, &w.APIKeyID, &w.URL, &eventTypes, &w.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("WebhookList rows.Next: %w", err)
+		}
+		w.EventTypes = strings.Split(eventTypes, ",")
+
+		list = append(list, w)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("WebhookList rows.Err: %w", err)
+	}
+
+	return list, nil
+}
+
+// Удаление подписки клиента, недоставленные по ней события больше не отправляются
+// возвращает false, если действующая подписка не найдена
+func (p *WebhookRepo) DeleteWebhook(ctx context.Context, apiKeyID int64, id int64) (bool, error) {
+	ctx, span := tracing.Start(ctx, "WebhookRepo.DeleteWebhook")
+	defer span.End()
+
+	tx, err := p.storage.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("DeleteWebhook | BeginTx: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE webhook_subscriptions SET deleted_at = now()
+			  WHERE id = $1 AND api_key_id = $2 AND deleted_at IS NULL`
+
+	res, err := tx.ExecContext(ctx, query, id, apiKeyID)
+	if err != nil {
+		return false, fmt.Errorf("DeleteWebhook: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("DeleteWebhook | RowsAffected: %w", err)
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	query = `UPDATE webhook_deliveries SET status = $1, last_error = 'подписка удалена'
+			  WHERE subscription_id = $2 AND status = $3`
+	_, err = tx.ExecContext(ctx, query, constants.DeliveryFailed, id, constants.DeliveryPending)
+	if err != nil {
+		return false, fmt.Errorf("DeleteWebhook | deliveries: %w", err)
+	}
+
+	return true, tx.Commit()
+}
+
+// Создание доставок события по подходящим подпискам действующих ключей API
+// события заказа получает только партнер, загрузивший заказ,
+// списания - партнеры, загружавшие заказы этого пользователя; остальным партнерам данные пользователя не передаются
+// повторный вызов для того же события новых доставок не создает
+func (p *WebhookRepo) EnqueueDeliveries(ctx context.Context, eventID int64, eventType string) error {
+	ctx, span := tracing.Start(ctx, "WebhookRepo.EnqueueDeliveries")
+	defer span.End()
+
+	query := `INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, status, created_at, next_attempt_at)
+			  SELECT s.id, $1, $2, $3, now(), now()
+			  FROM webhook_subscriptions s
+			  JOIN api_keys k ON k.id = s.api_key_id
+			  JOIN domain_events e ON e.id = $1
+			  WHERE s.deleted_at IS NULL AND k.revoked_at IS NULL
+			    AND $2 = ANY(string_to_array(s.event_types, ','))
+			    AND CASE WHEN $2 = $4
+			        THEN EXISTS (SELECT 1 FROM orders po WHERE po.user_id = e.user_id AND po.api_key_id = s.api_key_id)
+			        ELSE EXISTS (SELECT 1 FROM orders po WHERE po.num = e.order_number AND po.api_key_id = s.api_key_id)
+			    END
+			  ON CONFLICT (subscription_id, event_id) DO NOTHING`
+
+	err := p.storage.retryExec(ctx, query, eventID, eventType, constants.DeliveryPending, constants.WebhookPointsWithdrawn)
+	if err != nil {
+		return fmt.Errorf("EnqueueDeliveries: %w", err)
+	}
+
+	return nil
+}
+
+// Взятие в работу доставок, время следующей попытки которых наступило
+// взятые доставки откладываются на lease, чтобы их не взял другой экземпляр сервиса;
+// если результат попытки не будет сохранен (сбой), доставка повторится по истечении lease
+func (p *WebhookRepo) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDeliveryRow, error) {
+	ctx, span := tracing.Start(ctx, "WebhookRepo.ClaimDeliveries")
+	defer span.End()
+
+	list := make([]WebhookDeliveryRow, 0)
+
+	query := `WITH due AS (
+			      SELECT id FROM webhook_deliveries
+			      WHERE status = $1 AND next_attempt_at <= now()
+			      ORDER BY next_attempt_at ASC
+			      LIMIT $2
+			      FOR UPDATE SKIP LOCKED
+			  )
+			  UPDATE webhook_deliveries d SET next_attempt_at = now() + make_interval(secs => $3)
+			  FROM due, webhook_subscriptions s, domain_events o
+			  WHERE d.id = due.id AND s.id = d.subscription_id AND o.id = d.event_id
+			  RETURNING d.id, d.subscription_id, d.event_id, d.event_type, d.status, d.attempts, d.created_at,
+			      s.url, s.secret, COALESCE((SELECT login FROM users WHERE id = o.user_id), ''),
+			      o.order_number, o.amount, o.created_at`
+	rows, err := p.storage.db.QueryContext(ctx, query, constants.DeliveryPending, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("ClaimDeliveries error: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d WebhookDeliveryRow
+		err = rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Status, &d.Attempts, &d.CreatedAt,
+			&d.URL, &d.Secret, &d.Login, &d.OrderNumber, &d.Amount, &d.EventAt)
+		if err != nil {
+			return nil, fmt.Errorf("ClaimDeliveries rows.Next: %w", err)
+		}
+
+		list = append(list, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ClaimDeliveries rows.Err: %w", err)
+	}
+
+	return list, nil
+}
+
+// Сохранение результата попытки доставки
+// статус не меняется, если доставка уже не в ожидании (подписка удалена во время отправки)
+func (p *WebhookRepo) FinishDelivery(ctx context.Context, d WebhookDeliveryRow) error {
+	ctx, span := tracing.Start(ctx, "WebhookRepo.FinishDelivery")
+	defer span.End()
+
+	query := `UPDATE webhook_deliveries
+			  SET status = $1, attempts = $2, response_code = $3, last_error = $4, next_attempt_at = $5, last_attempt_at = now()
+			  WHERE id = $6 AND status = $7`
+
+	err := p.storage.retryExec(ctx, query, d.Status, d.Attempts, d.ResponseCode, d.LastError, d.NextAttemptAt,
+		d.ID, constants.DeliveryPending)
+	if err != nil {
+		return fmt.Errorf("FinishDelivery: %w", err)
+	}
+
+	return nil
+}
+
+// Журнал доставок по подписке клиента, сначала новые, не более limit
+// возвращает sql.ErrNoRows (обернутую), если подписка не найдена
+func (p *WebhookRepo) DeliveryList(ctx context.Context, apiKeyID int64, webhookID int64, limit int) ([]WebhookDeliveryRow, error) {
+	ctx, span := tracing.Start(ctx, "WebhookRepo.DeliveryList")
+	defer span.End()
+
+	list := make([]WebhookDeliveryRow, 0)
+
+	var id int64
+	query := `SELECT id FROM webhook_subscriptions WHERE id = $1 AND api_key_id = $2`
+	err := p.storage.db.QueryRowContext(ctx, query, webhookID, apiKeyID).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("DeliveryList: %w", err)
+	}
+
+	query = `SELECT id, subscription_id, event_id, event_type, status, attempts, response_code,
+			      last_error, created_at, next_attempt_at, last_attempt_at
+			  FROM webhook_deliveries
+			  WHERE subscription_id = $1
+			  ORDER BY id DESC
+			  LIMIT $2`
+	rows, err := p.storage.db.QueryContext(ctx, query, webhookID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("DeliveryList error: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d WebhookDeliveryRow
+		var lastAttemptAt sql.NullTime
+		err = rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Status, &d.Attempts, &d.ResponseCode,
+			&d.LastError, &d.CreatedAt, &d.NextAttemptAt, &lastAttemptAt)
+		if err != nil {
+			return nil, fmt.Errorf("DeliveryList rows.Next: %w", err)
+		}
+		d.LastAttemptAt = lastAttemptAt.Time
+
+		list = append(list, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("DeliveryList rows.Err: %w", err)
+	}
+
+	return list, nil
+}
+
+// Отправка события получателю, возвращает код ответа
+func (p *WebhookRepo) SendWebhook(ctx context.Context, url string, headers map[string]string, body []byte) (int, error) {
+	ctx, span := tracing.Start(ctx, "WebhookRepo.SendWebhook")
+	defer span.End()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("SendWebhook: %w", err)
+	}
+	request.Header.Set("Content-Type", constants.ApplicationJSON)
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+
+	resp, err := p.client.Do(request)
+	if err != nil {
+		return 0, fmt.Errorf("SendWebhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// тело ответа не нужно, но вычитывается, чтобы соединение вернулось в пул
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	return resp.StatusCode, nil
+}
+
+// диапазон адресов операторских NAT (RFC 6598), net.IP.IsPrivate его не учитывает
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// PublicIP доступен ли адрес из интернета: вебхуки не отправляются на адреса сервиса и внутренней сети
+func PublicIP(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() && !sharedAddressSpace.Contains(ip)
+}
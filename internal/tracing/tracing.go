@@ -0,0 +1,102 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/dnsoftware/gophermart2/internal/constants"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.25.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Init настройка трассировки: экспорт по OTLP/HTTP на otlpEndpoint (например, http://localhost:4318)
+// или, если он не задан, в файл file; если не задано ни то ни другое, спаны не записываются,
+// но контекст трассировки по-прежнему передается дальше
+// возвращает функцию, которая досылает накопленные спаны и останавливает экспорт
+func Init(ctx context.Context, otlpEndpoint string, file string) (func(context.Context) error, error) {
+	// W3C Trace Context и Baggage
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var closeFile func() error
+
+	switch {
+	case otlpEndpoint != "":
+		otlp, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(otlpEndpoint))
+		if err != nil {
+			return nil, fmt.Errorf("tracing | otlptracehttp: %w", err)
+		}
+		exporter = otlp
+
+	case file != "":
+		f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("tracing | OpenFile: %w", err)
+		}
+		stdout, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("tracing | stdouttrace: %w", err)
+		}
+		exporter = stdout
+		closeFile = f.Close
+
+	default:
+		return func(context.Context) error { return nil }, nil
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(constants.TracingServiceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("tracing | resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.AlwaysSample())),
+	)
+	otel.SetTracerProvider(provider)
+
+	shutdown := func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closeFile != nil {
+			closeFile()
+		}
+
+		return err
+	}
+
+	return shutdown, nil
+}
+
+// Start начало спана name, дочернего к спану из ctx
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(constants.TracingServiceName).Start(ctx, name, opts...)
+}
+
+// Error отметка спана как завершившегося ошибкой
+func Error(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// Inject передача контекста трассировки в заголовках исходящего запроса (traceparent, tracestate)
+func Inject(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// Extract контекст трассировки из заголовков входящего запроса
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}